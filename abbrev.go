@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/snippet"
+	"github.com/bgrundmann/e/view"
+)
+
+// abbrevFile is the name of the file in the home directory defining
+// abbreviations.
+const abbrevFile = ".eabbrev"
+
+// allFiles is the pattern of the abbreviations defined for all buffers.
+const allFiles = "*"
+
+// abbreviations map patterns of file names (see filepath.Match, matched
+// against the base name) to the abbreviations used in their buffers,
+// from the trigger typed to the snippet it expands to (see package
+// snippet).
+type abbreviations map[string]map[string]string
+
+// Load adds the abbreviations read from r.  r must contain a JSON object
+// like
+//
+//	{"*": {"teh": "the"}, "*.go": {"iferr": "if err != nil {\n\treturn ${1}\n}"}}
+func (a abbreviations) Load(r io.Reader) error {
+	var config abbreviations
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return err
+	}
+	for pattern, abbrevs := range config {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+		for trigger, s := range abbrevs {
+			if err := a.define(pattern, trigger, s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// define makes trigger expand to the snippet s in the buffers of the
+// files matching pattern.
+func (a abbreviations) define(pattern, trigger, s string) error {
+	if _, err := snippet.Parse(s); err != nil {
+		return fmt.Errorf("%s: %v", trigger, err)
+	}
+	if a[pattern] == nil {
+		a[pattern] = make(map[string]string)
+	}
+	a[pattern][trigger] = s
+	return nil
+}
+
+// lookup returns the snippet trigger expands to in b.  Abbreviations
+// for specific files take precedence over those for all files.
+func (a abbreviations) lookup(b *buf.Buf, trigger string) (string, bool) {
+	var patterns []string
+	for pattern := range a {
+		if pattern != allFiles {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+	name := filepath.Base(b.Name())
+	for _, pattern := range append(patterns, allFiles) {
+		if ok, _ := filepath.Match(pattern, name); !ok || b.Name() == "" && pattern != allFiles {
+			continue
+		}
+		if s, ok := a[pattern][trigger]; ok {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// activeSnippet is a snippet inserted whose placeholders are still to be
+// visited.
+type activeSnippet struct {
+	v     *view.View
+	stops []buf.Marker // placeholders not visited yet
+}
+
+// expandAbbreviation expands the abbreviation before the cursor, if
+// any, as a key not part of words is typed.  Returns true if the key
+// is used up: a snippet with placeholders takes it and puts the cursor
+// at its first placeholder instead.
+func (ed *editor) expandAbbreviation() bool {
+	v := ed.focus
+	if len(v.Cursors()) > 0 {
+		return false
+	}
+	b := v.Buffer()
+	end := v.Cursor()
+	start := wordStart(b, end)
+	if start == end {
+		return false
+	}
+	s, ok := ed.abbrevs.lookup(b, string(b.Bytes(start, end)))
+	if !ok {
+		return false
+	}
+	sn, err := snippet.Parse(s)
+	if err != nil {
+		return false
+	}
+	if err := b.TryDelete(start, end); err != nil {
+		ed.setError(err)
+		return false
+	}
+	v.SetCursor(start)
+	ed.insert(sn.Text)
+	if len(sn.Stops) == 0 {
+		return false
+	}
+	ed.endSnippet()
+	as := &activeSnippet{v: v}
+	for _, off := range sn.Stops[1:] {
+		as.stops = append(as.stops, b.NewMarker(start+off))
+	}
+	if len(as.stops) > 0 {
+		ed.snippet = as
+	}
+	v.SetCursor(start + sn.Stops[0])
+	return true
+}
+
+// nextPlaceholder moves the cursor to the next placeholder of the
+// snippet inserted last.  Returns false if there is none.
+func (ed *editor) nextPlaceholder() bool {
+	as := ed.snippet
+	if as == nil || as.v != ed.focus {
+		return false
+	}
+	next := as.stops[0]
+	as.stops = as.stops[1:]
+	as.v.SetCursor(next.Offset())
+	next.Close()
+	if len(as.stops) == 0 {
+		ed.snippet = nil
+	}
+	return true
+}
+
+// endSnippet forgets the placeholders still to be visited.
+func (ed *editor) endSnippet() {
+	if as := ed.snippet; as != nil {
+		for _, m := range as.stops {
+			m.Close()
+		}
+		ed.snippet = nil
+	}
+}
+
+// insertTabOrPlaceholder expands the abbreviation before the cursor or
+// goes to the next placeholder of the snippet inserted, otherwise
+// inserts a tab.
+func (ed *editor) insertTabOrPlaceholder() {
+	if ed.expandAbbreviation() || ed.nextPlaceholder() {
+		return
+	}
+	ed.insertTab()
+}
+
+// :ab[breviate] {trigger} {text} makes trigger expand to text in insert
+// mode in all buffers, see package snippet for the placeholders text
+// may contain.  With only the trigger it shows its abbreviation,
+// without arguments all abbreviations.
+func (ed *editor) cmdAbbreviate(cmd ex.Command) error {
+	trigger, text, _ := strings.Cut(cmd.Arg, " ")
+	text = strings.TrimSpace(text)
+	if text != "" {
+		return ed.abbrevs.define(allFiles, trigger, text)
+	}
+	var lines []string
+	var patterns []string
+	for pattern := range ed.abbrevs {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		var triggers []string
+		for t := range ed.abbrevs[pattern] {
+			if trigger == "" || t == trigger {
+				triggers = append(triggers, t)
+			}
+		}
+		sort.Strings(triggers)
+		for _, t := range triggers {
+			lines = append(lines, fmt.Sprintf("%-8s %-10s %s", pattern, t, ed.abbrevs[pattern][t]))
+		}
+	}
+	if len(lines) == 0 {
+		return errors.New("No abbreviation found")
+	}
+	ed.setMessage(strings.Join(lines, "\n"))
+	return nil
+}
+
+// :una[bbreviate] {trigger} removes the abbreviation for all buffers.
+func (ed *editor) cmdUnabbreviate(cmd ex.Command) error {
+	if _, ok := ed.abbrevs[allFiles][cmd.Arg]; !ok {
+		return fmt.Errorf("No such abbreviation: %s", cmd.Arg)
+	}
+	delete(ed.abbrevs[allFiles], cmd.Arg)
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestAbbreviationsLookup(t *testing.T) {
+	a := make(abbreviations)
+	err := a.Load(strings.NewReader(`{
+		"*": {"teh": "the", "fn": "function"},
+		"*.go": {"fn": "func ${1}() {\n}"},
+		"Makefile": {"all": "all:"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, trigger string
+		want          string // "" for none
+	}{
+		{"x.txt", "teh", "the"},
+		{"x.txt", "fn", "function"},
+		// specific files take precedence
+		{"x.go", "fn", "func ${1}() {\n}"},
+		{"dir/x.go", "fn", "func ${1}() {\n}"},
+		{"x.go", "teh", "the"},
+		{"Makefile", "all", "all:"},
+		{"x.txt", "all", ""},
+		{"x.txt", "te", ""},
+		// unnamed buffers only get those for all files
+		{"", "fn", "function"},
+		{"", "all", ""},
+	}
+	for _, test := range tests {
+		var b buf.Buf
+		b.Init()
+		b.SetName(test.name)
+		if got, _ := a.lookup(&b, test.trigger); got != test.want {
+			t.Errorf("lookup(%q, %q) = %q expected %q", test.name, test.trigger, got, test.want)
+		}
+	}
+	for _, config := range []string{`{"[": {"a": "b"}}`, `{"*": {"a": "${x"}}`, `[]`} {
+		if err := make(abbreviations).Load(strings.NewReader(config)); err == nil {
+			t.Errorf("loaded %s", config)
+		}
+	}
+}
+
+func TestExpandAbbreviation(t *testing.T) {
+	tests := []struct {
+		abbrev, keys string
+		want         string
+	}{
+		// expanded by keys not part of words, which are inserted too
+		{"teh the", "iteh <Esc>", "the "},
+		{"teh the", "iteh.<Esc>", "the."},
+		{"teh the", "iteh<CR><Esc>", "the\n"},
+		{"teh the", "iteh<Tab><Esc>", "the\t"},
+		// but only whole words
+		{"teh the", "ixteh <Esc>", "xteh "},
+		{"teh the", "itehx <Esc>", "tehx "},
+		{"teh the", "ix.teh <Esc>", "x.the "},
+		// placeholders are visited by tab, the key expanding is used up
+		{`fn func ${1}(${2}) {\n}${0}`, "ifn<Tab>main<Tab>x<Tab>y<Esc>", "func main(x) {\n}y"},
+		{`fn f(${1})`, "ifn(x<Tab>.<Esc>", "f(x)."},
+		{`fn f(${1})`, "ifn x<Esc>", "f(x)"},
+		// leaving insert mode forgets the placeholders
+		{`fn ${1}-${2}`, "ifn<Tab>a<Esc>a<Tab><Esc>", "a\t-"},
+	}
+	for _, test := range tests {
+		ed := newTestEditor(t, "")
+		if err := ed.commands.Execute("ab " + test.abbrev); err != nil {
+			t.Fatal(err)
+		}
+		typeKeys(t, ed, test.keys)
+		if got := bufferText(ed); got != test.want {
+			t.Errorf("%s with %s gives %q expected %q", test.abbrev, test.keys, got, test.want)
+		}
+	}
+}
+
+func TestUnabbreviate(t *testing.T) {
+	ed := newTestEditor(t, "")
+	if err := ed.commands.Execute("ab teh the"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.commands.Execute("una teh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed.commands.Execute("una teh"); err == nil {
+		t.Error("removed an abbreviation twice")
+	}
+	typeKeys(t, ed, "iteh <Esc>")
+	if got := bufferText(ed); got != "teh " {
+		t.Errorf("got %q after :una", got)
+	}
+}
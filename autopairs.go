@@ -0,0 +1,79 @@
+package main
+
+import "github.com/bgrundmann/e/wordindex"
+
+// pairedRunes maps the brackets and quotes closed automatically (see
+// the option autopairs) to the runes closing them.
+var pairedRunes = map[rune]rune{'(': ')', '[': ']', '{': '}', '"': '"', '\'': '\'', '`': '`'}
+
+// closesPair returns whether r closes one of pairedRunes.
+func closesPair(r rune) bool {
+	for _, close := range pairedRunes {
+		if r == close {
+			return true
+		}
+	}
+	return false
+}
+
+// autoPairs returns whether the option autopairs is set for the buffer
+// of the focused window.
+func (ed *editor) autoPairs() bool {
+	return ed.options.Bool("autopairs", ed.bufferOptions(ed.focus.Buffer()), nil)
+}
+
+// insertPaired inserts r as typed with autopairs set: an opening
+// bracket or quote is inserted together with the rune closing it,
+// unless a word follows (or, for a quote, precedes) the cursor, and a
+// closing one is typed over if it is behind the cursor already.
+func (ed *editor) insertPaired(r rune) {
+	v := ed.focus
+	b := v.Buffer()
+	off := v.Cursor()
+	next, size, err := b.NewReader(off).ReadRune()
+	if err == nil && next == r && closesPair(r) {
+		v.SetCursor(off + size)
+		return
+	}
+	close, ok := pairedRunes[r]
+	if err == nil && wordindex.IsWordRune(next) {
+		ok = false
+	}
+	if close == r {
+		rd := b.NewReader(off)
+		rd.Reverse()
+		if prev, _, err := rd.ReadRune(); err == nil && wordindex.IsWordRune(prev) {
+			// like the apostrophe in don't
+			ok = false
+		}
+	}
+	if !ok {
+		ed.insert(string(r))
+		return
+	}
+	ed.insert(string(r) + string(close))
+	v.SetCursor(off + len(string(r)))
+}
+
+// backspacePaired deletes like backspace, but with autopairs set an
+// empty pair of brackets or quotes around the cursor as a whole.
+func (ed *editor) backspacePaired() {
+	v := ed.focus
+	b := v.Buffer()
+	off := v.Cursor()
+	if ed.autoPairs() {
+		rd := b.NewReader(off)
+		rd.Reverse()
+		prev, prevSize, err1 := rd.ReadRune()
+		next, nextSize, err2 := b.NewReader(off).ReadRune()
+		if close, ok := pairedRunes[prev]; ok && err1 == nil && err2 == nil && next == close {
+			if err := b.TryDelete(off-prevSize, off+nextSize); err != nil {
+				ed.setError(err)
+				return
+			}
+			v.SetCursor(off - prevSize)
+			return
+		}
+	}
+	ed.backspace()
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bgrundmann/e/keymap"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/textobject"
+	"github.com/bgrundmann/e/view"
+	"github.com/nsf/termbox-go"
+)
+
+// motionActions maps action names to the motions they trigger in
+// normal and visual mode and after an operator.
+var motionActions = map[string]motion.Motion{
+	"right":           motion.GraphemeForward,
+	"left":            motion.GraphemeBackward,
+	"down":            motion.LineForward,
+	"up":              motion.LineBackward,
+	"word-forward":    motion.WordForward,
+	"word-backward":   motion.WordBackward,
+	"word-end":        motion.EndOfWord,
+	"line-start":      motion.LineStart,
+	"line-end":        motion.LineEnd,
+	"first-non-blank": motion.FirstNonBlank,
+}
+
+// lineMotions are the motions going to the line given as count.
+// Without a count they go to the first or last line.
+var lineMotions = map[string]func(count int) motion.Motion{
+	"buffer-start": func(count int) motion.Motion {
+		if count > 0 {
+			return motion.GotoLine(count)
+		}
+		return motion.BufferStart
+	},
+	"buffer-end": func(count int) motion.Motion {
+		if count > 0 {
+			return motion.GotoLine(count)
+		}
+		return motion.BufferEnd
+	},
+}
+
+// findMotions are the motions searching for the rune typed after
+// their key in the current line.
+var findMotions = map[string]func(rune) motion.Motion{
+	"find-forward":  motion.FindForward,
+	"find-backward": motion.FindBackward,
+	"till-forward":  motion.TillForward,
+	"till-backward": motion.TillBackward,
+}
+
+// reversedFinds maps each find to the one searching in the other
+// direction, used by ,.
+var reversedFinds = map[string]string{
+	"find-forward":  "find-backward",
+	"find-backward": "find-forward",
+	"till-forward":  "till-backward",
+	"till-backward": "till-forward",
+}
+
+// textObjects maps action names to text objects, usable after an
+// operator and in visual mode.
+var textObjects = map[string]textobject.Object{
+	"inner-word":         textobject.InnerWord,
+	"a-word":             textobject.AWord,
+	"inner-double-quote": textobject.Quote('"', false),
+	"a-double-quote":     textobject.Quote('"', true),
+	"inner-single-quote": textobject.Quote('\'', false),
+	"a-single-quote":     textobject.Quote('\'', true),
+	"inner-paren":        textobject.Block('(', ')', false),
+	"a-paren":            textobject.Block('(', ')', true),
+	"inner-bracket":      textobject.Block('[', ']', false),
+	"a-bracket":          textobject.Block('[', ']', true),
+	"inner-brace":        textobject.Block('{', '}', false),
+	"a-brace":            textobject.Block('{', '}', true),
+	"inner-paragraph":    textobject.InnerParagraph,
+	"a-paragraph":        textobject.AParagraph,
+}
+
+// jumpActions are the motions that record the cursor position in the
+// jump list (see view.View.PushJump).
+var jumpActions = map[string]bool{
+	"buffer-start":         true,
+	"buffer-end":           true,
+	"search-next":          true,
+	"search-previous":      true,
+	"search-word-forward":  true,
+	"search-word-backward": true,
+	"goto-mark":            true,
+}
+
+// normalActions are the actions of normal mode that are neither
+// motions nor operators.
+var normalActions = map[string]func(ed *editor){
+	"escape": func(ed *editor) {
+		ed.focus.RemoveCursors()
+		ed.reset()
+	},
+	"page-down":       func(ed *editor) { ed.focus.PageDown() },
+	"page-up":         func(ed *editor) { ed.focus.PageUp() },
+	"command-line":    (*editor).startCmdline,
+	"search":          func(ed *editor) { ed.startSearch(false) },
+	"search-backward": func(ed *editor) { ed.startSearch(true) },
+	"visual":          func(ed *editor) { ed.startVisual(view.SelectChars) },
+	"visual-line":     func(ed *editor) { ed.startVisual(view.SelectLines) },
+	"visual-block":    func(ed *editor) { ed.startVisual(view.SelectBlock) },
+	"insert":          func(ed *editor) { ed.mode = modeInsert },
+	"append": func(ed *editor) {
+		ed.eachCursor(func() { ed.focus.MoveCursor(motion.GraphemeForward) })
+		ed.mode = modeInsert
+	},
+	"put-after":        func(ed *editor) { ed.put(true, ed.takeCount()) },
+	"put-before":       func(ed *editor) { ed.put(false, ed.takeCount()) },
+	"join":             func(ed *editor) { ed.join(ed.takeCount()) },
+	"toggle-case-char": func(ed *editor) { ed.toggleCaseChars(ed.takeCount()) },
+	"increment":        func(ed *editor) { ed.increment(int64(ed.takeCount())) },
+	"decrement":        func(ed *editor) { ed.increment(-int64(ed.takeCount())) },
+	"jump-back":        func(ed *editor) { ed.focus.JumpBack() },
+	"jump-forward":     func(ed *editor) { ed.focus.JumpForward() },
+	"window-left":      func(ed *editor) { ed.focusNeighbor(view.Left) },
+	"window-right":     func(ed *editor) { ed.focusNeighbor(view.Right) },
+	"window-up":        func(ed *editor) { ed.focusNeighbor(view.Up) },
+	"window-down":      func(ed *editor) { ed.focusNeighbor(view.Down) },
+	"split":            func(ed *editor) { ed.split(view.Horizontal) },
+	"vsplit":           func(ed *editor) { ed.split(view.Vertical) },
+	"close-window": func(ed *editor) {
+		if err := ed.closeWindow(false); err != nil {
+			ed.setError(err)
+		}
+	},
+	"equalize-windows": (*editor).equalize,
+	"next-tab": func(ed *editor) {
+		if ed.count > 0 {
+			// like vim {count}gt goes to tab page count
+			ed.gotoTab(ed.takeCount() - 1)
+		} else {
+			ed.nextTab(1)
+		}
+	},
+	"previous-tab": func(ed *editor) { ed.nextTab(-ed.takeCount()) },
+	"pick-buffer":  (*editor).pickBuffer,
+	"find-file":    (*editor).findFile,
+	"goto-tag": func(ed *editor) {
+		if err := ed.gotoTagUnderCursor(); err != nil {
+			ed.setError(err)
+		}
+	},
+	"goto-definition": func(ed *editor) {
+		if err := ed.gotoDefinition(); err != nil {
+			ed.setError(err)
+		}
+	},
+	"hover": func(ed *editor) {
+		if err := ed.hover(); err != nil {
+			ed.setError(err)
+		}
+	},
+	"toggle-fold":  func(ed *editor) { ed.fold((*view.View).ToggleFold) },
+	"open-fold":    func(ed *editor) { ed.fold((*view.View).OpenFold) },
+	"close-fold":   func(ed *editor) { ed.fold((*view.View).CloseFold) },
+	"fold-indent":  func(ed *editor) { ed.focus.FoldIndent() },
+	"delete-folds": func(ed *editor) { ed.focus.DeleteFolds() },
+	"add-cursor": func(ed *editor) {
+		if err := ed.addCursor(); err != nil {
+			ed.setError(err)
+		}
+	},
+	"scroll-down": func(ed *editor) { ed.focus.ScrollDown(ed.takeCount()) },
+	"scroll-up":   func(ed *editor) { ed.focus.ScrollUp(ed.takeCount()) },
+	"half-page-down": func(ed *editor) {
+		// like vim a count is the number of lines instead
+		ed.focus.HalfPageDown(ed.count)
+		ed.count = 0
+	},
+	"half-page-up": func(ed *editor) {
+		ed.focus.HalfPageUp(ed.count)
+		ed.count = 0
+	},
+	"cursor-line-top":    func(ed *editor) { ed.focus.CursorLineToTop() },
+	"cursor-line-center": func(ed *editor) { ed.focus.CursorLineToCenter() },
+	"cursor-line-bottom": func(ed *editor) { ed.focus.CursorLineToBottom() },
+}
+
+// visualActions are the actions of visual mode that are neither
+// motions nor operators.
+var visualActions = map[string]func(ed *editor){
+	"escape":       (*editor).endVisual,
+	"visual":       func(ed *editor) { ed.toggleVisual(view.SelectChars) },
+	"visual-line":  func(ed *editor) { ed.toggleVisual(view.SelectLines) },
+	"visual-block": func(ed *editor) { ed.toggleVisual(view.SelectBlock) },
+	"swap-anchor":  func(ed *editor) { ed.focus.SwapAnchor() },
+	"block-insert": func(ed *editor) { ed.insertIntoBlock(false) },
+	"block-append": func(ed *editor) { ed.insertIntoBlock(true) },
+	"command-line": (*editor).visualCmdline,
+}
+
+// insertActions are the actions of insert mode.  Keys not bound
+// insert themselves.
+var insertActions = map[string]func(ed *editor){
+	"escape":    (*editor).endInsert,
+	"newline":   (*editor).newline,
+	"tab":       (*editor).insertTabOrPlaceholder,
+	"backspace": (*editor).backspacePaired,
+	"delete":    (*editor).deleteForward,
+	"delete-word-backward": func(ed *editor) {
+		ed.deleteBackTo(motion.WordBackward)
+	},
+	"delete-line-backward": func(ed *editor) {
+		ed.deleteBackTo(motion.FirstNonBlank)
+	},
+}
+
+// motionBindings are bound in normal and visual mode.
+var motionBindings = map[string]string{
+	"l":       "right",
+	"h":       "left",
+	"j":       "down",
+	"k":       "up",
+	"w":       "word-forward",
+	"b":       "word-backward",
+	"e":       "word-end",
+	"<Right>": "right",
+	"<Left>":  "left",
+	"<Down>":  "down",
+	"<Up>":    "up",
+	"0":       "line-start",
+	"$":       "line-end",
+	"^":       "first-non-blank",
+	"<Home>":  "line-start",
+	"<End>":   "line-end",
+	"f":       "find-forward",
+	"F":       "find-backward",
+	"t":       "till-forward",
+	"T":       "till-backward",
+	";":       "repeat-find",
+	",":       "repeat-find-reverse",
+	"n":       "search-next",
+	"N":       "search-previous",
+	"*":       "search-word-forward",
+	"#":       "search-word-backward",
+	"gj":      "row-down",
+	"gk":      "row-up",
+	"`":       "goto-mark",
+	"'":       "goto-mark-line",
+	"gg":      "buffer-start",
+	"G":       "buffer-end",
+}
+
+// textObjectBindings are bound after an operator and in visual mode.
+var textObjectBindings = map[string]string{
+	"iw": "inner-word",
+	"aw": "a-word",
+	`i"`: "inner-double-quote",
+	`a"`: "a-double-quote",
+	"i'": "inner-single-quote",
+	"a'": "a-single-quote",
+	"i(": "inner-paren",
+	"i)": "inner-paren",
+	"ib": "inner-paren",
+	"a(": "a-paren",
+	"a)": "a-paren",
+	"ab": "a-paren",
+	"i[": "inner-bracket",
+	"i]": "inner-bracket",
+	"a[": "a-bracket",
+	"a]": "a-bracket",
+	"i{": "inner-brace",
+	"i}": "inner-brace",
+	"iB": "inner-brace",
+	"a{": "a-brace",
+	"a}": "a-brace",
+	"aB": "a-brace",
+	"ip": "inner-paragraph",
+	"ap": "a-paragraph",
+}
+
+// operatorBindings are bound in normal and visual mode, and after an
+// operator, where repeating it applies it to whole lines (dd, yy, ...).
+var operatorBindings = map[string]string{
+	"d":  "delete",
+	"c":  "change",
+	"y":  "yank",
+	">":  "shift-right",
+	"<":  "shift-left",
+	"!":  "filter",
+	"zf": "fold",
+	"g~": "toggle-case",
+	"gu": "lowercase",
+	"gU": "uppercase",
+	"gc": "comment",
+}
+
+var defaultBindings = map[string]map[string]string{
+	"normal": {
+		"<Esc>":      "escape",
+		"<PageDown>": "page-down",
+		"<PageUp>":   "page-up",
+		"<C-e>":      "scroll-down",
+		"<C-y>":      "scroll-up",
+		"<C-d>":      "half-page-down",
+		"<C-u>":      "half-page-up",
+		"zt":         "cursor-line-top",
+		"zz":         "cursor-line-center",
+		"zb":         "cursor-line-bottom",
+		":":          "command-line",
+		"/":          "search",
+		"?":          "search-backward",
+		"v":          "visual",
+		"V":          "visual-line",
+		"<C-v>":      "visual-block",
+		"i":          "insert",
+		"a":          "append",
+		"p":          "put-after",
+		"P":          "put-before",
+		"J":          "join",
+		"~":          "toggle-case-char",
+		"<C-a>":      "increment",
+		"<C-x>":      "decrement",
+		"m":          "set-mark",
+		"<C-o>":      "jump-back",
+		"<Tab>":      "jump-forward", // same as <C-i> on terminals
+		"<C-w>h":     "window-left",
+		"<C-w>l":     "window-right",
+		"<C-w>k":     "window-up",
+		"<C-w>j":     "window-down",
+		"<C-w>s":     "split",
+		"<C-w>v":     "vsplit",
+		"<C-w>c":     "close-window",
+		"<C-w>=":     "equalize-windows",
+		"gt":         "next-tab",
+		"gT":         "previous-tab",
+		"gb":         "pick-buffer",
+		"gd":         "goto-definition",
+		"K":          "hover",
+		"<C-n>":      "add-cursor",
+		"<C-p>":      "find-file",
+		"<C-]>":      "goto-tag",
+		"za":         "toggle-fold",
+		"zo":         "open-fold",
+		"zc":         "close-fold",
+		"zx":         "fold-indent",
+		"zE":         "delete-folds",
+	},
+	"visual": {
+		"<Esc>": "escape",
+		"v":     "visual",
+		"V":     "visual-line",
+		"<C-v>": "visual-block",
+		"o":     "swap-anchor",
+		"x":     "delete",
+		"I":     "block-insert",
+		"A":     "block-append",
+		":":     "command-line",
+		"~":     "toggle-case",
+		"u":     "lowercase",
+		"U":     "uppercase",
+	},
+	"operator": {
+		"<Esc>": "escape",
+		// the second key of g~~, guu and gUU
+		"~": "toggle-case",
+		"u": "lowercase",
+		"U": "uppercase",
+		// the s of ys, yss, ds and cs
+		"s": "surround",
+		// d/pat<CR> and the like
+		"/": "search",
+		"?": "search-backward",
+	},
+	"directory": {
+		"<CR>": "open-entry",
+		"-":    "parent-directory",
+	},
+	"quickfix": {
+		"<CR>": "goto-item",
+	},
+	"insert": {
+		"<Esc>": "escape",
+		"<CR>":  "newline",
+		"<Tab>": "tab",
+		"<BS>":  "backspace",
+		"<Del>": "delete",
+		"<C-w>": "delete-word-backward",
+		"<C-u>": "delete-line-backward",
+		"<C-n>": "complete-next",
+		"<C-p>": "complete-previous",
+		"<C-e>": "complete-cancel",
+	},
+}
+
+// defaultKeymaps returns the keymaps before any configuration is loaded.
+func defaultKeymaps() keymap.Keymaps {
+	km := keymap.Keymaps{}
+	bind := func(mode string, bindings map[string]string) {
+		for keys, action := range bindings {
+			if err := km.Bind(mode, keys, action); err != nil {
+				panic(err)
+			}
+		}
+	}
+	for mode, bindings := range defaultBindings {
+		bind(mode, bindings)
+	}
+	for _, mode := range []string{"normal", "visual", "operator"} {
+		bind(mode, operatorBindings)
+	}
+	for _, mode := range []string{"normal", "visual", "operator"} {
+		bind(mode, motionBindings)
+	}
+	for _, mode := range []string{"visual", "operator"} {
+		bind(mode, textObjectBindings)
+	}
+	return km
+}
+
+// configFile is the name of the file in the home directory holding
+// the key bindings of the user (see keymap.Keymaps.Load).
+const configFile = ".erc"
+
+// themeFile is the name of the file in the home directory holding the
+// colors of the user (see theme.Theme.Load).
+const themeFile = ".etheme"
+
+// loadKeymaps returns the default keymaps with the bindings of the
+// user's config file added, if there is one.
+func loadKeymaps() (keymap.Keymaps, error) {
+	km := defaultKeymaps()
+	return km, loadConfig(configFile, km.Load)
+}
+
+// loadConfig passes the file name in the home directory to load, if it
+// exists.
+func loadConfig(name string, load func(r io.Reader) error) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(home, name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := load(f); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// specialKeys are the names of keys not producing a character.
+var specialKeys = map[termbox.Key]string{
+	termbox.KeyEsc:        "<Esc>",
+	termbox.KeyEnter:      "<CR>",
+	termbox.KeyTab:        "<Tab>",
+	termbox.KeySpace:      "<Space>",
+	termbox.KeyBackspace:  "<BS>",
+	termbox.KeyBackspace2: "<BS>",
+	termbox.KeyDelete:     "<Del>",
+	termbox.KeyInsert:     "<Insert>",
+	termbox.KeyArrowUp:    "<Up>",
+	termbox.KeyArrowDown:  "<Down>",
+	termbox.KeyArrowLeft:  "<Left>",
+	termbox.KeyArrowRight: "<Right>",
+	termbox.KeyHome:       "<Home>",
+	termbox.KeyEnd:        "<End>",
+	termbox.KeyPgup:       "<PageUp>",
+	termbox.KeyPgdn:       "<PageDown>",
+}
+
+// keyName returns the name of the key pressed in ev as used by keymap,
+// including the modifiers reported, or "" for keys without a name.
+func keyName(ev termbox.Event) string {
+	var name string
+	if ev.Ch != 0 {
+		name = string(ev.Ch)
+	} else if special, ok := specialKeys[ev.Key]; ok {
+		name = special
+	} else if termbox.KeyCtrlA <= ev.Key && ev.Key <= termbox.KeyCtrlZ {
+		name = keymap.Ctrl(rune('a' + ev.Key - termbox.KeyCtrlA))
+	} else if ev.Key == termbox.KeyCtrlRsqBracket {
+		name = keymap.Ctrl(']')
+	} else {
+		return ""
+	}
+	if ev.Mod&(termbox.ModAlt|screen.ModCtrl|screen.ModShift) == 0 {
+		return name
+	}
+	return keymap.Modified(name, ev.Mod&screen.ModCtrl != 0, ev.Mod&termbox.ModAlt != 0, ev.Mod&screen.ModShift != 0)
+}
+
+// motion returns the motion bound to action, if any, repeated as often
+// as the count typed so far (times the count of a pending operator) says.
+// The count is used up.
+func (ed *editor) motion(action string) (motion.Motion, bool) {
+	var m motion.Motion
+	lineMotion, isLine := lineMotions[action]
+	switch {
+	case isLine:
+	case action == "row-down" || action == "row-up":
+		m = ed.focus.RowMotion(action == "row-up")
+	case action == "search-next" || action == "search-previous":
+		var ok bool
+		if m, ok = ed.searchMotion(action == "search-previous"); !ok {
+			return nil, false
+		}
+	case action == "search-word-forward" || action == "search-word-backward":
+		var ok bool
+		if m, ok = ed.wordSearchMotion(action == "search-word-backward"); !ok {
+			return nil, false
+		}
+	case action == "repeat-find" || action == "repeat-find-reverse":
+		if ed.lastFind.action == "" {
+			return nil, false
+		}
+		find := ed.lastFind.action
+		if action == "repeat-find-reverse" {
+			find = reversedFinds[find]
+		}
+		m = findMotions[find](ed.lastFind.r)
+	default:
+		var ok bool
+		if m, ok = motionActions[action]; !ok {
+			if m, ok = ed.pluginMotion(action); !ok {
+				return nil, false
+			}
+		}
+	}
+	n := ed.count
+	ed.count = 0
+	if ed.pending != nil && ed.opCount > 1 {
+		if n == 0 {
+			n = 1
+		}
+		n *= ed.opCount
+	}
+	if isLine {
+		return lineMotion(n), true
+	}
+	if ed.pending != nil && ed.opName == "change" && action == "word-forward" {
+		// like vim cw changes to the end of the word
+		m = motion.EndOfWord
+	}
+	if n > 1 {
+		m = motion.Repeat(m, n)
+	}
+	return m, true
+}
+
+// findState is the find (f, t, ...) last done, repeated by ; and ,.
+type findState struct {
+	action string // one of findMotions or "" if none
+	r      rune
+}
+
+// startFind makes the next key the rune to search for with the
+// find action, if action is one.
+func (ed *editor) startFind(action string) bool {
+	if _, ok := findMotions[action]; !ok {
+		return false
+	}
+	ed.findAction = action
+	return true
+}
+
+// findKey handles the key following f, F, t or T.  Returns the motion
+// searching for its rune, or false if the key isn't one.
+func (ed *editor) findKey(ev termbox.Event) (motion.Motion, bool) {
+	action := ed.findAction
+	ed.findAction = ""
+	r := ev.Ch
+	if ev.Key == termbox.KeySpace {
+		r = ' '
+	}
+	if r == 0 {
+		return nil, false
+	}
+	ed.lastFind = findState{action, r}
+	return ed.motion("repeat-find")
+}
+
+// lookupKey adds the key of ev to the keys typed so far and looks them
+// up in the keymap of mode, in normal mode after the keymap of the kind
+// of a special buffer.  Returns false if more keys are needed or the
+// keys are not bound.  In the latter case the keys are forgotten.
+func (ed *editor) lookupKey(mode string, ev termbox.Event) (string, bool) {
+	name := keyName(ev)
+	if name == "" {
+		ed.keys = ed.keys[:0]
+		return "", false
+	}
+	ed.keys = append(ed.keys, name)
+	action, prefix := ed.keymaps.Lookup(mode, ed.keys)
+	if kind := ed.kind(ed.focus.Buffer()); kind != nil && mode == "normal" {
+		if a, p := ed.keymaps.Lookup(kind.mode, ed.keys); a != "" || p {
+			action, prefix = a, p || prefix
+		}
+	}
+	if !prefix {
+		ed.keys = ed.keys[:0]
+	}
+	return action, action != ""
+}
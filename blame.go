@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/vcs"
+	"github.com/bgrundmann/e/view"
+)
+
+// blameKind is the kind of the buffers showing who last changed the
+// lines of a file, see :Gblame.
+var blameKind = &bufKind{mode: "blame"}
+
+// :Gblame shows for each line of the buffer the commit that last
+// changed it in a window split off beside it.  The two windows scroll
+// together and the cursor stays on the same line in both.
+func (ed *editor) cmdGblame(cmd ex.Command) error {
+	if cmd.Arg != "" {
+		return fmt.Errorf("Trailing characters: %s", cmd.Arg)
+	}
+	v := ed.focus
+	b := v.Buffer()
+	if b.Name() == "" || ed.kind(b) != nil {
+		return errors.New("No file name")
+	}
+	lines, err := vcs.Blame(b.Name(), b.Bytes(0, b.Len()))
+	if err != nil {
+		return err
+	}
+	bb := new(buf.Buf).Init()
+	bb.SetName("[Blame] " + b.Name())
+	bb.Insert(0, []byte(blameText(lines)))
+	bb.MarkSaved()
+	ed.addBuffer(bb)
+	ed.bufEntry(bb).kind = blameKind
+	ed.split(view.Vertical)
+	bv := ed.focus
+	ed.showBuffer(bv, bb)
+	d, _ := ed.options.Lookup("wrap")
+	ed.options.Set(d, ed.windowOptions(bv), false)
+	ed.blames[bv] = v
+	ed.syncBlame()
+	return nil
+}
+
+// blameText returns the lines of a blame buffer: the abbreviated hash,
+// author, date and summary of the commit of each line.
+func blameText(lines []vcs.BlameLine) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		if !l.Committed() {
+			// git's summary of the line not committed yet is noise
+			l.Summary = ""
+		}
+		fmt.Fprintf(&sb, "%.7s %-12.12s %s %s\n", l.Commit, l.Author, l.Time.Format("2006-01-02"), l.Summary)
+	}
+	return sb.String()
+}
+
+// syncBlame keeps the windows showing blame in step with the windows
+// of their files: the focused one of the two sets the line of the
+// cursor and the first line shown of the other.  Blame windows closed,
+// or whose file window was, are forgotten.
+func (ed *editor) syncBlame() {
+	for bv, v := range ed.blames {
+		if ed.layout.Leaf(bv) == nil || ed.layout.Leaf(v) == nil || ed.kind(bv.Buffer()) != blameKind {
+			delete(ed.blames, bv)
+			continue
+		}
+		from, to := v, bv
+		if ed.focus == bv {
+			from, to = bv, v
+		}
+		fb, tb := from.Buffer(), to.Buffer()
+		n := fb.LineOfOffset(from.Cursor())
+		if n > tb.Lines() {
+			n = tb.Lines()
+		}
+		if tb.LineOfOffset(to.Cursor()) != n {
+			to.SetCursor(tb.Line(n))
+		}
+		to.SetFirstLine(from.FirstLine())
+	}
+}
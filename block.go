@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/view"
+)
+
+// blockInsert is text being inserted into the lines of a block: it is
+// typed into the first line and repeated in the others when leaving
+// insert mode.
+type blockInsert struct {
+	v           *view.View
+	first, last int  // lines of the block
+	col         int  // column the text is inserted at
+	pad         bool // pad lines not reaching col with blanks, or skip them
+	start       int  // offset the text starts at in the first line
+}
+
+// blockOperators are the operators acting on the text in a block
+// selection rather than on its lines.
+var blockOperators = map[string]func(ed *editor){
+	"delete":      func(ed *editor) { ed.deleteBlock(false) },
+	"change":      func(ed *editor) { ed.deleteBlock(true) },
+	"yank":        (*editor).yankBlock,
+	"toggle-case": func(ed *editor) { ed.changeBlockCase(toggleCase) },
+	"lowercase":   func(ed *editor) { ed.changeBlockCase(unicode.ToLower) },
+	"uppercase":   func(ed *editor) { ed.changeBlockCase(unicode.ToUpper) },
+}
+
+// yankBlock copies the text of the block selection into the selected
+// register, one line of the block per line, and leaves visual mode.
+func (ed *editor) yankBlock() {
+	v := ed.focus
+	ranges := v.BlockRanges()
+	ed.endVisual()
+	if len(ranges) == 0 {
+		return
+	}
+	lines := make([][]byte, len(ranges))
+	for i, r := range ranges {
+		lines[i] = v.Buffer().Bytes(r.Off1, r.Off2)
+	}
+	ed.registers.Set(ed.selectedRegister(), bytes.Join(lines, newline))
+	v.SetCursor(ranges[0].Off1)
+}
+
+// deleteBlock deletes the text of the block selection after yanking
+// it.  If change is set, text is then inserted into the lines of the
+// block (vim's c in visual block mode).
+func (ed *editor) deleteBlock(change bool) {
+	v := ed.focus
+	first, last, left, _, _ := v.Block()
+	ranges := v.BlockRanges()
+	ed.yankBlock()
+	rs := make([]buf.Replacement, len(ranges))
+	for i, r := range ranges {
+		rs[i] = buf.Replacement{Off1: r.Off1, Off2: r.Off2}
+	}
+	if err := v.Buffer().ReplaceAll(rs); err != nil {
+		ed.setError(err)
+		return
+	}
+	if change {
+		ed.startBlockInsert(first, last, left, false)
+	}
+}
+
+// insertIntoBlock starts inserting text in front of the block selection,
+// or behind it if after is set (vim's I and A in visual block mode).
+func (ed *editor) insertIntoBlock(after bool) {
+	first, last, left, right, ok := ed.focus.Block()
+	if !ok {
+		return
+	}
+	if err := ed.modifiable(); err != nil {
+		ed.setError(err)
+		ed.endVisual()
+		return
+	}
+	ed.endVisual()
+	if after {
+		ed.startBlockInsert(first, last, right+1, true)
+	} else {
+		ed.startBlockInsert(first, last, left, false)
+	}
+}
+
+// startBlockInsert switches to insert mode at column col of line first,
+// to repeat the text inserted in the lines up to last when leaving
+// insert mode.
+func (ed *editor) startBlockInsert(first, last, col int, pad bool) {
+	v := ed.focus
+	off, _, ok := v.BlockLine(first, col, col)
+	if !ok && pad {
+		blanks := []byte(strings.Repeat(" ", col-v.Column(off)))
+		if err := v.Buffer().TryInsert(off, blanks); err != nil {
+			ed.setError(err)
+			return
+		}
+		off += len(blanks)
+	}
+	v.SetCursor(off)
+	ed.block = &blockInsert{v: v, first: first, last: last, col: col, pad: pad, start: off}
+	ed.mode = modeInsert
+}
+
+// finishBlockInsert repeats the text inserted into the first line of a
+// block in its other lines.  Text spanning several lines isn't repeated.
+func (ed *editor) finishBlockInsert() {
+	bi := ed.block
+	ed.block = nil
+	v := ed.focus
+	if bi == nil || bi.v != v || v.Cursor() <= bi.start {
+		return
+	}
+	b := v.Buffer()
+	text := b.Bytes(bi.start, v.Cursor())
+	if bytes.IndexByte(text, '\n') >= 0 {
+		return
+	}
+	var rs []buf.Replacement
+	for n := bi.first + 1; n <= bi.last && n <= b.Lines(); n++ {
+		off, _, ok := v.BlockLine(n, bi.col, bi.col)
+		if !ok && !bi.pad {
+			continue
+		}
+		line := text
+		if !ok {
+			blanks := strings.Repeat(" ", bi.col-v.Column(off))
+			line = append([]byte(blanks), text...)
+		}
+		rs = append(rs, buf.Replacement{Off1: off, Off2: off, Text: line})
+	}
+	if err := b.ReplaceAll(rs); err != nil {
+		ed.setError(err)
+	}
+}
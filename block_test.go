@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bgrundmann/e/register"
+)
+
+func TestBlockOperators(t *testing.T) {
+	tests := []struct {
+		keysTest
+		yanked string // "" if not checked
+	}{
+		{keysTest{"abcd\nefgh\n", "l<C-v>jly", "abcd\nefgh\n", 1}, "bc\nfg"},
+		{keysTest{"abcd\nefgh\n", "l<C-v>jld", "ad\neh\n", 1}, "bc\nfg"},
+		// short lines contribute what they have
+		{keysTest{"abcd\ne\nfghi\n", "l<C-v>2jld", "ad\ne\nfi\n", 1}, "bc\n\ngh"},
+		// c inserts into each line, skipping those too short
+		{keysTest{"abcd\ne\nfghi\n", "l<C-v>2jlcX<Esc>", "aXd\neX\nfXi\n", 1}, ""},
+		{keysTest{"abcd\n\nfghi\n", "l<C-v>2jlcX<Esc>", "aXd\n\nfXi\n", 1}, ""},
+		// I inserts in front of the block, skipping short lines
+		{keysTest{"abcd\ne\nfghi\n", "l<C-v>2jI> <Esc>", "a> bcd\ne> \nf> ghi\n", 2}, ""},
+		{keysTest{"abcd\n\nfghi\n", "l<C-v>2jI> <Esc>", "a> bcd\n\nf> ghi\n", 2}, ""},
+		// A appends behind it, padding short lines
+		{keysTest{"abcd\ne\nfghi\n", "l<C-v>2jlA|<Esc>", "abc|d\ne  |\nfgh|i\n", 3}, ""},
+		{keysTest{"ab\ncd\n", "<C-v>j$A;<Esc>", "ab;\ncd;\n", 2}, ""},
+		// text spanning several lines isn't repeated
+		{keysTest{"ab\ncd\n", "<C-v>jIx<CR>y<Esc>", "x\nyab\ncd\n", 2}, ""},
+		// leaving insert mode without typing changes nothing else
+		{keysTest{"ab\ncd\n", "<C-v>jI<Esc>", "ab\ncd\n", 0}, ""},
+	}
+	for _, test := range tests {
+		ed := test.run(t)
+		if test.yanked == "" {
+			continue
+		}
+		if got, _ := ed.registers.Get(register.Unnamed); string(got) != test.yanked {
+			t.Errorf("%q with %s yanks %q expected %q", test.text, test.keys, got, test.yanked)
+		}
+	}
+}
@@ -12,14 +12,16 @@ import (
 )
 
 type piece struct {
-	off1 int
-	off2 int
+	text []byte // part of the store (or the original file)
+	nl   int    // number of newlines in the piece
 	prev *piece
 	next *piece
+	skip []link // skip[l-1] is the link on level l, see skiplist.go
+	orig bool   // text is part of the text loaded from the file
 }
 
 func (p *piece) len() int {
-	return p.off2 - p.off1
+	return len(p.text)
 }
 
 func (p *piece) link(p2 *piece) {
@@ -27,29 +29,60 @@ func (p *piece) link(p2 *piece) {
 	p2.prev = p
 }
 
+// newPiece returns a piece holding text.
+func newPiece(text []byte) *piece {
+	return &piece{
+		text: text,
+		nl:   bytes.Count(text, newline),
+	}
+}
+
 // split piece into two pieces such that the first piece is n characters long
-func (p *piece) split(n int) (*piece, *piece) {
-	off2 := p.off1 + n
-	return &piece{off1: p.off1, off2: off2}, &piece{off1: off2, off2: p.off2}
+func (b *Buf) split(p *piece, n int) (*piece, *piece) {
+	p1 := newPiece(p.text[:n])
+	p1.orig = p.orig
+	return p1, &piece{text: p.text[n:], nl: p.nl - p1.nl, orig: p.orig}
+}
+
+var newline = []byte{'\n'}
+
+// A Change describes an edit of a buffer: Deleted bytes starting at Off
+// are replaced by Inserted.  Buf.Insert and Buf.Delete make changes
+// that either insert or delete.
+type Change struct {
+	Off      int
+	Deleted  int
+	Inserted []byte
 }
 
 // BufferObserver is the interface that get's notified when a Buffer changes
-// Both functions are called before the change has happened
+// OnBufChange is called before the change has happened
 type BufferObserver interface {
-	OnBufDelete(off1, off2 int)
-	OnBufInsert(off int, bytes []byte)
+	OnBufChange(c Change)
 }
 
 // A text editors buffer.
 // It implements Writer.  Any writes done that way are appended at the end of the buffer.
 type Buf struct {
-	bytes              bytes.Buffer
+	store              store
 	sentinel           piece
 	len                int
 	nextFreeObserverId int
 	observers          map[int]BufferObserver
 	lineCache          OneLineCache // position of most recently asked for line
-	lines              int // number of lines in buffer or 0 if unknown
+	posCache           positionCache // most recently translated offset
+	newlines           int          // number of newlines in buffer
+	name               string       // usually the name of the file
+	lineEnding         LineEnding   // used when writing the buffer to a file
+	encoding           Encoding     // used when writing the buffer to a file
+	seq                int          // number of changes so far
+	savedSeq           int          // seq when the buffer was last saved
+	markers            markers
+	validating         bool         // see SetValidating
+	log                []Edit       // log[i] is the edit with Seq i+1
+	original           int          // length of the text loaded from a file
+	logStart           int          // Seq before the first edit in log, see Vacuum
+	autoVacuum         int          // see SetAutoVacuum
 }
 
 type OneLineCache struct {
@@ -57,100 +90,207 @@ type OneLineCache struct {
 	off int   // offset of the line
 } 
 
+// The caches are kept across edits that don't change the text before
+// the offsets they hold, as only that text decides which line starts
+// where and the position of an offset.  A line moves along with text
+// inserted or deleted in the lines before it.
+
+// insert updates the cache for the insertion of n bytes holding nl
+// newlines at off.
+func (c *OneLineCache) insert(off, n, nl int) {
+	if c.line != 0 && off < c.off {
+		c.line += nl
+		c.off += n
+	}
+}
+
+// delete updates the cache for the deletion of the bytes between off1
+// and off2, holding nl newlines.  Deleting the line break before the
+// line joins it to the previous one.
+func (c *OneLineCache) delete(off1, off2, nl int) {
+	switch {
+	case c.line == 0 || off1 >= c.off:
+	case off2 < c.off:
+		c.line -= nl
+		c.off -= off2 - off1
+	default:
+		c.line = 0
+	}
+}
+
+// positionCache remembers the position of an offset, so that translating
+// offsets near it (e.g. of a cursor moving in a line) doesn't need to
+// scan its line from the start.
+type positionCache struct {
+	off int
+	pos Position // if Line is zero the cache is invalid
+}
+
 // Init initializes a buffer and returns it.
 func (b *Buf) Init() *Buf {
 	b.sentinel.next = &b.sentinel
 	b.sentinel.prev = &b.sentinel
+	b.initSkipList()
 	b.observers = make(map[int]BufferObserver)
 	return b
 }
 
+// Name returns the name of the buffer.  For buffers holding a file
+// this is the file name.
+func (b *Buf) Name() string {
+	return b.name
+}
+
+// SetName sets the name of the buffer.
+func (b *Buf) SetName(name string) {
+	b.name = name
+}
+
+// Seq returns the number of changes made to the buffer so far.  It
+// only ever grows, so comparing it with an earlier value tells whether
+// the buffer changed in between.
+func (b *Buf) Seq() int {
+	return b.seq
+}
+
+// Dirty returns true if the buffer was changed since it was last
+// marked as saved (or since it was loaded).
+func (b *Buf) Dirty() bool {
+	return b.seq != b.savedSeq
+}
+
+// MarkSaved marks the current contents of the buffer as saved.
+func (b *Buf) MarkSaved() {
+	b.savedSeq = b.seq
+}
+
 // Len returns the length of the buffer in bytes.
 func (b *Buf) Len() int {
 	return b.len
 }
 
 // Delete the bytes between off1 (inclusive) and off2 (exclusive) in a Buf.
+// Invalid offsets are a bug of the caller and panic, see TryDelete.
 func (b *Buf) Delete(off1, off2 int) {
+	if err := b.TryDelete(off1, off2); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryDelete is like Delete but returns an error instead of panicking if
+// the offsets are invalid, for edits asked for by the user.
+func (b *Buf) TryDelete(off1, off2 int) error {
 	if off1 > off2 || off1 < 0 || off2 > b.len {
-		panic(fmt.Sprintf("Delete: Invalid offsets given %v-%v valid:0-%v", off1, off2, b.len))
+		return fmt.Errorf("Delete: Invalid offsets given %v-%v valid:0-%v", off1, off2, b.len)
 	}
 	if off1 == off2 {
 		// deleting the empty string => noop
-		return
+		return nil
 	}
-	b.lineCache.line = 0
-	b.lines = 0
+	if _, err := b.validate(off1, off2, nil); err != nil {
+		return err
+	}
+	b.seq++
+	b.record(Deletion, off1, b.Bytes(off1, off2))
+	// the observers see the buffer as it is before the change
 	for _, ob := range b.observers {
-		ob.OnBufDelete(off1, off2)
+		ob.OnBufChange(Change{Off: off1, Deleted: off2 - off1})
+	}
+	nl := b.countNewlines(off1, off2)
+	b.lineCache.delete(off1, off2, nl)
+	if off1 < b.posCache.off {
+		b.posCache.pos.Line = 0
 	}
+	b.newlines -= nl
+	b.markers.delete(off1, off2)
 
 	o1, p1 := b.findPiece(off1)
 	o2, p2 := b.findPiece(off2)
 
-	var left *piece
-	if off1 == o1 {
-		// we are deleting all of p1
-		left = p1.prev
-	} else {
-		len1 := off1 - o1
-		prev := p1.prev
-		left, _ = p1.split(len1)
-		prev.link(left)
+	// the parts of p1 and p2 that are kept
+	var left, right *piece
+	if off1 > o1 {
+		left, _ = b.split(p1, off1-o1)
 	}
-
-	var right *piece
-	if off2 == o2 {
-		// we at the beginning of p2 and therefore won't delete
-		// anything of it
-		right = p2
-	} else {
-		len2 := off2 - o2
-		next := p2.next
-		_, right = p2.split(len2)
-		right.link(next)
+	stop := p2
+	if off2 > o2 {
+		_, right = b.split(p2, off2-o2)
+		stop = p2.next
+	}
+	// the pieces following a removed piece move to o1
+	for p := p1; p != stop; {
+		next := p.next
+		b.removePiece(p, o1)
+		p = next
+	}
+	if right != nil {
+		b.insertPiece(right, o1)
+	}
+	if left != nil {
+		b.insertPiece(left, o1)
 	}
-	left.link(right)
 	b.len -= off2 - off1
+	if b.autoVacuum > 0 && b.store.size-b.len > b.autoVacuum {
+		b.Vacuum()
+	}
+	return nil
 }
 
-// Insert the bytes starting at off into a buf.
+// Insert the bytes starting at off into a buf.  An invalid offset is a
+// bug of the caller and panics, see TryInsert.
 func (b *Buf) Insert(off int, s []byte) {
+	if err := b.TryInsert(off, s); err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryInsert is like Insert but returns an error instead of panicking if
+// the offset is invalid, for edits asked for by the user.
+func (b *Buf) TryInsert(off int, s []byte) error {
 	if off < 0 || off > b.len {
-		panic(fmt.Sprintf("Insert: invalid offset %v valid:0-%v", off, b.len))
+		return fmt.Errorf("Insert: invalid offset %v valid:0-%v", off, b.len)
 	}
 	if len(s) == 0 {
 		// inserting the empty string => noop
-		return
+		return nil
 	}
-	b.lineCache.line = 0
-	b.lines = 0
-	for _, ob := range b.observers {
-		ob.OnBufInsert(off, s)
+	s, err := b.validate(off, off, s)
+	if err != nil {
+		return err
 	}
+	b.insertStored(off, b.store.append(s))
+	return nil
+}
 
-	off1 := b.bytes.Len()
-	n, err := b.bytes.Write(s)
-	if err != nil {
-		panic("bytes.Write returned an error but doc says it never does so")
+// insertStored inserts text, which is in the store already, at off as
+// a new piece.
+func (b *Buf) insertStored(off int, text []byte) {
+	b.seq++
+	// the observers see the buffer as it is before the change
+	for _, ob := range b.observers {
+		ob.OnBufChange(Change{Off: off, Inserted: text})
 	}
-	np := &piece{
-		off1: off1,
-		off2: off1 + n,
+	np := newPiece(text)
+	b.lineCache.insert(off, np.len(), np.nl)
+	if off < b.posCache.off {
+		b.posCache.pos.Line = 0
 	}
+	b.markers.insert(off, len(text))
+
+	b.record(Insertion, off, np.text)
+	n := np.len()
+	b.newlines += np.nl
 	o, p := b.findPiece(off)
-	left := p.prev
 	if off == o {
 		// insert at beginning of piece
-		np.link(p)
-		left.link(np)
+		b.insertPiece(np, off)
 	} else {
 		// split piece and insert in middle
-		len1 := off - o
-		p1, p2 := p.split(len1)
-		p1.link(np)
-		np.link(p2)
-		left.link(p1)
+		p1, p2 := b.split(p, off-o)
+		b.removePiece(p, o)
+		b.insertPiece(p2, o)
+		b.insertPiece(np, o)
+		b.insertPiece(p1, o)
 	}
 	b.len += n
 }
@@ -161,20 +301,8 @@ func (b *Buf) eachpiece(f func(p *piece)) {
 	}
 }
 
-// findPiece finds the piece with off1 >= off
-func (b *Buf) findPiece(off int) (pieceStart int, piece *piece) {
-	pieceStart = 0
-	for piece = b.sentinel.next; piece != &b.sentinel; piece = piece.next {
-		if pieceStart <= off && off < pieceStart+piece.len() {
-			return
-		}
-		pieceStart += piece.len()
-	}
-	return
-}
-
 func (b *Buf) sliceOfPiece(p *piece) []byte {
-	return b.bytes.Bytes()[p.off1:p.off2]
+	return p.text
 }
 
 func (b *Buf) String() string {
@@ -190,6 +318,51 @@ func (b *Buf) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// Bytes returns a copy of the bytes between off1 (inclusive) and
+// off2 (exclusive).
+func (b *Buf) Bytes(off1, off2 int) []byte {
+	if off1 > off2 || off1 < 0 || off2 > b.len {
+		panic(fmt.Sprintf("Bytes: Invalid offsets given %v-%v valid:0-%v", off1, off2, b.len))
+	}
+	res := make([]byte, 0, off2-off1)
+	o, p := b.findPiece(off1)
+	for ; p != &b.sentinel && o < off2; p = p.next {
+		start, end := off1-o, off2-o
+		if start < 0 {
+			start = 0
+		}
+		if end > p.len() {
+			end = p.len()
+		}
+		res = append(res, b.sliceOfPiece(p)[start:end]...)
+		o += p.len()
+	}
+	return res
+}
+
+// Slice returns a reader reading the bytes between off1 (inclusive)
+// and off2 (exclusive) without copying them first.
+func (b *Buf) Slice(off1, off2 int) io.Reader {
+	if off1 > off2 || off1 < 0 || off2 > b.len {
+		panic(fmt.Sprintf("Slice: Invalid offsets given %v-%v valid:0-%v", off1, off2, b.len))
+	}
+	return io.LimitReader(b.NewReader(off1), int64(off2-off1))
+}
+
+// WriteTo writes the contents of the buffer to w.
+// It implements io.WriterTo.
+func (b *Buf) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for p := b.sentinel.next; p != &b.sentinel; p = p.next {
+		n, err := w.Write(b.sliceOfPiece(p))
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
 // A position in a file given by line and column (both starting at 1)
 // Note that this is a position in the file.  In particular columns
 // are counted in number of runes in the line NOT number of characters
@@ -203,30 +376,42 @@ type Position struct {
 // position (that is either > length of the file or in the middle of a
 // multibyte utf8 sequence).
 func (b *Buf) PositionFromOffset(off int) (Position, error) {
-	// TODO: This can obviously made more efficient by caching, etc...
+	if off < 0 || off > b.len {
+		return Position{}, fmt.Errorf("Invalid offset %d valid:0-%d", off, b.len)
+	}
+	cache := b.posCache
+	if cache.pos.Line != 0 && cache.off == off {
+		return cache.pos, nil
+	}
 	pos := Position{
-		Line:   1,
+		Line:   1 + b.countNewlines(0, off),
 		Column: 1,
 	}
-	rd := b.NewReader(0)
-	for rd.Offset() != off {
-		r, _, err := rd.ReadRune()
-		if err != nil {
+	start := b.Line(pos.Line)
+	if cache.pos.Line == pos.Line && start <= cache.off && cache.off < off {
+		// count the runes from the cached offset on
+		start, pos.Column = cache.off, cache.pos.Column
+	}
+	rd := b.NewReader(start)
+	for rd.Offset() < off {
+		if _, _, err := rd.ReadRune(); err != nil {
 			return Position{}, err
 		}
-		if r == '\n' {
-			pos.Line++
-			pos.Column = 1
-		} else {
-			pos.Column++
-		}
+		pos.Column++
+	}
+	if rd.Offset() != off {
+		return Position{}, fmt.Errorf("Offset %d is in the middle of a rune", off)
 	}
+	b.posCache = positionCache{off, pos}
 	return pos, nil
 }
 
 // Translate a position into an offset. Errors if the given position
 // is not a valid position.
 func (b *Buf) PositionToOffset(p Position) (int, error) {
+	if p.Line < 1 || p.Line > b.Lines() {
+		return 0, fmt.Errorf("Invalid position line %d valid:1-%d", p.Line, b.Lines())
+	}
 	off := b.Line(p.Line)
 	rd := b.NewReader(off)
 	// we are in the right line
@@ -236,87 +421,115 @@ func (b *Buf) PositionToOffset(p Position) (int, error) {
 			return 0, err
 		}
 		if r == '\n' {
-			return 0, fmt.Errorf("Invalid position line %i contains less than %i columns", p.Line, p.Column)
+			return 0, fmt.Errorf("Invalid position line %d contains less than %d columns", p.Line, p.Column)
 		}
 	}
 	return rd.Offset(), nil
 }
 
-// Line returns the offset of the first character of Line n.  
-// Note Line numbers start at 1.
-// FIXME: Either add error code, or make it panic if line number > number
+// Line returns the offset of the first character of Line n.
+// Note Line numbers start at 1.  Line numbers past the last line
+// return the offset of the last line.
 func (b *Buf) Line(n int) int {
-	var startOfLine, linesToSkip int
-	if b.lineCache.line != 0 && b.lineCache.line < n {
-		startOfLine = b.lineCache.off
-		linesToSkip = n - b.lineCache.line
-	} else if (b.lineCache.line == n) {
+	if n > b.newlines {
+		n = b.newlines + 1
+	}
+	if n <= 1 {
+		return 0
+	}
+	if b.lineCache.line == n {
 		return b.lineCache.off
-	} else {
-		startOfLine = 0
-		linesToSkip = n - 1
-	} 
-	rd := b.NewReader(startOfLine)
-	for ; linesToSkip > 0; linesToSkip-- {
-		for {
-			rn, _, err := rd.ReadRune()
-			if err != nil {
-				return startOfLine
-			}
-			if rn == '\n' {
-				startOfLine = rd.Offset()
-				break
-			}
+	}
+	b.lineCache.line = n
+	b.lineCache.off = b.findNewline(n - 1)
+	return b.lineCache.off
+}
+
+// LineRange returns the offset of the first character of line n and of
+// its end, the line break or the end of the buffer.  Like with Line,
+// line numbers are clamped to those of the buffer.
+func (b *Buf) LineRange(n int) (start, end int) {
+	if n < 1 {
+		n = 1
+	}
+	start = b.Line(n)
+	if n < b.Lines() {
+		return start, b.Line(n+1) - 1
+	}
+	return start, b.len
+}
+
+// LineOfOffset returns the number of the line containing off.  Offsets
+// outside of the buffer are clamped to it.  Unlike PositionFromOffset
+// it only needs the line index, not the text of the line.
+func (b *Buf) LineOfOffset(off int) int {
+	if off < 0 {
+		off = 0
+	} else if off > b.len {
+		off = b.len
+	}
+	return 1 + b.newlinesBefore(off)
+}
+
+// EachLine calls f with the number and the text (without the line break,
+// see Reader.ReadLine) of the lines from to to (inclusive).  Stops if f
+// returns false.
+func (b *Buf) EachLine(from, to int, f func(lineNo int, line []byte) bool) {
+	if from < 1 {
+		from = 1
+	}
+	if to > b.Lines() {
+		to = b.Lines()
+	}
+	if from > to {
+		return
+	}
+	rd := b.NewReader(b.Line(from))
+	for n := from; n <= to; n++ {
+		// at the end of the buffer, this is the empty last line
+		line, _ := rd.ReadLine()
+		if !f(n, line) {
+			return
 		}
 	}
-	// we always update the cache if it is invalid or
-	// if we asked for a line above the current line and we can't
-	// easily reach that line from the beginning or
-	// if it is more than a few lines past the the current line 
-	if (b.lineCache.line == 0) || 
-		(n < b.lineCache.line && n > 5) ||
-		(n - b.lineCache.line > 5) {
-		b.lineCache.line = n
-		b.lineCache.off = startOfLine
-	} 
-	return startOfLine
 }
 
 // Lines returns the number of lines in the buffer
 // The empty buffer has exactly one (empty) line.
 func (b *Buf) Lines() int {
-	if b.lines != 0 {
-		return b.lines
-	} else {
-		r := b.NewReader(0)
-		lines := 1
-		for {
-			rn, _, err := r.ReadRune()
-			if err != nil {
-				break
-			}
-			if rn == '\n' {
-				lines++
-			}
-		}
-		b.lines = lines
-		return lines
-	} 
+	return b.newlines + 1
+}
+
+// countNewlines returns the number of newlines between off1 (inclusive)
+// and off2 (exclusive).
+func (b *Buf) countNewlines(off1, off2 int) int {
+	return b.newlinesBefore(off2) - b.newlinesBefore(off1)
 }
 
 // The type of a Reader on the buffer.
 // Implements io.ReadSeeker and RuneScanner.
-// It also implements reading in reverse direction.  At the moment only
-// for runes.
+// It also implements reading in reverse direction (see Reverse) with
+// Read and ReadRune.
+//
+// UnreadRune undoes the previous operation if it was a successful
+// ReadRune in either direction: it moves the reader back to where it
+// was, so that the next ReadRune returns the same rune again.  After any
+// other operation, that is Read, ReadLine, Seek, Reverse, UnreadRune or
+// a ReadRune returning an error, UnreadRune returns
+// ErrInvalidUnreadRune and leaves the reader as it is.
 type Reader struct {
 	buf          *Buf
 	piece        *piece
 	offInPiece   int  // offset in the current piece
 	off          int  // absolute offset in file
 	reverse      bool // read in reverse direction
-	lastRuneSize int  // -1 if last read was not a ReadRune
+	lastRuneSize int  // -1 if last operation was not a successful ReadRune
 }
 
+// ErrInvalidUnreadRune is returned by UnreadRune if the previous
+// operation was not a successful ReadRune.
+var ErrInvalidUnreadRune = errors.New("Cannot call UnreadRune when previous operation wasn't ReadRune")
+
 // NewReader creates a new reader starting at off.
 func (b *Buf) NewReader(off int) *Reader {
 	o, p := b.findPiece(off)
@@ -333,17 +546,28 @@ func (b *Buf) NewReader(off int) *Reader {
 // Reverse reverses direction of reading.
 func (rd *Reader) Reverse() {
 	rd.reverse = !rd.reverse
+	rd.lastRuneSize = -1 // invalidate calls to UnreadRune
 }
 
+// Read implements io.Reader.  When reading in reverse direction Read
+// reads the (up to) len(dst) bytes in front of the reader and moves
+// the reader to the first of them.  The bytes are stored in dst in the
+// order they appear in the buffer, that is dst[:n] holds the bytes
+// between Offset()-n and Offset().  Like strings.Reader it returns
+// io.EOF only if no bytes are left to read.
 func (r *Reader) Read(dst []byte) (int, error) {
+	r.lastRuneSize = -1 // invalidate calls to UnreadRune
 	if r.reverse {
-		panic("Reader.Read in reverse direction not implemented")
+		return r.readBackward(dst)
+	}
+	if r.off >= r.buf.Len() {
+		return 0, io.EOF
 	}
 	offDst := 0
 process_piece:
 	if r.piece == &r.buf.sentinel { // no more bytes
 		// return however much we copied
-		return offDst, io.EOF
+		return offDst, nil
 	}
 	bytes := r.buf.sliceOfPiece(r.piece)
 	n := copy(dst[offDst:], bytes[r.offInPiece:])
@@ -351,7 +575,6 @@ process_piece:
 	r.off += n
 	if offDst == len(dst) { // no more space in buffer
 		r.offInPiece += n
-		r.lastRuneSize = -1 // invalidate calls to UnreadRune
 		return offDst, nil
 	} else { // we are done with the current piece
 		// but there is still space in the buffer
@@ -361,6 +584,68 @@ process_piece:
 	}
 }
 
+func (r *Reader) readBackward(dst []byte) (int, error) {
+	n := len(dst)
+	if n > r.off {
+		n = r.off
+	}
+	if n == 0 && len(dst) > 0 {
+		return 0, io.EOF
+	}
+	for end := n; end > 0; {
+		for r.offInPiece <= 0 {
+			r.piece = r.piece.prev
+			r.offInPiece = r.piece.len()
+		}
+		chunk := r.buf.sliceOfPiece(r.piece)[:r.offInPiece]
+		k := len(chunk)
+		if k > end {
+			k = end
+		}
+		copy(dst[end-k:end], chunk[len(chunk)-k:])
+		end -= k
+		r.offInPiece -= k
+		r.off -= k
+	}
+	return n, nil
+}
+
+// ReadLine reads the rest of the current line and moves the reader to
+// the start of the next one.  The line break is not included in the
+// result, which refers to the text of the buffer if possible, so it must
+// not be modified.  Returns io.EOF at the end of the buffer.  Only reads
+// in forward direction.
+func (r *Reader) ReadLine() ([]byte, error) {
+	if r.reverse {
+		return nil, errors.New("ReadLine: reader is reversed")
+	}
+	r.lastRuneSize = -1 // invalidate calls to UnreadRune
+	var line []byte     // only used if the line spans several pieces
+	eof := true
+	for r.piece != &r.buf.sentinel {
+		text := r.buf.sliceOfPiece(r.piece)[r.offInPiece:]
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			r.offInPiece += i + 1
+			r.off += i + 1
+			if line == nil {
+				return text[:i:i], nil
+			}
+			return append(line, text[:i]...), nil
+		}
+		if len(text) > 0 {
+			eof = false
+			line = append(line, text...)
+			r.off += len(text)
+		}
+		r.piece = r.piece.next
+		r.offInPiece = 0
+	}
+	if eof {
+		return nil, io.EOF
+	}
+	return line, nil
+}
+
 func (rd *Reader) readRuneForward() (r rune, size int, err error) {
 	bytes := rd.buf.sliceOfPiece(rd.piece)[rd.offInPiece:]
 	// specialisation of the common case
@@ -374,6 +659,7 @@ func (rd *Reader) readRuneForward() (r rune, size int, err error) {
 		rd.offInPiece += size
 	} else { // need to read several bytes
 		var buf [utf8.UTFMax]byte
+		start := rd.off
 		n, err := rd.Read(buf[:])
 		if n == 0 {
 			return 0, 0, io.EOF
@@ -381,45 +667,61 @@ func (rd *Reader) readRuneForward() (r rune, size int, err error) {
 			return 0, 0, err
 		}
 		r, size = utf8.DecodeRune(buf[:n])
+		if n != size {
+			// we read past the end of the rune
+			rd.Seek(int64(start+size), 0)
+		}
 	}
 	return r, size, nil
 }
 
-func (rd *Reader) readRuneBackward() (r rune, size int, err error) {
-	var bytes [4]byte
-	size = 0
-read_next_byte:
+// readByteBackward moves the reader one byte backwards and returns
+// that byte.  Returns false if the reader is already at the start.
+func (rd *Reader) readByteBackward() (byte, bool) {
 	if rd.off == 0 {
-		if size == 0 {
-			return 0, 0, io.EOF
-		}
-		// this means we wanted to read another byte
-		// because we don't have a valid utf character
-		// yet but there are not anymore...
-		// TODO: handle that
-		panic("partial utf8 at end of buffer not yet implemented")
+		return 0, false
 	}
-	if rd.offInPiece <= 0 {
+	for rd.offInPiece <= 0 {
 		rd.piece = rd.piece.prev
-		rd.offInPiece = rd.piece.off2
+		rd.offInPiece = rd.piece.len()
 	}
-	bytes[size] = rd.buf.sliceOfPiece(rd.piece)[rd.offInPiece-1]
-	size++
 	rd.offInPiece--
 	rd.off--
-	if rd.offInPiece <= 0 {
-		rd.piece = rd.piece.prev
-		rd.offInPiece = rd.piece.off2
+	return rd.buf.sliceOfPiece(rd.piece)[rd.offInPiece], true
+}
+
+func (rd *Reader) readRuneBackward() (r rune, size int, err error) {
+	// collect bytes backwards until we hit the start of a
+	// utf8 sequence.  bytes is filled from the end.
+	var bytes [utf8.UTFMax]byte
+	start := rd.off
+	n := 0
+	for n < utf8.UTFMax {
+		c, ok := rd.readByteBackward()
+		if !ok {
+			break
+		}
+		n++
+		bytes[utf8.UTFMax-n] = c
+		if utf8.RuneStart(c) {
+			break
+		}
+	}
+	if n == 0 {
+		return 0, 0, io.EOF
 	}
-	if utf8.FullRune(bytes[:size]) {
-		r, size = utf8.DecodeRune(bytes[:size])
-		return r, size, nil
+	r, size = utf8.DecodeRune(bytes[utf8.UTFMax-n:])
+	if size != n {
+		// not a valid utf8 sequence, step back over a single byte only
+		rd.Seek(int64(start-1), 0)
+		return utf8.RuneError, 1, nil
 	}
-	// not a full rune read another byte into the
-	// buffer and try again
-	goto read_next_byte
+	return r, size, nil
 }
 
+// ReadRune implements io.RuneScanner.  In reverse direction it reads the
+// rune in front of the reader.  Invalid UTF-8 is read as
+// utf8.RuneError of size 1.
 func (rd *Reader) ReadRune() (r rune, size int, err error) {
 	if rd.reverse {
 		r, size, err = rd.readRuneBackward()
@@ -428,14 +730,17 @@ func (rd *Reader) ReadRune() (r rune, size int, err error) {
 	}
 	if err == nil {
 		rd.lastRuneSize = size
+	} else {
+		rd.lastRuneSize = -1
 	}
 	return r, size, err
 }
 
+// UnreadRune implements io.RuneScanner, see Reader.
 func (rd *Reader) UnreadRune() error {
 	// TODO bgrundmann: This can be optimized for the common case
 	if rd.lastRuneSize < 0 {
-		return errors.New("Cannot call UnreadRune when previous operation wasn't ReadRune")
+		return ErrInvalidUnreadRune
 	}
 	var offset int64
 	if rd.reverse {
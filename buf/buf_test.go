@@ -2,10 +2,21 @@ package buf
 
 import "io"
 import "bufio"
+import "bytes"
 import "fmt"
+import "regexp"
+import "math/rand"
+import "strings"
 import "testing"
+import "os"
+import "path/filepath"
+import "reflect"
+import "unicode/utf8"
+import "crypto/sha256"
+import "errors"
+import "testing/iotest"
 
-func ExampleBufInsert() {
+func ExampleBuf_Insert() {
 	var b Buf
 	b.Init()
 	b.Insert(0, []byte("World"))
@@ -15,7 +26,7 @@ func ExampleBufInsert() {
 	// Output: Hello World
 }
 
-func ExampleBufDelete() {
+func ExampleBuf_Delete() {
 	var b Buf
 	b.Init()
 	b.Insert(0, []byte("Hello"))
@@ -24,14 +35,14 @@ func ExampleBufDelete() {
 	// Output:
 }
 
-func ExampleBufReader() {
+func ExampleBuf_NewReader() {
 	var b Buf
 	b.Init()
 	b.Insert(0, []byte("Hello"))
 	r := bufio.NewReaderSize(b.NewReader(0), 128)
 	s, err := r.ReadString('\n')
 	if err != io.EOF {
-		fmt.Printf("expected EOF", err)
+		fmt.Printf("expected EOF got %v", err)
 	}
 	fmt.Printf("%s\n", s)
 	// Output: Hello
@@ -91,7 +102,7 @@ func TestBufReverseReader(t *testing.T) {
 	check('e')
 	check('H')
 	if ch, n, err := r.ReadRune(); err != io.EOF {
-		t.Errorf("Expected EOF got: %c - %i - %v", ch, n, err)
+		t.Errorf("Expected EOF got: %c - %d - %v", ch, n, err)
 	}
 }
 
@@ -155,3 +166,1254 @@ func TestLines(t *testing.T) {
 		t.Errorf("expected 3 lines got %v", n)
 	}
 }
+
+func TestLineRange(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("Hello\n\nFoo"))
+	tests := []struct{ n, start, end int }{
+		{0, 0, 5},
+		{1, 0, 5},
+		{2, 6, 6},
+		{3, 7, 10},
+		{4, 7, 10},
+	}
+	for _, test := range tests {
+		if start, end := b.LineRange(test.n); start != test.start || end != test.end {
+			t.Errorf("LineRange(%d) = %d, %d, want %d, %d", test.n, start, end, test.start, test.end)
+		}
+	}
+	for off, want := range []int{1, 1, 1, 1, 1, 1, 2, 3, 3, 3, 3} {
+		if got := b.LineOfOffset(off); got != want {
+			t.Errorf("LineOfOffset(%d) = %d, want %d", off, got, want)
+		}
+	}
+	if b.LineOfOffset(-1) != 1 || b.LineOfOffset(100) != 3 {
+		t.Errorf("LineOfOffset doesn't clamp")
+	}
+}
+
+func TestReadRuneMultiByte(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("aä"))
+	b.Insert(3, []byte("€b"))
+	b.Insert(1, []byte("x"))
+	want := []rune("axä€b")
+	r := b.NewReader(0)
+	for _, c := range want {
+		if ch, _, err := r.ReadRune(); ch != c || err != nil {
+			t.Errorf("Expected %c got: %c (%v)", c, ch, err)
+		}
+	}
+	if r.Offset() != b.Len() {
+		t.Errorf("Expected reader at %v got: %v", b.Len(), r.Offset())
+	}
+	r.Reverse()
+	for i := len(want) - 1; i >= 0; i-- {
+		if ch, _, err := r.ReadRune(); ch != want[i] || err != nil {
+			t.Errorf("Expected %c got: %c (%v)", want[i], ch, err)
+		}
+	}
+	if _, _, err := r.ReadRune(); err != io.EOF {
+		t.Errorf("Expected EOF got: %v", err)
+	}
+}
+
+func TestLinesAfterEdits(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("one\ntwo\nthree\n"))
+	b.Insert(4, []byte("1\n2\n"))
+	b.Delete(2, 6)
+	// "on2\ntwo\nthree\n"
+	if s := b.String(); s != "on2\ntwo\nthree\n" {
+		t.Fatalf("unexpected content %q", s)
+	}
+	if n := b.Lines(); n != 4 {
+		t.Errorf("expected 4 lines got %v", n)
+	}
+	for n, off := range []int{0, 0, 4, 8, 14, 14} {
+		if got := b.Line(n); got != off {
+			t.Errorf("Line %v expected %v got: %v", n, off, got)
+		}
+	}
+}
+
+func TestLineCacheAfterEdits(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("one\ntwo\nthree\nfour\n"))
+	cached := func(line, off int) {
+		t.Helper()
+		if c := b.lineCache; c.line != line || line != 0 && c.off != off {
+			t.Errorf("expected line %v at %v cached got %+v", line, off, c)
+		}
+	}
+	b.Line(3)
+	cached(3, 8)
+	// edits from the start of the line on keep it
+	b.Insert(8, []byte("3"))
+	b.Delete(10, 20)
+	cached(3, 8)
+	// edits before it move it
+	b.Insert(0, []byte("zero\n"))
+	cached(4, 13)
+	b.Delete(0, 2)
+	cached(4, 11)
+	// joining it to the line before drops it
+	b.Delete(6, 11)
+	cached(0, 0)
+
+	// the lines cached stay right through random edits
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		if b.Len() > 0 && rnd.Intn(2) == 0 {
+			off1 := rnd.Intn(b.Len())
+			b.Delete(off1, off1+1+rnd.Intn(min(5, b.Len()-off1)))
+		} else {
+			b.Insert(rnd.Intn(b.Len()+1), []byte("ab\nc\n"[rnd.Intn(3):]))
+		}
+		if c := b.lineCache; c.line != 0 {
+			lines := strings.SplitAfter(b.String(), "\n")
+			if off := len(strings.Join(lines[:c.line-1], "")); c.off != off {
+				t.Fatalf("edit %v: line %v cached at %v, is at %v", i, c.line, c.off, off)
+			}
+		}
+		b.Line(1 + rnd.Intn(b.Lines()))
+	}
+}
+
+func TestPositionFromOffset(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("ab\nä\n\nc"))
+	b.Insert(3, []byte("x"))
+	// "ab\nxä\n\nc"
+	tests := []struct {
+		off int
+		pos Position
+	}{
+		{0, Position{1, 1}},
+		{2, Position{1, 3}},
+		{3, Position{2, 1}},
+		{4, Position{2, 2}},
+		{6, Position{2, 3}},
+		{7, Position{3, 1}},
+		{8, Position{4, 1}},
+		{9, Position{4, 2}},
+	}
+	for _, test := range tests {
+		pos, err := b.PositionFromOffset(test.off)
+		if err != nil || pos != test.pos {
+			t.Errorf("offset %v expected %v got: %v (%v)", test.off, test.pos, pos, err)
+		}
+		off, err := b.PositionToOffset(test.pos)
+		if err != nil || off != test.off {
+			t.Errorf("position %v expected %v got: %v (%v)", test.pos, test.off, off, err)
+		}
+	}
+	if _, err := b.PositionFromOffset(5); err == nil {
+		t.Errorf("expected error for offset in the middle of a rune")
+	}
+}
+
+func TestPositionCache(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("aäb\ncd"))
+	check := func(off int, want Position) {
+		t.Helper()
+		if pos, err := b.PositionFromOffset(off); err != nil || pos != want {
+			t.Errorf("offset %v expected %v got: %v (%v)", off, want, pos, err)
+		}
+	}
+	// forwards and backwards in a line and to the next one
+	check(1, Position{1, 2})
+	check(3, Position{1, 3})
+	check(4, Position{1, 4})
+	check(0, Position{1, 1})
+	check(6, Position{2, 2})
+	if _, err := b.PositionFromOffset(2); err == nil {
+		t.Errorf("expected error for offset in the middle of a rune")
+	}
+	// edits before the cached offset move it
+	check(3, Position{1, 3})
+	b.Insert(0, []byte("x\n"))
+	check(3, Position{2, 2})
+	check(5, Position{2, 3})
+	b.Delete(0, 2)
+	check(5, Position{2, 1})
+	check(6, Position{2, 2})
+}
+
+// positionObserver translates the offset of each change into a
+// position, as observers following lines do.
+type positionObserver struct {
+	b    *Buf
+	seen []Position
+}
+
+func (o *positionObserver) OnBufChange(c Change) {
+	pos, _ := o.b.PositionFromOffset(c.Off)
+	o.seen = append(o.seen, pos)
+}
+
+func TestObserverSeesOldState(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("ab\ncd\nef"))
+	o := &positionObserver{b: &b}
+	b.AddObserver(o)
+	b.Delete(2, 6)
+	b.Insert(4, []byte("\nxy"))
+	want := []Position{{1, 3}, {1, 5}}
+	if !reflect.DeepEqual(o.seen, want) {
+		t.Errorf("observer saw %v expected %v", o.seen, want)
+	}
+	// nothing translated by the observer is left over from before
+	for off, want := range []Position{{1, 1}, {1, 2}, {1, 3}, {1, 4}, {1, 5}, {2, 1}, {2, 2}} {
+		if pos, _ := b.PositionFromOffset(off); pos != want {
+			t.Errorf("offset %v expected %v got %v", off, want, pos)
+		}
+	}
+	if b.Lines() != 2 || b.Line(2) != 5 {
+		t.Errorf("expected 2 lines, the second at 5 got %v, %v", b.Lines(), b.Line(2))
+	}
+}
+
+func TestReverseRead(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("Hello World"))
+	b.Insert(5, []byte(","))
+	r := b.NewReader(b.Len())
+	r.Reverse()
+	dst := make([]byte, 5)
+	reads := []struct {
+		size int
+		exp  string
+	}{
+		{5, "World"},
+		{4, "lo, "},
+		{5, "Hel"}, // asks for more than there is
+	}
+	for _, read := range reads {
+		n, err := r.Read(dst[:read.size])
+		if err != nil || string(dst[:n]) != read.exp {
+			t.Errorf("expected %q got %q (%v)", read.exp, dst[:n], err)
+		}
+	}
+	if r.Offset() != 0 {
+		t.Errorf("expected reader at start got %v", r.Offset())
+	}
+	if n, err := r.Read(dst); n != 0 || err != io.EOF {
+		t.Errorf("expected EOF got %v %v", n, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("foo bar foo"))
+	b.Insert(4, []byte("fo"))
+	// "foo fobar foo"
+	re := regexp.MustCompile("fo+")
+	if s, e, ok := b.SearchForward(re, 1); !ok || s != 4 || e != 6 {
+		t.Errorf("forward: expected 4-6 got %v-%v %v", s, e, ok)
+	}
+	if s, e, ok := b.SearchBackward(re, 10); !ok || s != 4 || e != 6 {
+		t.Errorf("backward: expected 4-6 got %v-%v %v", s, e, ok)
+	}
+	if _, _, ok := b.SearchForward(re, 11); ok {
+		t.Errorf("forward: expected no match")
+	}
+	if _, _, ok := b.SearchBackward(re, 0); ok {
+		t.Errorf("backward: expected no match")
+	}
+}
+
+func TestBytes(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("Hello World"))
+	b.Insert(5, []byte(","))
+	for _, r := range [][2]int{{0, 12}, {3, 8}, {5, 6}, {4, 4}} {
+		exp := "Hello, World"[r[0]:r[1]]
+		if got := string(b.Bytes(r[0], r[1])); got != exp {
+			t.Errorf("Bytes %v: expected %q got %q", r, exp, got)
+		}
+		got, err := io.ReadAll(b.Slice(r[0], r[1]))
+		if err != nil || string(got) != exp {
+			t.Errorf("Slice %v: expected %q got %q (%v)", r, exp, got, err)
+		}
+	}
+}
+
+func TestRandomEdits(t *testing.T) {
+	var b Buf
+	b.Init()
+	ref := ""
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		if len(ref) > 0 && rnd.Intn(3) == 0 {
+			off1 := rnd.Intn(len(ref))
+			off2 := off1 + rnd.Intn(len(ref)-off1+1)
+			b.Delete(off1, off2)
+			ref = ref[:off1] + ref[off2:]
+		} else {
+			off := rnd.Intn(len(ref) + 1)
+			s := []string{"a", "bc\n", "\n", "def", "g\nh\n"}[rnd.Intn(5)]
+			b.Insert(off, []byte(s))
+			ref = ref[:off] + s + ref[off:]
+		}
+		if b.Len() != len(ref) || b.String() != ref {
+			t.Fatalf("step %v: expected %q got %q", i, ref, b.String())
+		}
+		lines := strings.Split(ref, "\n")
+		if b.Lines() != len(lines) {
+			t.Fatalf("step %v: expected %v lines got %v", i, len(lines), b.Lines())
+		}
+		n := 1 + rnd.Intn(len(lines))
+		if exp := len(strings.Join(lines[:n-1], "\n")) + 1; n > 1 && b.Line(n) != exp {
+			t.Fatalf("step %v: Line(%v) expected %v got %v", i, n, exp, b.Line(n))
+		}
+		off := rnd.Intn(len(ref) + 1)
+		pos, _ := b.PositionFromOffset(off)
+		if exp := 1 + strings.Count(ref[:off], "\n"); pos.Line != exp {
+			t.Fatalf("step %v: line of offset %v expected %v got %v", i, off, exp, pos.Line)
+		}
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	data, le := NormalizeLineEndings([]byte("a\r\nb\r\nc\n"))
+	if le != CRLF || string(data) != "a\nb\nc\n" {
+		t.Errorf("expected CRLF and unix text got %v %q", le, data)
+	}
+	if _, le := NormalizeLineEndings([]byte("a\r\nb\nc\n")); le != LF {
+		t.Errorf("expected LF got %v", le)
+	}
+	var b Buf
+	b.Init()
+	b.Write(data)
+	b.SetLineEnding(le)
+	var out bytes.Buffer
+	if err := b.WriteFile(&out); err != nil || out.String() != "a\r\nb\r\nc\r\n" {
+		t.Errorf("expected dos text got %q (%v)", out.String(), err)
+	}
+}
+
+func TestDirty(t *testing.T) {
+	var b Buf
+	b.Init()
+	if b.Dirty() {
+		t.Fatal("new buffer is dirty")
+	}
+	b.Insert(0, []byte{})
+	if b.Dirty() || b.Seq() != 0 {
+		t.Fatal("empty insert changed the buffer")
+	}
+	b.Insert(0, []byte("hello"))
+	if !b.Dirty() {
+		t.Fatal("insert didn't change the buffer")
+	}
+	b.MarkSaved()
+	if b.Dirty() {
+		t.Fatal("buffer dirty after MarkSaved")
+	}
+	seq := b.Seq()
+	b.Delete(1, 2)
+	if !b.Dirty() {
+		t.Fatal("delete didn't change the buffer")
+	}
+	if b.Seq() <= seq {
+		t.Fatalf("Seq didn't grow: %v after %v", b.Seq(), seq)
+	}
+}
+
+func TestLargeInserts(t *testing.T) {
+	var b Buf
+	b.Init()
+	ref := ""
+	// cross block boundaries with small inserts and insert text
+	// bigger than a block
+	small := strings.Repeat("abc\n", 1000)
+	big := strings.Repeat("0123456789", blockSize/5)
+	for i := 0; i < 40; i++ {
+		b.Insert(b.Len()/2, []byte(small))
+		ref = ref[:len(ref)/2] + small + ref[len(ref)/2:]
+	}
+	b.Insert(10, []byte(big))
+	ref = ref[:10] + big + ref[10:]
+	b.Insert(b.Len(), []byte(small))
+	ref += small
+	if b.String() != ref {
+		t.Fatal("buffer contents differ from reference")
+	}
+	if want := strings.Count(ref, "\n") + 1; b.Lines() != want {
+		t.Errorf("Lines() = %d, want %d", b.Lines(), want)
+	}
+}
+
+func TestInitFromFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		contents string
+		want     string
+		le       LineEnding
+	}{
+		{"", "", LF},
+		{"hello\nworld\n", "hello\nworld\n", LF},
+		{"hello\r\nworld\r\n", "hello\nworld\n", CRLF},
+	}
+	for i, test := range tests {
+		name := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		if err := os.WriteFile(name, []byte(test.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var b Buf
+		if err := b.InitFromFile(name); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.want || b.LineEnding() != test.le || b.Name() != name {
+			t.Errorf("%q: got %q (%v, %q)", test.contents, b.String(), b.LineEnding(), b.Name())
+		}
+		// the original text must survive edits
+		b.Insert(b.Len()/2, []byte("x"))
+		b.Delete(0, b.Len()/3)
+	}
+	var b Buf
+	if err := b.InitFromFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: got %v", err)
+	}
+
+	// files claiming to be empty are read all the same
+	if _, err := os.Stat("/proc/self/status"); err == nil {
+		if err := b.InitFromFile("/proc/self/status"); err != nil || !strings.HasPrefix(b.String(), "Name:") {
+			t.Errorf("/proc/self/status: got %q, %v", b.String(), err)
+		}
+	}
+}
+
+func TestInitFromFileChangedOnDisk(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(name, []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var b Buf
+	if err := b.InitFromFile(name); err != nil {
+		t.Fatal(err)
+	}
+	lines := b.Lines()
+	// neither rewriting the file in place nor truncating it changes
+	// the buffer, which has a copy of its own
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("HELLO\n\n"), 0)
+	f.Truncate(1)
+	f.Close()
+	if b.String() != "hello\nworld\n" || b.Lines() != lines {
+		t.Errorf("got %q, %d lines", b.String(), b.Lines())
+	}
+}
+
+func TestMarkers(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("0123456789"))
+	right := b.NewMarker(5)
+	left := b.NewMarkerWithGravity(5, GravityLeft)
+	if right.Gravity() != GravityRight || left.Gravity() != GravityLeft {
+		t.Fatal("wrong gravity")
+	}
+	b.Insert(5, []byte("ab"))
+	if right.Offset() != 7 || left.Offset() != 5 {
+		t.Errorf("after insert: right %d left %d", right.Offset(), left.Offset())
+	}
+	b.Delete(4, 9)
+	if right.Offset() != 4 || left.Offset() != 4 {
+		t.Errorf("after delete around: right %d left %d", right.Offset(), left.Offset())
+	}
+	b.Delete(0, 2)
+	if right.Offset() != 2 {
+		t.Errorf("after delete before: %d", right.Offset())
+	}
+	r := b.NewRange(1, 3, GravityLeft, GravityRight)
+	b.Insert(3, []byte("x"))
+	b.Insert(1, []byte("y"))
+	if off1, off2 := r.Offsets(); off1 != 1 || off2 != 5 {
+		t.Errorf("range: %d-%d", off1, off2)
+	}
+	r.Close()
+	right.Close()
+	if right.Valid() || r.Start.Valid() || !left.Valid() {
+		t.Error("wrong validity after Close")
+	}
+	b.Insert(0, []byte("z"))
+	if right.Offset() != 3 {
+		t.Errorf("closed marker moved to %d", right.Offset())
+	}
+	if len(b.markers) != 1 {
+		t.Errorf("%d markers left, want 1", len(b.markers))
+	}
+}
+
+func TestManyMarkers(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte(strings.Repeat("x", 100)))
+	var ms []Marker
+	var want []int
+	for i := 0; i < 200; i++ {
+		off := rand.Intn(b.Len() + 1)
+		ms = append(ms, b.NewMarkerWithGravity(off, Gravity(i%2)))
+		want = append(want, off)
+	}
+	for i := 0; i < 1000; i++ {
+		if rand.Intn(2) == 0 {
+			off, n := rand.Intn(b.Len()+1), 1+rand.Intn(5)
+			b.Insert(off, []byte(strings.Repeat("y", n)))
+			for j, w := range want {
+				if w > off || (w == off && ms[j].Gravity() == GravityRight) {
+					want[j] += n
+				}
+			}
+		} else if b.Len() > 0 {
+			off1 := rand.Intn(b.Len())
+			off2 := off1 + rand.Intn(b.Len()-off1+1)
+			b.Delete(off1, off2)
+			for j, w := range want {
+				if w >= off2 {
+					want[j] -= off2 - off1
+				} else if w > off1 {
+					want[j] = off1
+				}
+			}
+		}
+		if i%10 == 0 {
+			j := rand.Intn(len(ms))
+			want[j] = rand.Intn(b.Len() + 1)
+			ms[j].Move(want[j])
+		}
+	}
+	for j, m := range ms {
+		if m.Offset() != want[j] {
+			t.Fatalf("marker %d at %d, want %d", j, m.Offset(), want[j])
+		}
+	}
+}
+
+func TestRuneBoundaries(t *testing.T) {
+	var b Buf
+	b.Init()
+	// ä is 2 bytes, € 3, followed by a stray continuation byte and an
+	// incomplete sequence
+	b.Insert(0, []byte("aä€\x80\xe2\x82"))
+	tests := []struct{ off, exp int }{
+		{0, 0}, {1, 1}, {2, 1}, {3, 3}, {4, 3}, {5, 3}, {6, 6}, {7, 7}, {8, 8}, {9, 9},
+	}
+	for _, test := range tests {
+		if got := b.NearestRuneBoundary(test.off); got != test.exp {
+			t.Errorf("NearestRuneBoundary(%v): expected %v got %v", test.off, test.exp, got)
+		}
+		if b.IsRuneBoundary(test.off) != (test.off == test.exp) {
+			t.Errorf("IsRuneBoundary(%v) wrong", test.off)
+		}
+	}
+}
+
+func TestValidating(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.SetValidating(true)
+	b.Insert(0, []byte("ä\xffb"))
+	if got := b.String(); got != "ä�b" {
+		t.Errorf("invalid utf-8 not replaced: %q", got)
+	}
+	for _, f := range []func(){
+		func() { b.Insert(1, []byte("x")) },
+		func() { b.Delete(0, 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("splitting a rune didn't panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestTryEdits(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.SetValidating(true)
+	b.Insert(0, []byte("äb"))
+	for _, err := range []error{
+		b.TryInsert(-1, []byte("x")),
+		b.TryInsert(4, []byte("x")),
+		b.TryInsert(1, []byte("x")),
+		b.TryDelete(2, 1),
+		b.TryDelete(0, 4),
+		b.TryDelete(0, 1),
+	} {
+		if err == nil {
+			t.Error("invalid edit didn't fail")
+		}
+	}
+	if got := b.String(); got != "äb" {
+		t.Errorf("failed edits changed the buffer to %q", got)
+	}
+	if err := b.TryInsert(3, []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.TryDelete(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "bc" {
+		t.Errorf("got %q want \"bc\"", got)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	var b Buf
+	b.Init()
+	// several pieces, with lines spanning them
+	b.Insert(0, []byte("foo\nbar"))
+	b.Insert(7, []byte("baz\n\nqux"))
+	b.Insert(2, []byte("XX"))
+	rd := b.NewReader(1)
+	for _, exp := range []string{"oXXo", "barbaz", "", "qux"} {
+		line, err := rd.ReadLine()
+		if err != nil || string(line) != exp {
+			t.Errorf("expected %q got %q (%v)", exp, line, err)
+		}
+	}
+	if _, err := rd.ReadLine(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+	var got []string
+	b.EachLine(2, 10, func(n int, line []byte) bool {
+		got = append(got, fmt.Sprintf("%d:%s", n, line))
+		return true
+	})
+	if exp := "2:barbaz 3: 4:qux"; strings.Join(got, " ") != exp {
+		t.Errorf("EachLine: expected %q got %q", exp, strings.Join(got, " "))
+	}
+	// the empty line after the last line break is a line too
+	b.Insert(b.Len(), []byte("\n"))
+	n := 0
+	b.EachLine(1, b.Lines(), func(int, []byte) bool { n++; return n < 5 })
+	if n != 5 {
+		t.Errorf("EachLine visited %d lines expected 5", n)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("hello world"))
+	b.Insert(5, []byte(",\n"))
+	s := b.Snapshot()
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		s.WriteTo(&out)
+		done <- out.String()
+	}()
+	// editing continues while the snapshot is read
+	for i := 0; i < 100; i++ {
+		b.Insert(3, []byte("x"))
+		b.Delete(0, 1)
+	}
+	if got := <-done; got != "hello,\n world" {
+		t.Errorf("snapshot changed: %q", got)
+	}
+	if s.Len() != 13 || s.Lines() != 2 || s.Seq() != 2 {
+		t.Errorf("wrong snapshot len %d lines %d seq %d", s.Len(), s.Lines(), s.Seq())
+	}
+	p := make([]byte, 6)
+	if n, err := s.ReadAt(p, 4); err != nil || string(p[:n]) != "o,\n wo" {
+		t.Errorf("ReadAt: got %q (%v)", p[:n], err)
+	}
+	if n, err := s.ReadAt(p, 10); err != io.EOF || string(p[:n]) != "rld" {
+		t.Errorf("ReadAt at the end: got %q (%v)", p[:n], err)
+	}
+	rest, _ := io.ReadAll(s.NewReader(7))
+	if string(rest) != " world" {
+		t.Errorf("NewReader: got %q", rest)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("hello"))
+	seq := b.Seq()
+	b.Insert(5, []byte(" world"))
+	b.Delete(0, 1)
+	edits := b.Changes(seq)
+	if len(edits) != 2 {
+		t.Fatalf("expected 2 edits got %d", len(edits))
+	}
+	exp := []struct {
+		kind EditKind
+		off  int
+		text string
+	}{{Insertion, 5, " world"}, {Deletion, 0, "h"}}
+	for i, e := range edits {
+		if e.Kind != exp[i].kind || e.Off != exp[i].off || string(e.Text) != exp[i].text || e.Seq != seq+i+1 {
+			t.Errorf("edit %d: expected %v got %v %v %q seq %v", i, exp[i], e.Kind, e.Off, e.Text, e.Seq)
+		}
+	}
+	if len(b.Changes(b.Seq())) != 0 || len(b.Changes(0)) != 3 {
+		t.Errorf("wrong number of changes")
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	const text = "aä€😀\n"
+	tests := []struct {
+		enc  Encoding
+		data []byte
+	}{
+		{UTF8, []byte(text)},
+		{UTF8BOM, append([]byte{0xef, 0xbb, 0xbf}, text...)},
+		{UTF16LE, []byte{0xff, 0xfe, 'a', 0, 0xe4, 0, 0xac, 0x20, 0x3d, 0xd8, 0x00, 0xde, '\n', 0}},
+		{UTF16BE, []byte{0xfe, 0xff, 0, 'a', 0, 0xe4, 0x20, 0xac, 0xd8, 0x3d, 0xde, 0x00, 0, '\n'}},
+	}
+	for _, test := range tests {
+		name := filepath.Join(t.TempDir(), "f")
+		if err := os.WriteFile(name, test.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		var b Buf
+		if err := b.InitFromFile(name); err != nil {
+			t.Fatal(err)
+		}
+		if b.Encoding() != test.enc || b.String() != text {
+			t.Errorf("%v: read %v %q", test.enc, b.Encoding(), b.String())
+		}
+		var out bytes.Buffer
+		if err := b.WriteFile(&out); err != nil || !bytes.Equal(out.Bytes(), test.data) {
+			t.Errorf("%v: wrote % x (%v)", test.enc, out.Bytes(), err)
+		}
+	}
+	// not utf-8
+	data, enc, _ := Decode([]byte("caf\xe9"))
+	if enc != Latin1 || string(data) != "café" {
+		t.Errorf("latin1: got %v %q", enc, data)
+	}
+	var b Buf
+	b.Init()
+	b.SetEncoding(Latin1)
+	b.Insert(0, []byte("café"))
+	var out bytes.Buffer
+	if err := b.WriteFile(&out); err != nil || out.String() != "caf\xe9" {
+		t.Errorf("latin1: wrote %q (%v)", out.String(), err)
+	}
+	if err := b.CheckEncoding(); err != nil {
+		t.Errorf("latin1: check: %v", err)
+	}
+	b.Insert(0, []byte("€"))
+	if err := b.WriteFile(&out); err == nil {
+		t.Errorf("latin1: wrote € without error")
+	}
+	if err := b.CheckEncoding(); err == nil {
+		t.Errorf("latin1: check passed €")
+	}
+	// a rune split across pieces
+	var b2 Buf
+	b2.Init()
+	b2.SetEncoding(UTF16BE)
+	b2.Insert(0, []byte("\xe2\x82"))
+	b2.Insert(2, []byte("\xac"))
+	out.Reset()
+	if err := b2.WriteFile(&out); err != nil || out.String() != "\xfe\xff\x20\xac" {
+		t.Errorf("utf-16be: wrote % x (%v)", out.Bytes(), err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("x = 41;"))
+	if err := b.Replace(4, 6, []byte("42")); err != nil || b.String() != "x = 42;" {
+		t.Errorf("got %q, %v", b.String(), err)
+	}
+	if err := b.Replace(4, 8, nil); err == nil {
+		t.Error("expected error for invalid offsets")
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("one\ntwo\nthree\n"))
+	err := b.ReplaceAll([]Replacement{
+		{0, 0, []byte("# ")},
+		{8, 10, []byte("TH")},
+		{4, 7, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "# one\n\nTHree\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	for _, rs := range [][]Replacement{
+		{{0, 3, nil}, {2, 4, nil}},
+		{{5, 20, nil}},
+		{{3, 2, nil}},
+	} {
+		if err := b.ReplaceAll(rs); err == nil {
+			t.Errorf("ReplaceAll(%v): expected error", rs)
+		}
+	}
+	if got, want := b.String(), "# one\n\nTHree\n"; got != want {
+		t.Errorf("failed replacements changed the buffer to %q", got)
+	}
+	// the first replacement splits a rune, the last one, made first, is fine
+	b.SetValidating(true)
+	b.Insert(0, []byte("é"))
+	if err := b.ReplaceAll([]Replacement{{0, 1, nil}, {b.Len() - 1, b.Len(), []byte("$")}}); err == nil {
+		t.Error("ReplaceAll splitting a rune: expected error")
+	}
+	if got, want := b.String(), "é# one\n\nTHree\n"; got != want {
+		t.Errorf("failed replacements changed the buffer to %q", got)
+	}
+}
+
+func TestHash(t *testing.T) {
+	var b Buf
+	b.Init()
+	if b.Hash() != sha256.Sum256(nil) {
+		t.Error("wrong hash of the empty buffer")
+	}
+	b.Insert(0, []byte("hello world\n"))
+	b.Insert(5, []byte(","))
+	b.Delete(0, 1)
+	b.Insert(0, []byte("H"))
+	if got, want := b.Hash(), sha256.Sum256([]byte("Hello, world\n")); got != want {
+		t.Errorf("got %x want %x", got, want)
+	}
+	// a snapshot keeps the hash of the text it was taken of
+	snap := b.Snapshot()
+	b.Delete(0, 7)
+	if got, want := snap.Hash(), sha256.Sum256([]byte("Hello, world\n")); got != want {
+		t.Errorf("snapshot: got %x want %x", got, want)
+	}
+}
+
+func TestInsertReader(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789abcde\n"), 3*blockSize/16+5)
+	tests := []struct {
+		name string
+		text []byte
+		r    func(text []byte) io.Reader
+	}{
+		{"small", []byte("hello\nworld"), func(text []byte) io.Reader { return bytes.NewReader(text) }},
+		{"small unknown size", []byte("hello\nworld"), func(text []byte) io.Reader { return iotest.HalfReader(bytes.NewReader(text)) }},
+		{"big", big, func(text []byte) io.Reader { return bytes.NewReader(text) }},
+		{"big unknown size", big, func(text []byte) io.Reader { return iotest.HalfReader(bytes.NewReader(text)) }},
+		{"empty", nil, func(text []byte) io.Reader { return strings.NewReader("") }},
+	}
+	for _, test := range tests {
+		var b Buf
+		b.Init()
+		b.Insert(0, []byte("<>"))
+		n, err := b.InsertReader(1, test.r(test.text))
+		if err != nil || n != len(test.text) {
+			t.Errorf("%s: got %d, %v", test.name, n, err)
+		}
+		if got, want := b.String(), "<"+string(test.text)+">"; got != want {
+			t.Errorf("%s: got %d bytes want %d", test.name, len(got), len(want))
+		}
+		if want := 3; len(test.text) > 0 && b.Stats().Pieces != want {
+			t.Errorf("%s: got %d pieces want %d", test.name, b.Stats().Pieces, want)
+		}
+		if got, want := b.Lines(), bytes.Count(test.text, []byte("\n"))+1; got != want {
+			t.Errorf("%s: got %d lines want %d", test.name, got, want)
+		}
+	}
+
+	// a text of known size gets a block of just its size
+	var big1 Buf
+	big1.Init()
+	big1.Insert(0, []byte("<>"))
+	big1.InsertReader(1, bytes.NewReader(big))
+	if got, want := big1.Stats().Backing, blockSize+len(big); got != want {
+		t.Errorf("got %d bytes of backing want %d", got, want)
+	}
+
+	// invalid utf-8 is replaced in validating mode, the text read before
+	// an error is inserted
+	var b Buf
+	b.Init()
+	b.SetValidating(true)
+	b.Insert(0, []byte("abxyz"))
+	n, err := b.InsertReader(2, io.MultiReader(strings.NewReader("1\xff2"), iotest.ErrReader(errors.New("broken"))))
+	if err == nil || err.Error() != "broken" || n != 5 || b.String() != "ab1\uFFFD2xyz" {
+		t.Errorf("got %q, %d, %v", b.String(), n, err)
+	}
+	if _, err := b.InsertReader(4, strings.NewReader("x")); err == nil {
+		t.Error("expected error for an offset within a rune")
+	}
+	if _, err := b.InsertReader(20, strings.NewReader("x")); err == nil {
+		t.Error("expected error for invalid offset")
+	}
+}
+
+// runeModel is what a Reader does, a strings.Reader reading forward
+// and the same for reading in reverse.
+type runeModel struct {
+	s        string
+	sr       *strings.Reader
+	reverse  bool
+	lastSize int // of the rune read in reverse or -1
+}
+
+func (m *runeModel) off() int {
+	return int(m.sr.Size()) - m.sr.Len()
+}
+
+// seek seeks without changing lastSize.
+func (m *runeModel) seek(off int) {
+	m.sr.Seek(int64(off), io.SeekStart)
+}
+
+func (m *runeModel) ReadRune() (rune, int, error) {
+	if !m.reverse {
+		return m.sr.ReadRune()
+	}
+	m.lastSize = -1
+	off := m.off()
+	if off == 0 {
+		return 0, 0, io.EOF
+	}
+	r, size := utf8.DecodeLastRuneInString(m.s[:off])
+	m.seek(off - size)
+	m.lastSize = size
+	return r, size, nil
+}
+
+func (m *runeModel) UnreadRune() error {
+	if !m.reverse {
+		return m.sr.UnreadRune()
+	}
+	if m.lastSize < 0 {
+		return ErrInvalidUnreadRune
+	}
+	m.seek(m.off() + m.lastSize)
+	m.lastSize = -1
+	return nil
+}
+
+func (m *runeModel) Read(dst []byte) (int, error) {
+	if !m.reverse {
+		n, err := m.sr.Read(dst)
+		// unlike a Reader strings.Reader allows UnreadRune after a
+		// Read at the end
+		m.seek(m.off())
+		return n, err
+	}
+	off := m.off()
+	m.seek(off) // invalidates UnreadRune
+	m.lastSize = -1
+	if off == 0 && len(dst) > 0 {
+		return 0, io.EOF
+	}
+	n := len(dst)
+	if n > off {
+		n = off
+	}
+	copy(dst, m.s[off-n:off])
+	m.seek(off - n)
+	return n, nil
+}
+
+func (m *runeModel) Seek(off int) {
+	m.seek(off)
+	m.lastSize = -1
+}
+
+func (m *runeModel) Reverse() {
+	m.reverse = !m.reverse
+	m.Seek(m.off())
+}
+
+// TestRuneScanner checks a Reader mixing Read, ReadRune, UnreadRune,
+// Seek and Reverse against a runeModel on the content of the buffer.
+func TestRuneScanner(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	texts := []string{"a", "ä", "€", "😀", "bc\n", "\xff", "\x80", "d€f"}
+	for round := 0; round < 50; round++ {
+		var b Buf
+		b.Init()
+		ref := ""
+		// many small pieces splitting runes
+		for i := 0; i < 30; i++ {
+			s := texts[rnd.Intn(len(texts))]
+			off := rnd.Intn(len(ref) + 1)
+			b.Insert(off, []byte(s))
+			ref = ref[:off] + s + ref[off:]
+		}
+		start := rnd.Intn(len(ref) + 1)
+		rd := b.NewReader(start)
+		m := &runeModel{s: ref, sr: strings.NewReader(ref), lastSize: -1}
+		m.seek(start)
+		var ops []string
+		for i := 0; i < 200; i++ {
+			var got, want string
+			switch rnd.Intn(6) {
+			case 0, 1:
+				ops = append(ops, "ReadRune")
+				r1, n1, err1 := rd.ReadRune()
+				r2, n2, err2 := m.ReadRune()
+				got, want = fmt.Sprint(r1, n1, err1), fmt.Sprint(r2, n2, err2)
+			case 2:
+				ops = append(ops, "UnreadRune")
+				got, want = fmt.Sprint(rd.UnreadRune() == nil), fmt.Sprint(m.UnreadRune() == nil)
+			case 3:
+				n := rnd.Intn(6)
+				ops = append(ops, fmt.Sprintf("Read(%d)", n))
+				dst1, dst2 := make([]byte, n), make([]byte, n)
+				n1, err1 := rd.Read(dst1)
+				n2, err2 := m.Read(dst2)
+				got, want = fmt.Sprintf("%q %v", dst1[:n1], err1), fmt.Sprintf("%q %v", dst2[:n2], err2)
+			case 4:
+				off := rnd.Intn(len(ref) + 1)
+				ops = append(ops, fmt.Sprintf("Seek(%d)", off))
+				rd.Seek(int64(off), io.SeekStart)
+				m.Seek(off)
+			case 5:
+				ops = append(ops, "Reverse")
+				rd.Reverse()
+				m.Reverse()
+			}
+			if got != want || rd.Offset() != m.off() {
+				t.Fatalf("%q from %d after %v: got %s at %d, want %s at %d",
+					ref, start, ops, got, rd.Offset(), want, m.off())
+			}
+		}
+	}
+}
+
+func TestUnreadRuneInvalid(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.Insert(0, []byte("ab"))
+	rd := b.NewReader(1)
+	tests := []struct {
+		name string
+		op   func()
+	}{
+		{"new reader", func() {}},
+		{"Read", func() { rd.ReadRune(); rd.Read(make([]byte, 1)) }},
+		{"Read at the end", func() { rd.Seek(1, io.SeekStart); rd.ReadRune(); rd.Read(make([]byte, 4)) }},
+		{"Reverse", func() { rd.ReadRune(); rd.Reverse() }},
+		{"Seek", func() { rd.ReadRune(); rd.Seek(0, io.SeekStart) }},
+		{"UnreadRune", func() { rd.ReadRune(); rd.UnreadRune() }},
+		{"ReadRune at EOF", func() { rd.Seek(1, io.SeekStart); rd.ReadRune(); rd.ReadRune() }},
+	}
+	for _, test := range tests {
+		test.op()
+		off := rd.Offset()
+		if err := rd.UnreadRune(); err != ErrInvalidUnreadRune || rd.Offset() != off {
+			t.Errorf("UnreadRune after %s: got %v at %d, want %v at %d", test.name, err, rd.Offset(), ErrInvalidUnreadRune, off)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	var b Buf
+	b.Init()
+	if st := b.Stats(); st != (Stats{}) {
+		t.Errorf("empty buffer: %+v", st)
+	}
+	b.Insert(0, []byte("hello"))
+	b.Insert(5, []byte(" world"))
+	b.Insert(5, []byte(","))
+	b.Delete(0, 1)
+	b.AddObserver(&positionObserver{})
+	st := b.Stats()
+	want := Stats{Pieces: 3, Backing: blockSize, Wasted: blockSize - 11, Log: 4, LogBytes: 13, Observers: 1}
+	if st != want {
+		t.Errorf("got %+v, want %+v", st, want)
+	}
+}
+
+// benchText is a line of text like in a source file.
+var benchText = []byte("\tif err := b.TryInsert(off, text); err != nil {\n")
+
+// BenchmarkTyping inserts one character after the other at a cursor
+// moving to another line every now and then.
+func BenchmarkTyping(bench *testing.B) {
+	var b Buf
+	b.Init()
+	for i := 0; i < 1000; i++ {
+		b.Insert(b.Len(), benchText)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	off := 0
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		if i%50 == 0 {
+			off = b.Line(1 + rnd.Intn(b.Lines()))
+		}
+		b.Insert(off, benchText[i%len(benchText):i%len(benchText)+1])
+		off++
+	}
+	bench.ReportMetric(float64(b.Stats().Pieces), "pieces")
+}
+
+// BenchmarkRandomEdits inserts and deletes text at random offsets.
+func BenchmarkRandomEdits(bench *testing.B) {
+	var b Buf
+	b.Init()
+	for i := 0; i < 1000; i++ {
+		b.Insert(b.Len(), benchText)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		off := rnd.Intn(b.Len())
+		if i%2 == 0 {
+			b.Insert(off, benchText[:1+rnd.Intn(len(benchText)-1)])
+		} else {
+			end := off + rnd.Intn(len(benchText))
+			if end > b.Len() {
+				end = b.Len()
+			}
+			b.Delete(off, end)
+		}
+	}
+	bench.ReportMetric(float64(b.Stats().Pieces), "pieces")
+}
+
+// fragmented returns a buffer of about 1MB split into many pieces.
+func fragmented() *Buf {
+	var b Buf
+	b.Init()
+	rnd := rand.New(rand.NewSource(1))
+	for b.Len() < 1<<20 {
+		b.Insert(rnd.Intn(b.Len()+1), benchText)
+	}
+	return &b
+}
+
+// BenchmarkRead reads a fragmented buffer in 4KB chunks.
+func BenchmarkRead(bench *testing.B) {
+	b := fragmented()
+	dst := make([]byte, 4096)
+	bench.SetBytes(int64(b.Len()))
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		rd := b.NewReader(0)
+		for {
+			if _, err := rd.Read(dst); err == io.EOF {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkReadRune reads a fragmented buffer rune by rune.
+func BenchmarkReadRune(bench *testing.B) {
+	b := fragmented()
+	bench.SetBytes(int64(b.Len()))
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		rd := b.NewReader(0)
+		for {
+			if _, _, err := rd.ReadRune(); err == io.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	var b Buf
+	b.Init()
+	ref := ""
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		off := rnd.Intn(len(ref) + 1)
+		if i%2 == 1 {
+			end := off + rnd.Intn(len(ref)-off+1)
+			b.Delete(off, end)
+			ref = ref[:off] + ref[end:]
+		} else {
+			b.Insert(off, []byte("pasted\ntext "))
+			ref = ref[:off] + "pasted\ntext " + ref[off:]
+		}
+	}
+	m := b.NewMarker(len(ref) / 2)
+	seq := b.Seq()
+	b.Vacuum()
+	if b.String() != ref || b.Lines() != strings.Count(ref, "\n")+1 || m.Offset() != len(ref)/2 {
+		t.Fatalf("after Vacuum got %q, %d lines", b.String(), b.Lines())
+	}
+	for n := 1; n <= b.Lines(); n++ {
+		if exp := len(strings.Join(strings.Split(ref, "\n")[:n-1], "\n")) + 1; n > 1 && b.Line(n) != exp {
+			t.Errorf("Line(%d) = %d, want %d", n, b.Line(n), exp)
+		}
+	}
+	if st := b.Stats(); st.Pieces != 1 || st.Wasted != 0 || st.Log != 0 {
+		t.Errorf("after Vacuum got %+v", st)
+	}
+	if b.Changes(seq-1) != nil || b.Seq() != seq {
+		t.Errorf("Changes from before Vacuum")
+	}
+	b.Insert(0, []byte("x"))
+	if edits := b.Changes(seq); len(edits) != 1 || edits[0].Seq != seq+1 {
+		t.Errorf("Changes after Vacuum: %v", edits)
+	}
+}
+
+func TestVacuumKeepsFileText(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(name, []byte("hello\nworld\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var b Buf
+	if err := b.InitFromFile(name); err != nil {
+		t.Fatal(err)
+	}
+	b.Insert(6, []byte("big "))
+	b.Insert(6, []byte("new "))
+	b.Insert(0, []byte("oh "))
+	b.Delete(3, 5)
+	b.Vacuum()
+	// the file text is split around "new big " and after "oh "
+	if b.String() != "oh llo\nnew big world\n" {
+		t.Errorf("got %q", b.String())
+	}
+	if st := b.Stats(); st.Pieces != 4 || st.Backing != 12+11 || st.Wasted != 2 {
+		t.Errorf("got %+v", st)
+	}
+}
+
+func TestAutoVacuum(t *testing.T) {
+	var b Buf
+	b.Init()
+	b.SetAutoVacuum(1)
+	text := bytes.Repeat([]byte("a"), blockSize/2)
+	b.Insert(0, text)
+	b.Insert(0, text)
+	b.Delete(0, 10)
+	if st := b.Stats(); st.Log != 3 {
+		t.Errorf("vacuumed too early: %+v", st)
+	}
+	b.Insert(0, text)
+	b.Insert(0, text)
+	b.Delete(0, b.Len()-5)
+	if st := b.Stats(); st.Log != 0 || st.Backing != b.Len() {
+		t.Errorf("not vacuumed: %+v", st)
+	}
+}
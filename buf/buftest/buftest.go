@@ -0,0 +1,267 @@
+// Package buftest checks buf.Buf against a reference implementation, a
+// plain slice of bytes, by applying the same edits to both and comparing
+// what they answer: the content, Len, Lines, Line(n), LineOfOffset and
+// what readers on them read.  The edits come from a random number
+// generator (Random) or from the input of a fuzzer (Decode), a failing
+// sequence of edits can be made shorter with Shrink.
+//
+// The tests of the package run the comparison on random edits, and
+// with go test -fuzz=FuzzBuf on the edits a fuzzer comes up with.
+package buftest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// A Ref is the reference implementation of a buffer.
+type Ref struct {
+	text []byte
+}
+
+// Insert inserts s at off.
+func (r *Ref) Insert(off int, s []byte) {
+	r.text = append(r.text[:off], append(append([]byte{}, s...), r.text[off:]...)...)
+}
+
+// Delete deletes the bytes from off1 to off2.
+func (r *Ref) Delete(off1, off2 int) {
+	r.text = append(r.text[:off1], r.text[off2:]...)
+}
+
+// Len returns the length in bytes.
+func (r *Ref) Len() int {
+	return len(r.text)
+}
+
+func (r *Ref) String() string {
+	return string(r.text)
+}
+
+// Lines returns the number of lines, like Buf.Lines.
+func (r *Ref) Lines() int {
+	return bytes.Count(r.text, []byte{'\n'}) + 1
+}
+
+// Line returns the offset of line n, like Buf.Line.
+func (r *Ref) Line(n int) int {
+	off := 0
+	for line := 1; line < n; line++ {
+		i := bytes.IndexByte(r.text[off:], '\n')
+		if i < 0 {
+			break
+		}
+		off += i + 1
+	}
+	return off
+}
+
+// An Op is an edit, the insertion of Text at Off1 if Text is not empty
+// and the deletion of the bytes from Off1 to Off2 otherwise.  The
+// offsets are clamped to the length of the buffer the op applies to, so
+// that any sequence of ops is valid.
+type Op struct {
+	Off1, Off2 int
+	Text       string
+}
+
+func (op Op) String() string {
+	if op.Text != "" {
+		return fmt.Sprintf("Insert(%d, %q)", op.Off1, op.Text)
+	}
+	return fmt.Sprintf("Delete(%d, %d)", op.Off1, op.Off2)
+}
+
+// Apply applies op to b and r, which must have the same length.
+func (op Op) Apply(b *buf.Buf, r *Ref) {
+	clamp := func(off int) int {
+		if off > r.Len() {
+			return r.Len()
+		}
+		return off
+	}
+	off1, off2 := clamp(op.Off1), clamp(op.Off2)
+	if op.Text != "" {
+		b.Insert(off1, []byte(op.Text))
+		r.Insert(off1, []byte(op.Text))
+		return
+	}
+	if off1 > off2 {
+		off1, off2 = off2, off1
+	}
+	b.Delete(off1, off2)
+	r.Delete(off1, off2)
+}
+
+// texts are the texts inserted, including line breaks, multi byte runes
+// and invalid UTF-8, as deletions may split runes anyway.
+var texts = []string{"a", "bc\n", "\n", "def", "ä€", "g\nh\n", "😀", "\xff", "\r\n"}
+
+// Random returns n random ops.
+func Random(rnd *rand.Rand, n int) []Op {
+	ops := make([]Op, n)
+	size := 0 // about the length of the buffer, to keep the offsets in range
+	for i := range ops {
+		off1 := rnd.Intn(size + 1)
+		if size > 0 && rnd.Intn(3) == 0 {
+			off2 := off1 + rnd.Intn(size-off1+1)
+			ops[i] = Op{Off1: off1, Off2: off2}
+			size -= off2 - off1
+		} else {
+			ops[i] = Op{Off1: off1, Text: texts[rnd.Intn(len(texts))]}
+			size += len(ops[i].Text)
+		}
+	}
+	return ops
+}
+
+// Decode turns arbitrary data, e.g. the input of a fuzzer, into ops:
+// every three bytes are an op, the first choosing the kind and the text,
+// the others the offsets.
+func Decode(data []byte) []Op {
+	var ops []Op
+	for ; len(data) >= 3; data = data[3:] {
+		op := Op{Off1: int(data[1]), Off2: int(data[2])}
+		if data[0]%4 != 0 {
+			op.Text = texts[int(data[0])%len(texts)]
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// Check returns an error describing the first difference between b and
+// r.
+func Check(b *buf.Buf, r *Ref) error {
+	if b.Len() != r.Len() {
+		return fmt.Errorf("Len() = %d, want %d", b.Len(), r.Len())
+	}
+	if got, want := b.String(), r.String(); got != want {
+		return fmt.Errorf("String() = %q, want %q", got, want)
+	}
+	if b.Lines() != r.Lines() {
+		return fmt.Errorf("Lines() = %d, want %d", b.Lines(), r.Lines())
+	}
+	for n := 0; n <= r.Lines()+1; n++ {
+		if got, want := b.Line(n), r.Line(n); got != want {
+			return fmt.Errorf("Line(%d) = %d, want %d", n, got, want)
+		}
+	}
+	for off := 0; off <= r.Len(); off++ {
+		if got, want := b.LineOfOffset(off), bytes.Count(r.text[:off], []byte{'\n'})+1; got != want {
+			return fmt.Errorf("LineOfOffset(%d) = %d, want %d", off, got, want)
+		}
+	}
+	for _, off := range []int{0, r.Len() / 2, r.Len()} {
+		if err := checkReaders(b, r, off); err != nil {
+			return fmt.Errorf("reader at %d: %v", off, err)
+		}
+	}
+	return nil
+}
+
+// checkReaders compares reading b from off with Read, ReadRune in both
+// directions and ReadLine with reading r.
+func checkReaders(b *buf.Buf, r *Ref, off int) error {
+	got, err := io.ReadAll(b.NewReader(off))
+	if err != nil {
+		return err
+	}
+	if want := r.text[off:]; !bytes.Equal(got, want) {
+		return fmt.Errorf("Read: %q, want %q", got, want)
+	}
+
+	rd := b.NewReader(off)
+	for i := off; ; {
+		ch, size, err := rd.ReadRune()
+		if i == r.Len() {
+			if err != io.EOF {
+				return fmt.Errorf("ReadRune at %d: %q, %v, want EOF", i, ch, err)
+			}
+			break
+		}
+		want, wantSize := utf8.DecodeRune(r.text[i:])
+		if ch != want || size != wantSize || err != nil {
+			return fmt.Errorf("ReadRune at %d: %q, %d, %v, want %q, %d", i, ch, size, err, want, wantSize)
+		}
+		i += size
+	}
+
+	rd = b.NewReader(off)
+	rd.Reverse()
+	for i := off; ; {
+		ch, size, err := rd.ReadRune()
+		if i == 0 {
+			if err != io.EOF {
+				return fmt.Errorf("reverse ReadRune at %d: %q, %v, want EOF", i, ch, err)
+			}
+			break
+		}
+		want, wantSize := utf8.DecodeLastRune(r.text[:i])
+		if ch != want || size != wantSize || err != nil {
+			return fmt.Errorf("reverse ReadRune at %d: %q, %d, %v, want %q, %d", i, ch, size, err, want, wantSize)
+		}
+		i -= size
+	}
+
+	rd = b.NewReader(off)
+	var lines []string
+	for {
+		line, err := rd.ReadLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		lines = append(lines, string(line))
+	}
+	var want []string
+	if rest := string(r.text[off:]); rest != "" {
+		want = strings.SplitAfter(rest, "\n")
+		if want[len(want)-1] == "" {
+			want = want[:len(want)-1]
+		}
+		for i := range want {
+			want[i] = strings.TrimSuffix(want[i], "\n")
+		}
+	}
+	if fmt.Sprintf("%q", lines) != fmt.Sprintf("%q", want) {
+		return fmt.Errorf("ReadLine: %q, want %q", lines, want)
+	}
+	return nil
+}
+
+// Run applies ops to a new buffer and a new reference, checking them
+// after each op.
+func Run(ops []Op) error {
+	var b buf.Buf
+	b.Init()
+	var r Ref
+	for i, op := range ops {
+		op.Apply(&b, &r)
+		if err := Check(&b, &r); err != nil {
+			return fmt.Errorf("after %v: %v", ops[:i+1], err)
+		}
+	}
+	return nil
+}
+
+// Shrink returns a shorter sequence of ops that still fails, by leaving
+// out the ops that fails doesn't need, e.g. with
+//
+//	Shrink(ops, func(ops []Op) bool { return Run(ops) != nil })
+func Shrink(ops []Op, fails func([]Op) bool) []Op {
+	for i := len(ops) - 1; i >= 0; i-- {
+		shorter := append(append([]Op{}, ops[:i]...), ops[i+1:]...)
+		if fails(shorter) {
+			ops = shorter
+		}
+	}
+	return ops
+}
@@ -0,0 +1,57 @@
+package buftest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestRandom(t *testing.T) {
+	for seed := int64(1); seed <= 100; seed++ {
+		ops := Random(rand.New(rand.NewSource(seed)), 100)
+		if err := Run(ops); err != nil {
+			t.Fatalf("seed %d: %v\nshortest failing: %v", seed, err, Shrink(ops, fails))
+		}
+	}
+}
+
+func fails(ops []Op) bool {
+	return Run(ops) != nil
+}
+
+func TestShrink(t *testing.T) {
+	// "fails" if the text ends up with two lines
+	twoLines := func(ops []Op) bool {
+		var b buf.Buf
+		b.Init()
+		var r Ref
+		for _, op := range ops {
+			op.Apply(&b, &r)
+		}
+		return r.Lines() == 2
+	}
+	ops := []Op{{Text: "a"}, {Off1: 1, Text: "bc\n"}, {Text: "def"}, {Off1: 0, Off2: 2}}
+	got := Shrink(ops, twoLines)
+	if len(got) != 1 || got[0] != ops[1] {
+		t.Errorf("Shrink = %v, want [%v]", got, ops[1])
+	}
+}
+
+func TestDecode(t *testing.T) {
+	ops := Decode([]byte{0, 3, 1, 1, 2, 0, 2})
+	want := []Op{{Off1: 3, Off2: 1}, {Off1: 2, Text: texts[1]}}
+	if len(ops) != len(want) || ops[0] != want[0] || ops[1] != want[1] {
+		t.Errorf("Decode = %v, want %v", ops, want)
+	}
+}
+
+func FuzzBuf(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 2, 1, 0, 0, 0, 2})
+	f.Add([]byte{4, 0, 0, 5, 1, 0, 6, 2, 0, 0, 1, 5, 0, 3, 3})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := Run(Decode(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package buf
+
+import "time"
+
+// EditKind tells whether an Edit inserted or deleted text.
+type EditKind int
+
+const (
+	Insertion EditKind = iota
+	Deletion
+)
+
+func (k EditKind) String() string {
+	if k == Deletion {
+		return "delete"
+	}
+	return "insert"
+}
+
+// An Edit is an entry of the change log of a buffer.
+type Edit struct {
+	Kind EditKind
+	Off  int
+	Text []byte    // the inserted or deleted text, must not be modified
+	Seq  int       // Seq of the buffer after the edit
+	Time time.Time // when the edit was made
+}
+
+// record adds an edit to the change log.  Called after seq was
+// incremented.
+func (b *Buf) record(kind EditKind, off int, text []byte) {
+	b.log = append(b.log, Edit{Kind: kind, Off: off, Text: text, Seq: b.seq, Time: time.Now()})
+}
+
+// Changes returns the edits made after the buffer's Seq was since,
+// oldest first.  Replaying them in order onto the buffer as it was at
+// since gives its current contents.  Returns nil if the log doesn't go
+// back that far since a Vacuum.
+func (b *Buf) Changes(since int) []Edit {
+	if since < 0 {
+		since = 0
+	}
+	if since < b.logStart || since >= b.seq {
+		return nil
+	}
+	// every change increments seq by one, so the edit with Seq n is
+	// log[n-1-logStart]
+	return b.log[since-b.logStart : len(b.log) : len(b.log)]
+}
@@ -0,0 +1,222 @@
+package buf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a file.  Buffers always hold
+// utf-8, files in other encodings are converted when they are loaded
+// and the encoding is applied again when the buffer is written.
+type Encoding int
+
+const (
+	UTF8    Encoding = iota
+	UTF8BOM          // utf-8 starting with a byte order mark
+	Latin1
+	UTF16LE // little endian with byte order mark
+	UTF16BE // big endian with byte order mark
+)
+
+var encodingNames = map[Encoding]string{
+	UTF8:    "utf-8",
+	UTF8BOM: "utf-8-bom",
+	Latin1:  "latin1",
+	UTF16LE: "utf-16le",
+	UTF16BE: "utf-16be",
+}
+
+func (e Encoding) String() string {
+	return encodingNames[e]
+}
+
+// ParseEncoding returns the encoding called name.
+func ParseEncoding(name string) (Encoding, error) {
+	switch name {
+	case "utf8":
+		return UTF8, nil
+	case "iso-8859-1", "latin-1":
+		return Latin1, nil
+	case "utf-16":
+		return UTF16BE, nil
+	}
+	for e, n := range encodingNames {
+		if n == name {
+			return e, nil
+		}
+	}
+	return UTF8, fmt.Errorf("Unknown encoding %s", name)
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// DetectEncoding returns the encoding of data: given by its byte order
+// mark if there is one, otherwise utf-8 if data is valid utf-8 and
+// Latin-1 if not.
+func DetectEncoding(data []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8BOM
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BE
+	case utf8.Valid(data):
+		return UTF8
+	}
+	return Latin1
+}
+
+// Decode detects the encoding of data and returns data converted to
+// utf-8.  utf-8 data is returned as is.
+func Decode(data []byte) ([]byte, Encoding, error) {
+	enc := DetectEncoding(data)
+	switch enc {
+	case UTF8BOM:
+		return data[len(bomUTF8):], enc, nil
+	case Latin1:
+		text := make([]byte, 0, len(data)+len(data)/8)
+		for _, c := range data {
+			text = utf8.AppendRune(text, rune(c))
+		}
+		return text, enc, nil
+	case UTF16LE, UTF16BE:
+		data = data[2:]
+		if len(data)%2 != 0 {
+			return nil, enc, errors.New("Invalid utf-16: odd number of bytes")
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if enc == UTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		text := make([]byte, 0, len(data))
+		for _, r := range utf16.Decode(units) {
+			text = utf8.AppendRune(text, r)
+		}
+		return text, enc, nil
+	}
+	return data, enc, nil
+}
+
+// Encoding returns the encoding used when writing the buffer to a file.
+func (b *Buf) Encoding() Encoding {
+	return b.encoding
+}
+
+// SetEncoding sets the encoding used when writing the buffer to a file.
+func (b *Buf) SetEncoding(e Encoding) {
+	b.encoding = e
+}
+
+// CheckEncoding returns the error WriteFile would fail with as some of
+// the text can't be converted to the buffer's encoding, without writing
+// anything.
+func (b *Buf) CheckEncoding() error {
+	w, err := newEncodingWriter(io.Discard, b.encoding)
+	if err != nil {
+		return err
+	}
+	ew, ok := w.(*encodingWriter)
+	if !ok {
+		return nil
+	}
+	if _, err := b.WriteTo(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// encodingWriter converts the utf-8 written to it to an encoding.
+type encodingWriter struct {
+	w       io.Writer
+	enc     Encoding
+	partial []byte // start of a rune split across calls to Write
+	out     []byte
+}
+
+// newEncodingWriter returns a writer converting to enc, which starts
+// by writing the byte order mark of enc.  Returns w if there is nothing
+// to convert.
+func newEncodingWriter(w io.Writer, enc Encoding) (io.Writer, error) {
+	var bom []byte
+	switch enc {
+	case UTF8:
+		return w, nil
+	case UTF8BOM:
+		bom = bomUTF8
+	case UTF16LE:
+		bom = bomUTF16LE
+	case UTF16BE:
+		bom = bomUTF16BE
+	}
+	if _, err := w.Write(bom); err != nil {
+		return nil, err
+	}
+	if enc == UTF8BOM {
+		return w, nil
+	}
+	return &encodingWriter{w: w, enc: enc}, nil
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+	text := p
+	if len(ew.partial) > 0 {
+		text = append(ew.partial, p...)
+	}
+	ew.out = ew.out[:0]
+	for len(text) > 0 {
+		if !utf8.FullRune(text) {
+			break
+		}
+		r, size := utf8.DecodeRune(text)
+		if r == utf8.RuneError && size == 1 {
+			return 0, fmt.Errorf("Can't convert invalid utf-8 to %s", ew.enc)
+		}
+		switch ew.enc {
+		case Latin1:
+			if r > 0xff {
+				return 0, fmt.Errorf("Can't convert %q to %s", r, ew.enc)
+			}
+			ew.out = append(ew.out, byte(r))
+		case UTF16LE, UTF16BE:
+			units := []uint16{uint16(r)}
+			if r >= 0x10000 {
+				r1, r2 := utf16.EncodeRune(r)
+				units = []uint16{uint16(r1), uint16(r2)}
+			}
+			for _, u := range units {
+				if ew.enc == UTF16LE {
+					ew.out = append(ew.out, byte(u), byte(u>>8))
+				} else {
+					ew.out = append(ew.out, byte(u>>8), byte(u))
+				}
+			}
+		}
+		text = text[size:]
+	}
+	ew.partial = append(ew.partial[:0], text...)
+	if _, err := ew.w.Write(ew.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close reports a rune cut short at the end of the text.
+func (ew *encodingWriter) Close() error {
+	if len(ew.partial) > 0 {
+		return fmt.Errorf("Can't convert invalid utf-8 to %s", ew.enc)
+	}
+	return nil
+}
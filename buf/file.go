@@ -0,0 +1,42 @@
+package buf
+
+import (
+	"os"
+)
+
+// InitFromFile initializes b with the contents of the file filename and
+// names it after the file.  The file is read into memory of its own,
+// which the buffer refers to as its original text instead of copying it
+// into the store.  Only files using dos line endings or an encoding other
+// than utf-8 are copied once more, to convert them (see Decode).
+//
+// The file is read rather than memory mapped, as a mapping would change
+// with the file and fault once another program truncated it.  Files
+// that aren't regular, like pipes or those in /proc, are read up to EOF
+// whatever size they claim.
+func (b *Buf) InitFromFile(filename string) error {
+	b.Init()
+	b.SetName(filename)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	data, enc, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	b.SetEncoding(enc)
+	data, le := NormalizeLineEndings(data)
+	b.SetLineEnding(le)
+	// The original text is used as is instead of copying it into the store.
+	p := newPiece(data)
+	p.orig = true
+	b.insertPiece(p, 0)
+	b.len = p.len()
+	b.original = p.len()
+	b.newlines = p.nl
+	return nil
+}
@@ -0,0 +1,27 @@
+package buf
+
+import (
+	"crypto/sha256"
+	"io"
+)
+
+// Hash returns the SHA-256 hash of the contents of the buffer.  The
+// pieces are hashed where they are, without copying them together.
+func (b *Buf) Hash() [sha256.Size]byte {
+	return hash(b)
+}
+
+// Hash returns the SHA-256 hash of the contents of the snapshot, the
+// same as Buf.Hash returned when it was taken.  Unlike that it may be
+// called from any goroutine.
+func (s *Snapshot) Hash() [sha256.Size]byte {
+	return hash(s)
+}
+
+func hash(wt io.WriterTo) [sha256.Size]byte {
+	h := sha256.New()
+	wt.WriteTo(h)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
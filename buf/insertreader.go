@@ -0,0 +1,34 @@
+package buf
+
+import (
+	"fmt"
+	"io"
+)
+
+// InsertReader inserts the text read from r up to EOF at off and
+// returns its length.  The text is read straight into the store, where
+// it becomes a single piece, instead of being collected in a slice and
+// copied.  If reading fails, the text read so far is inserted and the
+// error returned.
+func (b *Buf) InsertReader(off int, r io.Reader) (int, error) {
+	if off < 0 || off > b.len {
+		return 0, fmt.Errorf("InsertReader: invalid offset %v valid:0-%v", off, b.len)
+	}
+	if _, err := b.validate(off, off, nil); err != nil {
+		return 0, err
+	}
+	size := -1
+	if l, ok := r.(interface{ Len() int }); ok {
+		size = l.Len()
+	}
+	text, err := b.store.readFrom(r, size)
+	if len(text) == 0 {
+		return 0, err
+	}
+	if valid, _ := b.validate(off, off, text); &valid[0] != &text[0] {
+		// invalid utf-8 was replaced in a copy
+		text = b.store.append(valid)
+	}
+	b.insertStored(off, text)
+	return len(text), err
+}
@@ -0,0 +1,99 @@
+package buf
+
+import (
+	"bytes"
+	"io"
+)
+
+// LineEnding is the representation of line breaks in a file.  Buffers
+// always use '\n' internally, the line ending is applied when the
+// buffer is written to a file.
+type LineEnding int
+
+const (
+	LF   LineEnding = iota // unix: \n
+	CRLF                   // dos: \r\n
+)
+
+func (le LineEnding) String() string {
+	if le == CRLF {
+		return "dos"
+	}
+	return "unix"
+}
+
+var crlf = []byte("\r\n")
+
+// DetectLineEnding returns the line ending used by most lines in data.
+func DetectLineEnding(data []byte) LineEnding {
+	dos := bytes.Count(data, crlf)
+	unix := bytes.Count(data, newline) - dos
+	if dos > unix {
+		return CRLF
+	}
+	return LF
+}
+
+// NormalizeLineEndings detects the line ending of data and returns
+// it together with data converted to use '\n' only.
+func NormalizeLineEndings(data []byte) ([]byte, LineEnding) {
+	le := DetectLineEnding(data)
+	if le == CRLF {
+		data = bytes.Replace(data, crlf, newline, -1)
+	}
+	return data, le
+}
+
+// LineEnding returns the line ending used when writing the buffer to a file.
+func (b *Buf) LineEnding() LineEnding {
+	return b.lineEnding
+}
+
+// SetLineEnding sets the line ending used when writing the buffer to a file.
+func (b *Buf) SetLineEnding(le LineEnding) {
+	b.lineEnding = le
+}
+
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (cw crlfWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			n, err := cw.w.Write(p)
+			return written + n, err
+		}
+		if n, err := cw.w.Write(p[:i]); err != nil {
+			return written + n, err
+		}
+		if _, err := cw.w.Write(crlf); err != nil {
+			return written + i, err
+		}
+		written += i + 1
+		p = p[i+1:]
+	}
+	return written, nil
+}
+
+// WriteFile writes the contents of the buffer to w converting line
+// breaks to the buffer's line ending and the text to its encoding.
+func (b *Buf) WriteFile(w io.Writer) error {
+	w, err := newEncodingWriter(w, b.encoding)
+	if err != nil {
+		return err
+	}
+	ew, _ := w.(*encodingWriter)
+	if b.lineEnding == CRLF {
+		w = crlfWriter{w}
+	}
+	if _, err := b.WriteTo(w); err != nil {
+		return err
+	}
+	if ew != nil {
+		return ew.Close()
+	}
+	return nil
+}
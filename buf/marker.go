@@ -1,52 +1,193 @@
 package buf
 
+import (
+	"fmt"
+	"sort"
+)
+
 // A Marker represents a position in a buffer relative to its surrounding text.
 // A marker changes its offset from the beginning of the buffer automatically
 // whenever text is inserted or deleted, so that it stays with the two characters on
-// either side of it.
+// either side of it.  If the text around a marker is deleted, the marker moves to
+// the start of the deletion.
 type Marker interface {
 	Offset() int
 	// Move the Marker to the given offset.  Panics if the given offset is invalid.
-	Move(int) 
-} 
+	Move(int)
+	// Valid returns false once the marker has been closed.
+	Valid() bool
+	// Close stops the marker from tracking changes of the buffer.
+	Close()
+	// Gravity returns where the marker goes on inserts at its offset.
+	Gravity() Gravity
+}
+
+// Gravity decides where a marker goes when text is inserted at its offset.
+type Gravity int
+
+const (
+	// GravityRight markers stay behind the inserted text (the default).
+	GravityRight Gravity = iota
+	// GravityLeft markers stay in front of the inserted text.
+	GravityLeft
+)
 
 type marker struct {
-	buf *Buf
-	off int
-	id int
-} 
+	buf     *Buf
+	off     int
+	gravity Gravity
+	closed  bool
+}
 
-// Return a new marker at off.  
+// Return a new marker at off.  Text inserted at off is inserted in
+// front of it (see GravityRight).
 func (buf *Buf) NewMarker(off int) Marker {
-	m := &marker {
-		buf: buf,
-		off: off,
-	} 
-	m.id = buf.AddObserver(m)
+	return buf.NewMarkerWithGravity(off, GravityRight)
+}
+
+// NewMarkerWithGravity returns a new marker at off with the given gravity.
+// Panics if off is invalid.
+func (buf *Buf) NewMarkerWithGravity(off int, g Gravity) Marker {
+	if off < 0 || off > buf.len {
+		panic(fmt.Sprintf("NewMarker: invalid offset %v valid:0-%v", off, buf.len))
+	}
+	m := &marker{
+		buf:     buf,
+		off:     off,
+		gravity: g,
+	}
+	buf.markers.add(m)
 	return m
-} 
+}
 
 func (m *marker) Offset() int {
 	return m.off
 }
 
 func (m *marker) Move(off int) {
-	// FIXME: panic if offset is invalid.  Or maybe something else
+	if off < 0 || off > m.buf.len {
+		panic(fmt.Sprintf("Move: invalid offset %v valid:0-%v", off, m.buf.len))
+	}
+	if m.closed {
+		m.off = off
+		return
+	}
+	m.buf.markers.remove(m)
 	m.off = off
+	m.buf.markers.add(m)
 }
 
-func (m *marker) OnBufInsert(off int, bytes []byte) {
-	if off <= m.off {
-		m.off += len(bytes)
-	} 
-} 
+func (m *marker) Gravity() Gravity {
+	return m.gravity
+}
+
+func (m *marker) Valid() bool {
+	return !m.closed
+}
 
-func (m *marker) OnBufDelete(off1, off2 int) {
-	// TODO: think about what should happen if
-	// m.off between off1 and off2
-	if off2 <= m.off {
-		m.off -= off2 - off1
-	} 
-} 
+func (m *marker) Close() {
+	if !m.closed {
+		m.buf.markers.remove(m)
+		m.closed = true
+	}
+}
 
+// markers are the markers of a buffer sorted by offset.  Markers are
+// not BufferObservers, instead the buffer updates all of them in one
+// pass that skips the markers in front of the change.  So an edit
+// costs no more than a couple of instructions per marker behind it.
+type markers []*marker
 
+// search returns the index of the first marker at or behind off.
+func (ms markers) search(off int) int {
+	return sort.Search(len(ms), func(i int) bool { return ms[i].off >= off })
+}
+
+func (ms *markers) add(m *marker) {
+	i := ms.search(m.off)
+	*ms = append(*ms, nil)
+	copy((*ms)[i+1:], (*ms)[i:])
+	(*ms)[i] = m
+}
+
+func (ms *markers) remove(m *marker) {
+	for i := ms.search(m.off); i < len(*ms); i++ {
+		if (*ms)[i] == m {
+			*ms = append((*ms)[:i], (*ms)[i+1:]...)
+			return
+		}
+	}
+}
+
+// insert updates the markers for n bytes inserted at off.
+func (ms markers) insert(off, n int) {
+	i := ms.search(off)
+	k := i
+	for k < len(ms) && ms[k].off == off {
+		k++
+	}
+	// of the markers at off the ones with left gravity stay in front
+	same := ms[i:k]
+	if len(same) > 1 {
+		sort.SliceStable(same, func(a, b int) bool {
+			return same[a].gravity == GravityLeft && same[b].gravity != GravityLeft
+		})
+	}
+	for _, m := range same {
+		if m.gravity == GravityRight {
+			m.off += n
+		}
+	}
+	for _, m := range ms[k:] {
+		m.off += n
+	}
+}
+
+// delete updates the markers for the bytes between off1 and off2 being
+// deleted.  Markers inside the deleted text move to off1.
+func (ms markers) delete(off1, off2 int) {
+	for _, m := range ms[ms.search(off1+1):] {
+		if m.off < off2 {
+			m.off = off1
+		} else {
+			m.off -= off2 - off1
+		}
+	}
+}
+
+// A Range is the text between two markers, e.g. a selection or a
+// folded region.
+type Range struct {
+	Start Marker
+	End   Marker
+}
+
+// NewRange returns a range covering off1 to off2.  Text inserted at the
+// start or end of the range is included or not depending on the gravity
+// of the markers: NewRange(off1, off2, GravityLeft, GravityRight) grows
+// when text is inserted at either end, GravityRight, GravityLeft doesn't.
+func (buf *Buf) NewRange(off1, off2 int, start, end Gravity) *Range {
+	if off1 > off2 {
+		panic(fmt.Sprintf("NewRange: invalid range %v-%v", off1, off2))
+	}
+	return &Range{
+		Start: buf.NewMarkerWithGravity(off1, start),
+		End:   buf.NewMarkerWithGravity(off2, end),
+	}
+}
+
+// Offsets returns the start and end offset of the range.
+func (r *Range) Offsets() (off1, off2 int) {
+	off1, off2 = r.Start.Offset(), r.End.Offset()
+	if off1 > off2 {
+		// text inserted into an empty range that doesn't grow
+		off1 = off2
+	}
+	return off1, off2
+}
+
+// Close closes both markers of the range.
+func (r *Range) Close() {
+	r.Start.Close()
+	r.End.Close()
+}
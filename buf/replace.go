@@ -0,0 +1,55 @@
+package buf
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A Replacement replaces the bytes between Off1 (inclusive) and Off2
+// (exclusive) by Text.
+type Replacement struct {
+	Off1, Off2 int
+	Text       []byte
+}
+
+// Replace replaces the bytes between off1 and off2 by text.
+func (b *Buf) Replace(off1, off2 int, text []byte) error {
+	return b.ReplaceAll([]Replacement{{off1, off2, text}})
+}
+
+// ReplaceAll makes the replacements rs, whose offsets refer to the
+// buffer before any of them is made.  They must not overlap.  They are
+// made starting with the last one, so that the offsets of the others
+// stay valid.  Nothing is changed if an offset is invalid, or splits a
+// rune in validating mode (see SetValidating).
+//
+// Each replacement is a Delete followed by an Insert, so the markers
+// inside or at the start of a replaced range end up behind the new text,
+// or in front of it if they have GravityLeft.  The other markers stay
+// with the text around them.
+func (b *Buf) ReplaceAll(rs []Replacement) error {
+	sorted := append([]Replacement(nil), rs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Off1 > sorted[j].Off1 })
+	end := b.len
+	for _, r := range sorted {
+		if r.Off1 < 0 || r.Off1 > r.Off2 || r.Off2 > end {
+			return fmt.Errorf("ReplaceAll: invalid or overlapping offsets %v-%v valid:0-%v", r.Off1, r.Off2, b.len)
+		}
+		end = r.Off1
+		// checked up front: the replacements made before r are behind it
+		// and start with a whole rune, so they don't change whether its
+		// offsets split one
+		if _, err := b.validate(r.Off1, r.Off2, nil); err != nil {
+			return err
+		}
+	}
+	for _, r := range sorted {
+		if err := b.TryDelete(r.Off1, r.Off2); err != nil {
+			return err
+		}
+		if err := b.TryInsert(r.Off1, r.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
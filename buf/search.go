@@ -0,0 +1,41 @@
+package buf
+
+import (
+	"regexp"
+)
+
+// SearchForward returns the start and end offset of the first match
+// of re starting at or after off.  Returns false if there is none.
+func (b *Buf) SearchForward(re *regexp.Regexp, off int) (start, end int, ok bool) {
+	loc := re.FindReaderIndex(b.NewReader(off))
+	if loc == nil {
+		return 0, 0, false
+	}
+	return off + loc[0], off + loc[1], true
+}
+
+// SearchBackward returns the start and end offset of the last match
+// of re starting before off.  Returns false if there is none.
+func (b *Buf) SearchBackward(re *regexp.Regexp, off int) (start, end int, ok bool) {
+	// TODO: This scans from the start of the buffer.  Searching
+	// backwards line by line would be faster for big buffers.
+	pos := 0
+	for pos < off {
+		s, e, found := b.SearchForward(re, pos)
+		if !found || s >= off {
+			break
+		}
+		start, end, ok = s, e, true
+		if e > s {
+			pos = e
+		} else {
+			// empty match, continue after the next rune
+			rd := b.NewReader(s)
+			if _, _, err := rd.ReadRune(); err != nil {
+				break
+			}
+			pos = rd.Offset()
+		}
+	}
+	return start, end, ok
+}
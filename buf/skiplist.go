@@ -0,0 +1,173 @@
+package buf
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+// The pieces of a buffer form an indexable skip list.  Level 0 is the
+// doubly linked list of pieces (piece.prev / piece.next) starting and
+// ending at the sentinel.  Every piece additionally has links on levels
+// 1 up to its height.  A link records how many bytes and newlines there
+// are from the start of its piece up to (excluding) the piece it points
+// to, so that lookups by offset or by line are O(log pieces).
+// The sentinel has links on all levels and acts as the head of the list.
+
+const maxLevel = 16
+
+type link struct {
+	next *piece
+	len  int // length of the pieces from the owner of the link up to next
+	nl   int // number of newlines in those pieces
+}
+
+// forward returns the link of p on level l.
+func (p *piece) forward(l int) (next *piece, len, nl int) {
+	if l == 0 {
+		return p.next, p.len(), p.nl
+	}
+	lk := &p.skip[l-1]
+	return lk.next, lk.len, lk.nl
+}
+
+func randomHeight() int {
+	h := 1
+	for h < maxLevel && rand.Intn(4) == 0 {
+		h++
+	}
+	return h
+}
+
+func (b *Buf) initSkipList() {
+	b.sentinel.skip = make([]link, maxLevel-1)
+	for l := range b.sentinel.skip {
+		b.sentinel.skip[l].next = &b.sentinel
+	}
+}
+
+// predecessors returns for every level the last piece starting before
+// pos (or the sentinel) together with its start and the number of
+// newlines in front of it.
+func (b *Buf) predecessors(pos int) (preds [maxLevel]*piece, starts, nls [maxLevel]int) {
+	x, xpos, xnl := &b.sentinel, 0, 0
+	for l := maxLevel - 1; l >= 0; l-- {
+		for {
+			nx, span, spanNL := x.forward(l)
+			if nx == &b.sentinel || xpos+span >= pos {
+				break
+			}
+			x, xpos, xnl = nx, xpos+span, xnl+spanNL
+		}
+		preds[l], starts[l], nls[l] = x, xpos, xnl
+	}
+	return
+}
+
+// insertPiece links np into the list so that it starts at pos,
+// which must be the start of a piece or the end of the buffer.
+func (b *Buf) insertPiece(np *piece, pos int) {
+	preds, starts, nls := b.predecessors(pos)
+	h := randomHeight()
+	np.skip = make([]link, h-1)
+	// preds[0] ends at pos
+	nlBefore := nls[0] + preds[0].nl
+	next := preds[0].next
+	preds[0].link(np)
+	np.link(next)
+	for l := 1; l < maxLevel; l++ {
+		lk := &preds[l].skip[l-1]
+		if l < h {
+			// split the span of the predecessor at pos
+			head := pos - starts[l]
+			headNL := nlBefore - nls[l]
+			np.skip[l-1] = link{
+				next: lk.next,
+				len:  lk.len - head + np.len(),
+				nl:   lk.nl - headNL + np.nl,
+			}
+			lk.next, lk.len, lk.nl = np, head, headNL
+		} else {
+			lk.len += np.len()
+			lk.nl += np.nl
+		}
+	}
+}
+
+// removePiece unlinks p which starts at pos.
+func (b *Buf) removePiece(p *piece, pos int) {
+	preds, _, _ := b.predecessors(pos)
+	p.prev.link(p.next)
+	for l := 1; l < maxLevel; l++ {
+		lk := &preds[l].skip[l-1]
+		if l <= len(p.skip) {
+			pl := p.skip[l-1]
+			lk.next = pl.next
+			lk.len += pl.len - p.len()
+			lk.nl += pl.nl - p.nl
+		} else {
+			lk.len -= p.len()
+			lk.nl -= p.nl
+		}
+	}
+}
+
+// findPiece finds the piece containing off and its start.  Returns
+// the sentinel and the length of the buffer if off is at (or past) the end.
+func (b *Buf) findPiece(off int) (pieceStart int, piece *piece) {
+	x, xpos := &b.sentinel, 0
+	for l := maxLevel - 1; l >= 0; l-- {
+		for {
+			nx, span, _ := x.forward(l)
+			if nx == &b.sentinel || xpos+span > off {
+				break
+			}
+			x, xpos = nx, xpos+span
+		}
+	}
+	// x is the last piece starting at or before off
+	if x == &b.sentinel || off >= xpos+x.len() {
+		return xpos + x.len(), &b.sentinel
+	}
+	return xpos, x
+}
+
+// newlinesBefore returns the number of newlines in front of off.
+func (b *Buf) newlinesBefore(off int) int {
+	x, xpos, xnl := &b.sentinel, 0, 0
+	for l := maxLevel - 1; l >= 0; l-- {
+		for {
+			nx, span, spanNL := x.forward(l)
+			if nx == &b.sentinel || xpos+span > off {
+				break
+			}
+			x, xpos, xnl = nx, xpos+span, xnl+spanNL
+		}
+	}
+	end := off - xpos
+	if end >= x.len() {
+		return xnl + x.nl
+	}
+	return xnl + bytes.Count(b.sliceOfPiece(x)[:end], newline)
+}
+
+// findNewline returns the offset just behind the nth newline.
+// n must be between 1 and the number of newlines in the buffer.
+func (b *Buf) findNewline(n int) int {
+	x, xpos, xnl := &b.sentinel, 0, 0
+	for l := maxLevel - 1; l >= 0; l-- {
+		for {
+			nx, span, spanNL := x.forward(l)
+			if nx == &b.sentinel || xnl+spanNL >= n {
+				break
+			}
+			x, xpos, xnl = nx, xpos+span, xnl+spanNL
+		}
+	}
+	// x is the piece containing the newline
+	text := b.sliceOfPiece(x)
+	i := -1
+	for ; xnl < n; xnl++ {
+		i += 1 + bytes.IndexByte(text[i+1:], '\n')
+	}
+	return xpos + i + 1
+}
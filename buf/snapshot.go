@@ -0,0 +1,92 @@
+package buf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A Snapshot is an immutable copy of the contents of a buffer at some
+// point in time.  Taking one is cheap: as the text pieces refer to is
+// never modified (see store), a snapshot just remembers the pieces.
+// Snapshots are taken by the goroutine editing the buffer but may be
+// read from any goroutine while the editing continues, e.g. by background
+// searches or autosaving.
+type Snapshot struct {
+	texts  [][]byte // the texts of the pieces
+	starts []int    // starts[i] is the offset of texts[i]
+	len    int
+	lines  int
+	seq    int
+}
+
+// Snapshot returns a snapshot of the current contents of the buffer.
+func (b *Buf) Snapshot() *Snapshot {
+	s := &Snapshot{len: b.len, lines: b.Lines(), seq: b.seq}
+	off := 0
+	b.eachpiece(func(p *piece) {
+		s.texts = append(s.texts, p.text)
+		s.starts = append(s.starts, off)
+		off += p.len()
+	})
+	return s
+}
+
+// Len returns the length of the snapshot in bytes.
+func (s *Snapshot) Len() int {
+	return s.len
+}
+
+// Lines returns the number of lines in the snapshot.
+func (s *Snapshot) Lines() int {
+	return s.lines
+}
+
+// Seq returns the change number (see Buf.Seq) of the buffer when the
+// snapshot was taken.
+func (s *Snapshot) Seq() int {
+	return s.seq
+}
+
+// ReadAt implements io.ReaderAt.
+func (s *Snapshot) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("ReadAt: invalid offset %d", off)
+	}
+	if off >= int64(s.len) {
+		return 0, io.EOF
+	}
+	// the last piece starting at or before off
+	i := sort.Search(len(s.starts), func(i int) bool { return int64(s.starts[i]) > off }) - 1
+	n := 0
+	for ; i >= 0 && i < len(s.texts) && n < len(p); i++ {
+		text := s.texts[i]
+		if start := int64(s.starts[i]); off+int64(n) > start {
+			text = text[off+int64(n)-start:]
+		}
+		n += copy(p[n:], text)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// NewReader returns a reader reading the snapshot starting at off.
+func (s *Snapshot) NewReader(off int) io.Reader {
+	return io.NewSectionReader(s, int64(off), int64(s.len-off))
+}
+
+// WriteTo writes the contents of the snapshot to w.
+// It implements io.WriterTo.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, text := range s.texts {
+		n, err := w.Write(text)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,34 @@
+package buf
+
+// Stats describes the data structures of a buffer, to see where its
+// memory goes and how fragmented it is.
+type Stats struct {
+	Pieces int // number of pieces the text is split into
+	// bytes of the text the pieces refer to: the text loaded from the
+	// file and the blocks of the store holding the text inserted
+	Backing int
+	// bytes of Backing not part of the text: deleted text and the
+	// unused rest of the block being filled
+	Wasted    int
+	Log       int // edits in the change log
+	LogBytes  int // bytes of text in the change log
+	Observers int
+}
+
+// Stats returns the statistics of the buffer.  Takes time linear in the
+// number of pieces and edits.
+func (b *Buf) Stats() Stats {
+	st := Stats{
+		Backing:   b.original + b.store.size,
+		Log:       len(b.log),
+		Observers: len(b.observers),
+	}
+	b.eachpiece(func(p *piece) {
+		st.Pieces++
+	})
+	st.Wasted = st.Backing - b.len
+	for _, e := range b.log {
+		st.LogBytes += len(e.Text)
+	}
+	return st
+}
@@ -0,0 +1,78 @@
+package buf
+
+import "io"
+
+// The store holds all text ever inserted into a buffer.  Pieces refer
+// to slices of it.  It is split into fixed size blocks so that growing
+// it never moves (and thereby copies) text that is already stored, and
+// a large insert costs exactly one copy of the inserted text.
+// Blocks are never modified once text has been appended to them.
+
+const blockSize = 64 << 10
+
+type store struct {
+	block []byte // the block currently being filled, len is the part in use
+	size  int    // bytes allocated for the blocks so far
+}
+
+// append copies s into the store and returns the stored copy.
+func (st *store) append(s []byte) []byte {
+	if len(s) > cap(st.block)-len(st.block) {
+		if len(s) >= blockSize {
+			// gets a block of its own, keep filling the current one
+			st.size += len(s)
+			return append(make([]byte, 0, len(s)), s...)
+		}
+		st.block = make([]byte, 0, blockSize)
+		st.size += blockSize
+	}
+	start := len(st.block)
+	st.block = append(st.block, s...)
+	return st.block[start:len(st.block):len(st.block)]
+}
+
+// readFrom reads r up to EOF into the store and returns the stored text.
+// size is the length of the text if known, else -1.  The text is read
+// into the free part of the current block as long as it fits, else into
+// a block of its own, which is grown as needed.  If reading fails the
+// text read so far is returned with the error.
+func (st *store) readFrom(r io.Reader, size int) ([]byte, error) {
+	free := cap(st.block) - len(st.block)
+	if free == 0 || size > free && size < blockSize {
+		st.block = make([]byte, 0, blockSize)
+		st.size += blockSize
+	}
+	start := len(st.block)
+	data := st.block[start:start:cap(st.block)]
+	own := false
+	if size > cap(data) {
+		data = make([]byte, 0, size)
+		own = true
+	}
+	var err error
+	for err == nil {
+		if len(data) == cap(data) {
+			// only grow if there is more
+			var probe [1]byte
+			var n int
+			if n, err = io.ReadAtLeast(r, probe[:], 1); n == 0 {
+				break
+			}
+			grown := make([]byte, len(data), max(2*cap(data), blockSize))
+			copy(grown, data)
+			data, own = append(grown, probe[0]), true
+		}
+		var n int
+		n, err = r.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if own {
+		st.size += cap(data)
+	} else {
+		st.block = st.block[:start+len(data)]
+	}
+	return data[:len(data):len(data)], err
+}
@@ -0,0 +1,74 @@
+package buf
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Buffers may hold arbitrary bytes.  Bytes that aren't part of a valid
+// utf-8 sequence are read as runes of their own (utf8.RuneError of size
+// 1), just like utf8.DecodeRune does.
+
+var replacementChar = []byte(string(utf8.RuneError))
+
+// NearestRuneBoundary returns the offset of the start of the rune
+// containing off, which is off itself unless it is in the middle of a
+// multibyte sequence.
+func (b *Buf) NearestRuneBoundary(off int) int {
+	if off <= 0 {
+		return 0
+	}
+	if off >= b.len {
+		return b.len
+	}
+	lo := off - (utf8.UTFMax - 1)
+	if lo < 0 {
+		lo = 0
+	}
+	hi := off + utf8.UTFMax - 1
+	if hi > b.len {
+		hi = b.len
+	}
+	text := b.Bytes(lo, hi)
+	i := off - lo // index of off in text
+	for s := i; s >= 0 && i-s < utf8.UTFMax; s-- {
+		if utf8.RuneStart(text[s]) {
+			if _, size := utf8.DecodeRune(text[s:]); s+size > i {
+				return lo + s
+			}
+			break
+		}
+	}
+	// a continuation byte not belonging to any sequence
+	return off
+}
+
+// IsRuneBoundary returns true if off is not in the middle of a
+// multibyte sequence.
+func (b *Buf) IsRuneBoundary(off int) bool {
+	return b.NearestRuneBoundary(off) == off
+}
+
+// SetValidating switches validating mode on or off.  In validating
+// mode Insert and Delete panic (TryInsert and TryDelete return an error)
+// if given offsets in the middle of a rune and Insert replaces invalid utf-8 by U+FFFD, so that a buffer holding
+// valid utf-8 keeps doing so.
+func (b *Buf) SetValidating(validating bool) {
+	b.validating = validating
+}
+
+// validate checks the offsets of a change in validating mode and returns
+// the text to insert.
+func (b *Buf) validate(off1, off2 int, s []byte) ([]byte, error) {
+	if !b.validating {
+		return s, nil
+	}
+	if !b.IsRuneBoundary(off1) || !b.IsRuneBoundary(off2) {
+		return nil, fmt.Errorf("Offsets %v-%v split a rune", off1, off2)
+	}
+	if !utf8.Valid(s) {
+		s = bytes.ToValidUTF8(s, replacementChar)
+	}
+	return s, nil
+}
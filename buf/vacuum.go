@@ -0,0 +1,75 @@
+package buf
+
+// Deleted text stays in the store for as long as a piece or the change
+// log refers to the block holding it, which in a long session of
+// pasting and deleting is about forever.  Vacuum copies the text still
+// in use into a fresh store so that the old blocks can be freed.
+
+// Vacuum copies the text of the buffer inserted since it was loaded
+// into a new store, joining neighbouring pieces into one, and drops the
+// change log (see Changes), so that the memory of the deleted text can
+// be freed.  The text loaded from the file is kept as it is, it has
+// memory of its own.  Snapshots taken before keep referring to the
+// old text.  Readers must not be used across a Vacuum.
+func (b *Buf) Vacuum() {
+	var st store
+	var pieces []*piece
+	var run []*piece // pieces in the store following each other
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		n := 0
+		for _, p := range run {
+			n += p.len()
+		}
+		// a block of its own, as the joined text is never appended to
+		text := make([]byte, 0, n)
+		for _, p := range run {
+			text = append(text, p.text...)
+		}
+		st.size += n
+		pieces = append(pieces, &piece{text: text, nl: sumNewlines(run)})
+		run = run[:0]
+	}
+	b.eachpiece(func(p *piece) {
+		if p.orig {
+			flush()
+			pieces = append(pieces, p)
+		} else {
+			run = append(run, p)
+		}
+	})
+	flush()
+
+	b.store = st
+	b.sentinel.next = &b.sentinel
+	b.sentinel.prev = &b.sentinel
+	b.initSkipList()
+	off := 0
+	for _, p := range pieces {
+		b.insertPiece(p, off)
+		off += p.len()
+	}
+	b.logStart = b.seq
+	b.log = nil
+}
+
+func sumNewlines(pieces []*piece) int {
+	nl := 0
+	for _, p := range pieces {
+		nl += p.nl
+	}
+	return nl
+}
+
+// SetAutoVacuum makes the buffer Vacuum itself when a deletion leaves
+// more than waste bytes of the store unused.  Zero turns it off, the
+// default.  As the store grows in blocks, a waste of less than a block
+// is rounded up to one.
+func (b *Buf) SetAutoVacuum(waste int) {
+	if waste > 0 && waste < blockSize {
+		waste = blockSize
+	}
+	b.autoVacuum = waste
+}
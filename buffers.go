@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/view"
+)
+
+// A bufEntry is a buffer of the buffer list.  Buffers stay in the list
+// (hidden) when no window shows them any more.
+type bufEntry struct {
+	num  int // number the buffer is known by, e.g. in :b 2
+	b    *buf.Buf
+	last buf.Marker // cursor position when the buffer was last hidden or nil
+	kind *bufKind   // nil unless a special buffer
+	// not backed by a file, changes are never asked to be saved
+	scratch bool
+}
+
+// vacuumWaste is how much deleted text a buffer keeps before it frees
+// it, see buf.Buf.Vacuum.
+const vacuumWaste = 16 << 20
+
+// addBuffer adds b to the buffer list unless it is in it already.
+func (ed *editor) addBuffer(b *buf.Buf) {
+	if ed.bufEntry(b) != nil {
+		return
+	}
+	b.SetAutoVacuum(vacuumWaste)
+	num := 1
+	if n := len(ed.bufs); n > 0 {
+		num = ed.bufs[n-1].num + 1
+	}
+	ed.bufs = append(ed.bufs, &bufEntry{num: num, b: b, kind: kindOf(b.Name())})
+	ed.observeForPlugins(b)
+}
+
+// bufEntry returns the entry of b in the buffer list or nil.
+func (ed *editor) bufEntry(b *buf.Buf) *bufEntry {
+	for _, e := range ed.bufs {
+		if e.b == b {
+			return e
+		}
+	}
+	return nil
+}
+
+// loadBuffer returns the buffer of the file name, loading the file
+// unless it is in the buffer list already.  An empty name gives a new
+// empty buffer.
+func (ed *editor) loadBuffer(name string) (*buf.Buf, error) {
+	if name != "" {
+		abs, _ := filepath.Abs(name)
+		for _, e := range ed.bufs {
+			if other, _ := filepath.Abs(e.b.Name()); e.b.Name() != "" && other == abs {
+				return e.b, nil
+			}
+		}
+	}
+	b, err := LoadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	ed.addBuffer(b)
+	ed.protect(b)
+	ed.watch(b)
+	return b, nil
+}
+
+// hide remembers the cursor position of v, whose buffer it is going to
+// stop showing, so that showing the buffer again returns to it.
+func (ed *editor) hide(v *view.View) {
+	e := ed.bufEntry(v.Buffer())
+	if e == nil {
+		return
+	}
+	if e.last == nil {
+		e.last = e.b.NewMarker(v.Cursor())
+	} else {
+		e.last.Move(v.Cursor())
+	}
+}
+
+// switchBuffer makes the focused window show b.  The buffer shown
+// before is hidden, which is refused if it is modified and no other
+// window shows it, unless force is set.
+func (ed *editor) switchBuffer(b *buf.Buf, force bool) error {
+	v := ed.focus
+	old := v.Buffer()
+	if old == b {
+		return nil
+	}
+	if ed.unsaved(old) && !force && ed.windowsShowing(old) == 1 {
+		return errors.New("No write since last change (add ! to override)")
+	}
+	ed.hide(v)
+	ed.showBuffer(v, b)
+	if e := ed.bufEntry(b); e != nil && e.last != nil {
+		v.SetCursor(e.last.Offset())
+		ed.showCursor()
+	}
+	return nil
+}
+
+// bufferName returns the name of b shown to the user.
+func bufferName(b *buf.Buf) string {
+	if b.Name() == "" {
+		return "[No Name]"
+	}
+	return b.Name()
+}
+
+// bufferLine returns the line of the cursor in the buffer of e: in the
+// focused window if it shows the buffer, otherwise in the first window
+// showing it or where it was when it was last hidden.
+func (ed *editor) bufferLine(e *bufEntry) int {
+	off := -1
+	if ed.focus.Buffer() == e.b {
+		off = ed.focus.Cursor()
+	}
+	for _, t := range ed.tabs {
+		t.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+			if v := leaf.View(); off < 0 && v.Buffer() == e.b {
+				off = v.Cursor()
+			}
+		})
+	}
+	if off < 0 && e.last != nil {
+		off = e.last.Offset()
+	}
+	if off < 0 {
+		return 1
+	}
+	return e.b.LineOfOffset(off)
+}
+
+// :ls lists the buffers with their number, flags and the line of the
+// cursor.  The flags are % for the buffer of the current window, a for
+// buffers shown in a window, h for hidden ones and + for modified ones.
+func (ed *editor) cmdLs(cmd ex.Command) error {
+	var lines []string
+	for _, e := range ed.bufs {
+		current, shown := ' ', 'h'
+		if e.b == ed.focus.Buffer() {
+			current = '%'
+		}
+		if ed.windowsShowing(e.b) > 0 {
+			shown = 'a'
+		}
+		modified := ' '
+		if e.b.Dirty() {
+			modified = '+'
+		}
+		lines = append(lines, fmt.Sprintf("%3d %c%c %c %-30q line %d",
+			e.num, current, shown, modified, ed.bufferLabel(e.b), ed.bufferLine(e)))
+	}
+	ed.setMessage(strings.Join(lines, "\n"))
+	return nil
+}
+
+// :b[uffer] N and :b[uffer] name show the buffer with the given number
+// or (part of its) name in the current window.  Without an argument it
+// opens a picker choosing among the buffers.
+func (ed *editor) cmdBuffer(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		ed.pickBuffer()
+		return nil
+	}
+	var found []*bufEntry
+	if n, err := strconv.Atoi(cmd.Arg); err == nil {
+		for _, e := range ed.bufs {
+			if e.num == n {
+				found = append(found, e)
+			}
+		}
+	} else {
+		for _, e := range ed.bufs {
+			if strings.Contains(e.b.Name(), cmd.Arg) {
+				found = append(found, e)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return fmt.Errorf("No matching buffer for %s", cmd.Arg)
+	case 1:
+		return ed.switchBuffer(found[0].b, cmd.Bang)
+	}
+	return fmt.Errorf("More than one match for %s", cmd.Arg)
+}
+
+// pickBuffer opens a picker showing the buffer the user chooses in the
+// current window.
+func (ed *editor) pickBuffer() {
+	bufs := append([]*bufEntry(nil), ed.bufs...)
+	items := make([]string, len(bufs))
+	for i, e := range bufs {
+		modified := ""
+		if e.b.Dirty() {
+			modified = " +"
+		}
+		items[i] = fmt.Sprintf("%d %s%s", e.num, ed.bufferLabel(e.b), modified)
+	}
+	ed.startPicker("Buffer: ", items, func(i int) {
+		if err := ed.switchBuffer(bufs[i].b, false); err != nil {
+			ed.setError(err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+)
+
+// toggleCase returns r in upper case if it is in lower case and in
+// lower case otherwise.
+func toggleCase(r rune) rune {
+	if unicode.IsLower(r) {
+		return unicode.ToUpper(r)
+	}
+	return unicode.ToLower(r)
+}
+
+// caseOperator returns the operator mapping the runes covered with f
+// (vim's g~, gu and gU), leaving the cursor at the start.
+func caseOperator(f func(rune) rune) operatorFunc {
+	return func(ed *editor, r motion.Range) {
+		if err := changeCase(ed.focus.Buffer(), []motion.Range{r}, f); err != nil {
+			ed.setError(err)
+		}
+		ed.focus.SetCursor(r.Off1)
+	}
+}
+
+// changeCase maps the runes in the ranges rs of b with f.  Only the runs
+// of runes changing are replaced, all with one ReplaceAll, so that the
+// markers in between them stay where they are.  Those inside a run move
+// to one of its ends (see buf.Buf.ReplaceAll).
+func changeCase(b *buf.Buf, rs []motion.Range, f func(rune) rune) error {
+	var reps []buf.Replacement
+	for _, r := range rs {
+		text := b.Bytes(r.Off1, r.Off2)
+		for i := 0; i < len(text); {
+			ch, size := utf8.DecodeRune(text[i:])
+			off := r.Off1 + i
+			i += size
+			mapped := f(ch)
+			if mapped == ch || ch == utf8.RuneError && size == 1 {
+				continue
+			}
+			if n := len(reps); n > 0 && reps[n-1].Off2 == off {
+				reps[n-1].Off2 += size
+				reps[n-1].Text = utf8.AppendRune(reps[n-1].Text, mapped)
+			} else {
+				reps = append(reps, buf.Replacement{Off1: off, Off2: off + size, Text: utf8.AppendRune(nil, mapped)})
+			}
+		}
+	}
+	if len(reps) == 0 {
+		return nil
+	}
+	return b.ReplaceAll(reps)
+}
+
+// toggleCaseChars toggles the case of the count characters from the
+// cursor on, but not beyond the end of the line, and moves the cursor
+// behind them (vim's ~).
+func (ed *editor) toggleCaseChars(count int) {
+	v := ed.focus
+	b := v.Buffer()
+	if count < 1 {
+		count = 1
+	}
+	ed.eachCursor(func() {
+		off := v.Cursor()
+		_, end := b.LineRange(b.LineOfOffset(off))
+		rd := b.NewReader(off)
+		for i := 0; i < count && rd.Offset() < end; i++ {
+			motion.GraphemeForward.Move(b, rd)
+		}
+		to := rd.Offset()
+		if to > end {
+			to = end
+		}
+		if err := changeCase(b, []motion.Range{{Off1: off, Off2: to}}, toggleCase); err != nil {
+			ed.setError(err)
+			return
+		}
+		v.SetCursor(to)
+		if to == end && to > off {
+			// stay on the last character of the line
+			v.MoveCursor(motion.GraphemeBackward)
+		}
+	})
+}
+
+// changeBlockCase maps the runes in the block selection with f and
+// leaves visual mode.
+func (ed *editor) changeBlockCase(f func(rune) rune) {
+	v := ed.focus
+	ranges := v.BlockRanges()
+	ed.endVisual()
+	if len(ranges) == 0 {
+		return
+	}
+	if err := changeCase(v.Buffer(), ranges, f); err != nil {
+		ed.setError(err)
+	}
+	v.SetCursor(ranges[0].Off1)
+}
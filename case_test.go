@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+)
+
+func TestChangeCase(t *testing.T) {
+	tests := []struct {
+		text   string
+		ranges []motion.Range
+		f      func(rune) rune
+		want   string
+	}{
+		{"Hello, World", []motion.Range{{Off1: 0, Off2: 12}}, toggleCase, "hELLO, wORLD"},
+		{"Hello, World", []motion.Range{{Off1: 0, Off2: 12}}, unicode.ToUpper, "HELLO, WORLD"},
+		{"Hello, World", []motion.Range{{Off1: 7, Off2: 12}}, unicode.ToLower, "Hello, world"},
+		{"abc", []motion.Range{{Off1: 1, Off2: 1}}, unicode.ToUpper, "abc"},
+		// several ranges
+		{"ab cd ef", []motion.Range{{Off1: 0, Off2: 1}, {Off1: 3, Off2: 5}}, unicode.ToUpper, "Ab CD ef"},
+		// runes of other lengths
+		{"äöü", []motion.Range{{Off1: 0, Off2: 6}}, unicode.ToUpper, "ÄÖÜ"},
+		{"xıy", []motion.Range{{Off1: 0, Off2: 4}}, unicode.ToUpper, "XIY"},
+		{"ǅ", []motion.Range{{Off1: 0, Off2: 2}}, toggleCase, "ǆ"},
+		// invalid utf-8 is left alone
+		{"a\xffb", []motion.Range{{Off1: 0, Off2: 3}}, unicode.ToUpper, "A\xffB"},
+	}
+	for _, test := range tests {
+		var b buf.Buf
+		b.Init()
+		b.Insert(0, []byte(test.text))
+		if err := changeCase(&b, test.ranges, test.f); err != nil {
+			t.Errorf("changeCase(%q, %v): %v", test.text, test.ranges, err)
+		} else if got := b.String(); got != test.want {
+			t.Errorf("changeCase(%q, %v) gives %q expected %q", test.text, test.ranges, got, test.want)
+		}
+	}
+}
+
+func TestCaseCommands(t *testing.T) {
+	tests := []keysTest{
+		// ~ toggles and moves on
+		{"abc", "~", "Abc", 1},
+		{"abc", "2~", "ABc", 2},
+		// but stops at the end of the line, staying on its last character
+		{"abc\ndef", "5~", "ABC\ndef", 2},
+		{"abc", "ll~", "abC", 2},
+		{"\nabc", "~", "\nabc", 0},
+		{"äb", "~", "Äb", 2},
+		// the operators leave the cursor at the start
+		{"foo bar", "wg~iw", "foo BAR", 4},
+		{"foo Bar", "gUiw", "FOO Bar", 0},
+		{"FOO BAR", "wguiw", "FOO bar", 4},
+		{"one\ntwo\n", "gUj", "ONE\nTWO\n", 0},
+		{"aB cD", "v$~", "Ab Cd", 0},
+		{"aB cD", "veU", "AB cD", 0},
+		// block selections change the case of each line's part
+		{"abc\ndef\n", "l<C-v>jlU", "aBC\ndEF\n", 1},
+		{"abc\nd\nefg\n", "l<C-v>2jl~", "aBC\nd\neFG\n", 1},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/screen"
+	"github.com/nsf/termbox-go"
+)
+
+// cmdline is the mini-buffer used to enter ex commands.  It is drawn
+// in the last row of the screen, prefixed by its prompt.
+type cmdline struct {
+	prompt string
+	text   buf.Buf
+	cursor int // offset of the cursor in text
+
+	completion *completion // nil unless cycling through completions
+	covered    bool        // the completions were drawn above the command line
+}
+
+func (c *cmdline) init(prompt string) {
+	c.prompt = prompt
+	c.text.Init()
+	c.cursor = 0
+	c.completion = nil
+}
+
+func (c *cmdline) String() string {
+	return c.text.String()
+}
+
+// edit applies a key to the command line.  Returns false if the key
+// wasn't a line editing key.
+func (c *cmdline) edit(ev termbox.Event) bool {
+	c.completion = nil
+	switch ev.Key {
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if c.cursor > 0 {
+			rd := c.text.NewReader(c.cursor)
+			rd.Reverse()
+			rd.ReadRune()
+			c.text.Delete(rd.Offset(), c.cursor)
+			c.cursor = rd.Offset()
+		}
+	case termbox.KeyDelete:
+		if c.cursor < c.text.Len() {
+			rd := c.text.NewReader(c.cursor)
+			rd.ReadRune()
+			c.text.Delete(c.cursor, rd.Offset())
+		}
+	case termbox.KeyArrowLeft:
+		if c.cursor > 0 {
+			rd := c.text.NewReader(c.cursor)
+			rd.Reverse()
+			rd.ReadRune()
+			c.cursor = rd.Offset()
+		}
+	case termbox.KeyArrowRight:
+		if c.cursor < c.text.Len() {
+			rd := c.text.NewReader(c.cursor)
+			rd.ReadRune()
+			c.cursor = rd.Offset()
+		}
+	case termbox.KeyHome, termbox.KeyCtrlA:
+		c.cursor = 0
+	case termbox.KeyEnd, termbox.KeyCtrlE:
+		c.cursor = c.text.Len()
+	case termbox.KeySpace:
+		c.insert(' ')
+	default:
+		if ev.Ch == 0 {
+			return false
+		}
+		c.insert(ev.Ch)
+	}
+	return true
+}
+
+func (c *cmdline) insert(r rune) {
+	var b [utf8.UTFMax]byte
+	n := utf8.EncodeRune(b[:], r)
+	c.text.Insert(c.cursor, b[:n])
+	c.cursor += n
+}
+
+// display draws the command line into row y of scr and places the cursor.
+func (c *cmdline) display(scr screen.Screen, y, w int) {
+	const coldef = termbox.ColorDefault
+	x := 0
+	cursorX := 0
+	draw := func(r rune) {
+		if x < w {
+			scr.SetCell(x, y, r, coldef, coldef)
+		}
+		x++
+	}
+	for _, r := range c.prompt {
+		draw(r)
+	}
+	for i, r := range c.text.String() {
+		if i == c.cursor {
+			cursorX = x
+		}
+		draw(r)
+	}
+	if c.cursor == c.text.Len() {
+		cursorX = x
+	}
+	if cursorX >= w {
+		cursorX = w - 1
+	}
+	scr.SetCursor(cursorX, y)
+}
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/register"
+	"github.com/bgrundmann/e/swap"
+	"github.com/bgrundmann/e/view"
+)
+
+// registerCommands adds the ex commands of the editor to its dispatcher.
+func (ed *editor) registerCommands() {
+	ed.commands.Register("w[rite]", ed.cmdWrite)
+	ed.commands.Register("q[uit]", ed.cmdQuit)
+	ed.commands.Register("d[elete]", ed.cmdDelete)
+	ed.commands.Register("r[ead]", ed.cmdRead)
+	ed.commands.Register("e[dit]", ed.cmdEdit)
+	ed.commands.Register("se[t]", ed.cmdSet(setBoth))
+	ed.commands.Register("setl[ocal]", ed.cmdSet(setLocal))
+	ed.commands.Register("setg[lobal]", ed.cmdSet(setGlobal))
+	ed.commands.Register("rec[over]", ed.cmdRecover)
+	ed.commands.Register("!", ed.cmdFilter)
+	ed.commands.Register(">", ed.cmdShift(1))
+	ed.commands.Register("<", ed.cmdShift(-1))
+	ed.commands.Register("marks", ed.cmdMarks)
+	ed.commands.Register("sp[lit]", ed.cmdSplit(view.Horizontal))
+	ed.commands.Register("vs[plit]", ed.cmdSplit(view.Vertical))
+	ed.commands.Register("clo[se]", ed.cmdClose)
+	ed.commands.Register("ls", ed.cmdLs)
+	ed.commands.Register("buffers", ed.cmdLs)
+	ed.commands.Register("b[uffer]", ed.cmdBuffer)
+	ed.commands.Register("tabnew", ed.cmdTabNew)
+	ed.commands.Register("tabe[dit]", ed.cmdTabNew)
+	ed.commands.Register("tabc[lose]", ed.cmdTabClose)
+	ed.commands.Register("tabn[ext]", ed.cmdTabNext(1))
+	ed.commands.Register("tabp[revious]", ed.cmdTabNext(-1))
+	ed.commands.Register("gr[ep]", ed.cmdGrep)
+	ed.commands.Register("cn[ext]", ed.cmdQuickfixNext(1))
+	ed.commands.Register("cp[revious]", ed.cmdQuickfixNext(-1))
+	ed.commands.Register("mak[e]", ed.cmdMake)
+	ed.commands.Register("job", ed.cmdJob)
+	ed.commands.Register("jobs", ed.cmdJobs)
+	ed.commands.Register("jobk[ill]", ed.cmdJobKill)
+	ed.commands.Register("mks[ession]", ed.cmdMksession)
+	ed.commands.Register("au[tocmd]", ed.cmdAutocmd)
+	ed.commands.Register("ab[breviate]", ed.cmdAbbreviate)
+	ed.commands.Register("una[bbreviate]", ed.cmdUnabbreviate)
+	ed.commands.Register("mes[sages]", ed.cmdMessages)
+	ed.commands.Register("scr[atch]", ed.cmdScratch)
+	ed.commands.Register("dif[f]", ed.cmdDiff)
+	ed.commands.Register("Gbl[ame]", ed.cmdGblame)
+	ed.commands.Register("ta[g]", ed.cmdTag)
+	ed.commands.Register("ts[elect]", ed.cmdTselect)
+	for _, name := range []string{"map", "nmap", "imap", "vmap"} {
+		ed.commands.Register(name, ed.cmdMap(name))
+	}
+	ed.commands.Register("hi[ghlight]", ed.cmdHighlight)
+	ed.commands.Register("plug[in]", ed.cmdPlugin)
+	ed.commands.Register("scri[pt]", ed.cmdScript)
+	ed.commands.Register("scriptf[ile]", ed.cmdScriptFile)
+	ed.commands.Register("", ed.cmdGotoLine)
+
+	for _, name := range []string{"w[rite]", "e[dit]", "r[ead]", "tabnew", "tabe[dit]", "scriptf[ile]"} {
+		ed.commands.SetCompleter(name, completeFiles)
+	}
+	ed.commands.SetCompleter("b[uffer]", ed.completeBuffers)
+}
+
+// :w[!] [file] writes the buffer to its file or the given one.  The
+// buffer's file isn't overwritten if another program changed it since
+// it was read or written, unless ! is given.
+// :{range}w[!] file writes only the lines of range, to a file that
+// doesn't exist yet unless ! is given.
+func (ed *editor) cmdWrite(cmd ex.Command) error {
+	b := ed.focus.Buffer()
+	if cmd.Range.N > 0 {
+		return ed.writeLines(cmd)
+	}
+	name := cmd.Arg
+	if name == "" {
+		name = b.Name()
+	}
+	if name == "" {
+		return errors.New("No file name")
+	}
+	if name == b.Name() && !cmd.Bang && ed.changedOnDisk(b) {
+		return errors.New("File changed on disk since it was read (add ! to override)")
+	}
+	// a failing hook (e.g. gofmt on a syntax error) doesn't keep the
+	// buffer from being written
+	hookErr := ed.runHooks(bufWritePre, b)
+	if err := SaveFile(b, name); err != nil {
+		return err
+	}
+	if b.Name() == "" {
+		b.SetName(name)
+	}
+	if name == b.Name() {
+		b.MarkSaved()
+		if s := ed.swaps[b]; s != nil {
+			s.Saved()
+		} else {
+			ed.protect(b)
+		}
+		ed.watch(b)
+	}
+	ed.setMessage(fmt.Sprintf("%q %dL, %dB written", name, b.Lines(), b.Len()))
+	if err := ed.runHooks(bufWritePost, b); hookErr == nil {
+		hookErr = err
+	}
+	return hookErr
+}
+
+// writeLines writes the lines cmd applies to, see cmdWrite.
+func (ed *editor) writeLines(cmd ex.Command) error {
+	b := ed.focus.Buffer()
+	if cmd.Arg == "" {
+		return errors.New("No file name")
+	}
+	r, err := ed.cmdRange(cmd)
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if cmd.Bang {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(cmd.Arg, flags, 0644)
+	if os.IsExist(err) {
+		return errors.New("File exists (add ! to override)")
+	}
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, b.Slice(r.Off1, r.Off2))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	ed.setMessage(fmt.Sprintf("%q %dB written", cmd.Arg, n))
+	return nil
+}
+
+// :{range}d[elete] [x] deletes the lines into register x or the unnamed
+// register.
+func (ed *editor) cmdDelete(cmd ex.Command) error {
+	if err := ed.modifiable(); err != nil {
+		return err
+	}
+	r, err := ed.cmdRange(cmd)
+	if err != nil {
+		return err
+	}
+	if cmd.Arg != "" {
+		name, size := utf8.DecodeRuneInString(cmd.Arg)
+		if size != len(cmd.Arg) || !register.Valid(name) {
+			return fmt.Errorf("Invalid register name: %s", cmd.Arg)
+		}
+		ed.register = name
+		defer func() { ed.register = 0 }()
+	}
+	opDelete(ed, r)
+	return nil
+}
+
+// :[line]r[ead] [file] inserts the contents of file (by default the
+// buffer's file) below the current line or the line given, 0 for above
+// the first.  :[line]r !cmd inserts the output of cmd instead.  The
+// file or the command's output is streamed into the buffer as a single
+// piece, as it is, without converting its encoding or line endings.
+func (ed *editor) cmdRead(cmd ex.Command) error {
+	if err := ed.modifiable(); err != nil {
+		return err
+	}
+	b := ed.focus.Buffer()
+	_, line, err := ed.lines(cmd)
+	if err != nil {
+		return err
+	}
+	if line < 0 || line > b.Lines() {
+		return errors.New("Invalid range")
+	}
+	off := b.Len()
+	if line < b.Lines() {
+		off = b.Line(line + 1)
+	}
+	// the last line has no line break, the text is put on a line of its
+	// own and keeps it that way
+	noEOL := off == b.Len() && off > 0 && b.Bytes(off-1, off)[0] != '\n'
+	insert := func(r io.Reader) (int, error) {
+		lr := &lineReader{r: r, eol: !noEOL}
+		if noEOL {
+			r = io.MultiReader(strings.NewReader("\n"), lr)
+		} else {
+			r = lr
+		}
+		if _, err := b.InsertReader(off, r); err != nil || lr.n == 0 {
+			return lr.n, err
+		}
+		if end := b.Len(); noEOL && b.Bytes(end-1, end)[0] == '\n' {
+			return lr.n, b.TryDelete(end-1, end)
+		}
+		return lr.n, nil
+	}
+	var n int
+	msg := ""
+	if command, ok := strings.CutPrefix(cmd.Arg, "!"); ok {
+		var stderr bytes.Buffer
+		c := shellCommand(command)
+		c.Stderr = &stderr
+		out, err := c.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := c.Start(); err != nil {
+			return shellError(command, &stderr, err)
+		}
+		n, err = insert(out)
+		if werr := c.Wait(); werr != nil {
+			return shellError(command, &stderr, werr)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		name := cmd.Arg
+		if name == "" {
+			name = b.Name()
+		}
+		if name == "" {
+			return errors.New("No file name")
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if n, err = insert(f); err != nil {
+			return err
+		}
+		msg = fmt.Sprintf("%q %dB", name, n)
+	}
+	if n == 0 {
+		ed.setMessage(msg)
+		return nil
+	}
+	ed.focus.PushJump(ed.focus.Cursor())
+	ed.focus.SetCursor(b.Line(line + 1))
+	ed.focus.MoveCursor(motion.FirstNonBlank)
+	ed.showCursor()
+	ed.setMessage(msg)
+	return nil
+}
+
+// lineReader reads from r, counting the bytes read, and if eol is set
+// ends text not ending in a line break with one.
+type lineReader struct {
+	r    io.Reader
+	eol  bool
+	n    int  // bytes read from r
+	last byte // last byte read from r
+	done bool // the line break has been added
+}
+
+func (lr *lineReader) Read(p []byte) (int, error) {
+	if lr.done {
+		return 0, io.EOF
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		lr.n += n
+		lr.last = p[n-1]
+	}
+	if err == io.EOF && lr.eol && lr.n > 0 && lr.last != '\n' {
+		if n == len(p) {
+			return n, nil
+		}
+		p[n] = '\n'
+		lr.done = true
+		return n + 1, io.EOF
+	}
+	return n, err
+}
+
+// :q quits the editor.
+func (ed *editor) cmdQuit(cmd ex.Command) error {
+	ed.exit()
+	return nil
+}
+
+// :e file edits file in the current window.  Without a file it
+// reloads the file of the current buffer, which must not be modified
+// unless ! is given.
+func (ed *editor) cmdEdit(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		b := ed.focus.Buffer()
+		if b.Name() == "" {
+			return errors.New("No file name")
+		}
+		if b.Dirty() && !cmd.Bang {
+			return errors.New("No write since last change (add ! to override)")
+		}
+		return ed.reload(b)
+	}
+	b, err := ed.loadBuffer(cmd.Arg)
+	if err != nil {
+		return err
+	}
+	return ed.switchBuffer(b, cmd.Bang)
+}
+
+// :rec[over] restores the changes logged in the swap file of the buffer,
+// usually left behind by a crash.
+func (ed *editor) cmdRecover(cmd ex.Command) error {
+	b := ed.focus.Buffer()
+	if b.Name() == "" {
+		return errors.New("No file name")
+	}
+	if ed.swaps[b] != nil || !swap.Exists(b.Name()) {
+		return errors.New("No swap file to recover from")
+	}
+	s, err := swap.Recover(b)
+	if err != nil {
+		return err
+	}
+	ed.swaps[b] = s
+	ed.setMessage(fmt.Sprintf("Recovered %q, :w to keep the changes", b.Name()))
+	return nil
+}
+
+// :<n> moves the cursor to the start of line n.  Given a range it
+// moves to its last line.
+func (ed *editor) cmdGotoLine(cmd ex.Command) error {
+	_, line, err := ed.lines(cmd)
+	if err != nil {
+		return err
+	}
+	ed.focus.PushJump(ed.focus.Cursor())
+	ed.focus.MoveCursor(motion.GotoLine(line))
+	ed.showCursor()
+	return nil
+}
+
+// addresser resolves the addresses of ex commands in the focused
+// window.
+type addresser struct {
+	ed *editor
+}
+
+func (a addresser) CurrentLine() int {
+	v := a.ed.focus
+	return v.Buffer().LineOfOffset(v.Cursor())
+}
+
+func (a addresser) LastLine() int {
+	return a.ed.focus.Buffer().Lines()
+}
+
+func (a addresser) MarkLine(name rune) (int, error) {
+	b := a.ed.focus.Buffer()
+	m := a.ed.marks[b][name]
+	if m == nil {
+		return 0, fmt.Errorf("Mark not set: %c", name)
+	}
+	pos, err := b.PositionFromOffset(m.Offset())
+	return pos.Line, err
+}
+
+func (a addresser) SearchLine(pattern string, backward bool) (int, error) {
+	if pattern == "" {
+		pattern = a.ed.search.pattern
+	}
+	if pattern == "" {
+		return 0, errors.New("No previous search pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, err
+	}
+	b := a.ed.focus.Buffer()
+	current := a.CurrentLine()
+	var start int
+	var ok bool
+	if backward {
+		if start, _, ok = b.SearchBackward(re, b.Line(current)); !ok {
+			start, _, ok = b.SearchBackward(re, b.Len()+1)
+		}
+	} else {
+		from := b.Len()
+		if current < b.Lines() {
+			from = b.Line(current + 1)
+		}
+		if start, _, ok = b.SearchForward(re, from); !ok {
+			start, _, ok = b.SearchForward(re, 0)
+		}
+	}
+	if !ok {
+		return 0, fmt.Errorf("Pattern not found: %s", pattern)
+	}
+	pos, err := b.PositionFromOffset(start)
+	return pos.Line, err
+}
+
+// lines returns the lines of the focused buffer cmd applies to.
+func (ed *editor) lines(cmd ex.Command) (first, last int, err error) {
+	return cmd.Range.Lines(addresser{ed})
+}
+
+// bufferLines is like lines but errors if the lines aren't all in the
+// focused buffer.
+func (ed *editor) bufferLines(cmd ex.Command) (first, last int, err error) {
+	first, last, err = ed.lines(cmd)
+	if err == nil && (first < 1 || last > ed.focus.Buffer().Lines()) {
+		err = errors.New("Invalid range")
+	}
+	return first, last, err
+}
+
+// cmdRange returns the linewise range of the lines cmd applies to.
+func (ed *editor) cmdRange(cmd ex.Command) (motion.Range, error) {
+	first, last, err := ed.bufferLines(cmd)
+	if err != nil {
+		return motion.Range{}, err
+	}
+	return lineRange(ed.focus.Buffer(), first, last-first+1), nil
+}
+
+// :!cmd runs the shell command cmd showing its output.
+// :{range}!cmd filters the lines through cmd replacing them with its
+// output (e.g. :%!sort).
+func (ed *editor) cmdFilter(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		return errors.New("No shell command")
+	}
+	b := ed.focus.Buffer()
+	if cmd.Range.N == 0 {
+		out, err := runShell(cmd.Arg, nil)
+		if err != nil {
+			return err
+		}
+		ed.setMessage(strings.TrimSpace(string(out)))
+		return nil
+	}
+	if err := ed.modifiable(); err != nil {
+		return err
+	}
+	first, last, err := ed.bufferLines(cmd)
+	if err != nil {
+		return err
+	}
+	r := lineRange(b, first, last-first+1)
+	off1, off2 := r.Off1, r.Off2
+	out, err := runShell(cmd.Arg, b.Slice(off1, off2))
+	if err != nil {
+		return err
+	}
+	if off2 == b.Len() && (off2 == off1 || b.Bytes(off2-1, off2)[0] != '\n') {
+		// the last line had no line break, so don't add one
+		out = bytes.TrimSuffix(out, []byte("\n"))
+	}
+	// one replacement, so that the lines are either filtered or kept
+	if err := b.Replace(off1, off2, out); err != nil {
+		return err
+	}
+	ed.focus.SetCursor(off1)
+	ed.showCursor()
+	ed.setMessage(fmt.Sprintf("%d lines filtered", last-first+1))
+	return nil
+}
+
+// :[range]> and :[range]< shift the lines right or left by shiftwidth,
+// by one more level for each additional > or < (e.g. :>>).
+func (ed *editor) cmdShift(dir int) func(cmd ex.Command) error {
+	return func(cmd ex.Command) error {
+		if err := ed.modifiable(); err != nil {
+			return err
+		}
+		levels := 1
+		for _, c := range cmd.Arg {
+			if string(c) != cmd.Name {
+				return fmt.Errorf("Trailing characters: %s", cmd.Arg)
+			}
+			levels++
+		}
+		b := ed.focus.Buffer()
+		first, last, err := ed.bufferLines(cmd)
+		if err != nil {
+			return err
+		}
+		if err := shiftLines(b, first, last, dir*levels, ed.focus.Options); err != nil {
+			return err
+		}
+		ed.focus.SetCursor(b.Line(last))
+		ed.focus.MoveCursor(motion.FirstNonBlank)
+		return nil
+	}
+}
+
+// runShell runs command with the shell, feeding it stdin if not nil.
+// Returns its output or an error including what it wrote to stderr.
+func runShell(command string, stdin io.Reader) ([]byte, error) {
+	var stdout bytes.Buffer
+	if err := runShellTo(command, stdin, &stdout); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// runShellTo is like runShell but writes the output to stdout.
+func runShellTo(command string, stdin io.Reader, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	c := shellCommand(command)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return shellError(command, &stderr, err)
+	}
+	return nil
+}
+
+// shellError returns the error for command having failed with err,
+// including what it wrote to stderr if anything.
+func shellError(command string, stderr *bytes.Buffer, err error) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s: %s", command, msg)
+	}
+	return fmt.Errorf("%s: %v", command, err)
+}
+
+// shellCommand returns the command running command with the user's
+// shell.
+func shellCommand(command string) *exec.Cmd {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "sh"
+	}
+	return exec.Command(shell, "-c", command)
+}
+
+// splitArgs splits s into its blank separated arguments.  A blank
+// preceded by a backslash is part of the argument.
+func splitArgs(s string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t'):
+			i++
+			arg.WriteByte(s[i])
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestCmdRead(t *testing.T) {
+	t.Setenv("SHELL", "sh")
+	tests := []keysTest{
+		{"a\nb\n", ":r !printf 'x\\n'<CR>", "a\nx\nb\n", 2},
+		{"a\nb\n", ":r !printf x<CR>", "a\nx\nb\n", 2},
+		{"a\nb\n", ":0r !printf x<CR>", "x\na\nb\n", 0},
+		{"a\nb", ":2r !printf 'x\\n'<CR>", "a\nb\nx", 4},
+		{"a\nb", ":2r !printf x<CR>", "a\nb\nx", 4},
+		{"a\nb\n", ":r !true<CR>", "a\nb\n", 0},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+)
+
+// opComment comments out the lines r touches, or uncomments them if
+// they are comments already (vim-commentary's gc), with the line
+// comments of the type of the buffer (see fileTypes).
+func opComment(ed *editor, r motion.Range) {
+	v := ed.focus
+	b := v.Buffer()
+	ft, ok := detectFileType(b.Name())
+	if !ok || ft.comment == "" {
+		ed.setError(errors.New("No comment syntax for this buffer"))
+		return
+	}
+	first := b.LineOfOffset(r.Off1)
+	last := first
+	if r.Off2 > r.Off1 {
+		last = b.LineOfOffset(r.Off2 - 1)
+	}
+	if err := toggleComments(b, first, last, ft.comment); err != nil {
+		ed.setError(err)
+	}
+	v.SetCursor(b.Line(first))
+	v.MoveCursor(motion.FirstNonBlank)
+}
+
+// toggleComments comments out the lines first to last with prefix, or,
+// if all of them but the blank ones are comments already, removes the
+// prefix and the blank following it.  The comments are added at the
+// smallest indentation of the lines, all with one ReplaceAll.
+func toggleComments(b *buf.Buf, first, last int, prefix string) error {
+	type line struct {
+		start int    // offset of the first non-blank
+		text  []byte // from there to the end of the line
+	}
+	var lines []line
+	commented := true
+	indent := -1
+	for n := first; n <= last; n++ {
+		start, end := b.LineRange(n)
+		text := b.Bytes(start, end)
+		rest := bytes.TrimLeft(text, " \t")
+		if len(rest) == 0 {
+			continue
+		}
+		n := len(text) - len(rest)
+		lines = append(lines, line{start + n, rest})
+		if !bytes.HasPrefix(rest, []byte(prefix)) {
+			commented = false
+		}
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	reps := make([]buf.Replacement, 0, len(lines))
+	for _, l := range lines {
+		if commented {
+			n := len(prefix)
+			if n < len(l.text) && l.text[n] == ' ' {
+				n++
+			}
+			reps = append(reps, buf.Replacement{Off1: l.start, Off2: l.start + n})
+		} else {
+			off := b.Line(b.LineOfOffset(l.start)) + indent
+			reps = append(reps, buf.Replacement{Off1: off, Off2: off, Text: []byte(prefix + " ")})
+		}
+	}
+	return b.ReplaceAll(reps)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestToggleComments(t *testing.T) {
+	tests := []struct {
+		text        string
+		first, last int
+		want        string
+	}{
+		{"a\nb\n", 1, 2, "// a\n// b\n"},
+		{"a\nb\n", 2, 2, "a\n// b\n"},
+		// at the smallest indentation
+		{"\tif x {\n\t\ty()\n\t}\n", 1, 3, "\t// if x {\n\t// \ty()\n\t// }\n"},
+		{"    a\n  b\n", 1, 2, "  //   a\n  // b\n"},
+		// mixed indentation sharing a tab
+		{"\ta\n\t    b\n", 1, 2, "\t// a\n\t//     b\n"},
+		// blank lines are left alone
+		{"a\n\n  \nb\n", 1, 4, "// a\n\n  \n// b\n"},
+		{"\n\n", 1, 2, "\n\n"},
+		// uncommenting, with or without the blank after the prefix
+		{"// a\n//b\n", 1, 2, "a\nb\n"},
+		{"\t// a\n\n\t//   b\n", 1, 3, "\ta\n\n\t  b\n"},
+		{"//\n", 1, 1, "\n"},
+		// a line that isn't a comment comments out all of them
+		{"// a\nb\n", 1, 2, "// // a\n// b\n"},
+	}
+	for _, test := range tests {
+		var b buf.Buf
+		b.Init()
+		b.Insert(0, []byte(test.text))
+		if err := toggleComments(&b, test.first, test.last, "//"); err != nil {
+			t.Errorf("toggleComments(%q, %d, %d): %v", test.text, test.first, test.last, err)
+		} else if got := b.String(); got != test.want {
+			t.Errorf("toggleComments(%q, %d, %d) gives %q expected %q", test.text, test.first, test.last, got, test.want)
+		}
+	}
+}
+
+func TestOpComment(t *testing.T) {
+	tests := []struct {
+		name, text, keys string
+		want             string
+	}{
+		{"x.go", "a\nb\nc\n", "gcj", "// a\n// b\nc\n"},
+		{"x.go", "a\n\tb\n", "jgcc", "a\n\t// b\n"},
+		{"x.go", "// a\n// b\n// c\n", "j2gcc", "// a\nb\nc\n"},
+		{"x.go", "// a\n// b\n", "gcj", "a\nb\n"},
+		{"x.sh", "a\n", "gcc", "# a\n"},
+		{"x.unknown", "a\n", "gcc", "a\n"},
+	}
+	for _, test := range tests {
+		ed := newTestEditor(t, test.text)
+		ed.focus.Buffer().SetName(test.name)
+		typeKeys(t, ed, test.keys)
+		if got := bufferText(ed); got != test.want {
+			t.Errorf("%q in %s with %s gives %q expected %q", test.text, test.name, test.keys, got, test.want)
+		}
+	}
+}
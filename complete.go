@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/theme"
+	"github.com/nsf/termbox-go"
+)
+
+// completion is the state of completing the text before the cursor of
+// the command line with Tab.
+type completion struct {
+	start       int // offset of the completed text in the command line
+	completions []string
+	current     int // index of the completion shown
+}
+
+// complete completes the text before the cursor using complete, which
+// returns the possible completions of the text and the offset of the
+// text they replace.  The first Tab completes as far as all completions
+// agree, the following ones cycle through them.
+func (c *cmdline) complete(complete func(text string) (start int, completions []string)) {
+	if cp := c.completion; cp != nil {
+		cp.current = (cp.current + 1) % len(cp.completions)
+		c.replace(cp.start, cp.completions[cp.current])
+		return
+	}
+	text := c.String()[:c.cursor]
+	start, completions := complete(text)
+	if len(completions) == 0 {
+		return
+	}
+	prefix := completions[0]
+	for _, s := range completions[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// a glob pattern isn't a prefix of its completions
+	typed := text[start:]
+	if len(completions) == 1 || len(prefix) > len(typed) && strings.HasPrefix(prefix, typed) {
+		c.replace(start, prefix)
+		return
+	}
+	c.completion = &completion{start: start, completions: completions}
+	c.replace(start, completions[0])
+}
+
+// replace replaces the text between start and the cursor by s.
+func (c *cmdline) replace(start int, s string) {
+	c.text.Delete(start, c.cursor)
+	c.text.Insert(start, []byte(s))
+	c.cursor = start + len(s)
+}
+
+// displayCompletions draws the completions cycled through into row y of scr,
+// w columns wide, the current one highlighted.  Returns false if there
+// are none.
+func (c *cmdline) displayCompletions(scr screen.Screen, y, w int) bool {
+	const coldef = termbox.ColorDefault
+	cp := c.completion
+	if cp == nil || y < 0 {
+		return false
+	}
+	// start with the first completion that leaves the current one visible
+	first, width := cp.current, 0
+	for first >= 0 {
+		width += utf8.RuneCountInString(cp.completions[first]) + 2
+		if width > w {
+			break
+		}
+		first--
+	}
+	if first < cp.current {
+		first++
+	}
+	x := 0
+	for i, s := range cp.completions[first:] {
+		style := theme.Current[theme.Normal]
+		if first+i == cp.current {
+			style = theme.Current[theme.Selection].Over(style)
+		}
+		for _, r := range s {
+			if x < w {
+				scr.SetCell(x, y, r, style.Fg, style.Bg)
+			}
+			x++
+		}
+		for j := 0; j < 2 && x < w; j++ {
+			scr.SetCell(x, y, ' ', coldef, coldef)
+			x++
+		}
+	}
+	for ; x < w; x++ {
+		scr.SetCell(x, y, ' ', coldef, coldef)
+	}
+	return true
+}
+
+// completeFiles completes arg as a file name: to the files in the
+// directory of arg whose names start with the rest of it, or to the
+// files matching arg if it is a glob pattern.  Directories end in a
+// slash.
+func completeFiles(arg string) []string {
+	var names []string
+	if strings.ContainsAny(arg, "*?[") {
+		names, _ = filepath.Glob(arg)
+	} else {
+		dir, base := filepath.Split(arg)
+		read := dir
+		if read == "" {
+			read = "."
+		}
+		entries, _ := os.ReadDir(read)
+		for _, e := range entries {
+			name := e.Name()
+			// like the shell only complete to hidden files if asked to
+			if strings.HasPrefix(name, base) && (strings.HasPrefix(base, ".") || !strings.HasPrefix(name, ".")) {
+				names = append(names, dir+name)
+			}
+		}
+	}
+	for i, name := range names {
+		if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+			names[i] += string(filepath.Separator)
+		}
+	}
+	return names
+}
+
+// completeBuffers completes arg to the names of the buffers containing
+// it.
+func (ed *editor) completeBuffers(arg string) []string {
+	var names []string
+	for _, e := range ed.bufs {
+		if name := e.b.Name(); name != "" && strings.Contains(name, arg) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
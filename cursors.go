@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/bgrundmann/e/textobject"
+)
+
+// eachCursor calls f once for each cursor of the focused window, the
+// additional ones (see view.View.AddCursor) being made the main cursor
+// while f runs.  Cursors ending up at the same place are merged.
+func (ed *editor) eachCursor(f func()) {
+	v := ed.focus
+	for i := range v.Cursors() {
+		v.SwapCursor(i)
+		f()
+		v.SwapCursor(i)
+	}
+	f()
+	v.MergeCursors()
+}
+
+// addCursor adds a cursor at the next occurrence of the word under the
+// cursor after the cursor added last, at the same place in the word.
+func (ed *editor) addCursor() error {
+	v := ed.focus
+	b := v.Buffer()
+	r, ok := textobject.InnerWord(b, v.Cursor())
+	word := string(b.Bytes(r.Off1, r.Off2))
+	if !ok || strings.TrimSpace(word) == "" {
+		return errors.New("No string under cursor")
+	}
+	re, err := regexp.Compile(wordPattern(word))
+	if err != nil {
+		return err
+	}
+	from := r.Off2
+	if cursors := v.Cursors(); len(cursors) > 0 {
+		from = cursors[len(cursors)-1] - (v.Cursor() - r.Off1) + len(word)
+	}
+	start, _, found := b.SearchForward(re, from)
+	if !found {
+		// wrap around
+		start, _, found = b.SearchForward(re, 0)
+	}
+	if !found || start == r.Off1 {
+		return errors.New("No more occurrences")
+	}
+	n := len(v.Cursors())
+	v.AddCursor(start + v.Cursor() - r.Off1)
+	if v.MergeCursors(); len(v.Cursors()) == n {
+		return errors.New("No more occurrences")
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/diff"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/theme"
+	"github.com/bgrundmann/e/vcs"
+	"github.com/bgrundmann/e/view"
+)
+
+// diffSigns show in the gutter how buffers differ from their files or
+// from the versions staged in git, see :diff signs and :diff git.
+type diffSigns struct {
+	files map[*buf.Buf]*fileDiff
+	shown map[*view.View]shownDiff
+}
+
+// A fileDiff is the difference between a buffer and its file, or the
+// version of it in the git index.  The
+// lines of the buffer are kept up to date with its change log, so that
+// only the lines changed need to be looked at again.
+type fileDiff struct {
+	git     bool      // whether compared with the index
+	stamp   fileStamp // of the file as read
+	disk    [][]byte  // lines of the file or of its version in the index
+	lines   [][]byte  // lines of the buffer as of seq
+	seq     int
+	signs   []view.LineSign
+	version int // incremented when the signs change
+}
+
+// shownDiff identifies the signs shown in a window.
+type shownDiff struct {
+	d       *fileDiff // nil if the window shows none
+	version int
+}
+
+// :diff shows the changes to the buffer since it was saved in unified
+// format, in a scratch buffer in a window split off the current one.
+// :diff signs shows them as signs in the gutter instead, updated as the
+// buffer changes, until :diff off.  :diff git shows the changes to the
+// version of the file staged in git the same way, like git diff.
+func (ed *editor) cmdDiff(cmd ex.Command) error {
+	b := ed.focus.Buffer()
+	switch cmd.Arg {
+	case "":
+		return ed.showDiff(b)
+	case "signs":
+		return ed.startDiffSigns(b, false)
+	case "git":
+		return ed.startDiffSigns(b, true)
+	case "off":
+		delete(ed.diffs.files, b)
+		return nil
+	}
+	return fmt.Errorf("Trailing characters: %s", cmd.Arg)
+}
+
+// showDiff shows the changes to b in a new scratch buffer.
+func (ed *editor) showDiff(b *buf.Buf) error {
+	if b.Name() == "" || ed.kind(b) != nil {
+		return errors.New("No file name")
+	}
+	text, _, _, err := readFile(b.Name())
+	if err != nil {
+		return err
+	}
+	disk, lines := splitLines(text), splitLines(b.Bytes(0, b.Len()))
+	out := diff.Unified(b.Name(), b.Name()+" (buffer)", disk, lines, diff.Lines(disk, lines), 3)
+	if out == nil {
+		ed.setMessage("No changes")
+		return nil
+	}
+	d := new(buf.Buf).Init()
+	d.Insert(0, out)
+	ed.addBuffer(d)
+	ed.bufEntry(d).scratch = true
+	ed.split(view.Horizontal)
+	ed.showBuffer(ed.focus, d)
+	ed.focus.SetCursor(0)
+	return nil
+}
+
+// startDiffSigns starts showing the changes to b as signs, to its
+// version in the git index if git.
+func (ed *editor) startDiffSigns(b *buf.Buf, git bool) error {
+	if b.Name() == "" || ed.kind(b) != nil {
+		return errors.New("No file name")
+	}
+	d := &fileDiff{
+		git:   git,
+		lines: splitLines(b.Bytes(0, b.Len())),
+		seq:   b.Seq(),
+	}
+	if err := d.read(b.Name()); err != nil {
+		return err
+	}
+	d.signs = diffLineSigns(diff.Lines(d.disk, d.lines))
+	if ed.diffs.files == nil {
+		ed.diffs.files = make(map[*buf.Buf]*fileDiff)
+		ed.diffs.shown = make(map[*view.View]shownDiff)
+	}
+	ed.diffs.files[b] = d
+	return nil
+}
+
+// read reads the lines of the file name, or of its version in the
+// index.
+func (d *fileDiff) read(name string) error {
+	var text []byte
+	var err error
+	if d.git {
+		var data []byte
+		if data, err = vcs.IndexVersion(name); err == nil {
+			text, _, _, err = decodeText(data)
+		}
+	} else {
+		text, _, _, err = readFile(name)
+	}
+	if err != nil {
+		return err
+	}
+	d.disk = splitLines(text)
+	d.stamp, _ = stampOf(name)
+	return nil
+}
+
+// updateDiffSigns updates the signs of the buffers changed or saved
+// since and shows them in the windows.
+func (ed *editor) updateDiffSigns() {
+	for b, d := range ed.diffs.files {
+		saved := ed.stamps[b] != d.stamp
+		if saved {
+			if err := d.read(b.Name()); err != nil {
+				delete(ed.diffs.files, b)
+				ed.setError(err)
+				continue
+			}
+		}
+		if b.Seq() != d.seq {
+			if edits := b.Changes(d.seq); edits != nil {
+				for _, e := range edits {
+					d.lines = applyEdit(d.lines, e)
+				}
+			} else {
+				// the log doesn't go back that far
+				d.lines = splitLines(b.Bytes(0, b.Len()))
+			}
+		}
+		if saved || b.Seq() != d.seq {
+			d.seq = b.Seq()
+			d.signs = diffLineSigns(diff.Lines(d.disk, d.lines))
+			d.version++
+		}
+	}
+	if ed.diffs.shown == nil {
+		return
+	}
+	ed.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+		v := leaf.View()
+		var shown shownDiff
+		if d := ed.diffs.files[v.Buffer()]; d != nil {
+			shown = shownDiff{d, d.version}
+		}
+		if ed.diffs.shown[v] == shown {
+			return
+		}
+		ed.diffs.shown[v] = shown
+		if shown.d != nil {
+			v.SetLineSigns(shown.d.signs)
+		} else {
+			v.SetLineSigns(nil)
+		}
+	})
+}
+
+// diffLineSigns returns the signs of the lines added, changed and
+// deleted by hunks.  Deleted lines are marked in the line above them,
+// or in the first line if there is none.
+func diffLineSigns(hunks []diff.Hunk) []view.LineSign {
+	var signs []view.LineSign
+	for _, h := range hunks {
+		switch {
+		case h.B1 == h.B2 && h.B1 == 0:
+			signs = append(signs, view.LineSign{Line: 1, Sign: '‾', Element: theme.DiffDeleted})
+		case h.B1 == h.B2:
+			signs = append(signs, view.LineSign{Line: h.B1, Sign: '_', Element: theme.DiffDeleted})
+		default:
+			sign, elem := '~', theme.DiffChanged
+			if h.A1 == h.A2 {
+				sign, elem = '+', theme.DiffAdded
+			}
+			for n := h.B1; n < h.B2; n++ {
+				signs = append(signs, view.LineSign{Line: n + 1, Sign: sign, Element: elem})
+			}
+		}
+	}
+	return signs
+}
+
+// applyEdit returns lines, the lines of a buffer each with its line
+// break, with the edit e of the buffer made to them.  Only the lines
+// the edit touches are split again.
+func applyEdit(lines [][]byte, e buf.Edit) [][]byte {
+	// lines i up to j hold the text edited, starting at start
+	i, start := 0, 0
+	for i < len(lines) && start+len(lines[i]) <= e.Off {
+		start += len(lines[i])
+		i++
+	}
+	if i == len(lines) && i > 0 && !bytes.HasSuffix(lines[i-1], newline) {
+		// appending to the last line
+		i--
+		start -= len(lines[i])
+	}
+	end := e.Off
+	if e.Kind == buf.Deletion {
+		end += len(e.Text)
+	}
+	j, stop := i, start
+	for j < len(lines) && (j == i || stop < end) {
+		stop += len(lines[j])
+		j++
+	}
+	var text []byte
+	for _, l := range lines[i:j] {
+		text = append(text, l...)
+	}
+	rel := e.Off - start
+	edited := append([]byte(nil), text[:rel]...)
+	if e.Kind == buf.Deletion {
+		edited = append(edited, text[rel+len(e.Text):]...)
+	} else {
+		edited = append(edited, e.Text...)
+		edited = append(edited, text[rel:]...)
+	}
+	text = edited
+	if !bytes.HasSuffix(text, newline) && j < len(lines) {
+		// the line break was deleted, the next line joins
+		text = append(text, lines[j]...)
+		j++
+	}
+	return append(append(lines[:i:i], splitLines(text)...), lines[j:]...)
+}
@@ -0,0 +1,122 @@
+// Package diff computes the differences between two sequences of lines
+// using Myers' O(ND) algorithm.
+package diff
+
+import "bytes"
+
+// A Hunk replaces the lines A1 up to (excluding) A2 of the old lines by
+// the lines B1 up to (excluding) B2 of the new ones.  Either range may
+// be empty.
+type Hunk struct {
+	A1, A2 int
+	B1, B2 int
+}
+
+// maxEdits is the number of single line edits after which Lines gives up
+// looking for a minimal diff, as that takes time and memory quadratic in
+// their number.
+const maxEdits = 4096
+
+// Lines returns the hunks turning a into b, ordered by position.  The
+// diff is minimal unless a and b differ in a lot of lines.
+func Lines(a, b [][]byte) []Hunk {
+	// the lines common to the start and the end don't need to be
+	// looked at
+	pre := 0
+	for pre < len(a) && pre < len(b) && bytes.Equal(a[pre], b[pre]) {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && bytes.Equal(a[len(a)-1-suf], b[len(b)-1-suf]) {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	hunks := myers(a, b)
+	if hunks == nil {
+		// too many differences, replace everything
+		hunks = []Hunk{{0, len(a), 0, len(b)}}
+	}
+	for i := range hunks {
+		hunks[i].A1 += pre
+		hunks[i].A2 += pre
+		hunks[i].B1 += pre
+		hunks[i].B2 += pre
+	}
+	return hunks
+}
+
+// myers returns the hunks turning a into b or nil if there are more than
+// maxEdits edits.
+func myers(a, b [][]byte) []Hunk {
+	n, m := len(a), len(b)
+	max := n + m
+	// v[max+k] is the furthest x reached on diagonal k = x - y
+	v := make([]int, 2*max+2)
+	// trace[d] holds v[k] for -d < k < d before step d
+	var trace [][]int
+	for d := 0; d <= max && d <= maxEdits; d++ {
+		if d == 0 {
+			trace = append(trace, nil)
+		} else {
+			trace = append(trace, append([]int(nil), v[max-d+1:max+d]...))
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1] // insertion
+			} else {
+				x = v[max+k-1] + 1 // deletion
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[x], b[y]) {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack follows the path found by myers back from n, m and returns
+// the hunks along it.
+func backtrack(trace [][]int, n, m int) []Hunk {
+	var edits []Hunk // single line edits, last first
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		// skip the lines in common following the edit
+		for x > prevX && y > prevY {
+			x--
+			y--
+		}
+		edits = append(edits, Hunk{prevX, x, prevY, y})
+		x, y = prevX, prevY
+	}
+	// merge adjacent edits
+	var hunks []Hunk
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if l := len(hunks) - 1; l >= 0 && hunks[l].A2 == e.A1 && hunks[l].B2 == e.B1 {
+			hunks[l].A2 = e.A2
+			hunks[l].B2 = e.B2
+		} else {
+			hunks = append(hunks, e)
+		}
+	}
+	return hunks
+}
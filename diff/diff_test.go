@@ -0,0 +1,128 @@
+package diff
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func lines(s string) [][]byte {
+	var res [][]byte
+	for _, l := range strings.Split(s, "") {
+		res = append(res, []byte(l))
+	}
+	return res
+}
+
+// apply applies hunks to a.
+func apply(a, b [][]byte, hunks []Hunk) [][]byte {
+	var res [][]byte
+	i := 0
+	for _, h := range hunks {
+		res = append(res, a[i:h.A1]...)
+		res = append(res, b[h.B1:h.B2]...)
+		i = h.A2
+	}
+	return append(res, a[i:]...)
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int // number of lines inserted or deleted
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"abcabba", "cbabac", 5},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abcd", "axcd", 2},
+		{"abcdef", "abdefx", 2},
+	}
+	for _, test := range tests {
+		a, b := lines(test.a), lines(test.b)
+		hunks := Lines(a, b)
+		if got := apply(a, b, hunks); !bytes.Equal(bytes.Join(got, nil), []byte(test.b)) {
+			t.Errorf("%q -> %q: hunks %v give %q", test.a, test.b, hunks, bytes.Join(got, nil))
+		}
+		edits := 0
+		for _, h := range hunks {
+			edits += h.A2 - h.A1 + h.B2 - h.B1
+		}
+		if edits != test.edits {
+			t.Errorf("%q -> %q: expected %d edits got %d (%v)", test.a, test.b, test.edits, edits, hunks)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() string {
+		var s []byte
+		for i := r.Intn(30); i > 0; i-- {
+			s = append(s, byte('a'+r.Intn(4)))
+		}
+		return string(s)
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := lines(random()), lines(random())
+		if got := apply(a, b, Lines(a, b)); !bytes.Equal(bytes.Join(got, nil), bytes.Join(b, nil)) {
+			t.Fatalf("%q -> %q: got %q", bytes.Join(a, nil), bytes.Join(b, nil), bytes.Join(got, nil))
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	split := func(s string) [][]byte {
+		return bytes.SplitAfter([]byte(s), []byte("\n"))
+	}
+	a := split("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12")
+	b := split("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12\n13")
+	want := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -8,5 +8,5 @@
+ 8
+ 9
+ 10
+-11
+-12
+\ No newline at end of file
++12
++13
+\ No newline at end of file
+`
+	if got := string(Unified("a", "b", a, b, Lines(a, b), 3)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	want = `--- a
++++ b
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -10,3 +10,3 @@
+ 10
+-11
+-12
+\ No newline at end of file
++12
++13
+\ No newline at end of file
+`
+	if got := string(Unified("a", "b", a, b, Lines(a, b), 1)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := Unified("a", "b", a, a, nil, 3); got != nil {
+		t.Errorf("got %q for no hunks", got)
+	}
+}
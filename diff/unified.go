@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Unified returns the hunks turning a into b in the unified format of
+// diff -u, with context lines of context around the changes, a and b
+// being named nameA and nameB.  The lines are expected to end with
+// their line breaks, one without is marked like diff does.
+func Unified(nameA, nameB string, a, b [][]byte, hunks []Hunk, context int) []byte {
+	if len(hunks) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	line := func(prefix byte, l []byte) {
+		out.WriteByte(prefix)
+		out.Write(l)
+		if !bytes.HasSuffix(l, []byte{'\n'}) {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	for len(hunks) > 0 {
+		// the hunks whose context touches or overlaps go together
+		n := 1
+		for n < len(hunks) && hunks[n].A1-hunks[n-1].A2 <= 2*context {
+			n++
+		}
+		group := hunks[:n]
+		hunks = hunks[n:]
+		first, last := group[0], group[n-1]
+		a1 := max(first.A1-context, 0)
+		a2 := min(last.A2+context, len(a))
+		b1 := first.B1 - (first.A1 - a1)
+		b2 := last.B2 + (a2 - last.A2)
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", span(a1, a2), span(b1, b2))
+		i := a1
+		for _, h := range group {
+			for ; i < h.A1; i++ {
+				line(' ', a[i])
+			}
+			for _, l := range a[h.A1:h.A2] {
+				line('-', l)
+			}
+			for _, l := range b[h.B1:h.B2] {
+				line('+', l)
+			}
+			i = h.A2
+		}
+		for ; i < a2; i++ {
+			line(' ', a[i])
+		}
+	}
+	return out.Bytes()
+}
+
+// span formats the lines from up to to of a hunk header: the number
+// of the first line and the number of lines, which is left out if it
+// is 1.  An empty span is given by the number of the line before it.
+func span(from, to int) string {
+	switch to - from {
+	case 0:
+		return fmt.Sprintf("%d,0", from)
+	case 1:
+		return fmt.Sprint(from + 1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// A bufKind is the kind of a special buffer, one generated by the
+// editor rather than holding a file, like the listing of a directory.
+// Special buffers are read-only.  In normal mode the keys bound in the
+// keymap of their mode take precedence over the normal mode ones.
+type bufKind struct {
+	mode    string                      // keymap mode, e.g. "directory"
+	actions map[string]func(ed *editor) // the actions bound in mode
+}
+
+// directoryKind is the kind of the buffers listing a directory.  <CR>
+// opens the file or directory of the current line, - the parent
+// directory.
+var directoryKind = &bufKind{mode: "directory"}
+
+func init() {
+	// not in the declaration, as opening an entry refers to directoryKind
+	directoryKind.actions = map[string]func(ed *editor){
+		"open-entry":       func(ed *editor) { ed.openEntry(ed.currentLine()) },
+		"parent-directory": func(ed *editor) { ed.openEntry("..") },
+	}
+}
+
+// kindOf returns the kind of the buffer named name: directoryKind for a
+// directory, otherwise nil.
+func kindOf(name string) *bufKind {
+	if name == "" {
+		return nil
+	}
+	if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+		return directoryKind
+	}
+	return nil
+}
+
+// kind returns the kind of b, nil unless it is a special buffer.
+func (ed *editor) kind(b *buf.Buf) *bufKind {
+	if e := ed.bufEntry(b); e != nil {
+		return e.kind
+	}
+	return nil
+}
+
+// modifyingActions are the actions of normal and visual mode changing
+// the buffer, refused in special buffers.
+var modifyingActions = map[string]bool{
+	"insert":           true,
+	"append":           true,
+	"put-after":        true,
+	"put-before":       true,
+	"join":             true,
+	"delete":           true,
+	"change":           true,
+	"shift-right":      true,
+	"shift-left":       true,
+	"filter":           true,
+	"toggle-case":      true,
+	"lowercase":        true,
+	"uppercase":        true,
+	"toggle-case-char": true,
+	"increment":        true,
+	"decrement":        true,
+	"surround":         true,
+	"comment":          true,
+}
+
+// modifiable returns an error if the buffer of the focused window must
+// not be changed.
+func (ed *editor) modifiable() error {
+	if ed.kind(ed.focus.Buffer()) != nil {
+		return errors.New("Cannot make changes, buffer is read-only")
+	}
+	return nil
+}
+
+// listDirectory returns the listing of the directory dir: "../" followed
+// by its entries one per line, the directories first and ending in a
+// slash.
+func listDirectory(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+	var listing bytes.Buffer
+	listing.WriteString("../\n")
+	for _, e := range entries {
+		listing.WriteString(e.Name())
+		if e.IsDir() {
+			listing.WriteByte(filepath.Separator)
+		}
+		listing.WriteByte('\n')
+	}
+	return listing.Bytes(), nil
+}
+
+// currentLine returns the line of the cursor in the focused window
+// without its line break.
+func (ed *editor) currentLine() string {
+	v := ed.focus
+	pos, _ := v.Buffer().PositionFromOffset(v.Cursor())
+	var line string
+	v.Buffer().EachLine(pos.Line, pos.Line, func(n int, text []byte) bool {
+		line = string(text)
+		return false
+	})
+	return line
+}
+
+// openEntry shows the file or directory name of the directory listed in
+// the focused window in its place.
+func (ed *editor) openEntry(name string) {
+	if name == "" {
+		return
+	}
+	dir := ed.focus.Buffer().Name()
+	b, err := ed.loadBuffer(filepath.Join(dir, name))
+	if err == nil && ed.kind(b) == directoryKind {
+		// the directory may have changed since it was last listed
+		err = ed.reload(b)
+	}
+	if err == nil {
+		err = ed.switchBuffer(b, false)
+	}
+	if err != nil {
+		ed.setError(err)
+	}
+}
@@ -2,27 +2,127 @@ package main
 
 import "github.com/nsf/termbox-go"
 import "github.com/bgrundmann/e/buf"
-import "github.com/bgrundmann/e/motion"
+import "github.com/bgrundmann/e/highlight"
 import "github.com/bgrundmann/e/view"
 import "io"
+import "bufio"
 import "os"
+import "path/filepath"
 import "flag"
 import "fmt"
+import "strings"
+import "sort"
 import "log"
-import "encoding/json"
+import "errors"
+import "time"
+import "github.com/bgrundmann/e/recording"
+import "github.com/bgrundmann/e/screen"
 import "runtime/pprof"
 
-// AppendFile appends the contents of file to buf.
-func AppendFile(buf *buf.Buf, filename string) error {
-	f, err := os.Open(filename)
+// LoadFile returns a new buffer named filename holding the contents of
+// the file.  A file that doesn't exist yet results in an empty buffer,
+// a directory in the listing of its entries (see listDirectory).
+func LoadFile(filename string) (*buf.Buf, error) {
+	var b buf.Buf
+	if filename == "" {
+		b.Init()
+		return &b, nil
+	}
+	if kindOf(filename) == directoryKind {
+		listing, err := listDirectory(filename)
+		if err != nil {
+			return nil, err
+		}
+		b.Init()
+		b.SetName(filename)
+		b.Write(listing)
+		b.MarkSaved()
+		return &b, nil
+	}
+	if err := b.InitFromFile(filename); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SaveFile writes the contents of buf to file using the buffer's
+// line ending.  Symbolic links are followed.  The file is replaced
+// by a new one written next to it, so that a failure leaves it intact,
+// unless that would lose something besides the contents: its other hard
+// links, its owner or its extended attributes (see replaceable and
+// chown).  Such
+// files and those in directories we may not write are overwritten in
+// place, which is safe as the buffer doesn't refer to the file on disk
+// (see buf.InitFromFile).
+func SaveFile(buf *buf.Buf, filename string) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	perm := os.FileMode(0644)
+	fi, err := os.Stat(filename)
+	if err == nil {
+		perm = fi.Mode().Perm()
+		if !fi.Mode().IsRegular() || !replaceable(filename, fi) {
+			return overwriteFile(buf, filename, perm)
+		}
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return overwriteFile(buf, filename, perm)
+	}
+	tmp := f.Name()
+	if fi != nil && chown(f, fi) != nil {
+		f.Close()
+		os.Remove(tmp)
+		return overwriteFile(buf, filename, perm)
+	}
+	err = buf.WriteFile(f)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return overwriteFile(buf, filename, perm)
+	}
+	return nil
+}
+
+// overwriteFile truncates file, creating it with perm if necessary,
+// and writes the contents of buf to it.  Text that can't be converted
+// to the buffer's encoding is reported before the file is touched.
+func overwriteFile(buf *buf.Buf, filename string, perm os.FileMode) error {
+	if err := buf.CheckEncoding(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = io.Copy(buf, f)
+	err = buf.WriteFile(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	return err
 }
 
+// showBuffer makes v display b, choosing a highlighter based on
+// the type of the buffer's file.
+func showBuffer(v *view.View, b *buf.Buf) {
+	v.SetBuffer(b)
+	if ft, _ := detectFileType(b.Name()); ft.name == "go" {
+		v.SetHighlighter(highlight.NewGo(b))
+	} else {
+		v.SetHighlighter(nil)
+	}
+}
+
 type RunMode int
 const (
 	RunModeRegular RunMode = iota
@@ -33,8 +133,14 @@ const (
 type commandLineArgs struct {
 	runMode RunMode
 	recordingFile string // name of the file to record/replay
+	realtime bool // replay events at the pace they were recorded
+	batch bool // run without terminal
 	cpuprofile string
 	initialFiles []string
+	splitFiles bool // open one window per initial file
+	splitDir view.SplitDirection
+	session string // file of the session to restore
+	terminal string // name of the terminal backend, see terminals
 } 
 
 func parseCommandLine() commandLineArgs {
@@ -42,13 +148,28 @@ func parseCommandLine() commandLineArgs {
 	var args commandLineArgs
 	flag.StringVar(&recordFile, "record", "", "record all events to file")
 	flag.StringVar(&replayFile, "replay", "", "replay all events from file")
+	flag.BoolVar(&args.realtime, "realtime", false, "replay events with their recorded timing")
+	flag.BoolVar(&args.batch, "batch", false, "run without terminal executing the ex commands read from stdin (or the events of -replay)")
 	flag.StringVar(&args.cpuprofile, "cpuprofile", "", "write cpu profile to file")
+	splitH := flag.Bool("o", false, "open one window per file, stacked")
+	splitV := flag.Bool("O", false, "open one window per file, side by side")
+	flag.StringVar(&args.session, "S", "", "restore the session saved in file by :mksession")
+	flag.StringVar(&args.terminal, "terminal", "termbox", "terminal backend: " + strings.Join(terminalNames(), ", "))
 	flag.Parse()
+	if *splitH || *splitV {
+		args.splitFiles = true
+		if *splitV {
+			args.splitDir = view.Vertical
+		}
+	}
 	args.runMode = RunModeRegular
 	if recordFile != "" && replayFile != "" {
 		fmt.Fprintf(os.Stderr, "Must specify only one of record/replay!\n")
 		flag.PrintDefaults()
 		os.Exit(1)
+	} else if recordFile != "" && args.batch {
+		fmt.Fprintf(os.Stderr, "Can't record in batch mode!\n")
+		os.Exit(1)
 	} else if recordFile != "" {
 		args.runMode = RunModeRecord
 		args.recordingFile = recordFile
@@ -57,53 +178,141 @@ func parseCommandLine() commandLineArgs {
 		args.recordingFile = replayFile
 	} 
 	args.initialFiles = flag.Args()
+	if args.session != "" && (len(args.initialFiles) > 0 || args.runMode != RunModeRegular) {
+		fmt.Fprintf(os.Stderr, "Can't restore a session together with files or a recording!\n")
+		os.Exit(1)
+	}
 	return args
 } 
 
 // All init* functions below setup some part of the subsystem and return at least
 // a cleanup function that should be run when main exits (via defer).
 
-func initTermbox() func() {
-	err := termbox.Init()
-	if err != nil {
-		panic(err)
+// terminals are the terminal backends by the name given to -terminal.
+// Building with the tag tcell adds "tcell".
+var terminals = map[string]func() (screen.Terminal, error){
+	"termbox": initTermbox,
+}
+
+func terminalNames() []string {
+	var names []string
+	for name := range terminals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func initTerminal(name string) (screen.Terminal, error) {
+	start, ok := terminals[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown terminal %q (have %s)", name, strings.Join(terminalNames(), ", "))
+	}
+	return start()
+}
+
+func initTermbox() (screen.Terminal, error) {
+	if err := termbox.Init(); err != nil {
+		return nil, err
 	}
 	termbox.SetInputMode(termbox.InputEsc)
-	return termbox.Close
+	return screen.Termbox{}, nil
 } 
 
-func initEventSource(args commandLineArgs) (nextEvent func() termbox.Event, cleanup func()) {
+// hashFiles returns the description of the files the editor was
+// started on as stored in recordings.
+func hashFiles(names []string) []recording.File {
+	files := make([]recording.File, 0, len(names))
+	for _, name := range names {
+		f, err := recording.HashFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// hashBuffers returns the description of buffers as stored in recordings.
+func hashBuffers(buffers []*buf.Buf) []recording.File {
+	files := make([]recording.File, 0, len(buffers))
+	for _, b := range buffers {
+		files = append(files, recording.HashBuffer(b))
+	}
+	return files
+}
+
+// initEventSource returns the function delivering the events and the
+// function to be called with the buffers at the end of the session, which
+// completes a recording or checks the end state of a replay.
+func initEventSource(args commandLineArgs, term screen.Terminal) (nextEvent func() termbox.Event, finish func([]*buf.Buf) error, cleanup func()) {
 	switch args.runMode {
 	case RunModeRegular:
 		// nothing to be done
-		return termbox.PollEvent, func() {}
+		return term.PollEvent, func([]*buf.Buf) error { return nil }, func() {}
 	case RunModeReplay:
 		f, err := os.Open(args.recordingFile)
 		if err != nil {
 			log.Fatal(err)
 		} 
-		dec := json.NewDecoder(f)
+		rd, err := recording.NewReader(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := recording.Verify(rd.Header().Files, hashFiles(args.initialFiles)); err != nil {
+			log.Fatalf("files differ from the recording: %v", err)
+		}
+		start := time.Now()
+		// the first event tells the size of the recorded screen
+		h := rd.Header()
+		resized := false
 		return func() termbox.Event {
-			var ev *termbox.Event
-			if err := dec.Decode(&ev); err != nil {
-				log.Fatal(err)
+			if !resized {
+				resized = true
+				return termbox.Event{Type: termbox.EventResize, Width: h.Width, Height: h.Height}
+			}
+			ev, err := rd.Next()
+			if err == io.EOF {
+				err = errors.New("recording ended before the editor quit")
+			}
+			if err != nil {
+				return termbox.Event{Type: termbox.EventError, Err: err}
+			}
+			if args.realtime {
+				time.Sleep(time.Until(start.Add(ev.Time)))
 			}
-			return *ev
-		} , func() {
+			return ev.Event
+		}, func(buffers []*buf.Buf) error {
+			if _, err := rd.Next(); err != io.EOF {
+				return errors.New("editor quit before the end of the recording")
+			}
+			if err := recording.Verify(rd.End(), hashBuffers(buffers)); err != nil {
+				return fmt.Errorf("replay differs from the recording (made by %q, replayed by %q): %v",
+					rd.Header().Editor, recording.EditorVersion(), err)
+			}
+			return nil
+		}, func() {
 			f.Close()
 		}
 	case RunModeRecord:
-		f, err := os.OpenFile(args.recordingFile, os.O_APPEND | os.O_WRONLY | os.O_CREATE, 0600)
+		f, err := os.OpenFile(args.recordingFile, os.O_TRUNC | os.O_WRONLY | os.O_CREATE, 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		width, height := term.Size()
+		w, err := recording.NewWriter(f, recording.Header{
+			Files: hashFiles(args.initialFiles), Width: width, Height: height})
 		if err != nil {
 			log.Fatal(err)
 		}
-		enc := json.NewEncoder(f)
 		return func() termbox.Event {
-			ev := termbox.PollEvent()
-			if err := enc.Encode(&ev); err != nil {
+			ev := term.PollEvent()
+			if err := w.Event(ev); err != nil {
 				log.Fatal(err)
 			} 
 			return ev
+		}, func(buffers []*buf.Buf) error {
+			return w.End(hashBuffers(buffers))
 		}, func() {
 			f.Close()
 		} 
@@ -112,17 +321,37 @@ func initEventSource(args commandLineArgs) (nextEvent func() termbox.Event, clea
 	} 
 } 
 
-func initBufferAndView(v *view.View, args commandLineArgs) func() {
-	var b buf.Buf
-	b.Init()
-	v.Init(&b)
-	if len(args.initialFiles) > 0 {
-		if err := AppendFile(&b, args.initialFiles[0]); err != nil {
+// initLayout creates the window layout, one window per initial file
+// if requested and returns the focused view.
+func initLayout(args commandLineArgs) (*view.Layout, *view.View, func()) {
+	newView := func(filename string) *view.View {
+		b, err := LoadFile(filename)
+		if err != nil {
 			log.Fatal(err)
-		} 
-	} 
-	return func() {}
-} 
+		}
+		var v view.View
+		v.Init(b)
+		showBuffer(&v, b)
+		return &v
+	}
+	files := args.initialFiles
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	if !args.splitFiles {
+		files = files[:1]
+	}
+	// Split puts new windows before the split one, so start with the
+	// last file and work backwards.
+	focus := newView(files[len(files)-1])
+	layout := view.NewLayout(focus)
+	leaf := layout
+	for i := len(files) - 2; i >= 0; i-- {
+		focus = newView(files[i])
+		leaf = leaf.Split(args.splitDir, focus)
+	}
+	return layout, focus, func() {}
+}
 
 func initProfiling(args commandLineArgs) func() {
 	if args.cpuprofile != "" {
@@ -138,41 +367,61 @@ func initProfiling(args commandLineArgs) func() {
 } 
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runBatch executes the ex commands read from r, one per line, stopping
+// at the first one that fails.  Empty lines and lines starting with "
+// are ignored.
+func runBatch(ed *editor, r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; !ed.quit && sc.Scan(); n++ {
+		line := strings.TrimPrefix(strings.TrimSpace(sc.Text()), ":")
+		if line == "" || strings.HasPrefix(line, "\"") {
+			continue
+		}
+		if err := ed.commands.Execute(line); err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	ed.exit()
+	return nil
+}
+
+func run() error {
 	args := parseCommandLine()
-	cleanup := initTermbox(); defer cleanup()
-	nextEvent, cleanup := initEventSource(args); defer cleanup()
-	var v view.View
-	cleanup = initBufferAndView(&v, args); defer cleanup()
+	var scr screen.Screen = screen.NewMemory(80, 25)
+	var term screen.Terminal
+	if !args.batch {
+		var err error
+		if term, err = initTerminal(args.terminal); err != nil {
+			return err
+		}
+		defer term.Close()
+		scr = term
+	}
+	layout, v, cleanup := initLayout(args); defer cleanup()
+	ed := newEditor(scr, layout, v)
+	if args.session != "" {
+		if err := ed.loadSession(args.session); err != nil {
+			return err
+		}
+	}
+	if args.batch && args.runMode == RunModeRegular {
+		return runBatch(ed, os.Stdin)
+	}
+	nextEvent, finish, cleanup := initEventSource(args, term); defer cleanup()
 	// not that interested in startup and tear down cost
 	// so let's start profiling only now
 	cleanup = initProfiling(args); defer cleanup()
 
-mainloop:
-	for {
-		v.Display()
-		switch ev := nextEvent(); ev.Type {
-		case termbox.EventKey:
-			switch ev.Key {
-			case termbox.KeyEsc:
-				break mainloop
-			case termbox.KeyPgdn:
-				v.PageDown()
-			case termbox.KeyPgup:
-				v.PageUp()
-			default:
-				switch ev.Ch {
-				case 'l':
-					v.MoveCursor(motion.RuneForward)
-				case 'h':
-					v.MoveCursor(motion.RuneBackward)
-				case 'j':
-					v.MoveCursor(motion.LineForward)
-				case 'k':
-					v.MoveCursor(motion.LineBackward)
-				}
-			}
-		case termbox.EventError:
-			panic(ev.Err)
-		}
+	if err := ed.loop(nextEvent, args.batch); err != nil {
+		return err
 	}
+	return finish(ed.buffers())
 }
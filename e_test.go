@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestSaveFileUnencodable(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "latin1.txt")
+	if err := os.WriteFile(name, []byte("caf\xe9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a second link makes SaveFile overwrite the file in place
+	if err := os.Link(name, filepath.Join(dir, "link.txt")); err != nil {
+		t.Skip(err)
+	}
+	b, err := LoadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Encoding() != buf.Latin1 {
+		t.Fatalf("encoding %v expected %v", b.Encoding(), buf.Latin1)
+	}
+	b.Insert(0, []byte("€ "))
+	if err := SaveFile(b, name); err == nil {
+		t.Error("saved € as latin1")
+	}
+	if data, err := os.ReadFile(name); err != nil || string(data) != "caf\xe9\n" {
+		t.Errorf("file changed to %q (%v)", data, err)
+	}
+}
+
+func TestSaveFileInPlace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(name, []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Link(name, link); err != nil {
+		t.Skip(err)
+	}
+	b, err := LoadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Insert(6, []byte("big "))
+	if err := SaveFile(b, name); err != nil {
+		t.Fatal(err)
+	}
+	// both links see the new contents, which the buffer still holds
+	for _, n := range []string{name, link} {
+		if data, err := os.ReadFile(n); err != nil || string(data) != "hello\nbig world\n" {
+			t.Errorf("%s: got %q (%v)", n, data, err)
+		}
+	}
+	if b.String() != "hello\nbig world\n" {
+		t.Errorf("buffer changed to %q", b.String())
+	}
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/jobs"
+	"github.com/bgrundmann/e/keymap"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/options"
+	"github.com/bgrundmann/e/register"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/swap"
+	"github.com/bgrundmann/e/theme"
+	"github.com/bgrundmann/e/view"
+	"github.com/bgrundmann/e/wordindex"
+	"github.com/nsf/termbox-go"
+)
+
+// mode is the editing mode determining how keys are interpreted.
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeInsert
+	modeCmdline
+	modeSearch
+	modeVisual
+	modePicker
+)
+
+// modeName returns the name of the mode shown in the status line of
+// the focused view.
+func (ed *editor) modeName() string {
+	switch ed.mode {
+	case modeInsert:
+		return "INSERT"
+	case modeVisual:
+		switch ed.focus.SelectionKind() {
+		case view.SelectLines:
+			return "VISUAL LINE"
+		case view.SelectBlock:
+			return "VISUAL BLOCK"
+		}
+		return "VISUAL"
+	}
+	return ""
+}
+
+// editor holds the state of the whole editor that is not specific
+// to a single view.
+type editor struct {
+	screen   screen.Screen // what display draws on
+	layout   *view.Layout  // windows of the current tab page
+	focus    *view.View    // view receiving the key strokes
+	tabs     []tabPage
+	tab      int         // index of the current tab page
+	bufs     []*bufEntry // the buffer list
+	picker   picker
+	mode     mode
+	cmdline  cmdline
+	commands ex.Dispatcher
+	message  string // shown in the last row when not entering a command
+	isError  bool   // message is an error message
+	overlaid bool   // message has several lines drawn over the windows
+	// the messages shown so far, see :messages
+	messagesBuf *buf.Buf
+	search      searchState
+	block       *blockInsert // text being inserted into the lines of a block or nil
+	// word being completed in insert mode or nil
+	completion *wordCompletion
+	wordIndex  map[*buf.Buf]*wordindex.Index // of the buffers completed from
+	popupShown bool                          // a popup was drawn over the windows
+	abbrevs    abbreviations
+	snippet    *activeSnippet // snippet whose placeholders are visited with Tab or nil
+	quickfix   quickfix
+	options    *options.Options
+	bufOptions map[*buf.Buf]*options.Local   // options set for a buffer
+	winOptions map[*view.View]*options.Local // options set for a window
+	hooks      []hook
+	plugins    plugins
+	scripts    scripts
+	lsp        languageServer
+	diffs      diffSigns
+	blames     map[*view.View]*view.View // blame windows and the windows of their files
+	tags       tagState
+	jobs       *jobs.Runner
+	jobOutput  map[*jobs.Job]*jobOutput
+	messages   *mailbox // posted by the subsystems working in the background
+	registers  register.Registers
+	swaps      map[*buf.Buf]*swap.File          // swap files of the buffers shown
+	stamps     map[*buf.Buf]fileStamp           // versions of the files of the buffers shown
+	texts      map[*buf.Buf]*fileText           // what the files of the buffers shown were when read or written
+	marks      map[*buf.Buf]map[rune]buf.Marker // named marks of the buffers
+	width      int                              // size of the screen as of
+	height     int                              // the last resize
+	register   rune                             // register selected via " for the next command or 0
+	keymaps    keymap.Keymaps
+	keys       []string // keys of an incomplete key sequence
+	pending    Operator // operator waiting for its motion (e.g. d) or nil
+	opName     string   // action that started the pending operator
+	opKey      string   // last key of the pending operator (c of gc)
+	opCount    int      // count given before the pending operator
+	// find waiting for the rune to search for (after f) or ""
+	findAction string
+	lastFind   findState
+	// mark action waiting for the name of the mark (after m, ` or ') or ""
+	markAction string
+	// surround command waiting for its delimiters (see surroundKey) or ""
+	surround       string
+	surroundRanges []motion.Range // text ys surrounds
+	surroundOld    rune           // delimiter cs replaces, once typed
+	count          int            // count typed so far or 0
+	quit           bool
+	// true if the next key names a register (after ")
+	selectingRegister bool
+}
+
+func newEditor(s screen.Screen, layout *view.Layout, focus *view.View) *editor {
+	ed := &editor{
+		screen: s,
+		layout: layout,
+		focus:  focus,
+		swaps:  make(map[*buf.Buf]*swap.File),
+		stamps: make(map[*buf.Buf]fileStamp),
+		texts:  make(map[*buf.Buf]*fileText),
+		marks:  make(map[*buf.Buf]map[rune]buf.Marker),
+		tabs:   []tabPage{{layout, focus}},
+	}
+	ed.messagesBuf = newMessagesBuffer()
+	ed.options = newOptions()
+	ed.bufOptions = make(map[*buf.Buf]*options.Local)
+	ed.winOptions = make(map[*view.View]*options.Local)
+	ed.options.Observe(ed.optionChanged)
+	ed.hooks = append([]hook(nil), builtinHooks...)
+	ed.messages = newMailbox()
+	ed.jobs = jobs.NewRunner()
+	go ed.forwardJobEvents()
+	ed.jobOutput = make(map[*jobs.Job]*jobOutput)
+	ed.blames = make(map[*view.View]*view.View)
+	ed.wordIndex = make(map[*buf.Buf]*wordindex.Index)
+	ed.registerCommands()
+	km, err := loadKeymaps()
+	if err != nil {
+		ed.setError(err)
+	}
+	ed.keymaps = km
+	if err := loadConfig(themeFile, theme.Current.Load); err != nil {
+		ed.setError(err)
+	}
+	ed.abbrevs = abbreviations{}
+	if err := loadConfig(abbrevFile, ed.abbrevs.Load); err != nil {
+		ed.setError(err)
+	}
+	layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+		ed.addBuffer(leaf.View().Buffer())
+		ed.protect(leaf.View().Buffer())
+		ed.watch(leaf.View().Buffer())
+	})
+	ed.runInit()
+	return ed
+}
+
+// protect starts logging the changes to b to its swap file.  If there
+// already is a swap file, it is left alone so that it can be recovered
+// with :recover.
+func (ed *editor) protect(b *buf.Buf) {
+	if b.Name() == "" || ed.swaps[b] != nil || ed.kind(b) != nil {
+		return
+	}
+	if swap.Exists(b.Name()) {
+		ed.setError(fmt.Errorf("Found swap file %s, use :recover to restore the changes", swap.Path(b.Name())))
+		return
+	}
+	ed.swaps[b] = swap.New(b)
+}
+
+// exit ends the editing session.  The swap files are removed, as
+// there is nothing to recover from a regular exit.
+func (ed *editor) exit() {
+	for b := range ed.swaps {
+		ed.unprotect(b)
+	}
+	ed.stopLanguageServer()
+	ed.stopPlugins()
+	ed.quit = true
+}
+
+// resize sets the size of the windows for a screen of w x h (including
+// the last row for messages).
+func (ed *editor) resize(w, h int) {
+	ed.width, ed.height = w, h
+	y, rows := ed.windowArea(h)
+	ed.layout.Root().Each(0, y, w, rows, func(leaf *view.Layout, x, y, w, h int) {
+		leaf.View().Resize(w, h)
+	})
+}
+
+// buffers returns the buffers shown in the windows of all tab pages in
+// display order.
+func (ed *editor) buffers() []*buf.Buf {
+	var buffers []*buf.Buf
+	for _, t := range ed.tabs {
+		t.layout.Root().Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+			buffers = append(buffers, leaf.View().Buffer())
+		})
+	}
+	return buffers
+}
+
+// flushSwaps writes the changes logged to the swap files so far, which
+// otherwise are only written after a number of them.
+func (ed *editor) flushSwaps() {
+	for _, s := range ed.swaps {
+		s.Flush()
+	}
+}
+
+// unprotect stops logging the changes to b and removes its swap file.
+func (ed *editor) unprotect(b *buf.Buf) {
+	if s := ed.swaps[b]; s != nil {
+		s.Close()
+		delete(ed.swaps, b)
+	}
+}
+
+// setMessage shows msg in the last row.
+func (ed *editor) setMessage(msg string) {
+	ed.message = msg
+	ed.isError = false
+	ed.logMessage(msg)
+}
+
+// setError shows err in the last row.
+func (ed *editor) setError(err error) {
+	ed.message = err.Error()
+	ed.isError = true
+	ed.logMessage(ed.message)
+}
+
+// display updates the screen, placing the hardware cursor
+// in the focused view or the command line.  The last row is used
+// for the command line and messages.
+func (ed *editor) display() {
+	const coldef = termbox.ColorDefault
+	// Views redraw themselves only if they changed, so the screen
+	// isn't cleared.
+	ed.screen.SetCursor(-1, -1)
+	w, h := ed.screen.Size()
+	for x := 0; x < w; x++ {
+		ed.screen.SetCell(x, h-1, ' ', coldef, coldef)
+	}
+	if ed.cmdline.covered || ed.popupShown {
+		ed.invalidateWindows()
+	}
+	y, rows := ed.windowArea(h)
+	if y > 0 {
+		ed.displayTabs(w)
+	}
+	ed.layout.Root().Each(0, y, w, rows, func(leaf *view.Layout, x, y, w, h int) {
+		leaf.View().SetMode("")
+		leaf.View().SetFocused(leaf.View() == ed.focus)
+	})
+	ed.focus.SetMode(strings.TrimSpace(ed.modeName() + " " + ed.jobsMode()))
+	ed.layout.Root().Display(ed.screen, 0, y, w, rows)
+	ed.popupShown = ed.displayCompletion(w, h)
+	if ed.mode == modePicker {
+		ed.picker.display(ed.screen, h-1, w)
+	}
+	ed.cmdline.covered = ed.mode == modeCmdline && ed.cmdline.displayCompletions(ed.screen, h-2, w)
+	if ed.mode == modeCmdline || ed.mode == modeSearch || ed.mode == modePicker {
+		ed.cmdline.display(ed.screen, h-1, w)
+	} else {
+		if x, y, ok := ed.focus.CursorPosition(); ok {
+			ed.screen.SetCursor(x, y)
+		}
+		style := theme.Current[theme.Normal]
+		if ed.isError {
+			style = theme.Current[theme.ErrorMessage]
+		}
+		// messages of several lines cover the windows above the last row
+		lines := strings.Split(ed.message, "\n")
+		if len(lines) > h {
+			lines = lines[len(lines)-h:]
+		}
+		ed.overlaid = len(lines) > 1
+		for i, line := range lines {
+			y := h - len(lines) + i
+			x := 0
+			for _, r := range line {
+				if x >= w {
+					break
+				}
+				ed.screen.SetCell(x, y, r, style.Fg, style.Bg)
+				x++
+			}
+			for ; x < w; x++ {
+				ed.screen.SetCell(x, y, ' ', coldef, coldef)
+			}
+		}
+	}
+	ed.screen.Flush()
+}
+
+// reset forgets any partially entered command.
+func (ed *editor) reset() {
+	ed.register = 0
+	ed.keys = ed.keys[:0]
+	ed.findAction = ""
+	ed.markAction = ""
+	ed.pending = nil
+	ed.count = 0
+	ed.selectingRegister = false
+}
+
+// handleKey processes a key press.
+func (ed *editor) handleKey(ev termbox.Event) {
+	// the focus may change while handling the key
+	defer func() { ed.focus.EnsureCursorVisible() }()
+	if ed.overlaid {
+		// any key removes a message covering the windows
+		ed.overlaid = false
+		ed.setMessage("")
+		ed.invalidateWindows()
+	}
+	switch ed.mode {
+	case modeNormal:
+		ed.handleNormalKey(ev)
+	case modeInsert:
+		ed.handleInsertKey(ev)
+	case modeCmdline:
+		ed.handleCmdlineKey(ev)
+	case modeSearch:
+		ed.handleSearchKey(ev)
+	case modeVisual:
+		ed.handleVisualKey(ev)
+	case modePicker:
+		ed.handlePickerKey(ev)
+	}
+}
+
+// handleCmdlineKey processes a key press while entering a command.
+func (ed *editor) handleCmdlineKey(ev termbox.Event) {
+	switch ev.Key {
+	case termbox.KeyEsc:
+		ed.mode = modeNormal
+	case termbox.KeyEnter:
+		ed.mode = modeNormal
+		if err := ed.commands.Execute(ed.cmdline.String()); err != nil {
+			ed.setError(err)
+		}
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if ed.cmdline.text.Len() == 0 {
+			ed.mode = modeNormal
+			return
+		}
+		ed.cmdline.edit(ev)
+	case termbox.KeyTab:
+		ed.cmdline.complete(ed.commands.Complete)
+	default:
+		ed.cmdline.edit(ev)
+	}
+}
+
+// prefixKey handles the keys that may precede a command: register
+// selection and counts.  Returns true if the key was consumed.
+func (ed *editor) prefixKey(ev termbox.Event) bool {
+	if ed.selectingRegister {
+		ed.selectingRegister = false
+		if register.Valid(ev.Ch) {
+			ed.register = ev.Ch
+		} else {
+			ed.reset()
+		}
+		return true
+	}
+	if ev.Ch == '"' && ed.pending == nil {
+		ed.selectingRegister = true
+		return true
+	}
+	if ('1' <= ev.Ch && ev.Ch <= '9') || (ev.Ch == '0' && ed.count > 0) {
+		ed.count = ed.count*10 + int(ev.Ch-'0')
+		return true
+	}
+	return false
+}
+
+// handleNormalKey processes a key press in normal mode.
+func (ed *editor) handleNormalKey(ev termbox.Event) {
+	if ed.findAction != "" {
+		if m, ok := ed.findKey(ev); ok {
+			ed.normalMotion("", m)
+		} else {
+			ed.reset()
+		}
+		return
+	}
+	if ed.markAction != "" {
+		if m, ok := ed.markKey(ev); ok {
+			ed.normalMotion("goto-mark", m)
+		} else {
+			ed.reset()
+		}
+		return
+	}
+	if ed.surround != "" {
+		ed.surroundKey(ev)
+		return
+	}
+	if len(ed.keys) == 0 && ed.prefixKey(ev) {
+		return
+	}
+	mode := "normal"
+	if ed.pending != nil {
+		// operator pending, waiting for a motion or text object
+		mode = "operator"
+	}
+	action, ok := ed.lookupKey(mode, ev)
+	if !ok {
+		if len(ed.keys) == 0 {
+			// not bound
+			ed.reset()
+		}
+		return
+	}
+	v := ed.focus
+	if modifyingActions[action] {
+		if err := ed.modifiable(); err != nil {
+			ed.setError(err)
+			ed.reset()
+			return
+		}
+	}
+	if kind := ed.kind(v.Buffer()); kind != nil && ed.pending == nil {
+		if f, ok := kind.actions[action]; ok {
+			f(ed)
+			ed.reset()
+			return
+		}
+	}
+	if ed.startFind(action) || ed.startMark(action) {
+		return
+	}
+	if m, ok := ed.motion(action); ok {
+		ed.normalMotion(action, m)
+		return
+	}
+	if obj, ok := textObjects[action]; ok && ed.pending != nil {
+		ed.eachCursor(func() {
+			if r, ok := obj(v.Buffer(), v.Cursor()); ok {
+				ed.pending.Apply(ed, r)
+			}
+		})
+		ed.reset()
+		return
+	}
+	if ed.pending != nil && (action == "search" || action == "search-backward") {
+		// the operator waits for the pattern, see searchOperator
+		ed.startSearch(action == "search-backward")
+		return
+	}
+	if ed.pending != nil && action == "surround" && ed.opName != "surround" {
+		ed.startSurround()
+		return
+	}
+	if ed.pending != nil {
+		if action == ed.opName || keyName(ev) == ed.opKey {
+			// doubled operator (dd, yy, ...), or its last key
+			// doubled (gcc)
+			ed.applyToLines()
+		}
+		// otherwise not a motion, cancel the operator
+		ed.reset()
+		return
+	}
+	if op, ok := operators[action]; ok {
+		ed.pending = op
+		ed.opName = action
+		ed.opKey = keyName(ev)
+		ed.opCount = ed.takeCount()
+		return
+	}
+	if f, ok := normalActions[action]; ok {
+		f(ed)
+	} else if ed.plugins.commands[action] != nil {
+		if err := ed.runPluginCommand(action, ex.Command{Name: action}); err != nil {
+			ed.setError(err)
+		}
+	} else if ed.scripts.commands[action] != nil {
+		if err := ed.runScriptCommand(action, ""); err != nil {
+			ed.setError(err)
+		}
+	}
+	ed.reset()
+}
+
+// applyToLines applies the pending operator to the current line and the
+// lines following it, as many as the counts typed say.
+func (ed *editor) applyToLines() {
+	v := ed.focus
+	b := v.Buffer()
+	n := ed.opCount * ed.takeCount()
+	ed.eachCursor(func() {
+		cursor := v.Cursor()
+		ed.pending.Apply(ed, lineRange(b, b.LineOfOffset(cursor), n))
+		if ed.opName == "yank" {
+			// like vim yy leaves the cursor alone
+			v.SetCursor(cursor)
+		}
+	})
+}
+
+// normalMotion moves the cursor with m, the motion of action, or applies
+// the pending operator to the text m covers.
+func (ed *editor) normalMotion(action string, m motion.Motion) {
+	v := ed.focus
+	if ed.pending != nil {
+		ed.eachCursor(func() {
+			if r, ok := motion.Covered(v.Buffer(), v.Cursor(), m); ok {
+				ed.pending.Apply(ed, r)
+			}
+		})
+		ed.reset()
+	} else if jumpActions[action] {
+		v.PushJump(v.Cursor())
+		ed.eachCursor(func() { v.MoveCursor(m) })
+		ed.showCursor()
+	} else {
+		ed.eachCursor(func() { v.MoveCursor(m) })
+	}
+}
+
+// showCursor scrolls the focused view so that the cursor is visible.
+func (ed *editor) showCursor() {
+	v := ed.focus
+	v.ShowLine(v.Buffer().LineOfOffset(v.Cursor()))
+}
+
+// startCmdline starts entering an ex command.
+func (ed *editor) startCmdline() {
+	ed.mode = modeCmdline
+	ed.cmdline.init(":")
+	ed.setMessage("")
+}
+
+// takeCount returns the count typed so far, 1 if none was given,
+// and clears it.
+func (ed *editor) takeCount() int {
+	n := ed.count
+	ed.count = 0
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// selectedRegister returns the register the current command should use.
+func (ed *editor) selectedRegister() rune {
+	if ed.register == 0 {
+		return register.Unnamed
+	}
+	return ed.register
+}
+
+// put inserts the contents of the selected register count times after
+// the cursor if after is true and before it otherwise.  Whole lines are
+// put below or above the current line.
+func (ed *editor) put(after bool, count int) {
+	text, err := ed.registers.Get(ed.selectedRegister())
+	if err != nil || len(text) == 0 {
+		return
+	}
+	text = bytes.Repeat(text, count)
+	v := ed.focus
+	b := v.Buffer()
+	off := v.Cursor()
+	if ed.registers.Linewise(ed.selectedRegister()) {
+		ed.putLines(after, text)
+		return
+	}
+	if after && off < b.Len() {
+		// after the rune under the cursor
+		rd := b.NewReader(off)
+		rd.ReadRune()
+		off = rd.Offset()
+	}
+	if err := b.TryInsert(off, text); err != nil {
+		ed.setError(err)
+		return
+	}
+	// leave the cursor on the last rune of the inserted text
+	rd := b.NewReader(off + len(text))
+	rd.Reverse()
+	rd.ReadRune()
+	v.SetCursor(rd.Offset())
+}
+
+// putLines inserts the lines in text below the current line if after is
+// true and above it otherwise, leaving the cursor on the first of them.
+func (ed *editor) putLines(after bool, text []byte) {
+	v := ed.focus
+	b := v.Buffer()
+	line := b.LineOfOffset(v.Cursor())
+	off := b.Line(line)
+	first := off // of the first line put
+	if after {
+		if line < b.Lines() {
+			off = b.Line(line + 1)
+			first = off
+		} else {
+			// the last line has no line break to put the lines after,
+			// so it goes in front of them instead
+			off = b.Len()
+			first = off + 1
+			text = append([]byte{'\n'}, bytes.TrimSuffix(text, newline)...)
+		}
+	}
+	if err := b.TryInsert(off, text); err != nil {
+		ed.setError(err)
+		return
+	}
+	v.SetCursor(first)
+	v.MoveCursor(motion.FirstNonBlank)
+}
+
+// join joins the current line with the count-1 following ones (at
+// least one), replacing the line breaks and the blanks around them by a
+// single space.
+func (ed *editor) join(count int) {
+	v := ed.focus
+	b := v.Buffer()
+	line := b.LineOfOffset(v.Cursor())
+	if count < 2 {
+		count = 2
+	}
+	for i := 1; i < count && line < b.Lines(); i++ {
+		lineStart, brk := b.LineRange(line)
+		start := brk
+		for start > lineStart && isBlank(b.Bytes(start-1, start)[0]) {
+			start--
+		}
+		end, _ := indentation(b, line+1, v.Options)
+		sep := []byte(" ")
+		if start == lineStart || end == b.Len() || b.Bytes(end, end+1)[0] == '\n' {
+			// no space next to an empty line
+			sep = nil
+		}
+		if err := b.Replace(start, end, sep); err != nil {
+			ed.setError(err)
+			return
+		}
+		v.SetCursor(start)
+	}
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// handleInsertKey processes a key press in insert mode.
+func (ed *editor) handleInsertKey(ev termbox.Event) {
+	action, ok := ed.lookupKey("insert", ev)
+	if f, complete := completionActions[action]; ok && complete {
+		f(ed)
+		return
+	}
+	if len(ed.keys) == 0 {
+		// accept the completion
+		ed.completion = nil
+	}
+	if ok {
+		if action == "escape" {
+			ed.endInsert()
+		} else if action == "newline" && ed.expandAbbreviation() {
+			return
+		} else if f, ok := insertActions[action]; ok {
+			ed.eachCursor(func() { f(ed) })
+		}
+		return
+	}
+	if len(ed.keys) > 0 {
+		// wait for the rest of the key sequence
+		return
+	}
+	if ev.Ch != 0 && !wordindex.IsWordRune(ev.Ch) || ev.Key == termbox.KeySpace {
+		if ed.expandAbbreviation() {
+			return
+		}
+	}
+	if ev.Ch != 0 && ed.autoPairs() {
+		ed.eachCursor(func() { ed.insertPaired(ev.Ch) })
+	} else if ev.Ch != 0 {
+		ed.eachCursor(func() { ed.insert(string(ev.Ch)) })
+	} else if ev.Key == termbox.KeySpace {
+		ed.eachCursor(func() { ed.insert(" ") })
+	}
+}
+
+// endInsert leaves insert mode.
+func (ed *editor) endInsert() {
+	ed.mode = modeNormal
+	ed.endSnippet()
+	ed.finishBlockInsert()
+	// like vim, leave the cursor on the last inserted character
+	ed.eachCursor(func() { ed.focus.MoveCursor(motion.GraphemeBackward) })
+}
+
+// insertTab inserts a tab, or spaces up to the next tab stop if
+// ExpandTab is set.
+func (ed *editor) insertTab() {
+	v := ed.focus
+	if v.ExpandTab {
+		col := v.Column(v.Cursor())
+		ed.insert(strings.Repeat(" ", v.TabStop-col%v.TabStop))
+	} else {
+		ed.insert("\t")
+	}
+}
+
+// backspace deletes the grapheme cluster in front of the cursor.
+func (ed *editor) backspace() {
+	v := ed.focus
+	if off := v.Cursor(); off > 0 {
+		v.MoveCursor(motion.GraphemeBackward)
+		if err := v.Buffer().TryDelete(v.Cursor(), off); err != nil {
+			v.SetCursor(off)
+			ed.setError(err)
+		}
+	}
+}
+
+// deleteForward deletes the grapheme cluster behind the cursor.
+func (ed *editor) deleteForward() {
+	v := ed.focus
+	b := v.Buffer()
+	off := v.Cursor()
+	rd := b.NewReader(off)
+	if motion.GraphemeForward.Move(b, rd) {
+		if err := b.TryDelete(off, rd.Offset()); err != nil {
+			ed.setError(err)
+		}
+	}
+}
+
+// deleteBackTo deletes the text in front of the cursor back to where m
+// moves, but not beyond the start of the line, like vim's CTRL-W and
+// CTRL-U.  If m doesn't move back within the line everything in front
+// of the cursor in the line is deleted, at the start of a line the line
+// break in front of it.
+func (ed *editor) deleteBackTo(m motion.Motion) {
+	v := ed.focus
+	b := v.Buffer()
+	off := v.Cursor()
+	rd := b.NewReader(off)
+	motion.LineStart.Move(b, rd)
+	start := rd.Offset()
+	if start == off {
+		ed.backspace()
+		return
+	}
+	rd = b.NewReader(off)
+	if m.Move(b, rd) && start < rd.Offset() && rd.Offset() < off {
+		start = rd.Offset()
+	}
+	if err := b.TryDelete(start, off); err != nil {
+		ed.setError(err)
+		return
+	}
+	v.SetCursor(start)
+}
+
+// insert inserts s at the cursor, moving the cursor behind it.
+func (ed *editor) insert(s string) {
+	v := ed.focus
+	off := v.Cursor()
+	if err := v.Buffer().TryInsert(off, []byte(s)); err != nil {
+		ed.setError(err)
+		return
+	}
+	v.SetCursor(off + len(s))
+}
+
+// newline breaks the line at the cursor.  With AutoIndent the new line
+// starts with the leading blanks of the broken one.
+func (ed *editor) newline() {
+	v := ed.focus
+	b := v.Buffer()
+	indent := ""
+	if v.AutoIndent {
+		line := b.LineOfOffset(v.Cursor())
+		start := b.Line(line)
+		end, _ := indentation(b, line, v.Options)
+		if end > v.Cursor() {
+			end = v.Cursor()
+		}
+		indent = string(b.Bytes(start, end))
+	}
+	ed.insert("\n" + indent)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/keymap"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/view"
+	"github.com/nsf/termbox-go"
+)
+
+// newTestEditor returns an editor on a memory screen showing a buffer
+// holding text, with the cursor at its start.  The user's configuration
+// isn't read.
+func newTestEditor(t *testing.T, text string) *editor {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte(text))
+	var v view.View
+	v.Init(&b)
+	ed := newEditor(screen.NewMemory(80, 25), view.NewLayout(&v), &v)
+	ed.resize(80, 25)
+	return ed
+}
+
+// typeKeys hands ed the keys of the key sequence keys (see
+// keymap.ParseKeys) as if typed.
+func typeKeys(t *testing.T, ed *editor, keys string) {
+	t.Helper()
+	seq, err := keymap.ParseKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range seq {
+		ev := termbox.Event{Type: termbox.EventKey}
+		switch {
+		case k == " ":
+			ev.Key = termbox.KeySpace
+		case len([]rune(k)) == 1:
+			ev.Ch = []rune(k)[0]
+		case len(k) == 5 && strings.HasPrefix(k, "<C-") && 'a' <= k[3] && k[3] <= 'z':
+			ev.Key = termbox.KeyCtrlA + termbox.Key(k[3]-'a')
+		default:
+			for key, name := range specialKeys {
+				if name == k {
+					ev.Key = key
+				}
+			}
+			if ev.Key == 0 {
+				t.Fatalf("can't type %s", k)
+			}
+		}
+		ed.handleKey(ev)
+	}
+}
+
+// bufferText returns the contents of the focused buffer.
+func bufferText(ed *editor) string {
+	return ed.focus.Buffer().String()
+}
+
+// A keysTest types keys into an editor holding text, which should then
+// hold want with the cursor at cursor.
+type keysTest struct {
+	text, keys string
+	want       string
+	cursor     int
+}
+
+// run runs the test and returns the editor, for checking more.
+func (test keysTest) run(t *testing.T) *editor {
+	t.Helper()
+	ed := newTestEditor(t, test.text)
+	typeKeys(t, ed, test.keys)
+	if got, cursor := bufferText(ed), ed.focus.Cursor(); got != test.want || cursor != test.cursor {
+		t.Errorf("%q with %s gives %q, cursor at %d expected %q, %d", test.text, test.keys, got, cursor, test.want, test.cursor)
+	}
+	return ed
+}
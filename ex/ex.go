@@ -0,0 +1,376 @@
+// Package ex parses and dispatches ex style commands as entered on the
+// command line (after ':').
+package ex
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An AddressKind tells how an Address gives its line.
+type AddressKind int
+
+const (
+	LineNumber     AddressKind = iota // a line number, e.g. 12
+	CurrentLine                       // .
+	LastLine                          // $
+	Mark                              // the line of a mark, e.g. 'a or '<
+	Search                            // the next line matching a pattern, /pat/
+	SearchBackward                    // the previous line matching a pattern, ?pat?
+)
+
+// An Address is a line given in front of a command.
+type Address struct {
+	Kind    AddressKind
+	Line    int    // of a LineNumber
+	Mark    rune   // name of a Mark
+	Pattern string // of a Search or SearchBackward, empty for the last one
+	Offset  int    // lines added to the line addressed, e.g. 3 for .+3
+}
+
+// A Range is the lines given in front of a command by up to two
+// addresses, e.g. 12,34 or '<,'>.
+type Range struct {
+	N          int // number of addresses given: 0, 1 or 2
+	Start, End Address
+}
+
+// An Addresser resolves addresses in the buffer a command applies to.
+type Addresser interface {
+	CurrentLine() int
+	LastLine() int
+	// MarkLine returns the line of the mark name.
+	MarkLine(name rune) (int, error)
+	// SearchLine returns the line of the next match of pattern after the
+	// current line, or of the previous one if backward is set, wrapping
+	// around the end of the buffer.  The empty pattern stands for the
+	// last one searched for.
+	SearchLine(pattern string, backward bool) (int, error)
+}
+
+// A Command is a parsed command line.
+type Command struct {
+	Range Range  // lines given in front of the command
+	Name  string // name of the command as typed, may be abbreviated
+	Bang  bool   // true if the name was followed by '!'
+	Arg   string // rest of the line with surrounding blanks removed
+}
+
+// Resolve returns the line addressed by addr.  It isn't checked to be
+// within the buffer.
+func (addr Address) Resolve(a Addresser) (int, error) {
+	n := addr.Line
+	switch addr.Kind {
+	case CurrentLine:
+		n = a.CurrentLine()
+	case LastLine:
+		n = a.LastLine()
+	case Mark:
+		line, err := a.MarkLine(addr.Mark)
+		if err != nil {
+			return 0, err
+		}
+		n = line
+	case Search, SearchBackward:
+		line, err := a.SearchLine(addr.Pattern, addr.Kind == SearchBackward)
+		if err != nil {
+			return 0, err
+		}
+		n = line
+	}
+	return n + addr.Offset, nil
+}
+
+// Lines returns the first and last line of r.  Without any address
+// that is the current line.  The lines aren't checked to be within the
+// buffer.
+func (r Range) Lines(a Addresser) (first, last int, err error) {
+	if r.N == 0 {
+		return a.CurrentLine(), a.CurrentLine(), nil
+	}
+	if first, err = r.Start.Resolve(a); err != nil {
+		return 0, 0, err
+	}
+	last = first
+	if r.N == 2 {
+		if last, err = r.End.Resolve(a); err != nil {
+			return 0, 0, err
+		}
+	}
+	if first > last {
+		first, last = last, first
+	}
+	return first, last, nil
+}
+
+// parseAddress parses the address at the start of s: a line number, .,
+// $, 'x for mark x, /pattern/ or ?pattern?, followed by any number of
+// +n or -n (n defaulting to 1).  Only offsets are relative to the
+// current line.  Returns false if s doesn't start with an address.
+func parseAddress(s string) (Address, string, bool, error) {
+	var addr Address
+	found := true
+	switch {
+	case s == "":
+		return addr, s, false, nil
+	case s[0] == '.':
+		addr.Kind = CurrentLine
+		s = s[1:]
+	case s[0] == '$':
+		addr.Kind = LastLine
+		s = s[1:]
+	case s[0] == '\'':
+		r, size := utf8.DecodeRuneInString(s[1:])
+		if size == 0 {
+			return addr, s, false, errors.New("Missing mark name")
+		}
+		addr.Kind, addr.Mark = Mark, r
+		s = s[1+size:]
+	case s[0] == '/' || s[0] == '?':
+		addr.Kind = Search
+		if s[0] == '?' {
+			addr.Kind = SearchBackward
+		}
+		addr.Pattern, s = parsePattern(s[1:], s[0])
+	case '0' <= s[0] && s[0] <= '9':
+		var err error
+		if addr.Line, s, err = parseNumber(s); err != nil {
+			return addr, s, false, err
+		}
+	default:
+		addr.Kind = CurrentLine
+		found = false
+	}
+	for s != "" && (s[0] == '+' || s[0] == '-') {
+		sign := 1
+		if s[0] == '-' {
+			sign = -1
+		}
+		n := 1
+		s = s[1:]
+		if s != "" && '0' <= s[0] && s[0] <= '9' {
+			var err error
+			if n, s, err = parseNumber(s); err != nil {
+				return addr, s, false, err
+			}
+		}
+		addr.Offset += sign * n
+		found = true
+	}
+	return addr, s, found, nil
+}
+
+// parseNumber parses the decimal number at the start of s.
+func parseNumber(s string) (int, string, error) {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(s[:i])
+	return n, s[i:], err
+}
+
+// parsePattern returns the pattern at the start of s up to the
+// unescaped delimiter delim or the end of s, and the rest of s after
+// the delimiter.  An escaped delimiter stands for itself.
+func parsePattern(s string, delim byte) (string, string) {
+	var pattern strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == delim:
+			return pattern.String(), s[i+1:]
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			pattern.WriteByte(s[i])
+			i++
+		}
+		pattern.WriteByte(s[i])
+	}
+	return pattern.String(), ""
+}
+
+// parseRange parses the range at the start of s.  Returns the range and
+// the rest of s.
+func parseRange(s string) (Range, string, error) {
+	var r Range
+	if strings.HasPrefix(s, "%") {
+		r.N = 2
+		r.Start = Address{Line: 1}
+		r.End = Address{Kind: LastLine}
+		return r, s[1:], nil
+	}
+	addr, s, ok, err := parseAddress(s)
+	if err != nil {
+		return r, s, err
+	}
+	if ok {
+		r.N, r.Start = 1, addr
+	}
+	if strings.HasPrefix(s, ",") {
+		if addr, s, ok, err = parseAddress(s[1:]); err != nil {
+			return r, s, err
+		}
+		if r.N == 0 || !ok {
+			return r, s, errors.New("Invalid range")
+		}
+		r.N, r.End = 2, addr
+	}
+	return r, s, nil
+}
+
+// Parse parses a command line of the form [range][name][!] [arg],
+// where range is an address or two separated by ',' or % for all
+// lines.  Addresses are line numbers, . for the current and $ for the
+// last line, 'x for the line of mark x, /pattern/ and ?pattern? for
+// the next and previous line matching pattern, each optionally
+// followed by offsets like +3 or -.  A range without a command name
+// is a command with an empty Name.  Non alphabetic commands (e.g. :!)
+// consist of a single character.
+func Parse(s string) (Command, error) {
+	var cmd Command
+	var err error
+	s = strings.TrimSpace(s)
+	if cmd.Range, s, err = parseRange(s); err != nil {
+		return cmd, err
+	}
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	if i < 0 {
+		i = len(s)
+	}
+	if i == 0 && s != "" {
+		i = 1
+	}
+	cmd.Name = s[:i]
+	s = s[i:]
+	if cmd.Name != "" && unicode.IsLetter(rune(cmd.Name[0])) && strings.HasPrefix(s, "!") {
+		cmd.Bang = true
+		s = s[1:]
+	}
+	cmd.Arg = strings.TrimSpace(s)
+	return cmd, nil
+}
+
+// Func executes a parsed command.
+type Func func(cmd Command) error
+
+// A Completer returns the possible completions of the argument of a
+// command typed so far.  Each completion is a whole argument.
+type Completer func(arg string) []string
+
+type entry struct {
+	name     string // full name
+	abbrev   int    // minimal number of characters that must be given
+	execute  Func
+	complete Completer // may be nil
+}
+
+// A Dispatcher maps command names to the functions executing them.
+// The zero value is an empty dispatcher ready to use.
+type Dispatcher struct {
+	entries []entry
+}
+
+// Register adds a command.  name uses vim's notation for abbreviations:
+// "e[dit]" means the command may be given as e, ed, edi or edit.
+// The empty name registers the command run for a bare line number.
+func (d *Dispatcher) Register(name string, f Func) {
+	e := entry{execute: f}
+	e.name, e.abbrev = parseName(name)
+	d.entries = append(d.entries, e)
+}
+
+// parseName returns the full name and the length of the shortest
+// abbreviation of a name given in vim's notation.
+func parseName(name string) (full string, abbrev int) {
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		return name[:i] + strings.TrimSuffix(name[i+1:], "]"), i
+	}
+	return name, len(name)
+}
+
+// Lookup returns the function registered for the possibly abbreviated name.
+func (d *Dispatcher) Lookup(name string) (Func, bool) {
+	for _, e := range d.entries {
+		if len(name) >= e.abbrev && strings.HasPrefix(e.name, name) {
+			return e.execute, true
+		}
+	}
+	return nil, false
+}
+
+// SetCompleter sets the completer of the arguments of the command
+// registered as name.
+func (d *Dispatcher) SetCompleter(name string, c Completer) {
+	full, _ := parseName(name)
+	for i, e := range d.entries {
+		if e.name == full {
+			d.entries[i].complete = c
+			return
+		}
+	}
+	panic("SetCompleter: unknown command " + name)
+}
+
+// Complete returns the completions of line, a command line typed so far:
+// either the names of the commands starting with the name typed or, if
+// an argument is being typed, the completions of the command's
+// Completer.  start is the offset in line of the text the completions
+// replace.
+func (d *Dispatcher) Complete(line string) (start int, completions []string) {
+	cmd, err := Parse(line)
+	if err != nil || cmd.Name == "" {
+		return len(line), nil
+	}
+	// the name follows the range
+	_, rest, _ := parseRange(strings.TrimLeft(line, " \t"))
+	i := len(line) - len(rest) + len(cmd.Name)
+	rest = line[i:]
+	if cmd.Bang {
+		rest = rest[1:]
+	}
+	if rest == "" && !cmd.Bang {
+		seen := make(map[string]bool)
+		for _, e := range d.entries {
+			if e.name != "" && strings.HasPrefix(e.name, cmd.Name) && !seen[e.name] {
+				seen[e.name] = true
+				completions = append(completions, e.name)
+			}
+		}
+		sort.Strings(completions)
+		return i - len(cmd.Name), completions
+	}
+	arg := strings.TrimLeft(rest, " \t")
+	start = len(line) - len(arg)
+	for _, e := range d.entries {
+		if len(cmd.Name) >= e.abbrev && strings.HasPrefix(e.name, cmd.Name) {
+			if e.complete == nil {
+				return start, nil
+			}
+			return start, e.complete(arg)
+		}
+	}
+	return start, nil
+}
+
+// Execute parses line and runs the corresponding command.
+func (d *Dispatcher) Execute(line string) error {
+	cmd, err := Parse(line)
+	if err != nil {
+		return err
+	}
+	if cmd.Name == "" && cmd.Range.N == 0 {
+		// empty command line does nothing
+		return nil
+	}
+	f, ok := d.Lookup(cmd.Name)
+	if !ok {
+		return fmt.Errorf("Not an editor command: %s", cmd.Name)
+	}
+	return f(cmd)
+}
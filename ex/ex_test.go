@@ -0,0 +1,154 @@
+package ex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		cmd  Command
+	}{
+		{"w", Command{Name: "w"}},
+		{" e  foo.go ", Command{Name: "e", Arg: "foo.go"}},
+		{"q!", Command{Name: "q", Bang: true}},
+		{"42", Command{Range: Range{N: 1, Start: Address{Line: 42}}}},
+		{"12write! x", Command{Range: Range{N: 1, Start: Address{Line: 12}}, Name: "write", Bang: true, Arg: "x"}},
+		{"$", Command{Range: Range{N: 1, Start: Address{Kind: LastLine}}}},
+		{"%!gofmt", Command{Range: Range{N: 2, Start: Address{Line: 1}, End: Address{Kind: LastLine}}, Name: "!", Arg: "gofmt"}},
+		{".,5!sort -u", Command{Range: Range{N: 2, Start: Address{Kind: CurrentLine}, End: Address{Line: 5}}, Name: "!", Arg: "sort -u"}},
+		{"'<,'>d", Command{Range: Range{N: 2, Start: Address{Kind: Mark, Mark: '<'}, End: Address{Kind: Mark, Mark: '>'}}, Name: "d"}},
+		{"/a\\/b/,?c?-2w x", Command{Range: Range{N: 2, Start: Address{Kind: Search, Pattern: "a/b"}, End: Address{Kind: SearchBackward, Pattern: "c", Offset: -2}}, Name: "w", Arg: "x"}},
+		{"+3,$-", Command{Range: Range{N: 2, Start: Address{Kind: CurrentLine, Offset: 3}, End: Address{Kind: LastLine, Offset: -1}}}},
+		{`/\d+/`, Command{Range: Range{N: 1, Start: Address{Kind: Search, Pattern: `\d+`}}}},
+		{"!ls", Command{Name: "!", Arg: "ls"}},
+	}
+	for _, test := range tests {
+		cmd, err := Parse(test.line)
+		if err != nil || cmd != test.cmd {
+			t.Errorf("%q: expected %+v got %+v (%v)", test.line, test.cmd, cmd, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{"1,", ",5", "1,xw", "'"} {
+		if cmd, err := Parse(line); err == nil {
+			t.Errorf("%q: expected error got %+v", line, cmd)
+		}
+	}
+}
+
+// addresser resolves addresses in a buffer of 10 lines with the
+// cursor on line 5, mark a on line 2, lines 3 and 8 matching "x".
+type addresser struct{}
+
+func (addresser) CurrentLine() int { return 5 }
+func (addresser) LastLine() int    { return 10 }
+
+func (addresser) MarkLine(name rune) (int, error) {
+	if name != 'a' {
+		return 0, errors.New("Mark not set")
+	}
+	return 2, nil
+}
+
+func (addresser) SearchLine(pattern string, backward bool) (int, error) {
+	if pattern != "x" {
+		return 0, errors.New("Pattern not found")
+	}
+	if backward {
+		return 3, nil
+	}
+	return 8, nil
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		line        string
+		first, last int
+	}{
+		{"w", 5, 5},
+		{"3", 3, 3},
+		{"%", 1, 10},
+		{".,$", 5, 10},
+		{"7,2", 2, 7},
+		{"'a,.+2", 2, 7},
+		{"/x/,?x?", 3, 8},
+		{"-,+", 4, 6},
+		{"$-3-", 6, 6},
+	}
+	for _, test := range tests {
+		cmd, err := Parse(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, last, err := cmd.Range.Lines(addresser{})
+		if err != nil || first != test.first || last != test.last {
+			t.Errorf("%q: expected %v-%v got %v-%v (%v)", test.line, test.first, test.last, first, last, err)
+		}
+	}
+	for _, line := range []string{"'b", "1,/y/"} {
+		cmd, err := Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := cmd.Range.Lines(addresser{}); err == nil {
+			t.Errorf("%q: expected error", line)
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var d Dispatcher
+	var got string
+	d.Register("e[dit]", func(cmd Command) error { got = "edit " + cmd.Arg; return nil })
+	d.Register("ec[ho]", func(cmd Command) error { got = "echo " + cmd.Arg; return nil })
+	for line, exp := range map[string]string{
+		"e x":    "edit x",
+		"edit x": "edit x",
+		"ec x":   "echo x",
+		"echo x": "echo x",
+	} {
+		got = ""
+		if err := d.Execute(line); err != nil || got != exp {
+			t.Errorf("%q: expected %q got %q (%v)", line, exp, got, err)
+		}
+	}
+	for _, line := range []string{"edx", "echox", "foo"} {
+		if err := d.Execute(line); err == nil {
+			t.Errorf("%q: expected error", line)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var d Dispatcher
+	nop := func(cmd Command) error { return nil }
+	d.Register("e[dit]", nop)
+	d.Register("ec[ho]", nop)
+	d.Register("s[et]", nop)
+	d.SetCompleter("e[dit]", func(arg string) []string { return []string{arg + "1", arg + "2"} })
+	tests := []struct {
+		line        string
+		start       int
+		completions []string
+	}{
+		{"e", 0, []string{"echo", "edit"}},
+		{"1,2ec", 3, []string{"echo"}},
+		{"'<,'>e", 5, []string{"echo", "edit"}},
+		{"x", 0, nil},
+		{"e ", 2, []string{"1", "2"}},
+		{"ed! fo", 4, []string{"fo1", "fo2"}},
+		{"s fo", 2, nil},
+		{"e!", 2, []string{"1", "2"}},
+	}
+	for _, test := range tests {
+		start, completions := d.Complete(test.line)
+		if start != test.start || !reflect.DeepEqual(completions, test.completions) {
+			t.Errorf("%q: expected %v %q got %v %q", test.line, test.start, test.completions, start, completions)
+		}
+	}
+}
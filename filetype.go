@@ -0,0 +1,57 @@
+package main
+
+import "path/filepath"
+
+// A fileType is the language of a file, as far as the editor knows it.
+type fileType struct {
+	name    string
+	comment string // starts line comments, "" if there are none
+}
+
+// fileTypes detect the types of files: a file is of the type of the
+// first entry whose pattern matches its base name (see filepath.Match).
+var fileTypes = []struct {
+	pattern string
+	fileType
+}{
+	{"*.go", fileType{"go", "//"}},
+	{"*.[ch]", fileType{"c", "//"}},
+	{"*.cc", fileType{"cpp", "//"}},
+	{"*.cpp", fileType{"cpp", "//"}},
+	{"*.hpp", fileType{"cpp", "//"}},
+	{"*.java", fileType{"java", "//"}},
+	{"*.js", fileType{"javascript", "//"}},
+	{"*.ts", fileType{"typescript", "//"}},
+	{"*.rs", fileType{"rust", "//"}},
+	{"*.py", fileType{"python", "#"}},
+	{"*.rb", fileType{"ruby", "#"}},
+	{"*.sh", fileType{"sh", "#"}},
+	{"*.yaml", fileType{"yaml", "#"}},
+	{"*.yml", fileType{"yaml", "#"}},
+	{"*.toml", fileType{"toml", "#"}},
+	{"Makefile", fileType{"make", "#"}},
+	{"*.mk", fileType{"make", "#"}},
+	{"*.lua", fileType{"lua", "--"}},
+	{"*.sql", fileType{"sql", "--"}},
+	{"*.hs", fileType{"haskell", "--"}},
+	{"*.ml", fileType{"ocaml", ""}},
+	{"*.lisp", fileType{"lisp", ";"}},
+	{"*.el", fileType{"lisp", ";"}},
+	{"*.vim", fileType{"vim", "\""}},
+	{"*.tex", fileType{"tex", "%"}},
+	{"*.md", fileType{"markdown", ""}},
+	{"*.json", fileType{"json", ""}},
+	{"go.mod", fileType{"gomod", "//"}},
+}
+
+// detectFileType returns the type of the file name, false if it is
+// none of fileTypes.
+func detectFileType(name string) (fileType, bool) {
+	base := filepath.Base(name)
+	for _, t := range fileTypes {
+		if ok, _ := filepath.Match(t.pattern, base); ok {
+			return t.fileType, true
+		}
+	}
+	return fileType{}, false
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bgrundmann/e/fuzzy"
+	"github.com/bgrundmann/e/vcs"
+)
+
+// findInterval is how often the files found so far are added to the
+// picker while looking for them, as each time all are filtered again.
+const findInterval = 100 * time.Millisecond
+
+// errStopped stops walking the directory tree.
+var errStopped = errors.New("stopped")
+
+// findFile opens a picker choosing among the files under the current
+// directory, leaving out those git ignores, and shows the file picked in
+// the current window.  The files are looked for in the background and
+// offered as they are found, so that one can be picked before all are.
+func (ed *editor) findFile() {
+	stop := make(chan struct{})
+	ed.startPicker("File: ", nil, func(i int) {
+		b, err := ed.loadBuffer(filepath.FromSlash(ed.picker.items[i]))
+		if err == nil {
+			err = ed.switchBuffer(b, false)
+		}
+		if err != nil {
+			ed.setError(err)
+		}
+	})
+	ed.picker.match = fuzzy.MatchPath
+	ed.picker.stop = stop
+	found := func(names []string) {
+		ed.post(func(ed *editor) {
+			if ed.picker.stop == stop {
+				ed.picker.add(names, ed.cmdline.String())
+			}
+		})
+	}
+	go func() {
+		if err := walkFiles(".", stop, found); err != nil && err != errStopped {
+			ed.post(func(ed *editor) { ed.setError(err) })
+		}
+	}()
+}
+
+// walkFiles calls found with the names of the files under dir, relative
+// to it and with slashes, leaving out those git ignores.  The names are
+// passed in batches at most findInterval apart, until all are found or
+// stop is closed.
+func walkFiles(dir string, stop <-chan struct{}, found func(names []string)) error {
+	// git is asked about the names relative to the top of the repository,
+	// which prefix leads from to dir
+	var ig vcs.Ignore
+	prefix := ""
+	if root := vcs.Root(dir); root != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			prefix = filepath.ToSlash(rel) + "/"
+			// the .gitignore files of the directories above apply as well
+			elems := strings.Split(filepath.ToSlash(rel), "/")
+			for i := range elems {
+				base := strings.Join(elems[:i], "/")
+				ig.Read(filepath.Join(root, filepath.FromSlash(base)), base)
+			}
+		}
+	}
+	var batch []string
+	last := time.Now()
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		select {
+		case <-stop:
+			return errStopped
+		default:
+		}
+		if err != nil {
+			if name == dir {
+				return err
+			}
+			// left out like the directories that can't be read
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == ".":
+			// an unreadable .gitignore ignores nothing
+			ig.Read(name, strings.TrimSuffix(prefix, "/"))
+		case d.IsDir():
+			if d.Name() == ".git" || ig.Ignored(prefix+rel, true) {
+				return filepath.SkipDir
+			}
+			ig.Read(name, prefix+rel)
+		case !ig.Ignored(prefix+rel, false):
+			batch = append(batch, rel)
+			if time.Since(last) >= findInterval {
+				found(batch)
+				batch, last = nil, time.Now()
+			}
+		}
+		return nil
+	})
+	if len(batch) > 0 {
+		found(batch)
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/view"
+)
+
+// opFold creates a closed fold of the lines covered by r.
+func opFold(ed *editor, r motion.Range) {
+	v := ed.focus
+	b := v.Buffer()
+	end := r.Off2
+	if end > r.Off1 {
+		// the range ends in front of Off2
+		end--
+	}
+	first, last := b.LineOfOffset(r.Off1), b.LineOfOffset(end)
+	v.CreateFold(first, last)
+	v.SetCursor(b.Line(first))
+}
+
+// fold applies f, one of the view's methods opening or closing folds,
+// to the line of the cursor.
+func (ed *editor) fold(f func(v *view.View, line int) bool) {
+	v := ed.focus
+	if !f(v, v.Buffer().LineOfOffset(v.Cursor())) {
+		ed.setError(errors.New("No fold found"))
+	}
+}
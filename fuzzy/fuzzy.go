@@ -0,0 +1,77 @@
+// Package fuzzy filters lists by patterns typed by the user, matching
+// items containing the runes of the pattern in order but not
+// necessarily next to each other (e.g. "edgo" matches "editor.go").
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match returns whether s contains the runes of pattern in order,
+// ignoring case.  The score tells how good the match is, lower is
+// better: it is the number of runes skipped after the first match.
+func Match(pattern, s string) (score int, ok bool) {
+	start := -1
+	for _, p := range pattern {
+		p = unicode.ToLower(p)
+		for {
+			r, size := utf8.DecodeRuneInString(s)
+			if size == 0 {
+				return 0, false
+			}
+			s = s[size:]
+			if unicode.ToLower(r) == p {
+				start = 0
+				break
+			}
+			if start >= 0 {
+				score++
+			}
+		}
+	}
+	return score, true
+}
+
+// MatchPath is Match for file names: a match within the last element
+// of path is better than any across its directories, and among those
+// the shorter path is better.
+func MatchPath(pattern, path string) (score int, ok bool) {
+	if !strings.ContainsRune(pattern, '/') {
+		base := path[strings.LastIndexByte(path, '/')+1:]
+		if score, ok := Match(pattern, base); ok {
+			return score, true
+		}
+	}
+	score, ok = Match(pattern, path)
+	if !ok {
+		return 0, false
+	}
+	// the score of a match within the last element is at most its length
+	return score + utf8.RuneCountInString(path), true
+}
+
+// Filter returns the indices of the items matching pattern, the best
+// matches first.  Equally good matches keep their order.
+func Filter(pattern string, items []string) []int {
+	return FilterFunc(pattern, items, Match)
+}
+
+// FilterFunc is Filter with match, e.g. MatchPath, telling whether and
+// how well an item matches.
+func FilterFunc(pattern string, items []string, match func(pattern, s string) (int, bool)) []int {
+	var matches []int
+	scores := make(map[int]int)
+	for i, item := range items {
+		if score, ok := match(pattern, item); ok {
+			matches = append(matches, i)
+			scores[i] = score
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return scores[matches[i]] < scores[matches[j]]
+	})
+	return matches
+}
@@ -0,0 +1,68 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		score      int
+		ok         bool
+	}{
+		{"", "foo", 0, true},
+		{"foo", "foo", 0, true},
+		{"edgo", "editor.go", 5, true},
+		{"EG", "editor.go", 6, true},
+		{"og", "editor.go", 2, true},
+		{"ge", "editor.go", 0, false},
+		{"x", "", 0, false},
+		{"ä", "Äpfel", 0, true},
+	}
+	for _, test := range tests {
+		score, ok := Match(test.pattern, test.s)
+		if score != test.score || ok != test.ok {
+			t.Errorf("Match(%q, %q) = %v, %v expected %v, %v",
+				test.pattern, test.s, score, ok, test.score, test.ok)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		score         int
+		ok            bool
+	}{
+		{"edgo", "editor.go", 5, true},
+		{"edgo", "cmd/e/editor.go", 5, true},
+		{"cmdgo", "cmd/e/editor.go", 25, true},
+		{"e/ed", "cmd/e/editor.go", 15, true},
+		{"x", "cmd/e/editor.go", 0, false},
+	}
+	for _, test := range tests {
+		score, ok := MatchPath(test.pattern, test.path)
+		if score != test.score || ok != test.ok {
+			t.Errorf("MatchPath(%q, %q) = %v, %v expected %v, %v",
+				test.pattern, test.path, score, ok, test.score, test.ok)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	items := []string{"editor.go", "e.go", "bindings.go", "README.md"}
+	if got, want := Filter("ego", items), []int{1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+	if got, want := Filter("", items), []int{0, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+}
+
+func TestFilterFunc(t *testing.T) {
+	items := []string{"buf/edit.go", "e/go.mod", "editor.go", "e.go"}
+	if got, want := FilterFunc("ego", items, MatchPath), []int{3, 0, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+}
@@ -0,0 +1,211 @@
+// Package grapheme finds the boundaries of extended grapheme clusters as
+// defined by UAX #29, the units a user perceives as one character: a
+// letter with its combining marks, a Hangul syllable made of jamos, an
+// emoji with modifiers or a sequence of emoji joined by zero width
+// joiners, a flag made of two regional indicators.
+//
+// Like runewidth it doesn't use the Unicode tables of the standard
+// library where they don't exist: Extended_Pictographic is approximated
+// by the blocks of emoji and Prepend by its few characters in common
+// use.
+package grapheme
+
+import "unicode"
+
+// class is the Grapheme_Cluster_Break property of a rune.
+type class uint8
+
+const (
+	other class = iota
+	cr
+	lf
+	control
+	extend
+	zwj
+	regional
+	prepend
+	spacingMark
+	hangulL
+	hangulV
+	hangulT
+	hangulLV
+	hangulLVT
+	pictographic // Extended_Pictographic, not a break property of its own
+)
+
+// pictographicTable holds (approximately) the runes of
+// Extended_Pictographic below the blocks of emoji, which are taken to be
+// pictographs as a whole.
+var pictographicTable = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00a9, 0x00ae, 5},
+		{0x203c, 0x2049, 13},
+		{0x2122, 0x2139, 23},
+		{0x2194, 0x2199, 1},
+		{0x21a9, 0x21aa, 1},
+		{0x231a, 0x231b, 1},
+		{0x2328, 0x23cf, 167},
+		{0x23e9, 0x23f3, 1},
+		{0x23f8, 0x23fa, 1},
+		{0x24c2, 0x25aa, 232},
+		{0x25ab, 0x25b6, 11},
+		{0x25c0, 0x25fb, 59},
+		{0x25fc, 0x25fe, 1},
+		{0x2600, 0x27bf, 1},
+		{0x2934, 0x2935, 1},
+		{0x2b05, 0x2b07, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b55, 5},
+		{0x3030, 0x303d, 13},
+		{0x3297, 0x3299, 2},
+	},
+}
+
+// prependTable holds the runes of class Prepend in common use.
+var prependTable = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0600, 0x0605, 1},
+		{0x06dd, 0x070f, 50},
+		{0x0890, 0x0891, 1},
+		{0x08e2, 0x0d4e, 1132},
+	},
+	R32: []unicode.Range32{
+		{0x110bd, 0x110cd, 16},
+	},
+}
+
+func classOf(r rune) class {
+	switch {
+	case r < 0x7f:
+		switch {
+		case r == '\r':
+			return cr
+		case r == '\n':
+			return lf
+		case r < 0x20:
+			return control
+		}
+		return other
+	case r == 0x200d:
+		return zwj
+	case r == 0x200c || 0x1f3fb <= r && r <= 0x1f3ff || 0xe0020 <= r && r <= 0xe007f || r == 0xff9e || r == 0xff9f:
+		// zero width non-joiner, emoji modifiers, tags, halfwidth
+		// (semi-)voiced sound marks
+		return extend
+	case 0x1f1e6 <= r && r <= 0x1f1ff:
+		return regional
+	case 0x1100 <= r && r <= 0x115f || 0xa960 <= r && r <= 0xa97c:
+		return hangulL
+	case 0x1160 <= r && r <= 0x11a7 || 0xd7b0 <= r && r <= 0xd7c6:
+		return hangulV
+	case 0x11a8 <= r && r <= 0x11ff || 0xd7cb <= r && r <= 0xd7fb:
+		return hangulT
+	case 0xac00 <= r && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return extend
+	case unicode.Is(prependTable, r):
+		return prepend
+	case unicode.Is(unicode.Mc, r) || r == 0x0e33 || r == 0x0eb3:
+		return spacingMark
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return control
+	case 0x1f000 <= r && r <= 0x1faff || 0x1fc00 <= r && r <= 0x1fffd || unicode.Is(pictographicTable, r):
+		return pictographic
+	}
+	return other
+}
+
+// breaks returns whether there is a boundary between runes of class a
+// and b, given whether a is a zero width joiner following a pictograph
+// (and extending runes) and whether a is a regional indicator starting
+// a pair.
+func breaks(a, b class, pictZWJ, pairRI bool) bool {
+	switch {
+	case a == cr && b == lf: // GB3
+		return false
+	case a == cr || a == lf || a == control: // GB4
+		return true
+	case b == cr || b == lf || b == control: // GB5
+		return true
+	case a == hangulL && (b == hangulL || b == hangulV || b == hangulLV || b == hangulLVT): // GB6
+		return false
+	case (a == hangulLV || a == hangulV) && (b == hangulV || b == hangulT): // GB7
+		return false
+	case (a == hangulLVT || a == hangulT) && b == hangulT: // GB8
+		return false
+	case b == extend || b == zwj || b == spacingMark: // GB9, GB9a
+		return false
+	case a == prepend: // GB9b
+		return false
+	case a == zwj && b == pictographic: // GB11
+		return !pictZWJ
+	case a == regional && b == regional: // GB12, GB13
+		return !pairRI
+	}
+	return true
+}
+
+// Joins returns whether a and b, b following a, may belong to the same
+// cluster.  It only looks at the two runes, so where it returns false
+// there is a boundary whatever precedes a, where it returns true there
+// may be none: it takes all regional indicators to pair up and all
+// pictographs after a zero width joiner to be joined.
+func Joins(a, b rune) bool {
+	return !breaks(classOf(a), classOf(b), true, true)
+}
+
+// Merges returns whether b, following a, is drawn as part of the glyph
+// a belongs to, as far as Joins can tell: extending runes like combining
+// marks, zero width joiners, pictographs after a joiner and regional
+// indicators after one.  Runes joining a otherwise, like spacing marks
+// and Hangul jamos, are drawn next to it.
+func Merges(a, b rune) bool {
+	ca, cb := classOf(a), classOf(b)
+	if breaks(ca, cb, true, true) {
+		return false
+	}
+	return cb == extend || cb == zwj || ca == zwj && cb == pictographic || ca == regional && cb == regional
+}
+
+// A Segmenter finds the boundaries of the clusters in text passed to it
+// rune by rune.  The zero value is at the start of the text.
+type Segmenter struct {
+	started bool
+	prev    class
+	pict    bool // prev is a pictograph, maybe followed by extending runes
+	pictZWJ bool // prev is a zero width joiner following those
+	ris     int  // number of regional indicators in a row up to prev
+}
+
+// Boundary returns whether a cluster starts with r, the rune following
+// those passed before.  The first rune always starts one.
+func (s *Segmenter) Boundary(r rune) bool {
+	c := classOf(r)
+	b := !s.started || breaks(s.prev, c, s.pictZWJ, s.ris%2 == 1)
+	s.pictZWJ = c == zwj && s.pict
+	s.pict = c == pictographic || s.pict && c == extend
+	if c == regional {
+		s.ris++
+	} else {
+		s.ris = 0
+	}
+	s.started = true
+	s.prev = c
+	return b
+}
+
+// Clusters returns the number of clusters in s.
+func Clusters(s string) int {
+	var seg Segmenter
+	n := 0
+	for _, r := range s {
+		if seg.Boundary(r) {
+			n++
+		}
+	}
+	return n
+}
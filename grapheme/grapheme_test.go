@@ -0,0 +1,94 @@
+package grapheme
+
+import (
+	"reflect"
+	"testing"
+)
+
+// split splits s into its clusters.
+func split(s string) []string {
+	var seg Segmenter
+	var clusters []string
+	start := 0
+	for i, r := range s {
+		if seg.Boundary(r) && i > 0 {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+func TestSegmenter(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"abc", []string{"a", "b", "c"}},
+		{"e\u0301x", []string{"e\u0301", "x"}},                                 // combining acute accent
+		{"\u0301a", []string{"\u0301", "a"}},                                   // nothing to combine with
+		{"\n\u0301", []string{"\n", "\u0301"}},                                 // nor after a line break
+		{"\u1100\u1161\u11a8\uac00", []string{"\u1100\u1161\u11a8", "\uac00"}}, // jamos, a syllable
+		{"a\r\nb\n\n", []string{"a", "\r\n", "b", "\n", "\n"}},
+		{"👍🏽!", []string{"👍🏽", "!"}}, // emoji modifier
+		{"👨‍👩‍👧x", []string{"👨‍👩‍👧", "x"}},
+		{"a‍👩", []string{"a‍", "👩"}},         // no pictograph before the joiner
+		{"❤️", []string{"❤️"}},               // variation selector
+		{"🇩🇪🇫🇷🇮", []string{"🇩🇪", "🇫🇷", "🇮"}}, // regional indicators pair up
+		{"क्षि", []string{"क्", "षि"}},       // virama, spacing vowel sign
+		{"؀١", []string{"؀١"}},               // prepended
+		{"a\tb", []string{"a", "\t", "b"}},
+	}
+	for _, test := range tests {
+		if got := split(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.s, got, test.want)
+		}
+	}
+	if n := Clusters("é🇩🇪"); n != 2 {
+		t.Errorf("Clusters = %d, want 2", n)
+	}
+}
+
+func TestJoins(t *testing.T) {
+	tests := []struct {
+		a, b rune
+		want bool
+	}{
+		{'a', 'b', false},
+		{'e', 0x301, true},
+		{'\r', '\n', true},
+		{'\n', 0x301, false},
+		{0x200d, 0x1f469, true},
+		{0x1f1e9, 0x1f1ea, true},
+		{0x1f1e9, 'a', false},
+	}
+	for _, test := range tests {
+		if got := Joins(test.a, test.b); got != test.want {
+			t.Errorf("Joins(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestMerges(t *testing.T) {
+	tests := []struct {
+		a, b rune
+		want bool
+	}{
+		{'e', 0x301, true},
+		{0x1f468, 0x200d, true},
+		{0x200d, 0x1f469, true},
+		{0x1f44d, 0x1f3fd, true},
+		{0x1f1e9, 0x1f1ea, true},
+		{'\r', '\n', false},
+		{0x915, 0x93f, false}, // spacing mark
+		{'a', 'b', false},
+	}
+	for _, test := range tests {
+		if got := Merges(test.a, test.b); got != test.want {
+			t.Errorf("Merges(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
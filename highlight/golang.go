@@ -0,0 +1,105 @@
+package highlight
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// States of the Go scanner at the start of a line.
+const (
+	goNormal = iota
+	goBlockComment
+	goRawString
+)
+
+var goKeywords = map[string]Kind{}
+
+func init() {
+	for _, k := range []string{
+		"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+		"interface", "map", "package", "range", "return", "select", "struct",
+		"switch", "type", "var",
+	} {
+		goKeywords[k] = Keyword
+	}
+	for _, t := range []string{
+		"bool", "byte", "complex64", "complex128", "error", "float32",
+		"float64", "int", "int8", "int16", "int32", "int64", "rune", "string",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "any",
+		"true", "false", "nil", "iota",
+	} {
+		goKeywords[t] = Type
+	}
+}
+
+var goTokens = regexp.MustCompile(
+	`//.*` + // line comment
+		`|/\*` + // start of block comment
+		"|`" + // start of raw string
+		`|"(?:\\.|[^"\\])*"?` + // interpreted string
+		`|'(?:\\.|[^'\\])*'?` + // rune literal
+		`|\b[0-9][0-9a-zA-Z_.]*` + // number
+		`|[\pL_][\pL\pN_]*`) // identifier
+
+// ScanGo is the ScanFunc of the Go highlighter.
+func ScanGo(text []byte, off int, state int) ([]Span, int) {
+	var spans []Span
+	i := 0
+	start := 0 // start of the current token
+	// finish a multi line construct whose contents start at i
+	closeConstruct := func(kind Kind, end []byte) bool {
+		j := bytes.Index(text[i:], end)
+		if j < 0 {
+			spans = append(spans, Span{off + start, off + len(text), kind})
+			return false
+		}
+		i += j + len(end)
+		spans = append(spans, Span{off + start, off + i, kind})
+		state = goNormal
+		return true
+	}
+	for {
+		switch state {
+		case goBlockComment:
+			if !closeConstruct(Comment, []byte("*/")) {
+				return spans, state
+			}
+		case goRawString:
+			if !closeConstruct(String, []byte("`")) {
+				return spans, state
+			}
+		}
+		loc := goTokens.FindIndex(text[i:])
+		if loc == nil {
+			return spans, state
+		}
+		start = i + loc[0]
+		end := i + loc[1]
+		tok := text[start:end]
+		i = end
+		switch {
+		case bytes.HasPrefix(tok, []byte("//")):
+			spans = append(spans, Span{off + start, off + end, Comment})
+		case bytes.Equal(tok, []byte("/*")):
+			state = goBlockComment
+		case tok[0] == '`':
+			state = goRawString
+		case tok[0] == '"' || tok[0] == '\'':
+			spans = append(spans, Span{off + start, off + end, String})
+		case tok[0] >= '0' && tok[0] <= '9':
+			spans = append(spans, Span{off + start, off + end, Number})
+		default:
+			if kind, ok := goKeywords[string(tok)]; ok {
+				spans = append(spans, Span{off + start, off + end, kind})
+			}
+		}
+	}
+}
+
+// NewGo returns a highlighter for Go source in b.
+func NewGo(b *buf.Buf) *Incremental {
+	return NewIncremental(b, ScanGo)
+}
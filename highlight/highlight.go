@@ -0,0 +1,132 @@
+// Package highlight maps byte ranges of a buffer to display attributes.
+package highlight
+
+import (
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/theme"
+)
+
+// Kind classifies a highlighted piece of text.
+type Kind int
+
+const (
+	Normal Kind = iota
+	Keyword
+	Type
+	String
+	Number
+	Comment
+)
+
+// A Span marks the bytes between Off1 (inclusive) and Off2 (exclusive)
+// as being of the given Kind.
+type Span struct {
+	Off1, Off2 int
+	Kind       Kind
+}
+
+// A Highlighter computes the spans of the lines of a buffer.
+type Highlighter interface {
+	// Line returns the spans of line n (starting at 1) sorted by offset.
+	// Text not covered by any span is Normal.
+	Line(n int) []Span
+}
+
+// elements maps each Kind to the element of the theme it is drawn as.
+var elements = map[Kind]theme.Element{
+	Normal:  theme.Normal,
+	Keyword: theme.Keyword,
+	Type:    theme.Type,
+	String:  theme.String,
+	Number:  theme.Number,
+	Comment: theme.Comment,
+}
+
+// StyleOf returns the style of the current theme text of kind k is drawn
+// with.
+func StyleOf(k Kind) theme.Style {
+	return theme.Current[elements[k]]
+}
+
+// Find returns the index of the span containing off in spans
+// or -1 if there is none.
+func Find(spans []Span, off int) int {
+	for i, s := range spans {
+		if s.Off1 <= off && off < s.Off2 {
+			return i
+		}
+		if s.Off1 > off {
+			break
+		}
+	}
+	return -1
+}
+
+// A ScanFunc highlights a single line given its text (without the trailing
+// newline), the offset of the line in the buffer and the state the scanner
+// was in at the start of the line.  It returns the spans and the state at
+// the start of the next line.  States allow constructs spanning several
+// lines (e.g. block comments); 0 is the state at the start of the buffer.
+type ScanFunc func(text []byte, off int, state int) ([]Span, int)
+
+// Incremental is a Highlighter driven by a ScanFunc.  It remembers the
+// scanner state at the start of each line it has seen and, as a
+// BufferObserver, forgets the states of all lines after an edit.  So
+// after a change only the lines from the changed one onwards get rescanned
+// and only as far as they are asked for.
+type Incremental struct {
+	buf    *buf.Buf
+	scan   ScanFunc
+	states []int // states[i] is the state at the start of line i+1
+	id     int   // observer id
+}
+
+// NewIncremental returns a highlighter for b using scan.
+func NewIncremental(b *buf.Buf, scan ScanFunc) *Incremental {
+	h := &Incremental{
+		buf:    b,
+		scan:   scan,
+		states: []int{0},
+	}
+	h.id = b.AddObserver(h)
+	return h
+}
+
+// Close stops the highlighter from observing its buffer.
+func (h *Incremental) Close() {
+	h.buf.RemoveObserver(h.id)
+}
+
+func (h *Incremental) lineText(n int) (text []byte, off int) {
+	off, end := h.buf.LineRange(n)
+	return h.buf.Bytes(off, end), off
+}
+
+func (h *Incremental) Line(n int) []Span {
+	if n < 1 || n > h.buf.Lines() {
+		return nil
+	}
+	for len(h.states) < n {
+		text, off := h.lineText(len(h.states))
+		_, state := h.scan(text, off, h.states[len(h.states)-1])
+		h.states = append(h.states, state)
+	}
+	text, off := h.lineText(n)
+	spans, _ := h.scan(text, off, h.states[n-1])
+	return spans
+}
+
+// invalidate forgets the states of all lines after the one containing off.
+func (h *Incremental) invalidate(off int) {
+	line := 1
+	if pos, err := h.buf.PositionFromOffset(off); err == nil {
+		line = pos.Line
+	}
+	if line < len(h.states) {
+		h.states = h.states[:line]
+	}
+}
+
+func (h *Incremental) OnBufChange(c buf.Change) {
+	h.invalidate(c.Off)
+}
@@ -0,0 +1,28 @@
+package highlight
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestGoIncremental(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte("func f() {\n\treturn \"x\" // y\n}\n"))
+	h := NewGo(&b)
+	if got, exp := h.Line(1), []Span{{0, 4, Keyword}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("line 1: expected %v got %v", exp, got)
+	}
+	exp := []Span{{12, 18, Keyword}, {19, 22, String}, {23, 27, Comment}}
+	if got := h.Line(2); !reflect.DeepEqual(got, exp) {
+		t.Errorf("line 2: expected %v got %v", exp, got)
+	}
+	// opening a block comment on line 1 must turn the rest into a comment
+	b.Insert(10, []byte("/*"))
+	exp = []Span{{13, 29, Comment}}
+	if got := h.Line(2); !reflect.DeepEqual(got, exp) {
+		t.Errorf("line 2 after edit: expected %v got %v", exp, got)
+	}
+}
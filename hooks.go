@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+)
+
+// Events hooks are run on, named as in vim's autocommands.
+const (
+	bufWritePre  = "BufWritePre"  // before a buffer is written to its file
+	bufWritePost = "BufWritePost" // after a buffer was written to its file
+)
+
+// defaultGofmtprg is the command Go buffers are formatted with before
+// they are written unless set otherwise with :set gofmtprg.
+const defaultGofmtprg = "gofmt"
+
+// A hook is run on event for the buffers whose file name matches
+// pattern.
+type hook struct {
+	event   string
+	pattern string // see filepath.Match, matched against the base name
+	command string // ex command of a user defined hook, "" for built-in ones
+	run     func(ed *editor, b *buf.Buf) error
+}
+
+// builtinHooks are the hooks every editor starts with.
+var builtinHooks = []hook{
+	{event: bufWritePre, pattern: "*.go", run: (*editor).gofmt},
+}
+
+// matches returns whether h is run on event for b.
+func (h hook) matches(event string, b *buf.Buf) bool {
+	if !strings.EqualFold(h.event, event) || b.Name() == "" {
+		return false
+	}
+	ok, _ := filepath.Match(h.pattern, filepath.Base(b.Name()))
+	return ok
+}
+
+// runHooks runs the hooks for event on b.  A failing hook doesn't keep
+// the others from running, the first error is returned.
+func (ed *editor) runHooks(event string, b *buf.Buf) error {
+	var first error
+	for _, h := range ed.hooks {
+		if !h.matches(event, b) {
+			continue
+		}
+		if err := h.run(ed, b); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// gofmt formats b with the command set with :set gofmtprg.  Only the
+// lines that change are replaced, so the cursor and the marks stay put.
+func (ed *editor) gofmt(b *buf.Buf) error {
+	gofmtprg := ed.options.String("gofmtprg", nil, nil)
+	if gofmtprg == "" {
+		return nil
+	}
+	out, err := runShell(gofmtprg, b.Slice(0, b.Len()))
+	if err != nil {
+		return err
+	}
+	_, err = replaceLines(b, out)
+	return err
+}
+
+// :au[tocmd] {event} {pattern} {command} runs the ex command for event
+// on the buffers whose file name matches pattern, e.g.
+//
+//	:autocmd BufWritePost *.go make
+//
+// Without arguments it lists the hooks defined.
+func (ed *editor) cmdAutocmd(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		var lines []string
+		for _, h := range ed.hooks {
+			command := h.command
+			if command == "" {
+				command = "(built-in)"
+			}
+			lines = append(lines, fmt.Sprintf("%-12s %-10s %s", h.event, h.pattern, command))
+		}
+		ed.setMessage(strings.Join(lines, "\n"))
+		return nil
+	}
+	event, rest, _ := strings.Cut(cmd.Arg, " ")
+	pattern, command, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return fmt.Errorf("Invalid autocommand: %s", cmd.Arg)
+	}
+	if !strings.EqualFold(event, bufWritePre) && !strings.EqualFold(event, bufWritePost) {
+		return fmt.Errorf("No such event: %s", event)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("Invalid pattern: %s", pattern)
+	}
+	ed.hooks = append(ed.hooks, hook{
+		event:   event,
+		pattern: pattern,
+		command: command,
+		run: func(ed *editor, b *buf.Buf) error {
+			return ed.commands.Execute(command)
+		},
+	})
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/view"
+)
+
+// indentation returns the end offset of the leading blanks of line n
+// and the number of columns they occupy.
+func indentation(b *buf.Buf, n int, opts view.Options) (end, width int) {
+	rd := b.NewReader(b.Line(n))
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil {
+			return rd.Offset(), width
+		}
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += opts.TabStop - width%opts.TabStop
+		default:
+			rd.UnreadRune()
+			return rd.Offset(), width
+		}
+	}
+}
+
+// indentString returns the blanks making up an indentation width columns
+// wide, using tabs unless ExpandTab is set.
+func indentString(width int, opts view.Options) string {
+	if width <= 0 {
+		return ""
+	}
+	if opts.ExpandTab {
+		return strings.Repeat(" ", width)
+	}
+	return strings.Repeat("\t", width/opts.TabStop) + strings.Repeat(" ", width%opts.TabStop)
+}
+
+// setIndent replaces the indentation of line n by one width columns wide.
+func setIndent(b *buf.Buf, n int, width int, opts view.Options) error {
+	off := b.Line(n)
+	end, _ := indentation(b, n, opts)
+	if err := b.TryDelete(off, end); err != nil {
+		return err
+	}
+	return b.TryInsert(off, []byte(indentString(width, opts)))
+}
+
+// shiftLines changes the indentation of lines first to last by
+// levels times the shift width.  Empty lines are left alone.
+func shiftLines(b *buf.Buf, first, last, levels int, opts view.Options) error {
+	for n := first; n <= last; n++ {
+		end, width := indentation(b, n, opts)
+		if r, _, err := b.NewReader(end).ReadRune(); err != nil || r == '\n' {
+			continue
+		}
+		width += levels * opts.ShiftWidth
+		if width < 0 {
+			width = 0
+		}
+		if err := setIndent(b, n, width, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
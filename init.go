@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/keymap"
+	"github.com/bgrundmann/e/theme"
+	"github.com/bgrundmann/e/view"
+)
+
+// initFile is the name of the file in the user's configuration
+// directory (usually ~/.config) holding the ex commands run on startup.
+var initFile = filepath.Join("e", "init")
+
+// runInit runs the commands of the init file, one per line.  Blank
+// lines and lines starting with " are skipped.  A failing command
+// doesn't stop the others, its error goes to the messages (see
+// :messages).
+func (ed *editor) runInit() {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return
+	}
+	name := filepath.Join(dir, initFile)
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		ed.setError(err)
+		return
+	}
+	defer f.Close()
+	failed := 0
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, `"`) {
+			continue
+		}
+		if err := ed.commands.Execute(strings.TrimPrefix(line, ":")); err != nil {
+			ed.logMessage(fmt.Sprintf("%s:%d: %v", name, n, err))
+			failed++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		ed.setError(fmt.Errorf("%s: %v", name, err))
+	} else if failed > 0 {
+		ed.setError(fmt.Errorf("%d errors in %s, see :messages", failed, name))
+	}
+}
+
+// mapModes are the modes of the map commands.
+var mapModes = map[string]string{
+	"map":  "normal",
+	"nmap": "normal",
+	"imap": "insert",
+	"vmap": "visual",
+}
+
+// :map {keys} {action} binds keys to action in normal mode, as do :nmap
+// in normal, :imap in insert and :vmap in visual mode.  An action of ""
+// removes the binding.  With only the keys it shows their action.
+func (ed *editor) cmdMap(name string) func(cmd ex.Command) error {
+	mode := mapModes[name]
+	return func(cmd ex.Command) error {
+		args := splitArgs(cmd.Arg)
+		switch len(args) {
+		case 1:
+			keys, err := keymap.ParseKeys(args[0])
+			if err != nil {
+				return err
+			}
+			action, _ := ed.keymaps.Lookup(mode, keys)
+			if action == "" {
+				return errors.New("No mapping found")
+			}
+			ed.setMessage(fmt.Sprintf("%s %s %s", name, args[0], action))
+			return nil
+		case 2:
+			action := args[1]
+			if action == `""` {
+				action = ""
+			}
+			return ed.keymaps.Bind(mode, args[0], action)
+		}
+		return fmt.Errorf("Usage: %s {keys} {action}", name)
+	}
+}
+
+// :hi[ghlight] {element} {style} sets the style of an element of the
+// theme, e.g. :hi keyword blue bold.
+func (ed *editor) cmdHighlight(cmd ex.Command) error {
+	element, style, _ := strings.Cut(cmd.Arg, " ")
+	if element == "" {
+		return errors.New("Usage: highlight {element} {style}")
+	}
+	if err := theme.Current.Set(element, strings.TrimSpace(style)); err != nil {
+		return err
+	}
+	for _, t := range ed.tabs {
+		t.layout.Root().Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+			leaf.View().Invalidate()
+		})
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/jobs"
+	"github.com/bgrundmann/e/view"
+)
+
+// jobKind is the kind of the buffers collecting the output of a job.
+var jobKind = &bufKind{mode: "job"}
+
+// jobOutput is where the output of a job goes.
+type jobOutput struct {
+	b      *buf.Buf
+	status string // how the job ended, "running" until then
+}
+
+// startJob runs command with the shell in the background.  Its output
+// is appended to a new buffer shown in a window split off the focused
+// one.
+func (ed *editor) startJob(command string) error {
+	j, err := ed.jobs.Start(shellCommand(command), command)
+	if err != nil {
+		return err
+	}
+	b := new(buf.Buf).Init()
+	b.SetName(fmt.Sprintf("[Job %d] %s", j.ID, command))
+	ed.addBuffer(b)
+	ed.bufEntry(b).kind = jobKind
+	ed.jobOutput[j] = &jobOutput{b: b, status: "running"}
+	focus := ed.focus
+	ed.split(view.Horizontal)
+	ed.showBuffer(ed.focus, b)
+	ed.setFocus(focus)
+	return nil
+}
+
+// forwardJobEvents posts the events of the jobs to the main loop.
+func (ed *editor) forwardJobEvents() {
+	for ev := range ed.jobs.Events {
+		ed.postJobEvent(ev)
+	}
+}
+
+func (ed *editor) postJobEvent(ev jobs.Event) {
+	ed.post(func(ed *editor) { ed.handleJobEvent(ev) })
+}
+
+// handleJobEvent appends the output of a job to its buffer.  Windows
+// with the cursor at the end of the buffer follow the output, as the
+// cursor stays behind text inserted at it.
+func (ed *editor) handleJobEvent(ev jobs.Event) {
+	out := ed.jobOutput[ev.Job]
+	if out == nil {
+		return
+	}
+	b := out.b
+	if !ev.Done {
+		if err := b.TryInsert(b.Len(), ev.Output); err != nil {
+			ed.setError(err)
+		}
+		b.MarkSaved()
+		return
+	}
+	out.status = "done"
+	if ev.Err != nil {
+		out.status = ev.Err.Error()
+	}
+	tail := []byte("[" + out.status + "]\n")
+	if b.Len() > 0 && b.Bytes(b.Len()-1, b.Len())[0] != '\n' {
+		tail = append([]byte{'\n'}, tail...)
+	}
+	if err := b.TryInsert(b.Len(), tail); err != nil {
+		ed.setError(err)
+	}
+	b.MarkSaved()
+	ed.setMessage(fmt.Sprintf("Job %d %s: %s", ev.Job.ID, ev.Job.Command, out.status))
+}
+
+// jobsMode returns what the status line shows about the running jobs.
+func (ed *editor) jobsMode() string {
+	if n := ed.jobs.Running(); n > 0 {
+		return fmt.Sprintf("[%d running]", n)
+	}
+	return ""
+}
+
+// :job {command} runs command in the background.
+func (ed *editor) cmdJob(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		return errors.New("No shell command")
+	}
+	return ed.startJob(cmd.Arg)
+}
+
+// :jobs lists the jobs with their number, status and command.
+func (ed *editor) cmdJobs(cmd ex.Command) error {
+	var lines []string
+	for _, j := range ed.jobs.Jobs() {
+		lines = append(lines, fmt.Sprintf("%3d %-20s %s", j.ID, ed.jobOutput[j].status, j.Command))
+	}
+	if len(lines) == 0 {
+		return errors.New("No jobs")
+	}
+	ed.setMessage(strings.Join(lines, "\n"))
+	return nil
+}
+
+// :jobk[ill] [N] kills job N or, without N, the last job started that is
+// still running.
+func (ed *editor) cmdJobKill(cmd ex.Command) error {
+	all := ed.jobs.Jobs()
+	if cmd.Arg != "" {
+		n, err := strconv.Atoi(cmd.Arg)
+		if err != nil || n < 1 || n > len(all) {
+			return fmt.Errorf("No such job: %s", cmd.Arg)
+		}
+		return all[n-1].Kill()
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if !all[i].Done() {
+			return all[i].Kill()
+		}
+	}
+	return errors.New("No running job")
+}
@@ -0,0 +1,118 @@
+// Package jobs runs external commands in the background, delivering
+// their output and their end as events, so that the editor can go on
+// handling keys meanwhile.
+package jobs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// An Event tells about a job: either output it wrote or that it ended.
+type Event struct {
+	Job    *Job
+	Output []byte // what the job wrote to stdout or stderr
+	Done   bool   // the job ended, Err tells how
+	Err    error  // nil if it exited successfully
+}
+
+// A Job is a command running in the background.
+type Job struct {
+	ID      int    // the jobs of a Runner are numbered from 1
+	Command string // as shown to the user
+	cmd     *exec.Cmd
+
+	mu   sync.Mutex
+	done bool
+}
+
+// Done returns whether the job ended.
+func (j *Job) Done() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done
+}
+
+// Kill ends the job if it is still running.
+func (j *Job) Kill() error {
+	if j.Done() {
+		return nil
+	}
+	if err := j.cmd.Process.Kill(); !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// A Runner starts jobs, sending their events to Events.
+type Runner struct {
+	Events chan Event
+
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+// NewRunner returns a runner without jobs.
+func NewRunner() *Runner {
+	return &Runner{Events: make(chan Event)}
+}
+
+// Start starts cmd as a job called command.  Its standard output and
+// error end up in Output events, stdin is empty.
+func (r *Runner) Start(cmd *exec.Cmd, command string) (*Job, error) {
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	j := &Job{ID: len(r.jobs) + 1, Command: command, cmd: cmd}
+	r.jobs = append(r.jobs, j)
+	r.mu.Unlock()
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		exited <- err
+		pw.Close()
+	}()
+	go func() {
+		for {
+			buf := make([]byte, 4096)
+			n, err := pr.Read(buf)
+			if n > 0 {
+				r.Events <- Event{Job: j, Output: buf[:n]}
+			}
+			if err != nil {
+				break
+			}
+		}
+		err := <-exited
+		j.mu.Lock()
+		j.done = true
+		j.mu.Unlock()
+		r.Events <- Event{Job: j, Done: true, Err: err}
+	}()
+	return j, nil
+}
+
+// Jobs returns the jobs started so far, including those that ended.
+func (r *Runner) Jobs() []*Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Job(nil), r.jobs...)
+}
+
+// Running returns the number of jobs still running.
+func (r *Runner) Running() int {
+	n := 0
+	for _, j := range r.Jobs() {
+		if !j.Done() {
+			n++
+		}
+	}
+	return n
+}
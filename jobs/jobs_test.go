@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// wait collects the output of the next job to end.
+func wait(t *testing.T, r *Runner) (string, Event) {
+	var out []byte
+	for {
+		select {
+		case ev := <-r.Events:
+			if ev.Done {
+				return string(out), ev
+			}
+			out = append(out, ev.Output...)
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not end")
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	r := NewRunner()
+	j, err := r.Start(exec.Command("sh", "-c", "echo out; echo err >&2; exit 3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.ID != 1 || r.Running() != 1 {
+		t.Errorf("got job %d, %d running", j.ID, r.Running())
+	}
+	out, ev := wait(t, r)
+	if out != "out\nerr\n" {
+		t.Errorf("got output %q", out)
+	}
+	if ev.Job != j || ev.Err == nil || !j.Done() {
+		t.Errorf("got end %+v", ev)
+	}
+	if r.Running() != 0 || len(r.Jobs()) != 1 {
+		t.Errorf("%d running of %d", r.Running(), len(r.Jobs()))
+	}
+}
+
+func TestKill(t *testing.T) {
+	r := NewRunner()
+	j, err := r.Start(exec.Command("sleep", "10"), "sleep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ev := wait(t, r); ev.Err == nil {
+		t.Error("killed job ended successfully")
+	}
+}
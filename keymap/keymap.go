@@ -0,0 +1,236 @@
+// Package keymap maps sequences of keys to the names of editor actions.
+//
+// Keys are written as in vim: printable characters stand for themselves,
+// other keys are written in angle brackets, e.g. <Esc>, <CR>, <C-o>.
+// Modifiers are written C- (control), M- or A- (alt), S- (shift) in front
+// of the key, e.g. <M-x> or <C-S-Up>.
+// A sequence is just the keys one after the other, e.g. "gg" or "<C-w>s".
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// names maps the lower case names of special keys to their canonical form.
+var names = map[string]string{}
+
+func init() {
+	for _, name := range []string{
+		"Esc", "CR", "Tab", "BS", "Space", "Del", "Insert",
+		"Up", "Down", "Left", "Right", "Home", "End", "PageUp", "PageDown",
+	} {
+		names[strings.ToLower(name)] = "<" + name + ">"
+	}
+	// aliases
+	names["enter"] = "<CR>"
+	names["return"] = "<CR>"
+	names["escape"] = "<Esc>"
+	names["backspace"] = "<BS>"
+	names["lt"] = "<"
+}
+
+// Ctrl returns the name of the key c pressed together with control.
+func Ctrl(c rune) string {
+	return "<C-" + string(c) + ">"
+}
+
+// Modified returns the name of key, a name as returned by ParseKeys,
+// pressed together with control, alt (meta) and shift as far as they are
+// set, e.g. "<C-Up>" for "<Up>" and ctrl.  Shift with a printable
+// character gives the upper case character.
+func Modified(key string, ctrl, alt, shift bool) string {
+	k := modKey{base: key}
+	if len(key) > 2 && key[0] == '<' {
+		k, _ = parseKey(key[1 : len(key)-1])
+	}
+	k.ctrl = k.ctrl || ctrl
+	k.alt = k.alt || alt
+	k.shift = k.shift || shift
+	return k.String()
+}
+
+// A modKey is a key with the modifiers pressed together with it.
+type modKey struct {
+	ctrl, alt, shift bool
+	base             string // a character or a canonical name like "<Up>"
+}
+
+// parseKey parses the name of a key written in angle brackets, e.g.
+// "Up", "C-w" or "M-S-Left".
+func parseKey(name string) (modKey, bool) {
+	var k modKey
+	for len(name) > 2 && name[1] == '-' {
+		switch name[0] {
+		case 'c', 'C':
+			k.ctrl = true
+		case 'm', 'M', 'a', 'A':
+			k.alt = true
+		case 's', 'S':
+			k.shift = true
+		default:
+			return k, false
+		}
+		name = name[2:]
+	}
+	if key, ok := names[strings.ToLower(name)]; ok {
+		k.base = key
+	} else if utf8.RuneCountInString(name) == 1 && (k.ctrl || k.alt || k.shift) {
+		k.base = name
+	} else {
+		return k, false
+	}
+	return k, true
+}
+
+// String returns the canonical name of k: the modifiers in the order
+// C-, M-, S-, letters with control in lower case, e.g. "<C-M-x>".
+func (k modKey) String() string {
+	base := k.base
+	named := len(base) > 1 && base[0] == '<'
+	if !named {
+		if k.shift {
+			base = strings.ToUpper(base)
+			k.shift = false
+		}
+		if k.ctrl {
+			base = strings.ToLower(base)
+		}
+	}
+	mods := ""
+	if k.ctrl {
+		mods += "C-"
+	}
+	if k.alt {
+		mods += "M-"
+	}
+	if k.shift {
+		mods += "S-"
+	}
+	switch {
+	case mods == "":
+		return base
+	case named:
+		base = base[1 : len(base)-1]
+	case base == "<":
+		base = "lt"
+	}
+	return "<" + mods + base + ">"
+}
+
+// ParseKeys splits a key sequence into its keys, each in canonical form.
+func ParseKeys(s string) ([]string, error) {
+	var keys []string
+	for len(s) > 0 {
+		end := strings.IndexByte(s, '>')
+		if s[0] == '<' && end > 1 {
+			name := s[1:end]
+			k, ok := parseKey(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown key <%s>", name)
+			}
+			keys = append(keys, k.String())
+			s = s[end+1:]
+			continue
+		}
+		r := []rune(s)[0]
+		keys = append(keys, string(r))
+		s = s[len(string(r)):]
+	}
+	return keys, nil
+}
+
+// A Map maps key sequences to actions.  The zero value is an empty map.
+type Map struct {
+	action string
+	next   map[string]*Map // nil for leaves
+}
+
+// Bind binds the key sequence keys to action.  An empty action removes
+// the binding.
+func (m *Map) Bind(keys string, action string) error {
+	seq, err := ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	if len(seq) == 0 {
+		return fmt.Errorf("empty key sequence")
+	}
+	for _, key := range seq {
+		if m.next == nil {
+			m.next = make(map[string]*Map)
+		}
+		n, ok := m.next[key]
+		if !ok {
+			n = &Map{}
+			m.next[key] = n
+		}
+		m = n
+	}
+	m.action = action
+	return nil
+}
+
+// Lookup looks up the key sequence keys.  Returns the action bound to it,
+// or prefix true if keys is the start of a longer bound sequence.  Shorter
+// sequences win, so if "g" is bound "gg" can never be reached.
+func (m *Map) Lookup(keys []string) (action string, prefix bool) {
+	for _, key := range keys {
+		if m.action != "" {
+			return "", false
+		}
+		n, ok := m.next[key]
+		if !ok {
+			return "", false
+		}
+		m = n
+	}
+	if m.action != "" {
+		return m.action, false
+	}
+	return "", len(m.next) > 0
+}
+
+// Keymaps holds one Map per mode (e.g. "normal", "insert").
+type Keymaps map[string]*Map
+
+// Bind binds keys to action in the map of mode.
+func (km Keymaps) Bind(mode, keys, action string) error {
+	m, ok := km[mode]
+	if !ok {
+		m = &Map{}
+		km[mode] = m
+	}
+	return m.Bind(keys, action)
+}
+
+// Lookup looks up keys in the map of mode.  See Map.Lookup.
+func (km Keymaps) Lookup(mode string, keys []string) (action string, prefix bool) {
+	m, ok := km[mode]
+	if !ok {
+		return "", false
+	}
+	return m.Lookup(keys)
+}
+
+// Load adds the bindings read from r to km.  r must contain a JSON object
+// mapping modes to objects mapping key sequences to actions, e.g.
+//
+//	{"normal": {"<C-d>": "page-down", "Q": ""}}
+func (km Keymaps) Load(r io.Reader) error {
+	var config map[string]map[string]string
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return err
+	}
+	for mode, bindings := range config {
+		for keys, action := range bindings {
+			if err := km.Bind(mode, keys, action); err != nil {
+				return fmt.Errorf("%s: %q: %v", mode, keys, err)
+			}
+		}
+	}
+	return nil
+}
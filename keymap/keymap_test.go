@@ -0,0 +1,105 @@
+package keymap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"gg", []string{"g", "g"}},
+		{"<C-W>s", []string{"<C-w>", "s"}},
+		{"<esc><cr><lt>", []string{"<Esc>", "<CR>", "<"}},
+		{"<<", []string{"<", "<"}},
+		{"<", []string{"<"}},
+		{"ä>", []string{"ä", ">"}},
+		{"<c-s-up><M-x><a-X><S-a><m-C-W><M-lt><S-Tab>", []string{"<C-S-Up>", "<M-x>", "<M-X>", "A", "<C-M-w>", "<M-lt>", "<S-Tab>"}},
+	}
+	for _, test := range tests {
+		got, err := ParseKeys(test.s)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseKeys(%q) = %q, %v want %q", test.s, got, err, test.want)
+		}
+	}
+	for _, s := range []string{"<nokey>", "<X-a>", "<C-ab>"} {
+		if _, err := ParseKeys(s); err == nil {
+			t.Errorf("expected error for unknown key %s", s)
+		}
+	}
+}
+
+func TestModified(t *testing.T) {
+	tests := []struct {
+		key              string
+		ctrl, alt, shift bool
+		want             string
+	}{
+		{"x", false, false, false, "x"},
+		{"x", false, true, false, "<M-x>"},
+		{"x", false, false, true, "X"},
+		{"<", false, true, false, "<M-lt>"},
+		{"<Up>", true, false, true, "<C-S-Up>"},
+		{"<C-w>", false, true, false, "<C-M-w>"},
+		{"<Tab>", false, false, true, "<S-Tab>"},
+	}
+	for _, test := range tests {
+		if got := Modified(test.key, test.ctrl, test.alt, test.shift); got != test.want {
+			t.Errorf("Modified(%q, %v, %v, %v) = %q, want %q", test.key, test.ctrl, test.alt, test.shift, got, test.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	km := Keymaps{}
+	km.Bind("normal", "j", "down")
+	km.Bind("normal", "gg", "buffer-start")
+	km.Bind("normal", "<C-o>", "jump-back")
+	tests := []struct {
+		keys   string
+		action string
+		prefix bool
+	}{
+		{"j", "down", false},
+		{"g", "", true},
+		{"gg", "buffer-start", false},
+		{"gj", "", false},
+		{"jj", "", false},
+		{"<C-o>", "jump-back", false},
+		{"x", "", false},
+	}
+	for _, test := range tests {
+		keys, _ := ParseKeys(test.keys)
+		action, prefix := km.Lookup("normal", keys)
+		if action != test.action || prefix != test.prefix {
+			t.Errorf("Lookup(%q) = %q, %v want %q, %v", test.keys, action, prefix, test.action, test.prefix)
+		}
+	}
+	if action, _ := km.Lookup("insert", []string{"j"}); action != "" {
+		t.Errorf("insert mode: got %q", action)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	km := Keymaps{}
+	km.Bind("normal", "j", "down")
+	err := km.Load(strings.NewReader(`{"normal": {"j": "", "<C-d>": "page-down"}, "insert": {"jk": "escape"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action, _ := km.Lookup("normal", []string{"j"}); action != "" {
+		t.Errorf("j still bound to %q", action)
+	}
+	if action, _ := km.Lookup("normal", []string{"<C-d>"}); action != "page-down" {
+		t.Errorf("<C-d> bound to %q", action)
+	}
+	if action, _ := km.Lookup("insert", []string{"j", "k"}); action != "escape" {
+		t.Errorf("jk bound to %q", action)
+	}
+	if err := km.Load(strings.NewReader(`{"normal": {"<bad>": "x"}}`)); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
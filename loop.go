@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// A message is posted by a subsystem working in the background (jobs,
+// the language server) and handled by the main loop, the only one
+// allowed to touch the editor and the screen.
+type message func(ed *editor)
+
+// mailbox collects the messages posted until the main loop takes them.
+// Posting never blocks, so that a subsystem can post while the main loop
+// waits for it.
+type mailbox struct {
+	mu     sync.Mutex
+	queue  []message
+	posted chan struct{} // ready to receive once messages are queued
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{posted: make(chan struct{}, 1)}
+}
+
+// post queues m for the main loop.  Safe to call from any goroutine.
+func (mb *mailbox) post(m message) {
+	mb.mu.Lock()
+	mb.queue = append(mb.queue, m)
+	mb.mu.Unlock()
+	select {
+	case mb.posted <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the messages queued so far and empties the queue.
+func (mb *mailbox) take() []message {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	queue := mb.queue
+	mb.queue = nil
+	return queue
+}
+
+// post hands m to the main loop.
+func (ed *editor) post(m message) {
+	ed.messages.post(m)
+}
+
+// loop handles the events returned by nextEvent, the messages posted and
+// the ticks of the timer until the editor quits.  The screen is redrawn
+// after each of them unless batch is set.
+func (ed *editor) loop(nextEvent func() termbox.Event, batch bool) error {
+	// the events are read in the background, but only one at a time when
+	// asked for, so that none is read after quitting
+	events := make(chan termbox.Event)
+	wanted := make(chan bool)
+	defer close(wanted)
+	go func() {
+		for range wanted {
+			events <- nextEvent()
+		}
+	}()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	waiting := false
+	for !ed.quit {
+		if !batch {
+			ed.syncLanguageServer()
+			ed.updateDiffSigns()
+			ed.syncBlame()
+			ed.display()
+		}
+		if !waiting {
+			wanted <- true
+			waiting = true
+		}
+		select {
+		case ev := <-events:
+			waiting = false
+			switch ev.Type {
+			case termbox.EventKey:
+				ed.handleKey(ev)
+			case termbox.EventResize:
+				ed.resize(ev.Width, ev.Height)
+			case termbox.EventError:
+				return ev.Err
+			}
+		case <-ed.messages.posted:
+			for _, m := range ed.messages.take() {
+				m(ed)
+			}
+		case <-ticker.C:
+			ed.flushSwaps()
+			if !batch {
+				ed.checkFiles()
+			}
+		}
+	}
+	return nil
+}
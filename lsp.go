@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/lsp"
+	"github.com/bgrundmann/e/theme"
+	"github.com/bgrundmann/e/view"
+)
+
+// languageServerCommand starts the language server of Go files.
+var languageServerCommand = []string{"gopls"}
+
+// languageServer is the connection to the language server, started when
+// the first Go file is shown if the server is installed.
+type languageServer struct {
+	client *lsp.Client // nil unless started
+	failed bool        // starting the server failed, don't try again
+	docs   map[*buf.Buf]*lsp.Document
+	// version of the diagnostics the marks of a view show, and of which
+	// buffer
+	shown   map[*view.View]shownDiagnostics
+	message string // diagnostic shown as message or ""
+
+	diagnostics map[string][]lsp.Diagnostic // by URI
+	version     int                         // incremented with every change of diagnostics
+}
+
+type shownDiagnostics struct {
+	b       *buf.Buf
+	version int
+}
+
+// isGo returns whether b holds a Go file.
+func (ed *editor) isGo(b *buf.Buf) bool {
+	ft, _ := detectFileType(b.Name())
+	return ft.name == "go" && ed.kind(b) == nil
+}
+
+// startLanguageServer starts the language server unless it runs already
+// or failed to start.  Returns false if there is none.
+func (ed *editor) startLanguageServer() bool {
+	ls := &ed.lsp
+	if ls.client != nil || ls.failed {
+		return ls.client != nil
+	}
+	ls.failed = true
+	if _, err := exec.LookPath(languageServerCommand[0]); err != nil {
+		return false
+	}
+	client, err := lsp.Start(languageServerCommand, ed.notifyLanguageServer)
+	if err == nil {
+		err = client.Initialize(".")
+	}
+	if err != nil {
+		ed.setError(fmt.Errorf("%s: %v", languageServerCommand[0], err))
+		return false
+	}
+	ls.failed = false
+	ls.client = client
+	ls.docs = make(map[*buf.Buf]*lsp.Document)
+	ls.shown = make(map[*view.View]shownDiagnostics)
+	ls.diagnostics = make(map[string][]lsp.Diagnostic)
+	return true
+}
+
+// notifyLanguageServer handles the notifications of the language server,
+// posting the diagnostics published to the main loop.
+func (ed *editor) notifyLanguageServer(method string, params json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var p lsp.PublishDiagnostics
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+	ed.post(func(ed *editor) {
+		ed.lsp.diagnostics[p.URI] = p.Diagnostics
+		ed.lsp.version++
+	})
+}
+
+// document returns the document of b in the language server, opening it
+// or sending the changes made since it was last synced.
+func (ed *editor) document(b *buf.Buf) (*lsp.Document, error) {
+	if !ed.isGo(b) || !ed.startLanguageServer() {
+		return nil, errors.New("No language server for this buffer")
+	}
+	ls := &ed.lsp
+	doc := ls.docs[b]
+	if doc == nil {
+		var err error
+		if doc, err = ls.client.Open(b, "go"); err != nil {
+			return nil, err
+		}
+		ls.docs[b] = doc
+		return doc, nil
+	}
+	return doc, ls.client.Sync(doc, b)
+}
+
+// syncLanguageServer tells the language server about the changes to the
+// Go buffers shown and shows the diagnostics it published.
+func (ed *editor) syncLanguageServer() {
+	for _, b := range ed.buffers() {
+		if ed.isGo(b) {
+			if _, err := ed.document(b); err != nil && ed.lsp.client != nil {
+				ed.setError(err)
+			}
+		}
+	}
+	ls := &ed.lsp
+	if ls.client == nil {
+		return
+	}
+	ed.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+		v := leaf.View()
+		if shown := (shownDiagnostics{v.Buffer(), ls.version}); ls.shown[v] != shown {
+			ls.shown[v] = shown
+			v.SetMarks(ed.marksOf(v.Buffer()))
+		}
+	})
+	ed.showDiagnostic()
+}
+
+// marksOf returns the marks pointing out the diagnostics of b.
+func (ed *editor) marksOf(b *buf.Buf) []view.Mark {
+	doc := ed.lsp.docs[b]
+	if doc == nil {
+		return nil
+	}
+	text := b.Bytes(0, b.Len())
+	enc := ed.lsp.client.Encoding
+	var marks []view.Mark
+	for _, d := range ed.lsp.diagnostics[doc.URI] {
+		m := view.Mark{
+			Off1:    lsp.OffsetOf(text, d.Range.Start, enc),
+			Off2:    lsp.OffsetOf(text, d.Range.End, enc),
+			Sign:    'I',
+			Element: theme.DiagnosticInfo,
+		}
+		switch d.Severity {
+		case lsp.SeverityError:
+			m.Sign, m.Element = 'E', theme.DiagnosticError
+		case lsp.SeverityWarning:
+			m.Sign, m.Element = 'W', theme.DiagnosticWarning
+		}
+		marks = append(marks, m)
+	}
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].Off1 < marks[j].Off1 })
+	return marks
+}
+
+// showDiagnostic shows the first diagnostic of the line of the cursor as
+// message, unless there is another message.
+func (ed *editor) showDiagnostic() {
+	ls := &ed.lsp
+	if ed.mode != modeNormal || (ed.message != "" && ed.message != ls.message) {
+		return
+	}
+	msg := ""
+	if doc := ls.docs[ed.focus.Buffer()]; doc != nil {
+		line := ls.client.Position(doc, ed.focus.Cursor()).Line
+		for _, d := range ls.diagnostics[doc.URI] {
+			if d.Range.Start.Line == line {
+				msg = d.Message
+				break
+			}
+		}
+	}
+	if msg != ed.message {
+		ed.setMessage(msg)
+	}
+	ls.message = msg
+}
+
+// gotoDefinition goes to the definition of the symbol under the cursor.
+func (ed *editor) gotoDefinition() error {
+	v := ed.focus
+	doc, err := ed.document(v.Buffer())
+	if err != nil {
+		return err
+	}
+	client := ed.lsp.client
+	locs, err := client.Definition(doc, client.Position(doc, v.Cursor()))
+	if err != nil {
+		return err
+	}
+	if len(locs) == 0 {
+		return errors.New("No definition found")
+	}
+	name := lsp.Filename(locs[0].URI)
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, name); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	b, err := ed.loadBuffer(name)
+	if err != nil {
+		return err
+	}
+	if b == v.Buffer() {
+		v.PushJump(v.Cursor())
+	} else if err := ed.switchBuffer(b, false); err != nil {
+		return err
+	}
+	v.SetCursor(lsp.OffsetOf(b.Bytes(0, b.Len()), locs[0].Range.Start, client.Encoding))
+	ed.showCursor()
+	return nil
+}
+
+// hover shows the information about the symbol under the cursor.
+func (ed *editor) hover() error {
+	v := ed.focus
+	doc, err := ed.document(v.Buffer())
+	if err != nil {
+		return err
+	}
+	client := ed.lsp.client
+	text, err := client.Hover(doc, client.Position(doc, v.Cursor()))
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if !strings.HasPrefix(line, "```") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 || lines[0] == "" {
+		return errors.New("No information")
+	}
+	ed.setMessage(strings.Join(lines, "\n"))
+	return nil
+}
+
+// stopLanguageServer shuts the language server down.
+func (ed *editor) stopLanguageServer() {
+	if ed.lsp.client != nil {
+		ed.lsp.client.Close()
+		ed.lsp.client = nil
+	}
+}
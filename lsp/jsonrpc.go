@@ -0,0 +1,267 @@
+// Package lsp is a minimal client of the Language Server Protocol,
+// talking JSON-RPC 2.0 to a language server like gopls over its
+// standard input and output.
+//
+// Only what the editor uses is implemented: keeping documents in sync
+// (see Document), going to definitions, hover information and the
+// diagnostics the server publishes.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout is how long Call waits for the answer of the server.
+var Timeout = 10 * time.Second
+
+// A message is a request, a response or a notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // none for notifications
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// A ResponseError is the error the server answered a request with.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// A Client is the connection to a language server.
+type Client struct {
+	w   io.Writer
+	wmu sync.Mutex // serializes writing messages
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan *message // by id, waiting for the response
+	err     error                    // why reading stopped, if it did
+
+	notify func(method string, params json.RawMessage)
+	done   chan struct{} // closed when reading stops
+	cmd    *exec.Cmd     // the server process if started by Start
+
+	// Encoding is the encoding the character offsets of Positions are
+	// counted in, as agreed on with the server by Initialize.
+	Encoding string
+}
+
+// NewClient returns a client reading the messages of the server from r
+// and writing its own to w.  notify is called with the notifications
+// the server sends, from the goroutine reading them.
+func NewClient(r io.Reader, w io.Writer, notify func(method string, params json.RawMessage)) *Client {
+	c := &Client{
+		w:        w,
+		pending:  make(map[string]chan *message),
+		notify:   notify,
+		done:     make(chan struct{}),
+		Encoding: UTF16,
+	}
+	go c.read(bufio.NewReader(r))
+	return c
+}
+
+// Start starts the server command and returns a client connected to
+// it, see NewClient.
+func Start(command []string, notify func(method string, params json.RawMessage)) (*Client, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := NewClient(stdout, stdin, notify)
+	c.cmd = cmd
+	return c, nil
+}
+
+// read reads the messages of the server until it fails, handing them
+// out to the calls waiting for them.
+func (c *Client) read(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			for _, ch := range c.pending {
+				close(ch)
+			}
+			c.pending = nil
+			c.mu.Unlock()
+			return
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			c.answer(msg)
+		case msg.Method != "":
+			if c.notify != nil {
+				c.notify(msg.Method, msg.Params)
+			}
+		default:
+			c.mu.Lock()
+			ch := c.pending[string(msg.ID)]
+			delete(c.pending, string(msg.ID))
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// answer answers a request of the server.  None is supported, so all
+// get an empty result, which the server takes as the defaults.
+func (c *Client) answer(req *message) {
+	result := json.RawMessage("null")
+	if req.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(req.Params, &params)
+		result, _ = json.Marshal(make([]interface{}, len(params.Items)))
+	}
+	c.write(&message{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+// readMessage reads a message with its header.
+func readMessage(r *bufio.Reader) (*message, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// write sends msg with its header.
+func (c *Client) write(msg *message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// Call sends the request method with params and decodes the result of
+// the response into result, unless that is nil.  Gives up after
+// Timeout.
+func (c *Client) Call(method string, params, result interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.pending == nil {
+		err := c.err
+		c.mu.Unlock()
+		return fmt.Errorf("language server gone: %v", err)
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	ch := make(chan *message, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+	if err := c.write(&message{JSONRPC: "2.0", ID: id, Method: method, Params: p}); err != nil {
+		return err
+	}
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return fmt.Errorf("language server gone: %v", c.err)
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-time.After(Timeout):
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+		return fmt.Errorf("%s: no answer from the language server", method)
+	}
+}
+
+// Notify sends the notification method with params.
+func (c *Client) Notify(method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{JSONRPC: "2.0", Method: method, Params: p})
+}
+
+// Close shuts the server down, waiting for the server process to end
+// if Start started it.
+func (c *Client) Close() error {
+	err := c.Call("shutdown", nil, nil)
+	c.Notify("exit", nil)
+	if closer, ok := c.w.(io.Closer); ok {
+		closer.Close()
+	}
+	if c.cmd != nil {
+		select {
+		case <-c.done:
+		case <-time.After(Timeout):
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// server is a fake language server answering the requests of a client.
+type server struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// start returns a client connected to a fake server.
+func start(t *testing.T, notify func(string, json.RawMessage)) (*Client, *server) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	t.Cleanup(func() { cr.Close(); sr.Close() })
+	return NewClient(cr, cw, notify), &server{bufio.NewReader(sr), sw}
+}
+
+func (s *server) receive(t *testing.T) *message {
+	msg, err := readMessage(s.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func (s *server) send(t *testing.T, msg *message) {
+	msg.JSONRPC = "2.0"
+	c := &Client{w: s.w}
+	if err := c.write(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	notified := make(chan string, 1)
+	c, s := start(t, func(method string, params json.RawMessage) {
+		notified <- method + " " + string(params)
+	})
+	done := make(chan error)
+	var result struct{ Answer int }
+	go func() { done <- c.Call("question", []int{6, 7}, &result) }()
+	req := s.receive(t)
+	if req.Method != "question" || string(req.Params) != "[6,7]" {
+		t.Fatalf("got request %s %s", req.Method, req.Params)
+	}
+	// the server asks something itself and notifies before answering
+	s.send(t, &message{ID: json.RawMessage(`"s1"`), Method: "workspace/configuration",
+		Params: json.RawMessage(`{"items":[{},{}]}`)})
+	if answer := s.receive(t); string(answer.ID) != `"s1"` || string(answer.Result) != "[null,null]" {
+		t.Errorf("server request answered with %s %s", answer.ID, answer.Result)
+	}
+	s.send(t, &message{Method: "note", Params: json.RawMessage(`{"x":1}`)})
+	if got := <-notified; got != `note {"x":1}` {
+		t.Errorf("notified %q", got)
+	}
+	s.send(t, &message{ID: req.ID, Result: json.RawMessage(`{"Answer":42}`)})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if result.Answer != 42 {
+		t.Errorf("got %d, want 42", result.Answer)
+	}
+
+	go func() { done <- c.Call("fail", nil, nil) }()
+	req = s.receive(t)
+	s.send(t, &message{ID: req.ID, Error: &ResponseError{Code: -32601, Message: "no such method"}})
+	if err := <-done; err == nil || err.Error() != "no such method" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	text := []byte("a\nx😀é=1\n")
+	off := len("a\nx😀é")
+	tests := []struct {
+		enc  string
+		want Position
+	}{
+		{UTF8, Position{1, 7}},
+		{UTF16, Position{1, 4}},
+	}
+	for _, test := range tests {
+		p := PositionOf(text, off, test.enc)
+		if p != test.want {
+			t.Errorf("%s: PositionOf = %v, want %v", test.enc, p, test.want)
+		}
+		if got := OffsetOf(text, p, test.enc); got != off {
+			t.Errorf("%s: OffsetOf(%v) = %d, want %d", test.enc, p, got, off)
+		}
+	}
+	if got := OffsetOf(text, Position{0, 10}, UTF16); got != 1 {
+		t.Errorf("past the end of the line: got %d, want 1", got)
+	}
+}
+
+func TestSync(t *testing.T) {
+	c, s := start(t, nil)
+	var b buf.Buf
+	b.Init()
+	b.SetName("test.go")
+	b.Write([]byte("package p\n\nvar x\n"))
+	go c.Open(&b, "go")
+	if msg := s.receive(t); msg.Method != "textDocument/didOpen" {
+		t.Fatalf("got %s", msg.Method)
+	}
+	b.Insert(len("package p\n\nvar x"), []byte(" int"))
+	b.Delete(len("package "), len("package p"))
+	b.Insert(len("package "), []byte("q"))
+	doc := &Document{URI: URI("test.go"), version: 1, text: []byte("package p\n\nvar x\n"), seq: 1}
+	go c.Sync(doc, &b)
+	msg := s.receive(t)
+	var params struct {
+		TextDocument   struct{ Version int }
+		ContentChanges []struct {
+			Range Range
+			Text  string
+		}
+	}
+	json.Unmarshal(msg.Params, &params)
+	type change struct {
+		start, end Position
+		text       string
+	}
+	want := []change{
+		{Position{2, 5}, Position{2, 5}, " int"},
+		{Position{0, 8}, Position{0, 9}, ""},
+		{Position{0, 8}, Position{0, 8}, "q"},
+	}
+	if params.TextDocument.Version != 2 || len(params.ContentChanges) != len(want) {
+		t.Fatalf("got %s", msg.Params)
+	}
+	for i, ch := range params.ContentChanges {
+		if got := (change{ch.Range.Start, ch.Range.End, ch.Text}); got != want[i] {
+			t.Errorf("change %d: got %v, want %v", i, got, want[i])
+		}
+	}
+	if string(doc.text) != b.String() {
+		t.Errorf("document is %q, buffer %q", doc.text, b.String())
+	}
+}
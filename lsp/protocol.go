@@ -0,0 +1,317 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// Encodings of the character offsets of positions.
+const (
+	UTF8  = "utf-8"
+	UTF16 = "utf-16" // the default of the protocol
+)
+
+// A Position is a line (starting at 0) and the offset of a character in
+// it, counted in the units of the client's Encoding.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// A Range is the text between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// A Location is a range in a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Severities of diagnostics.
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+// A Diagnostic is a problem the server found in a document.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// PublishDiagnostics are the parameters of the notification
+// textDocument/publishDiagnostics.
+type PublishDiagnostics struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// URI returns the URI of the file name.
+func URI(name string) string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		abs = name
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+// Filename returns the name of the file of uri.
+func Filename(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// PositionOf returns the position of the byte offset off in text.
+func PositionOf(text []byte, off int, enc string) Position {
+	if off > len(text) {
+		off = len(text)
+	}
+	start := 0
+	p := Position{}
+	for i := 0; i < off; i++ {
+		if text[i] == '\n' {
+			p.Line++
+			start = i + 1
+		}
+	}
+	p.Character = characters(text[start:off], enc)
+	return p
+}
+
+// characters returns the length of s counted in enc.
+func characters(s []byte, enc string) int {
+	if enc == UTF8 {
+		return len(s)
+	}
+	n := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRune(s)
+		n += len(utf16.Encode([]rune{r}))
+		s = s[size:]
+	}
+	return n
+}
+
+// OffsetOf returns the byte offset of p in text.  Positions past the end
+// of their line (or of the text) give its end.
+func OffsetOf(text []byte, p Position, enc string) int {
+	off := 0
+	for line := 0; line < p.Line; line++ {
+		i := bytes.IndexByte(text[off:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		off += i + 1
+	}
+	for n := 0; n < p.Character && off < len(text) && text[off] != '\n'; {
+		r, size := utf8.DecodeRune(text[off:])
+		if enc == UTF8 {
+			n += size
+		} else {
+			n += len(utf16.Encode([]rune{r}))
+		}
+		off += size
+	}
+	return off
+}
+
+// A Document is a text document opened in the server, kept in sync
+// with the buffer holding it.
+type Document struct {
+	URI     string
+	version int
+	text    []byte // as the server knows it
+	seq     int    // Seq of the buffer text is the contents of
+}
+
+// Open tells the server that the editor opened the document held by b,
+// written in language (e.g. "go").
+func (c *Client) Open(b *buf.Buf, language string) (*Document, error) {
+	d := &Document{
+		URI:     URI(b.Name()),
+		version: 1,
+		text:    b.Bytes(0, b.Len()),
+		seq:     b.Seq(),
+	}
+	type item struct {
+		URI        string `json:"uri"`
+		LanguageID string `json:"languageId"`
+		Version    int    `json:"version"`
+		Text       string `json:"text"`
+	}
+	err := c.Notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": item{d.URI, language, d.version, string(d.text)},
+	})
+	return d, err
+}
+
+// Sync sends the changes made to b since the last Sync (or Open) to the
+// server, taken from the change log of b.
+func (c *Client) Sync(d *Document, b *buf.Buf) error {
+	if b.Seq() == d.seq {
+		return nil
+	}
+	type change struct {
+		Range *Range `json:"range,omitempty"` // nil for the whole text
+		Text  string `json:"text"`
+	}
+	var changes []change
+	edits := b.Changes(d.seq)
+	if len(edits) != b.Seq()-d.seq {
+		// the log doesn't go back far enough, send the whole text
+		d.text = b.Bytes(0, b.Len())
+		changes = append(changes, change{Text: string(d.text)})
+		edits = nil
+	}
+	for _, e := range edits {
+		start := PositionOf(d.text, e.Off, c.Encoding)
+		if e.Kind == buf.Insertion {
+			changes = append(changes, change{&Range{start, start}, string(e.Text)})
+			text := make([]byte, 0, len(d.text)+len(e.Text))
+			d.text = append(append(append(text, d.text[:e.Off]...), e.Text...), d.text[e.Off:]...)
+		} else {
+			end := PositionOf(d.text, e.Off+len(e.Text), c.Encoding)
+			changes = append(changes, change{&Range{start, end}, ""})
+			d.text = append(d.text[:e.Off:e.Off], d.text[e.Off+len(e.Text):]...)
+		}
+	}
+	d.seq = b.Seq()
+	d.version++
+	return c.Notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": d.URI, "version": d.version},
+		"contentChanges": changes,
+	})
+}
+
+// Position returns the position of the byte offset off in the document
+// as last synced.
+func (c *Client) Position(d *Document, off int) Position {
+	return PositionOf(d.text, off, c.Encoding)
+}
+
+// Initialize initializes the server for the workspace in the directory
+// root, agreeing on the encoding of positions.
+func (c *Client) Initialize(root string) error {
+	params := map[string]interface{}{
+		"processId": nil,
+		"rootUri":   URI(root),
+		"capabilities": map[string]interface{}{
+			"general": map[string]interface{}{
+				"positionEncodings": []string{UTF8, UTF16},
+			},
+			"textDocument": map[string]interface{}{
+				"hover": map[string]interface{}{
+					"contentFormat": []string{"plaintext"},
+				},
+				"publishDiagnostics": map[string]interface{}{},
+			},
+		},
+	}
+	var result struct {
+		Capabilities struct {
+			PositionEncoding string `json:"positionEncoding"`
+		} `json:"capabilities"`
+	}
+	if err := c.Call("initialize", params, &result); err != nil {
+		return err
+	}
+	if result.Capabilities.PositionEncoding != "" {
+		c.Encoding = result.Capabilities.PositionEncoding
+	}
+	return c.Notify("initialized", struct{}{})
+}
+
+// positionParams are the parameters of the requests about a position
+// in a document.
+func positionParams(d *Document, p Position) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": d.URI},
+		"position":     p,
+	}
+}
+
+// Definition returns the locations of the definition of the symbol at p.
+func (c *Client) Definition(d *Document, p Position) ([]Location, error) {
+	var result json.RawMessage
+	if err := c.Call("textDocument/definition", positionParams(d, p), &result); err != nil {
+		return nil, err
+	}
+	// a Location or a list of Locations or LocationLinks
+	type location struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	var list []location
+	if json.Unmarshal(result, &list) != nil {
+		var loc location
+		if json.Unmarshal(result, &loc) != nil {
+			return nil, nil
+		}
+		list = []location{loc}
+	}
+	var locs []Location
+	for _, l := range list {
+		if l.TargetURI != "" {
+			l.Location = Location{l.TargetURI, l.TargetSelectionRange}
+		}
+		if l.URI != "" {
+			locs = append(locs, l.Location)
+		}
+	}
+	return locs, nil
+}
+
+// Hover returns the information about the symbol at p, empty if there
+// is none.
+func (c *Client) Hover(d *Document, p Position) (string, error) {
+	var result struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.Call("textDocument/hover", positionParams(d, p), &result); err != nil {
+		return "", err
+	}
+	return markup(result.Contents), nil
+}
+
+// markup returns the text of hover contents: MarkupContent, a
+// MarkedString or a list of MarkedStrings.
+func markup(contents json.RawMessage) string {
+	var s string
+	if json.Unmarshal(contents, &s) == nil {
+		return s
+	}
+	var content struct {
+		Value string `json:"value"`
+	}
+	if json.Unmarshal(contents, &content) == nil && content.Value != "" {
+		return content.Value
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(contents, &list) == nil {
+		var parts []string
+		for _, item := range list {
+			if text := markup(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bgrundmann/e/ex"
+)
+
+// defaultMakeprg is the build command run by :make unless set otherwise
+// with :set makeprg.
+const defaultMakeprg = "go build"
+
+// errorLine matches the lines of compiler output referring to a
+// position, file:line:col: message or file:line: message.
+var errorLine = regexp.MustCompile(`^([^:\s][^:]*):(\d+):(?:(\d+):)?\s*(.*)$`)
+
+// parseErrors returns the positions referred to in output.  Other lines
+// are ignored.
+func parseErrors(output string) []qfItem {
+	var items []qfItem
+	for _, line := range strings.Split(output, "\n") {
+		m := errorLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		it := qfItem{file: filepath.Clean(m[1]), col: 1, text: m[4]}
+		it.line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			it.col, _ = strconv.Atoi(m[3])
+		}
+		items = append(items, it)
+	}
+	return items
+}
+
+// :mak[e] [arguments] runs the build command (see defaultMakeprg) with
+// the arguments.  The errors it reports make up the quickfix list.
+func (ed *editor) cmdMake(cmd ex.Command) error {
+	command := ed.options.String("makeprg", nil, nil)
+	if cmd.Arg != "" {
+		command += " " + cmd.Arg
+	}
+	out, err := shellCommand(command).CombinedOutput()
+	items := parseErrors(string(out))
+	if err := ed.setQuickfix(items); err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		return nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("%s: %v", command, err)
+	}
+	ed.setMessage(fmt.Sprintf("%s: no errors", command))
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/motion"
+	"github.com/nsf/termbox-go"
+)
+
+// markActions are the actions taking the name of a mark as the next key.
+var markActions = map[string]bool{
+	"set-mark":       true,
+	"goto-mark":      true,
+	"goto-mark-line": true,
+}
+
+func validMark(name rune) bool {
+	return 'a' <= name && name <= 'z'
+}
+
+// startMark makes the next key the name of the mark for action, if
+// action is one of markActions.
+func (ed *editor) startMark(action string) bool {
+	if !markActions[action] {
+		return false
+	}
+	ed.markAction = action
+	return true
+}
+
+// markKey handles the key following m, ` or '.  Sets the mark or returns
+// the motion going to it.  Returns false if there is nothing to move.
+func (ed *editor) markKey(ev termbox.Event) (motion.Motion, bool) {
+	action := ed.markAction
+	ed.markAction = ""
+	ed.count = 0
+	name := ev.Ch
+	if !validMark(name) {
+		return nil, false
+	}
+	v := ed.focus
+	b := v.Buffer()
+	if action == "set-mark" {
+		ed.setMark(b, name, v.Cursor())
+		return nil, false
+	}
+	mark := ed.marks[b][name]
+	if mark == nil {
+		ed.setError(fmt.Errorf("Mark not set: %c", name))
+		return nil, false
+	}
+	if action == "goto-mark-line" {
+		return motion.WithKind(motion.New(func(b *buf.Buf, rd *buf.Reader) bool {
+			rd.Seek(int64(mark.Offset()), 0)
+			return motion.FirstNonBlank.Move(b, rd)
+		}), motion.Linewise), true
+	}
+	return motion.New(func(b *buf.Buf, rd *buf.Reader) bool {
+		rd.Seek(int64(mark.Offset()), 0)
+		return true
+	}), true
+}
+
+// setMark sets the mark name of b to off.
+func (ed *editor) setMark(b *buf.Buf, name rune, off int) {
+	if m := ed.marks[b][name]; m != nil {
+		m.Move(off)
+		return
+	}
+	if ed.marks[b] == nil {
+		ed.marks[b] = make(map[rune]buf.Marker)
+	}
+	ed.marks[b][name] = b.NewMarker(off)
+}
+
+// setVisualMarks sets the marks < and > to the start and the end of the
+// selection, as used in the range '<,'> of ex commands.
+func (ed *editor) setVisualMarks() {
+	v := ed.focus
+	r, ok := v.Selection()
+	if !ok {
+		return
+	}
+	end := r.Off2
+	if end > r.Off1 {
+		// the end of the range is just after the selection
+		end--
+	}
+	ed.setMark(v.Buffer(), '<', r.Off1)
+	ed.setMark(v.Buffer(), '>', end)
+}
+
+// :marks lists the marks of the current buffer with their line and
+// column.
+func (ed *editor) cmdMarks(cmd ex.Command) error {
+	b := ed.focus.Buffer()
+	var names []rune
+	for name := range ed.marks[b] {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return errors.New("No marks set")
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	var list []string
+	for _, name := range names {
+		pos, err := b.PositionFromOffset(ed.marks[b][name].Offset())
+		if err != nil {
+			continue
+		}
+		list = append(list, fmt.Sprintf("%c %d:%d", name, pos.Line, pos.Column))
+	}
+	ed.setMessage(strings.Join(list, "  "))
+	return nil
+}
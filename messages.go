@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/view"
+)
+
+// messagesKind is the kind of the buffer holding the messages shown so
+// far, see :messages.
+var messagesKind = &bufKind{mode: "messages"}
+
+// maxMessages is the number of lines of messages kept.
+const maxMessages = 1000
+
+// logMessage appends msg to the messages buffer, dropping the oldest
+// lines beyond maxMessages.
+func (ed *editor) logMessage(msg string) {
+	b := ed.messagesBuf
+	if msg == "" || b == nil {
+		return
+	}
+	b.Insert(b.Len(), []byte(msg+"\n"))
+	if n := b.Lines() - 1; n > maxMessages {
+		b.Delete(0, b.Line(n-maxMessages+1))
+	}
+	b.MarkSaved()
+}
+
+// :mes[sages] shows the messages so far in a window split off the
+// current one, to be scrolled and yanked like any other text.
+func (ed *editor) cmdMessages(cmd ex.Command) error {
+	b := ed.messagesBuf
+	if ed.bufEntry(b) == nil {
+		ed.addBuffer(b)
+		ed.bufEntry(b).kind = messagesKind
+	}
+	ed.split(view.Horizontal)
+	ed.showBuffer(ed.focus, b)
+	// start at the latest message
+	ed.focus.SetCursor(b.Line(b.Lines() - 1))
+	ed.showCursor()
+	return nil
+}
+
+// newMessagesBuffer returns the empty messages buffer.
+func newMessagesBuffer() *buf.Buf {
+	b := new(buf.Buf).Init()
+	b.SetName("[Messages]")
+	return b
+}
+
+// isScratch returns whether b is a scratch buffer, which isn't backed by
+// a file and so is never asked to be saved.
+func (ed *editor) isScratch(b *buf.Buf) bool {
+	e := ed.bufEntry(b)
+	return e != nil && e.scratch
+}
+
+// unsaved returns whether b has changes that would be lost without
+// saving them.
+func (ed *editor) unsaved(b *buf.Buf) bool {
+	return b.Dirty() && !ed.isScratch(b)
+}
+
+// :scr[atch] shows a new scratch buffer in the current window, or in a
+// new window split off it with !.
+func (ed *editor) cmdScratch(cmd ex.Command) error {
+	b := new(buf.Buf).Init()
+	ed.addBuffer(b)
+	ed.bufEntry(b).scratch = true
+	if cmd.Bang {
+		ed.split(view.Horizontal)
+		ed.showBuffer(ed.focus, b)
+		return nil
+	}
+	return ed.switchBuffer(b, false)
+}
+
+// bufferLabel returns the name of b shown to the user, marking scratch
+// buffers.
+func (ed *editor) bufferLabel(b *buf.Buf) string {
+	if ed.isScratch(b) {
+		return "[Scratch]"
+	}
+	return bufferName(b)
+}
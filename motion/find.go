@@ -0,0 +1,68 @@
+package motion
+
+import (
+	"github.com/bgrundmann/e/buf"
+)
+
+// find returns a motion searching for needle in the current line, not
+// counting the rune under the cursor.  If till is set it stops one rune
+// short of needle, failing if that is where the cursor already is.
+func find(needle rune, backward, till bool) Motion {
+	return New(func(buf *buf.Buf, rd *buf.Reader) bool {
+		start := rd.Offset()
+		if backward {
+			rd.Reverse()
+		} else if _, _, err := rd.ReadRune(); err != nil {
+			// step over the rune under the cursor
+			return false
+		}
+		prev := start // offset of the rune read before the current one
+		for {
+			before := rd.Offset()
+			r, _, err := rd.ReadRune()
+			if err != nil || r == '\n' {
+				return false
+			}
+			// the rune read starts at before going forward and at
+			// rd.Offset() going backward
+			at := before
+			if backward {
+				at, prev = rd.Offset(), before
+			}
+			if r == needle {
+				target := at
+				if till {
+					target = prev
+				}
+				if target == start {
+					return false
+				}
+				rd.Seek(int64(target), 0)
+				return true
+			}
+			if !backward {
+				prev = at
+			}
+		}
+	})
+}
+
+// FindForward moves to the next needle in the line (f).
+func FindForward(needle rune) Motion {
+	return WithKind(find(needle, false, false), Inclusive)
+}
+
+// FindBackward moves to the previous needle in the line (F).
+func FindBackward(needle rune) Motion {
+	return find(needle, true, false)
+}
+
+// TillForward moves to the rune in front of the next needle in the line (t).
+func TillForward(needle rune) Motion {
+	return WithKind(find(needle, false, true), Inclusive)
+}
+
+// TillBackward moves to the rune behind the previous needle in the line (T).
+func TillBackward(needle rune) Motion {
+	return find(needle, true, true)
+}
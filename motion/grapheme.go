@@ -0,0 +1,65 @@
+package motion
+
+import (
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/grapheme"
+)
+
+// GraphemeForward moves to the start of the next grapheme cluster, so
+// that a letter with combining marks or an emoji sequence is passed as a
+// whole.
+var GraphemeForward = New(func(b *buf.Buf, rd *buf.Reader) bool {
+	var seg grapheme.Segmenter
+	r, _, err := rd.ReadRune()
+	if err != nil {
+		return false
+	}
+	seg.Boundary(r)
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil {
+			return true
+		}
+		if seg.Boundary(r) {
+			rd.UnreadRune()
+			return true
+		}
+	}
+})
+
+// GraphemeBackward moves to the start of the grapheme cluster in front
+// of the cursor, or of the one the cursor is in.
+var GraphemeBackward = New(func(b *buf.Buf, rd *buf.Reader) bool {
+	off := rd.Offset()
+	// Clusters can only be found reading forwards, from a boundary:
+	// go back to one which is there whatever precedes it.
+	rd.Reverse()
+	start := off
+	later := rune(-1)
+	for {
+		r, size, err := rd.ReadRune()
+		if err != nil || later >= 0 && !grapheme.Joins(r, later) {
+			break
+		}
+		start -= size
+		later = r
+	}
+	if start == off {
+		return false
+	}
+	last := start
+	var seg grapheme.Segmenter
+	fwd := b.NewReader(start)
+	for fwd.Offset() < off {
+		o := fwd.Offset()
+		r, _, err := fwd.ReadRune()
+		if err != nil {
+			break
+		}
+		if seg.Boundary(r) {
+			last = o
+		}
+	}
+	_, err := rd.Seek(int64(last), 0)
+	return err == nil
+})
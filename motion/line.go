@@ -0,0 +1,54 @@
+package motion
+
+import (
+	"github.com/bgrundmann/e/buf"
+)
+
+// lineBounds returns the offset of the start of the line containing off
+// and of its end (the newline or the end of the buffer).
+func lineBounds(b *buf.Buf, off int) (start, end int) {
+	return b.LineRange(b.LineOfOffset(off))
+}
+
+// LineStart moves to the first rune of the line (0).
+var LineStart = New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	start, _ := lineBounds(buf, rd.Offset())
+	_, err := rd.Seek(int64(start), 0)
+	return err == nil
+})
+
+// LineEnd moves to the last rune of the line ($).  On an empty line
+// it stays where it is.
+var LineEnd = WithGoal(WithKind(New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	start, end := lineBounds(buf, rd.Offset())
+	off := start
+	if end > start {
+		// step back over the last rune, with a reader of its own as
+		// rd must keep reading forward
+		last := buf.NewReader(end)
+		last.Reverse()
+		last.ReadRune()
+		off = last.Offset()
+	}
+	_, err := rd.Seek(int64(off), 0)
+	return err == nil
+}), Inclusive), EndGoal)
+
+// FirstNonBlank moves to the first rune of the line that is neither
+// a space nor a tab (^).  On a blank line that is the end of the line.
+var FirstNonBlank = New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	start, _ := lineBounds(buf, rd.Offset())
+	if _, err := rd.Seek(int64(start), 0); err != nil {
+		return false
+	}
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil {
+			return true
+		}
+		if r != ' ' && r != '\t' {
+			rd.UnreadRune()
+			return true
+		}
+	}
+})
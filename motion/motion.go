@@ -23,6 +23,130 @@ func New(move func(*buf.Buf, *buf.Reader) bool) Motion {
 	return motion(move)
 }
 
+// Kind determines which text an operator applied to a motion affects.
+type Kind int
+
+const (
+	// Exclusive motions cover the text from the start up to but
+	// excluding the end (e.g. w).
+	Exclusive Kind = iota
+	// Inclusive motions also cover the character at the end (e.g. e).
+	Inclusive
+	// Linewise motions cover all lines touched (e.g. j).
+	Linewise
+)
+
+type kinded struct {
+	Motion
+	kind Kind
+}
+
+func (k kinded) Kind() Kind {
+	return k.kind
+}
+
+func (k kinded) Goal() Goal {
+	return GoalOf(k.Motion)
+}
+
+// WithKind returns m but with kind k.  Motions are Exclusive by default.
+func WithKind(m Motion, k Kind) Motion {
+	return kinded{m, k}
+}
+
+// KindOf returns the kind of m.
+func KindOf(m Motion) Kind {
+	if k, ok := m.(interface{ Kind() Kind }); ok {
+		return k.Kind()
+	}
+	return Exclusive
+}
+
+// Goal determines what a motion does to the goal column, the column the
+// cursor keeps when moving up and down through shorter lines.
+type Goal int
+
+const (
+	// SetGoal motions make the column they end in the goal (e.g. w).
+	SetGoal Goal = iota
+	// KeepGoal motions end in the goal column of the line they move
+	// to, or as near to it as the line allows (e.g. j).
+	KeepGoal
+	// EndGoal motions make the end of the line the goal (e.g. $).
+	EndGoal
+)
+
+type goaled struct {
+	Motion
+	goal Goal
+}
+
+func (g goaled) Goal() Goal {
+	return g.goal
+}
+
+func (g goaled) Kind() Kind {
+	return KindOf(g.Motion)
+}
+
+// WithGoal returns m but treating the goal column as g.  Motions
+// SetGoal by default.
+func WithGoal(m Motion, g Goal) Motion {
+	return goaled{m, g}
+}
+
+// GoalOf returns what m does to the goal column.
+func GoalOf(m Motion) Goal {
+	if g, ok := m.(interface{ Goal() Goal }); ok {
+		return g.Goal()
+	}
+	return SetGoal
+}
+
+// A Range is the text between Off1 (inclusive) and Off2 (exclusive)
+// covered by a motion.
+type Range struct {
+	Off1, Off2 int
+	Linewise   bool // Range consists of whole lines
+}
+
+// Covered returns the range covered by moving from off with m,
+// taking the kind of m into account.  Returns false if the motion failed.
+func Covered(b *buf.Buf, off int, m Motion) (Range, bool) {
+	rd := b.NewReader(off)
+	if !m.Move(b, rd) {
+		return Range{}, false
+	}
+	r := Range{Off1: off, Off2: rd.Offset()}
+	if r.Off2 < r.Off1 {
+		r.Off1, r.Off2 = r.Off2, r.Off1
+	}
+	switch KindOf(m) {
+	case Exclusive:
+		// like vim: an exclusive motion ending at the start of a line
+		// doesn't cover the preceding line break
+		if r.Off2 > r.Off1 && r.Off2 < b.Len() && b.Line(b.LineOfOffset(r.Off2)) == r.Off2 &&
+			b.LineOfOffset(r.Off1) != b.LineOfOffset(r.Off2) {
+			r.Off2--
+		}
+	case Inclusive:
+		// the whole grapheme cluster at the end
+		end := b.NewReader(r.Off2)
+		GraphemeForward.Move(b, end)
+		r.Off2 = end.Offset()
+	case Linewise:
+		r.Linewise = true
+		r.Off1 = b.Line(b.LineOfOffset(r.Off1))
+		last := b.LineOfOffset(r.Off2)
+		if last < b.Lines() {
+			r.Off2 = b.Line(last + 1)
+		} else {
+			r.Off2 = b.Len()
+		}
+	}
+	return r, true
+}
+
 // Reverse the given motion.
 // Works by the reversing the read direction of the passed
 // in reader before passing it to the original motion.  
@@ -42,22 +166,6 @@ var RuneForward = New(func (buf *buf.Buf, rd *buf.Reader) bool {
 // RuneBackward moves one rune backwards
 var RuneBackward = reverse(RuneForward)
 
-// Move till the next occurence of the given rune forward.
-// Returns false if there is no such character before EOF 
-func RuneFindForward(needle rune) Motion {
-	return New(func (buf *buf.Buf, rd *buf.Reader) bool {
-		for {
-			r, _, err := rd.ReadRune()
-			if err != nil {
-				return false
-			} 
-			if needle == r {
-				return true
-			} 
-		} 
-	})
-} 
-
 //// Move several motions one after the other.  
 //func Sequence(motions ...Motion) Motion {
 //	return New(func (buf *buf.Buf, rd *buf.Reader) bool {
@@ -70,7 +178,8 @@ func RuneFindForward(needle rune) Motion {
 //	} 
 //} 
 
-var LineForward = New(func (buf *buf.Buf, rd *buf.Reader) bool {
+// LineForward moves to the same column in the next line.
+var LineForward = WithGoal(WithKind(New(func (buf *buf.Buf, rd *buf.Reader) bool {
 	pos, err := buf.PositionFromOffset(rd.Offset())
 	if err != nil {
 		return false
@@ -88,9 +197,10 @@ var LineForward = New(func (buf *buf.Buf, rd *buf.Reader) bool {
 	}
 	_, err = rd.Seek(int64(off), 0)
 	return err == nil
-})
+}), Linewise), KeepGoal)
 
-var LineBackward = New(func (buf *buf.Buf, rd *buf.Reader) bool {
+// LineBackward moves to the same column in the previous line.
+var LineBackward = WithGoal(WithKind(New(func (buf *buf.Buf, rd *buf.Reader) bool {
 	pos, err := buf.PositionFromOffset(rd.Offset())
 	if err != nil {
 		return false
@@ -111,4 +221,39 @@ var LineBackward = New(func (buf *buf.Buf, rd *buf.Reader) bool {
 	}
 	_, err = rd.Seek(int64(off), 0)
 	return err == nil
-})
+}), Linewise), KeepGoal)
+
+// GotoLine moves to the start of line n.  Line numbers past the last
+// line go to the last line, numbers below 1 to the first.
+func GotoLine(n int) Motion {
+	return WithKind(New(func(buf *buf.Buf, rd *buf.Reader) bool {
+		line := n
+		if line > buf.Lines() {
+			line = buf.Lines()
+		}
+		_, err := rd.Seek(int64(buf.Line(line)), 0)
+		return err == nil
+	}), Linewise)
+}
+
+// BufferStart moves to the start of the first line.
+var BufferStart = GotoLine(1)
+
+// BufferEnd moves to the start of the last line.
+var BufferEnd = WithKind(New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	_, err := rd.Seek(int64(buf.Line(buf.Lines())), 0)
+	return err == nil
+}), Linewise)
+
+// Repeat returns a motion that moves with m n times.  It stops early
+// if m fails, and fails itself only if the first move fails.
+func Repeat(m Motion, n int) Motion {
+	return WithGoal(WithKind(New(func(buf *buf.Buf, rd *buf.Reader) bool {
+		for i := 0; i < n; i++ {
+			if !m.Move(buf, rd) {
+				return i > 0
+			}
+		}
+		return true
+	}), KindOf(m)), GoalOf(m))
+}
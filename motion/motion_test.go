@@ -0,0 +1,255 @@
+package motion
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// run applies m starting at off and returns the resulting offset.
+func run(t *testing.T, s string, off int, m Motion) int {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte(s))
+	rd := b.NewReader(off)
+	if !m.Move(&b, rd) {
+		t.Errorf("%q: motion from %v failed", s, off)
+	}
+	return rd.Offset()
+}
+
+func TestWordMotions(t *testing.T) {
+	const s = "foo.bar  bäz qux"
+	tests := []struct {
+		name     string
+		m        Motion
+		off, exp int
+	}{
+		{"w", WordForward, 0, 3},
+		{"w", WordForward, 3, 4},
+		{"w", WordForward, 4, 9},
+		{"w", WordForward, 9, 14},
+		{"b", WordBackward, 14, 9},
+		{"b", WordBackward, 12, 9},
+		{"b", WordBackward, 9, 4},
+		{"b", WordBackward, 4, 3},
+		{"e", EndOfWord, 0, 2},
+		{"e", EndOfWord, 2, 3},
+		{"e", EndOfWord, 4, 6},
+		{"e", EndOfWord, 6, 12},
+	}
+	for _, test := range tests {
+		if got := run(t, s, test.off, test.m); got != test.exp {
+			t.Errorf("%s from %v: expected %v got %v", test.name, test.off, test.exp, got)
+		}
+	}
+}
+
+func TestCovered(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte("foo bar\nbaz\nqux"))
+	tests := []struct {
+		name string
+		m    Motion
+		off  int
+		exp  Range
+	}{
+		{"w", WordForward, 0, Range{0, 4, false}},
+		// exclusive motion ending at start of line keeps the newline
+		{"w", WordForward, 4, Range{4, 7, false}},
+		{"e", EndOfWord, 0, Range{0, 3, false}},
+		{"b", WordBackward, 5, Range{4, 5, false}},
+		{"j", LineForward, 5, Range{0, 12, true}},
+		{"k", LineBackward, 13, Range{8, 15, true}},
+	}
+	for _, test := range tests {
+		r, ok := Covered(&b, test.off, test.m)
+		if !ok || r != test.exp {
+			t.Errorf("%s from %v: expected %v got %v", test.name, test.off, test.exp, r)
+		}
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	if got := run(t, "a b c d", 0, Repeat(WordForward, 2)); got != 4 {
+		t.Errorf("2w: expected 4 got %v", got)
+	}
+	// stops at the end of the buffer
+	if got := run(t, "a b", 0, Repeat(WordForward, 5)); got != 3 {
+		t.Errorf("5w: expected 3 got %v", got)
+	}
+	if KindOf(Repeat(LineForward, 3)) != Linewise {
+		t.Errorf("Repeat must keep the kind of the motion")
+	}
+	if GoalOf(Repeat(LineForward, 3)) != KeepGoal {
+		t.Errorf("Repeat must keep the goal of the motion")
+	}
+}
+
+func TestGoal(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Motion
+		goal Goal
+		kind Kind
+	}{
+		{"w", WordForward, SetGoal, Exclusive},
+		{"j", LineForward, KeepGoal, Linewise},
+		{"k", LineBackward, KeepGoal, Linewise},
+		{"$", LineEnd, EndGoal, Inclusive},
+		{"kind over goal", WithKind(LineForward, Exclusive), KeepGoal, Exclusive},
+	}
+	for _, test := range tests {
+		if g, k := GoalOf(test.m), KindOf(test.m); g != test.goal || k != test.kind {
+			t.Errorf("%s: got goal %v kind %v want %v %v", test.name, g, k, test.goal, test.kind)
+		}
+	}
+}
+
+func TestGotoLine(t *testing.T) {
+	const s = "foo\nbar\nbaz"
+	tests := []struct {
+		name     string
+		m        Motion
+		off, exp int
+	}{
+		{"2G", GotoLine(2), 0, 4},
+		{"9G", GotoLine(9), 0, 8},
+		{"0G", GotoLine(0), 5, 0},
+		{"gg", BufferStart, 9, 0},
+		{"G", BufferEnd, 1, 8},
+	}
+	for _, test := range tests {
+		if got := run(t, s, test.off, test.m); got != test.exp {
+			t.Errorf("%s from %v: expected %v got %v", test.name, test.off, test.exp, got)
+		}
+		if KindOf(test.m) != Linewise {
+			t.Errorf("%s is not linewise", test.name)
+		}
+	}
+}
+
+func TestLineMotions(t *testing.T) {
+	const s = "  foo\n\n\tbä"
+	tests := []struct {
+		name     string
+		m        Motion
+		off, exp int
+	}{
+		{"0", LineStart, 4, 0},
+		{"0", LineStart, 6, 6},
+		{"0", LineStart, 9, 7},
+		{"$", LineEnd, 0, 4},
+		{"$", LineEnd, 6, 6},
+		// last line without newline, ending in a multibyte rune
+		{"$", LineEnd, 7, 9},
+		{"^", FirstNonBlank, 4, 2},
+		{"^", FirstNonBlank, 6, 6},
+		{"^", FirstNonBlank, 9, 8},
+	}
+	for _, test := range tests {
+		if got := run(t, s, test.off, test.m); got != test.exp {
+			t.Errorf("%s from %v: expected %v got %v", test.name, test.off, test.exp, got)
+		}
+	}
+}
+
+func TestFindMotions(t *testing.T) {
+	const s = "a.b.cä.d\n.x"
+	tests := []struct {
+		name     string
+		m        Motion
+		off, exp int
+	}{
+		{"f.", FindForward('.'), 0, 1},
+		{"f.", FindForward('.'), 1, 3},
+		{"fä", FindForward('ä'), 0, 5},
+		{"t.", TillForward('.'), 1, 2},
+		{"t.", TillForward('.'), 4, 5},
+		{"F.", FindBackward('.'), 4, 3},
+		{"F.", FindBackward('.'), 8, 7},
+		{"T.", TillBackward('.'), 5, 4},
+		{"T.", TillBackward('.'), 9, 8},
+	}
+	for _, test := range tests {
+		if got := run(t, s, test.off, test.m); got != test.exp {
+			t.Errorf("%s from %v: expected %v got %v", test.name, test.off, test.exp, got)
+		}
+	}
+	// searches don't leave the line, till doesn't move onto the cursor
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte(s))
+	for _, m := range []Motion{FindForward('x'), FindBackward('x'), TillForward('b'), TillBackward('a')} {
+		if m.Move(&b, b.NewReader(1)) {
+			t.Errorf("motion from 1 should fail")
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte("foo bar foo baz"))
+	re := regexp.MustCompile("fo+")
+	tests := []struct {
+		name       string
+		m          Motion
+		off        int
+		start, end int
+	}{
+		{"/fo+", Search(re, false), 0, 8, 11},
+		// wraps around
+		{"/fo+", Search(re, false), 9, 0, 3},
+		{"?fo+", Search(re, true), 9, 8, 11},
+		{"?fo+", Search(re, true), 8, 0, 3},
+		{"?fo+", Search(re, true), 0, 8, 11},
+		// not a range motion
+		{"w", WordForward, 0, 4, 4},
+	}
+	for _, test := range tests {
+		start, end, ok := Extent(&b, test.off, test.m)
+		if !ok || start != test.start || end != test.end {
+			t.Errorf("%s from %v: expected %v-%v got %v-%v (%v)", test.name, test.off, test.start, test.end, start, end, ok)
+		}
+	}
+	if _, _, ok := Extent(&b, 0, Search(regexp.MustCompile("qux"), false)); ok {
+		t.Errorf("search for missing text succeeded")
+	}
+}
+
+func TestGraphemeMotions(t *testing.T) {
+	// e with a combining accent, a flag, a family joined by zero
+	// width joiners
+	const s = "é🇩🇪👨‍👩‍👧x"
+	tests := []struct {
+		name     string
+		m        Motion
+		off, exp int
+	}{
+		{"l", GraphemeForward, 0, 3},
+		{"l", GraphemeForward, 3, 11},
+		{"l", GraphemeForward, 11, 29},
+		{"l", GraphemeForward, 29, 30},
+		{"h", GraphemeBackward, 30, 29},
+		{"h", GraphemeBackward, 29, 11},
+		{"h", GraphemeBackward, 11, 3},
+		{"h", GraphemeBackward, 3, 0},
+		// from within a cluster to its start
+		{"h", GraphemeBackward, 1, 0},
+		{"h", GraphemeBackward, 22, 11},
+	}
+	for _, test := range tests {
+		if got := run(t, s, test.off, test.m); got != test.exp {
+			t.Errorf("%s from %v: expected %v got %v", test.name, test.off, test.exp, got)
+		}
+	}
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte(s))
+	if GraphemeBackward.Move(&b, b.NewReader(0)) || GraphemeForward.Move(&b, b.NewReader(b.Len())) {
+		t.Error("moved beyond the ends of the buffer")
+	}
+}
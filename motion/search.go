@@ -0,0 +1,70 @@
+package motion
+
+import (
+	"regexp"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// A RangeMotion is a Motion moving to the start of some text it found,
+// e.g. the match of a search.  MoveRange is like Move but also returns
+// the offset of the end of that text.
+type RangeMotion interface {
+	Motion
+	MoveRange(buf *buf.Buf, reader *buf.Reader) (end int, ok bool)
+}
+
+// Extent moves from off with m and returns where it ended up.  For
+// RangeMotions end is the end of the text found, for other motions the
+// same as start.
+func Extent(b *buf.Buf, off int, m Motion) (start, end int, ok bool) {
+	rd := b.NewReader(off)
+	if rm, isRange := m.(RangeMotion); isRange {
+		end, ok = rm.MoveRange(b, rd)
+		return rd.Offset(), end, ok
+	}
+	ok = m.Move(b, rd)
+	return rd.Offset(), rd.Offset(), ok
+}
+
+type search struct {
+	re       *regexp.Regexp
+	backward bool
+}
+
+// Search returns a motion to the next match of re after the cursor,
+// or the previous one before it if backward is set.  Like vim the
+// search wraps around at the end (start) of the buffer.
+func Search(re *regexp.Regexp, backward bool) RangeMotion {
+	return search{re, backward}
+}
+
+func (s search) Move(buf *buf.Buf, rd *buf.Reader) bool {
+	_, ok := s.MoveRange(buf, rd)
+	return ok
+}
+
+func (s search) MoveRange(b *buf.Buf, rd *buf.Reader) (int, bool) {
+	off := rd.Offset()
+	var start, end int
+	var ok bool
+	if s.backward {
+		start, end, ok = b.SearchBackward(s.re, off)
+		if !ok {
+			start, end, ok = b.SearchBackward(s.re, b.Len()+1)
+		}
+	} else {
+		// matches at the cursor don't count
+		next := b.NewReader(off)
+		next.ReadRune()
+		start, end, ok = b.SearchForward(s.re, next.Offset())
+		if !ok {
+			start, end, ok = b.SearchForward(s.re, 0)
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+	rd.Seek(int64(start), 0)
+	return end, true
+}
@@ -0,0 +1,90 @@
+package motion
+
+import (
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// Character classes used by the word motions.  A word is a run of
+// runes of the same class, with blanks separating words.
+const (
+	classBlank = iota
+	classPunct
+	classWord
+)
+
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return classBlank
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return classWord
+	default:
+		return classPunct
+	}
+}
+
+func isClass(class int) func(rune) bool {
+	return func(r rune) bool {
+		return runeClass(r) == class
+	}
+}
+
+// skip reads runes as long as pred holds and leaves the reader in front
+// of the first rune for which it doesn't.  Returns false if it hit
+// the end (or start when reading in reverse) of the buffer.
+func skip(rd *buf.Reader, pred func(rune) bool) bool {
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil {
+			return false
+		}
+		if !pred(r) {
+			rd.UnreadRune()
+			return true
+		}
+	}
+}
+
+// WordForward moves to the start of the next word (vim's w).
+var WordForward = New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	r, _, err := rd.ReadRune()
+	if err != nil {
+		return false
+	}
+	if c := runeClass(r); c != classBlank {
+		skip(rd, isClass(c))
+	}
+	skip(rd, isClass(classBlank))
+	return true
+})
+
+// WordBackward moves to the start of the current or previous word (vim's b).
+var WordBackward = reverse(New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	skip(rd, isClass(classBlank))
+	r, _, err := rd.ReadRune()
+	if err != nil {
+		return false
+	}
+	skip(rd, isClass(runeClass(r)))
+	return true
+}))
+
+// EndOfWord moves to the last rune of the current or next word (vim's e).
+var EndOfWord = WithKind(New(func(buf *buf.Buf, rd *buf.Reader) bool {
+	if _, _, err := rd.ReadRune(); err != nil {
+		return false
+	}
+	skip(rd, isClass(classBlank))
+	r, _, err := rd.ReadRune()
+	if err != nil {
+		return false
+	}
+	skip(rd, isClass(runeClass(r)))
+	// we are now just past the word, step back onto its last rune
+	rd.Reverse()
+	rd.ReadRune()
+	rd.Reverse()
+	return true
+}), Inclusive)
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/wordindex"
+)
+
+// numberRe matches the numbers <C-a> and <C-x> change: hexadecimal ones
+// with a 0x prefix and decimal ones with an optional minus sign.
+var numberRe = regexp.MustCompile(`0[xX][0-9a-fA-F]+|-?[0-9]+`)
+
+// numberAt returns the offsets of the number under or after off on its
+// line.  A minus sign following a word character, as in x-1, is taken
+// to be an operator rather than the number's sign.
+func numberAt(b *buf.Buf, off int) (off1, off2 int, ok bool) {
+	start, end := b.LineRange(b.LineOfOffset(off))
+	line := b.Bytes(start, end)
+	for _, m := range numberRe.FindAllIndex(line, -1) {
+		if start+m[1] <= off {
+			continue
+		}
+		if line[m[0]] == '-' && m[0] > 0 {
+			if r, _ := utf8.DecodeLastRune(line[:m[0]]); wordindex.IsWordRune(r) {
+				m[0]++
+			}
+		}
+		return start + m[0], start + m[1], true
+	}
+	return 0, 0, false
+}
+
+var errNumberOverflow = errors.New("number out of range")
+
+// addToNumber returns the number s plus delta, written like s: a
+// hexadecimal number keeps its prefix, the case of its digits and the
+// number of them, wrapping around like an unsigned number, a decimal
+// number with leading zeros keeps the number of digits.
+func addToNumber(s string, delta int64) (string, error) {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		digits := s[2:]
+		n, err := strconv.ParseUint(digits, 16, 64)
+		if err != nil {
+			return "", errNumberOverflow
+		}
+		t := strconv.FormatUint(n+uint64(delta), 16)
+		if strings.ToLower(digits) != digits {
+			t = strings.ToUpper(t)
+		}
+		return s[:2] + pad(t, len(digits)), nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return "", errNumberOverflow
+	}
+	if delta > 0 && n > math.MaxInt64-delta || delta < 0 && n < math.MinInt64-delta {
+		return "", errNumberOverflow
+	}
+	n += delta
+	digits := strings.TrimPrefix(s, "-")
+	if len(digits) > 1 && digits[0] == '0' {
+		t := strconv.FormatInt(n, 10)
+		if u := strings.TrimPrefix(t, "-"); u != t {
+			return "-" + pad(u, len(digits)), nil
+		}
+		return pad(t, len(digits)), nil
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// pad returns s with zeros in front up to width.
+func pad(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// increment adds delta to the number under or after the cursor and
+// leaves the cursor on its last digit (vim's <C-a> and <C-x>).
+func (ed *editor) increment(delta int64) {
+	v := ed.focus
+	b := v.Buffer()
+	ed.eachCursor(func() {
+		off1, off2, ok := numberAt(b, v.Cursor())
+		if !ok {
+			ed.setError(errors.New("no number under or after the cursor"))
+			return
+		}
+		s, err := addToNumber(string(b.Bytes(off1, off2)), delta)
+		if err != nil {
+			ed.setError(fmt.Errorf("%s: %v", b.Bytes(off1, off2), err))
+			return
+		}
+		if err := b.Replace(off1, off2, []byte(s)); err != nil {
+			ed.setError(err)
+			return
+		}
+		v.SetCursor(off1 + len(s) - 1)
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestAddToNumber(t *testing.T) {
+	tests := []struct {
+		s     string
+		delta int64
+		want  string
+		err   error
+	}{
+		{"7", 1, "8", nil},
+		{"9", 1, "10", nil},
+		{"0", -1, "-1", nil},
+		{"-1", 2, "1", nil},
+		// leading zeros keep the number of digits
+		{"007", 1, "008", nil},
+		{"009", 1, "010", nil},
+		{"099", 1, "100", nil},
+		{"999", 1, "1000", nil},
+		{"-007", -3, "-010", nil},
+		{"001", -2, "-001", nil},
+		// hexadecimal numbers keep the case of their digits
+		{"0xff", 1, "0x100", nil},
+		{"0x0f", 1, "0x10", nil},
+		{"0XFE", 1, "0XFF", nil},
+		{"0xAb", 1, "0xAC", nil},
+		{"0x09", 1, "0x0a", nil},
+		// and wrap around like unsigned numbers
+		{"0x0", -1, "0xffffffffffffffff", nil},
+		{"0xffffffffffffffff", 1, "0x0000000000000000", nil},
+		{"0x00", -1, "0xffffffffffffffff", nil},
+		// overflow
+		{"9223372036854775807", 1, "", errNumberOverflow},
+		{"-9223372036854775808", -1, "", errNumberOverflow},
+		{"-9223372036854775807", -1, "-9223372036854775808", nil},
+		{"99999999999999999999", 1, "", errNumberOverflow},
+		{"0x10000000000000000", 1, "", errNumberOverflow},
+	}
+	for _, test := range tests {
+		got, err := addToNumber(test.s, test.delta)
+		if got != test.want || err != test.err {
+			t.Errorf("addToNumber(%q, %d) = %q, %v expected %q, %v", test.s, test.delta, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestNumberAt(t *testing.T) {
+	tests := []struct {
+		line string
+		off  int
+		want string // "" for none
+	}{
+		{"x = 42", 0, "42"},
+		{"x = 42", 5, "42"},
+		{"x = 42;", 6, ""},
+		{"a -3", 0, "-3"},
+		// a minus following a word is an operator
+		{"x-1", 0, "1"},
+		{"x -1", 0, "-1"},
+		{"(-1)", 0, "-1"},
+		{"0x1F+1", 0, "0x1F"},
+		{"0x1F+1", 4, "1"},
+		// only the line of the cursor counts
+		{"a\n1", 0, ""},
+	}
+	for _, test := range tests {
+		var b buf.Buf
+		b.Init()
+		b.Insert(0, []byte(test.line))
+		off1, off2, ok := numberAt(&b, test.off)
+		got := ""
+		if ok {
+			got = string(b.Bytes(off1, off2))
+		}
+		if got != test.want {
+			t.Errorf("numberAt(%q, %d) = %q expected %q", test.line, test.off, got, test.want)
+		}
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	tests := []keysTest{
+		{"x = 9;", "<C-a>", "x = 10;", 5},
+		{"x = 9;", "5<C-x>", "x = 4;", 4},
+		{"x-1", "<C-a>", "x-2", 2},
+		{"0x0f", "<C-a>", "0x10", 3},
+		{"007", "3<C-a>", "010", 2},
+		{"none", "<C-a>", "none", 0},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
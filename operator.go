@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+)
+
+// An Operator acts on the text covered by a motion (e.g. d in dw).
+type Operator interface {
+	Apply(ed *editor, r motion.Range)
+}
+
+type operatorFunc func(ed *editor, r motion.Range)
+
+func (f operatorFunc) Apply(ed *editor, r motion.Range) {
+	f(ed, r)
+}
+
+// operators maps action names to operators.
+var operators = map[string]Operator{
+	"delete":      operatorFunc(opDelete),
+	"change":      operatorFunc(opChange),
+	"yank":        operatorFunc(opYank),
+	"shift-right": operatorFunc(opShiftRight),
+	"shift-left":  operatorFunc(opShiftLeft),
+	"filter":      operatorFunc(opFilter),
+	"fold":        operatorFunc(opFold),
+	"toggle-case": caseOperator(toggleCase),
+	"lowercase":   caseOperator(unicode.ToLower),
+	"uppercase":   caseOperator(unicode.ToUpper),
+	"surround":    operatorFunc(opSurround),
+	"comment":     operatorFunc(opComment),
+}
+
+// yank copies the text in r into the selected register.  Whole lines
+// are yanked as such, so that they are put on lines of their own.
+func (ed *editor) yank(r motion.Range) {
+	b := ed.focus.Buffer()
+	text := b.Bytes(r.Off1, r.Off2)
+	if !r.Linewise {
+		ed.registers.Set(ed.selectedRegister(), text)
+		return
+	}
+	if len(text) == 0 || text[len(text)-1] != '\n' {
+		// the last line of the buffer
+		text = append(text, '\n')
+	}
+	ed.registers.SetLines(ed.selectedRegister(), text)
+}
+
+// lineRange returns the linewise range of n lines starting with line
+// first (fewer if the buffer ends before).
+func lineRange(b *buf.Buf, first, n int) motion.Range {
+	r := motion.Range{Off1: b.Line(first), Off2: b.Len(), Linewise: true}
+	if last := first + n - 1; last < b.Lines() {
+		r.Off2 = b.Line(last + 1)
+	}
+	return r
+}
+
+func opDelete(ed *editor, r motion.Range) {
+	ed.yank(r)
+	b := ed.focus.Buffer()
+	if r.Linewise && r.Off2 == b.Len() && r.Off1 > 0 &&
+		(r.Off2 == r.Off1 || b.Bytes(r.Off2-1, r.Off2)[0] != '\n') {
+		// the last line has no line break, take the one before it
+		r.Off1--
+	}
+	if err := b.TryDelete(r.Off1, r.Off2); err != nil {
+		ed.setError(err)
+		return
+	}
+	if !r.Linewise {
+		ed.focus.SetCursor(r.Off1)
+		return
+	}
+	ed.focus.SetCursor(b.Line(b.LineOfOffset(r.Off1)))
+	ed.focus.MoveCursor(motion.FirstNonBlank)
+}
+
+func opChange(ed *editor, r motion.Range) {
+	ed.yank(r)
+	b := ed.focus.Buffer()
+	if r.Linewise && r.Off2 > r.Off1 && b.Bytes(r.Off2-1, r.Off2)[0] == '\n' {
+		// keep the line break of the last line, so that we insert
+		// into an empty line
+		r.Off2--
+	}
+	if err := b.TryDelete(r.Off1, r.Off2); err != nil {
+		ed.setError(err)
+		return
+	}
+	ed.focus.SetCursor(r.Off1)
+	ed.mode = modeInsert
+}
+
+func opYank(ed *editor, r motion.Range) {
+	ed.yank(r)
+	ed.focus.SetCursor(r.Off1)
+}
+
+// linesOf returns the first and last line touched by r.
+func linesOf(b *buf.Buf, r motion.Range) (first, last int) {
+	end := r.Off2
+	if end > r.Off1 {
+		// the last line is the one containing the last byte
+		end--
+	}
+	return b.LineOfOffset(r.Off1), b.LineOfOffset(end)
+}
+
+func opShiftRight(ed *editor, r motion.Range) {
+	b := ed.focus.Buffer()
+	first, last := linesOf(b, r)
+	shiftLines(b, first, last, 1, ed.focus.Options)
+	ed.focus.SetCursor(b.Line(first))
+}
+
+func opShiftLeft(ed *editor, r motion.Range) {
+	b := ed.focus.Buffer()
+	first, last := linesOf(b, r)
+	shiftLines(b, first, last, -1, ed.focus.Options)
+	ed.focus.SetCursor(b.Line(first))
+}
+
+// opFilter starts entering a :! command filtering the lines touched by r.
+func opFilter(ed *editor, r motion.Range) {
+	first, last := linesOf(ed.focus.Buffer(), r)
+	ed.startCmdline()
+	for _, c := range fmt.Sprintf("%d,%d!", first, last) {
+		ed.cmdline.insert(c)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestOperatorSearch(t *testing.T) {
+	tests := []keysTest{
+		{"foo bar baz", "d/baz<CR>", "baz", 0},
+		{"foo bar baz", "$d?bar<CR>", "foo z", 4},
+		{"foo bar bar", "c/bar<CR>X<Esc>", "Xbar bar", 0},
+		// like other exclusive motions not the line break before the match
+		{"a\nfoo", "d/foo<CR>", "\nfoo", 0},
+		// cancelled searches cancel the operator
+		{"foo", "d/nope<CR>~", "Foo", 1},
+		{"foo bar", "d/bar<Esc>~", "Foo bar", 1},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/options"
+	"github.com/bgrundmann/e/view"
+)
+
+// newOptions returns the options of the editor set to their defaults.
+func newOptions() *options.Options {
+	d := view.DefaultOptions
+	return options.New(
+		options.Def{Name: "tabstop", Short: "ts", Kind: options.Int, Scope: options.Buffer, Default: d.TabStop, Min: 1},
+		options.Def{Name: "shiftwidth", Short: "sw", Kind: options.Int, Scope: options.Buffer, Default: d.ShiftWidth, Min: 1},
+		options.Def{Name: "expandtab", Short: "et", Kind: options.Bool, Scope: options.Buffer, Default: d.ExpandTab},
+		options.Def{Name: "autoindent", Short: "ai", Kind: options.Bool, Scope: options.Buffer, Default: d.AutoIndent},
+		options.Def{Name: "autopairs", Short: "ap", Kind: options.Bool, Scope: options.Buffer, Default: false},
+		options.Def{Name: "wrap", Kind: options.Bool, Scope: options.Window, Default: d.Wrap},
+		options.Def{Name: "scrolloff", Short: "so", Kind: options.Int, Scope: options.Window, Default: d.ScrollOff},
+		options.Def{Name: "number", Short: "nu", Kind: options.Bool, Scope: options.Window, Default: d.Number},
+		options.Def{Name: "relativenumber", Short: "rnu", Kind: options.Bool, Scope: options.Window, Default: d.RelativeNumber},
+		options.Def{Name: "list", Kind: options.Bool, Scope: options.Window, Default: d.List},
+		options.Def{Name: "listchars", Short: "lcs", Kind: options.String, Scope: options.Window, Default: d.ListChars,
+			Check: func(value string) error {
+				_, err := view.ParseListChars(value)
+				return err
+			}},
+		options.Def{Name: "colorcolumn", Short: "cc", Kind: options.Int, Scope: options.Window, Default: d.ColorColumn},
+		options.Def{Name: "fileencoding", Short: "fenc", Kind: options.String, Scope: options.Buffer, Default: buf.UTF8.String(),
+			Check: func(value string) error {
+				_, err := buf.ParseEncoding(value)
+				return err
+			}},
+		options.Def{Name: "makeprg", Short: "mp", Kind: options.String, Default: defaultMakeprg},
+		options.Def{Name: "gofmtprg", Kind: options.String, Default: defaultGofmtprg},
+		options.Def{Name: "tags", Short: "tag", Kind: options.String, Default: defaultTags},
+	)
+}
+
+// viewOptionFields map the options used by the views to their fields
+// of view.Options.
+var viewOptionFields = map[string]func(o *view.Options) any{
+	"tabstop":        func(o *view.Options) any { return &o.TabStop },
+	"shiftwidth":     func(o *view.Options) any { return &o.ShiftWidth },
+	"expandtab":      func(o *view.Options) any { return &o.ExpandTab },
+	"autoindent":     func(o *view.Options) any { return &o.AutoIndent },
+	"wrap":           func(o *view.Options) any { return &o.Wrap },
+	"scrolloff":      func(o *view.Options) any { return &o.ScrollOff },
+	"number":         func(o *view.Options) any { return &o.Number },
+	"relativenumber": func(o *view.Options) any { return &o.RelativeNumber },
+	"list":           func(o *view.Options) any { return &o.List },
+	"listchars":      func(o *view.Options) any { return &o.ListChars },
+	"colorcolumn":    func(o *view.Options) any { return &o.ColorColumn },
+}
+
+// bufferOptions returns the options set locally for b.
+func (ed *editor) bufferOptions(b *buf.Buf) *options.Local {
+	if ed.bufOptions[b] == nil {
+		ed.bufOptions[b] = new(options.Local)
+	}
+	return ed.bufOptions[b]
+}
+
+// windowOptions returns the options set locally for the window v.
+func (ed *editor) windowOptions(v *view.View) *options.Local {
+	if ed.winOptions[v] == nil {
+		ed.winOptions[v] = new(options.Local)
+	}
+	return ed.winOptions[v]
+}
+
+// localOptions returns where d is set locally for the focused window,
+// nil if d is a global option.
+func (ed *editor) localOptions(d *options.Def) *options.Local {
+	switch d.Scope {
+	case options.Buffer:
+		return ed.bufferOptions(ed.focus.Buffer())
+	case options.Window:
+		return ed.windowOptions(ed.focus)
+	}
+	return nil
+}
+
+// applyOptions updates the options of v to those of the window and the
+// buffer it shows.
+func (ed *editor) applyOptions(v *view.View) {
+	opts := v.Options
+	bo, wo := ed.bufferOptions(v.Buffer()), ed.windowOptions(v)
+	for name, field := range viewOptionFields {
+		switch p := field(&opts).(type) {
+		case *int:
+			*p = ed.options.Int(name, bo, wo)
+		case *bool:
+			*p = ed.options.Bool(name, bo, wo)
+		case *string:
+			*p = ed.options.String(name, bo, wo)
+		}
+	}
+	if opts != v.Options {
+		v.Options = opts
+		v.Invalidate()
+	}
+}
+
+// setViewOptions sets the options of v locally to opts, e.g. as saved
+// in a session.
+func (ed *editor) setViewOptions(v *view.View, opts view.Options) {
+	bo, wo := ed.bufferOptions(v.Buffer()), ed.windowOptions(v)
+	for name, field := range viewOptionFields {
+		d, _ := ed.options.Lookup(name)
+		local := bo
+		if d.Scope == options.Window {
+			local = wo
+		}
+		switch p := field(&opts).(type) {
+		case *int:
+			ed.options.Set(d, local, *p)
+		case *bool:
+			ed.options.Set(d, local, *p)
+		case *string:
+			ed.options.Set(d, local, *p)
+		}
+	}
+}
+
+// setOption sets the global option name to value.
+func (ed *editor) setOption(name string, value any) {
+	d, _ := ed.options.Lookup(name)
+	ed.options.Set(d, nil, value)
+}
+
+// optionChanged updates the windows after the option of c changed.
+func (ed *editor) optionChanged(c options.Change) {
+	if c.Def.Name == "fileencoding" && c.Local != nil {
+		// the encoding a buffer is written in belongs to the buffer
+		for b, local := range ed.bufOptions {
+			if local == c.Local {
+				enc, _ := buf.ParseEncoding(ed.options.String("fileencoding", local, nil))
+				b.SetEncoding(enc)
+			}
+		}
+	}
+	if _, ok := viewOptionFields[c.Def.Name]; !ok {
+		return
+	}
+	for _, t := range ed.tabs {
+		t.layout.Root().Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+			ed.applyOptions(leaf.View())
+		})
+	}
+}
+
+// showBuffer makes the window v display b with its options.
+func (ed *editor) showBuffer(v *view.View, b *buf.Buf) {
+	showBuffer(v, b)
+	ed.applyOptions(v)
+}
+
+// setScope tells where :set, :setlocal and :setglobal set options.
+type setScope int
+
+const (
+	setBoth setScope = iota
+	setLocal
+	setGlobal
+)
+
+// :se[t] {option} ... changes or shows options, see options.Parse.
+// Options that may be local to a buffer or a window are set for the
+// current one and globally, :setl[ocal] sets them only for the current
+// one and :setg[lobal] only globally.  Without options they are all
+// shown.
+func (ed *editor) cmdSet(scope setScope) func(cmd ex.Command) error {
+	return func(cmd ex.Command) error {
+		var shown []string
+		args := splitArgs(cmd.Arg)
+		if len(args) == 0 {
+			for _, d := range ed.options.Defs() {
+				shown = append(shown, options.Format(d, ed.optionValue(d, scope)))
+			}
+		}
+		for _, arg := range args {
+			a, err := ed.options.Parse(arg)
+			if err != nil {
+				return err
+			}
+			if a.Show {
+				shown = append(shown, options.Format(a.Def, ed.optionValue(a.Def, scope)))
+				continue
+			}
+			local := ed.localOptions(a.Def)
+			if scope != setGlobal && local != nil {
+				ed.options.Set(a.Def, local, a.Value)
+			}
+			if scope != setLocal || local == nil {
+				ed.options.Set(a.Def, nil, a.Value)
+			}
+		}
+		if len(shown) > 0 {
+			ed.setMessage(strings.Join(shown, "\n"))
+		}
+		return nil
+	}
+}
+
+// optionValue returns the value of d shown by the :set command of
+// scope.
+func (ed *editor) optionValue(d *options.Def, scope setScope) any {
+	if scope == setGlobal {
+		return ed.options.Global(d)
+	}
+	if d.Name == "fileencoding" {
+		// detected when the file was loaded
+		return fmt.Sprint(ed.focus.Buffer().Encoding())
+	}
+	v := ed.focus
+	return ed.options.Get(d.Name, ed.bufferOptions(v.Buffer()), ed.windowOptions(v))
+}
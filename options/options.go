@@ -0,0 +1,257 @@
+// Package options defines the options of the editor, e.g. tabstop, and
+// holds their values: the global ones and those set locally for a
+// buffer or a window.
+//
+// Each option has a scope.  Global options only have a global value.
+// Buffer and window options may in addition be set locally for a buffer
+// or a window, which takes precedence over the global value.
+package options
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of the values of an option.
+type Kind int
+
+const (
+	Bool Kind = iota
+	Int
+	String
+)
+
+// Scope tells what an option may be set locally for.
+type Scope int
+
+const (
+	Global Scope = iota // only set globally
+	Buffer              // may be set for a buffer
+	Window              // may be set for a window
+)
+
+// A Def defines an option.
+type Def struct {
+	Name    string // e.g. "tabstop"
+	Short   string // abbreviation, e.g. "ts", or empty
+	Kind    Kind
+	Scope   Scope
+	Default any // a bool, int or string as given by Kind
+	Min     int // smallest value of an Int option
+	// Check validates the values of a String option, may be nil
+	Check func(value string) error
+}
+
+// Local holds the values of the options set locally for a buffer or a
+// window.  The zero value holds none.
+type Local struct {
+	values map[string]any
+}
+
+// Copy returns a copy of l, e.g. for a window split off another one.
+func (l *Local) Copy() *Local {
+	c := &Local{values: make(map[string]any, len(l.values))}
+	for name, value := range l.values {
+		c.values[name] = value
+	}
+	return c
+}
+
+// A Change tells an observer which option was set.
+type Change struct {
+	Def   *Def
+	Local *Local // where it was set, nil if it was set globally
+}
+
+// Options are the definitions of the options and their global values.
+type Options struct {
+	defs      map[string]*Def // by name and abbreviation
+	global    map[string]any
+	observers []func(Change)
+}
+
+// New returns the options defined by defs, set to their defaults.
+func New(defs ...Def) *Options {
+	o := &Options{defs: make(map[string]*Def), global: make(map[string]any)}
+	for _, d := range defs {
+		o.Define(d)
+	}
+	return o
+}
+
+// Define adds the option d set to its default.  Defining an option
+// twice or with a default of the wrong type is a bug and panics.
+func (o *Options) Define(d Def) {
+	if _, ok := o.defs[d.Name]; ok {
+		panic("options: " + d.Name + " defined twice")
+	}
+	if !d.Kind.holds(d.Default) {
+		panic(fmt.Sprintf("options: invalid default %v of %s", d.Default, d.Name))
+	}
+	def := &d
+	o.defs[d.Name] = def
+	if d.Short != "" {
+		o.defs[d.Short] = def
+	}
+	o.global[d.Name] = d.Default
+}
+
+// holds returns whether value is of kind k.
+func (k Kind) holds(value any) bool {
+	switch value.(type) {
+	case bool:
+		return k == Bool
+	case int:
+		return k == Int
+	case string:
+		return k == String
+	}
+	return false
+}
+
+// Lookup returns the option called name, which may be abbreviated.
+func (o *Options) Lookup(name string) (*Def, bool) {
+	d, ok := o.defs[name]
+	return d, ok
+}
+
+// Defs returns the options sorted by name.
+func (o *Options) Defs() []*Def {
+	var defs []*Def
+	for name, d := range o.defs {
+		if name == d.Name {
+			defs = append(defs, d)
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Get returns the value of the option called name in a window with the
+// local options window showing a buffer with the local options buffer.
+// Either may be nil.  Getting an undefined option is a bug and panics.
+func (o *Options) Get(name string, buffer, window *Local) any {
+	d, ok := o.defs[name]
+	if !ok {
+		panic("options: undefined option " + name)
+	}
+	local := buffer
+	if d.Scope == Window {
+		local = window
+	}
+	if d.Scope != Global && local != nil {
+		if value, ok := local.values[d.Name]; ok {
+			return value
+		}
+	}
+	return o.global[d.Name]
+}
+
+// Bool is Get for Bool options.
+func (o *Options) Bool(name string, buffer, window *Local) bool {
+	return o.Get(name, buffer, window).(bool)
+}
+
+// Int is Get for Int options.
+func (o *Options) Int(name string, buffer, window *Local) int {
+	return o.Get(name, buffer, window).(int)
+}
+
+// String is Get for String options.
+func (o *Options) String(name string, buffer, window *Local) string {
+	return o.Get(name, buffer, window).(string)
+}
+
+// Global returns the global value of d.
+func (o *Options) Global(d *Def) any {
+	return o.global[d.Name]
+}
+
+// Set sets d to value for local, or globally if local is nil, and
+// tells the observers.  value must be of d's kind and valid, as
+// returned by Parse.
+func (o *Options) Set(d *Def, local *Local, value any) {
+	if local == nil || d.Scope == Global {
+		o.global[d.Name] = value
+		local = nil
+	} else {
+		if local.values == nil {
+			local.values = make(map[string]any)
+		}
+		local.values[d.Name] = value
+	}
+	for _, f := range o.observers {
+		f(Change{Def: d, Local: local})
+	}
+}
+
+// Observe makes o call f after each change of an option.
+func (o *Options) Observe(f func(Change)) {
+	o.observers = append(o.observers, f)
+}
+
+// An Assignment is a parsed argument of :set.
+type Assignment struct {
+	Def   *Def
+	Show  bool // show the value instead of setting it
+	Value any  // the new value unless Show
+}
+
+// Parse parses an argument of :set: name=value, name to switch on a
+// Bool option (or to show any other), noname to switch it off and
+// name? to show the value.
+func (o *Options) Parse(arg string) (Assignment, error) {
+	name, value, hasValue := strings.Cut(arg, "=")
+	if show := strings.HasSuffix(name, "?"); show && !hasValue {
+		d, ok := o.defs[strings.TrimSuffix(name, "?")]
+		if !ok {
+			return Assignment{}, fmt.Errorf("Unknown option: %s", strings.TrimSuffix(name, "?"))
+		}
+		return Assignment{Def: d, Show: true}, nil
+	}
+	d, ok := o.defs[name]
+	if !ok && !hasValue && strings.HasPrefix(name, "no") {
+		if d, ok := o.defs[name[2:]]; ok && d.Kind == Bool {
+			return Assignment{Def: d, Value: false}, nil
+		}
+	}
+	if !ok {
+		return Assignment{}, fmt.Errorf("Unknown option: %s", name)
+	}
+	if !hasValue {
+		if d.Kind == Bool {
+			return Assignment{Def: d, Value: true}, nil
+		}
+		return Assignment{Def: d, Show: true}, nil
+	}
+	switch d.Kind {
+	case Int:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < d.Min {
+			return Assignment{}, fmt.Errorf("Invalid argument: %s", arg)
+		}
+		return Assignment{Def: d, Value: n}, nil
+	case String:
+		if d.Check != nil {
+			if err := d.Check(value); err != nil {
+				return Assignment{}, err
+			}
+		}
+		return Assignment{Def: d, Value: value}, nil
+	}
+	return Assignment{}, fmt.Errorf("Invalid argument: %s", arg)
+}
+
+// Format returns value of d as :set shows it: name=value or, for Bool
+// options, name or noname.
+func Format(d *Def, value any) string {
+	if on, ok := value.(bool); ok {
+		if on {
+			return d.Name
+		}
+		return "no" + d.Name
+	}
+	return fmt.Sprintf("%s=%v", d.Name, value)
+}
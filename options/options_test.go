@@ -0,0 +1,99 @@
+package options
+
+import (
+	"errors"
+	"testing"
+)
+
+func testOptions() *Options {
+	return New(
+		Def{Name: "tabstop", Short: "ts", Kind: Int, Scope: Buffer, Default: 4, Min: 1},
+		Def{Name: "wrap", Kind: Bool, Scope: Window, Default: true},
+		Def{Name: "makeprg", Short: "mp", Kind: String, Default: "go build",
+			Check: func(value string) error {
+				if value == "" {
+					return errors.New("empty")
+				}
+				return nil
+			}},
+	)
+}
+
+func TestParse(t *testing.T) {
+	o := testOptions()
+	tests := []struct {
+		arg   string
+		name  string
+		show  bool
+		value any
+	}{
+		{"ts=8", "tabstop", false, 8},
+		{"tabstop", "tabstop", true, nil},
+		{"ts?", "tabstop", true, nil},
+		{"wrap", "wrap", false, true},
+		{"nowrap", "wrap", false, false},
+		{"wrap?", "wrap", true, nil},
+		{"mp=make -k", "makeprg", false, "make -k"},
+	}
+	for _, test := range tests {
+		a, err := o.Parse(test.arg)
+		if err != nil || a.Def.Name != test.name || a.Show != test.show || a.Value != test.value {
+			t.Errorf("%q: expected %s %v %v got %+v (%v)", test.arg, test.name, test.show, test.value, a, err)
+		}
+	}
+	for _, arg := range []string{"ts=0", "ts=x", "wrap=1", "nots", "foo", "foo?", "mp="} {
+		if a, err := o.Parse(arg); err == nil {
+			t.Errorf("%q: expected error got %+v", arg, a)
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	o := testOptions()
+	var changes []Change
+	o.Observe(func(c Change) { changes = append(changes, c) })
+	var b1, b2, w1, w2 Local
+	ts, _ := o.Lookup("ts")
+	wrap, _ := o.Lookup("wrap")
+	mp, _ := o.Lookup("makeprg")
+	o.Set(ts, &b1, 8)
+	o.Set(wrap, &w1, false)
+	o.Set(mp, &b1, "make")
+	if n := o.Int("tabstop", &b1, &w1); n != 8 {
+		t.Errorf("local tabstop %d", n)
+	}
+	if n := o.Int("tabstop", &b2, &w1); n != 4 {
+		t.Errorf("other buffer's tabstop %d", n)
+	}
+	if o.Bool("wrap", &b1, &w1) || !o.Bool("wrap", &b1, &w2) {
+		t.Error("wrap not local to the window")
+	}
+	if s := o.String("makeprg", &b2, nil); s != "make" {
+		t.Errorf("global option set locally: %q", s)
+	}
+	o.Set(ts, nil, 2)
+	if o.Int("tabstop", &b1, nil) != 8 || o.Int("tabstop", nil, nil) != 2 {
+		t.Error("global value overrides local one")
+	}
+	if c := w1.Copy(); o.Bool("wrap", nil, c) {
+		t.Error("copy lost local value")
+	}
+	if len(changes) != 4 || changes[0].Local != &b1 || changes[2].Local != nil || changes[3].Def != ts {
+		t.Errorf("wrong changes %+v", changes)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	o := testOptions()
+	d, _ := o.Lookup("wrap")
+	if s := Format(d, false); s != "nowrap" {
+		t.Errorf("got %q", s)
+	}
+	d, _ = o.Lookup("ts")
+	if s := Format(d, o.Global(d)); s != "tabstop=4" {
+		t.Errorf("got %q", s)
+	}
+	if defs := o.Defs(); len(defs) != 3 || defs[0].Name != "makeprg" {
+		t.Errorf("wrong defs %v", defs)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/bgrundmann/e/fuzzy"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/theme"
+	"github.com/nsf/termbox-go"
+)
+
+// picker is an overlay choosing one of several items.  The items shown
+// are those matching what is typed in the command line (see
+// fuzzy.Filter), the best match first.
+type picker struct {
+	items   []string
+	matches []int // indices of the items matching, best first
+	sel     int   // index into matches of the selected item
+	pick    func(item int)
+	match   func(pattern, s string) (int, bool) // fuzzy.Match if nil
+	stop    chan struct{}                       // closed when the picker ends, if set
+}
+
+// maxPickerRows is the maximal number of items shown at once.
+const maxPickerRows = 10
+
+// startPicker enters picker mode offering items.  pick is called with
+// the index of the chosen item.
+func (ed *editor) startPicker(prompt string, items []string, pick func(item int)) {
+	ed.mode = modePicker
+	ed.cmdline.init(prompt)
+	ed.picker = picker{items: items, pick: pick}
+	ed.picker.filter("")
+}
+
+// filter selects the items matching pattern.
+func (p *picker) filter(pattern string) {
+	match := p.match
+	if match == nil {
+		match = fuzzy.Match
+	}
+	p.matches = fuzzy.FilterFunc(pattern, p.items, match)
+	p.sel = 0
+}
+
+// add adds items to those offered, e.g. as they are found in the
+// background, keeping the selection.
+func (p *picker) add(items []string, pattern string) {
+	sel := -1
+	if len(p.matches) > 0 {
+		sel = p.matches[p.sel]
+	}
+	p.items = append(p.items, items...)
+	p.filter(pattern)
+	for i, m := range p.matches {
+		if m == sel {
+			p.sel = i
+		}
+	}
+}
+
+// endPicker leaves picker mode, uncovering the windows.
+func (ed *editor) endPicker() {
+	ed.mode = modeNormal
+	if ed.picker.stop != nil {
+		close(ed.picker.stop)
+		ed.picker.stop = nil
+	}
+	ed.invalidateWindows()
+}
+
+// handlePickerKey processes a key press in picker mode.
+func (ed *editor) handlePickerKey(ev termbox.Event) {
+	p := &ed.picker
+	switch ev.Key {
+	case termbox.KeyEsc:
+		ed.endPicker()
+	case termbox.KeyEnter:
+		ed.endPicker()
+		if len(p.matches) > 0 {
+			p.pick(p.matches[p.sel])
+		}
+	case termbox.KeyArrowUp, termbox.KeyCtrlP:
+		if p.sel > 0 {
+			p.sel--
+		}
+	case termbox.KeyArrowDown, termbox.KeyCtrlN:
+		if p.sel < len(p.matches)-1 {
+			p.sel++
+		}
+	default:
+		if ed.cmdline.edit(ev) {
+			p.filter(ed.cmdline.String())
+		}
+	}
+}
+
+// display draws the items matching above row y of scr, w columns wide, the
+// selected one highlighted.
+func (p *picker) display(scr screen.Screen, y, w int) {
+	rows := len(p.matches)
+	if rows > maxPickerRows {
+		rows = maxPickerRows
+	}
+	if rows > y {
+		rows = y
+	}
+	// scroll so that the selected item is visible
+	first := 0
+	if p.sel >= rows {
+		first = p.sel - rows + 1
+	}
+	for i := 0; i < rows; i++ {
+		row := y - rows + i
+		style := theme.Current[theme.Normal]
+		if first+i == p.sel {
+			style = theme.Current[theme.Selection].Over(style)
+		}
+		x := 0
+		for _, r := range p.items[p.matches[first+i]] {
+			if x >= w {
+				break
+			}
+			scr.SetCell(x, row, r, style.Fg, style.Bg)
+			x++
+		}
+		for ; x < w; x++ {
+			scr.SetCell(x, row, ' ', style.Fg, style.Bg)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package plugin connects the editor to plugins: programs extending it
+// with commands, key bindings, motions and buffer observers, without
+// changing the editor itself.
+//
+// A plugin runs as a child process talking JSON-RPC 2.0 over its
+// standard input and output, with messages framed like in the Language
+// Server Protocol (a Content-Length header before each).  The editor
+// calls
+//
+//	initialize {}
+//	    answered with the Manifest of the plugin
+//	command {"name", "arg", "bang", "buffer"}
+//	    for its commands, answered with a Result
+//	motion {"name", "buffer"}
+//	    for its motions, answered with {"offset", "ok"}
+//	shutdown
+//	    before it exits
+//
+// and sends the notification didChange with a Change for every change
+// of a buffer if the plugin asked to observe them.  A Buffer is sent
+// with its whole text, and offsets are byte offsets into it.
+package plugin
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bgrundmann/e/lsp"
+)
+
+// A Manifest tells what a plugin adds to the editor.
+type Manifest struct {
+	// ex commands, also available as actions for key bindings
+	Commands []string `json:"commands,omitempty"`
+	// actions moving the cursor, also used with operators
+	Motions  []string  `json:"motions,omitempty"`
+	Bindings []Binding `json:"bindings,omitempty"`
+	// whether to send didChange notifications
+	Observe bool `json:"observe,omitempty"`
+}
+
+// A Binding binds keys to an action in a mode, see package keymap.
+type Binding struct {
+	Mode   string `json:"mode"`
+	Keys   string `json:"keys"`
+	Action string `json:"action"`
+}
+
+// A Buffer is the state of the buffer a command or motion applies to.
+type Buffer struct {
+	Name   string `json:"name"`
+	Text   string `json:"text"`
+	Cursor int    `json:"cursor"`
+}
+
+// An Edit replaces the bytes from Off1 to Off2 by Text.
+type Edit struct {
+	Off1 int    `json:"off1"`
+	Off2 int    `json:"off2"`
+	Text string `json:"text"`
+}
+
+// A Result is what running a command of a plugin does to the buffer it
+// was run in.
+type Result struct {
+	// made as if at once, offsets refer to the buffer as sent
+	Edits   []Edit `json:"edits,omitempty"`
+	Cursor  *int   `json:"cursor,omitempty"` // after the edits, nil to keep it
+	Message string `json:"message,omitempty"`
+}
+
+// A Change is a change of a buffer, sent as it is about to be made.
+type Change struct {
+	Buffer   string `json:"buffer"` // its name
+	Off      int    `json:"off"`
+	Deleted  int    `json:"deleted"`
+	Inserted string `json:"inserted"`
+}
+
+// A Plugin is the connection to a plugin.
+type Plugin struct {
+	Name     string
+	Manifest Manifest
+	client   *lsp.Client
+}
+
+// Start starts the plugin command and asks it for its manifest.
+func Start(command []string) (*Plugin, error) {
+	client, err := lsp.Start(command, nil)
+	if err != nil {
+		return nil, err
+	}
+	return initialize(command[0], client)
+}
+
+// New connects to a plugin reading its messages from r and writing
+// to w, see Start.
+func New(name string, r io.Reader, w io.Writer) (*Plugin, error) {
+	return initialize(name, lsp.NewClient(r, w, nil))
+}
+
+func initialize(name string, client *lsp.Client) (*Plugin, error) {
+	p := &Plugin{Name: name, client: client}
+	if err := client.Call("initialize", struct{}{}, &p.Manifest); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return p, nil
+}
+
+// Command runs the command name of the plugin in b.
+func (p *Plugin) Command(name, arg string, bang bool, b Buffer) (Result, error) {
+	params := struct {
+		Name   string `json:"name"`
+		Arg    string `json:"arg"`
+		Bang   bool   `json:"bang"`
+		Buffer Buffer `json:"buffer"`
+	}{name, arg, bang, b}
+	var result Result
+	if err := p.client.Call("command", params, &result); err != nil {
+		return Result{}, fmt.Errorf("%s: %v", p.Name, err)
+	}
+	return result, nil
+}
+
+// Motion returns where the motion name of the plugin moves the cursor
+// of b to.  Returns false if it doesn't move.
+func (p *Plugin) Motion(name string, b Buffer) (int, bool, error) {
+	params := struct {
+		Name   string `json:"name"`
+		Buffer Buffer `json:"buffer"`
+	}{name, b}
+	var result struct {
+		Offset int  `json:"offset"`
+		OK     bool `json:"ok"`
+	}
+	if err := p.client.Call("motion", params, &result); err != nil {
+		return 0, false, fmt.Errorf("%s: %v", p.Name, err)
+	}
+	if result.Offset < 0 || result.Offset > len(b.Text) {
+		return 0, false, fmt.Errorf("%s: %s: invalid offset %d", p.Name, name, result.Offset)
+	}
+	return result.Offset, result.OK, nil
+}
+
+// Changed tells the plugin about c, if it observes changes.
+func (p *Plugin) Changed(c Change) error {
+	if !p.Manifest.Observe {
+		return nil
+	}
+	return p.client.Notify("didChange", c)
+}
+
+// Close shuts the plugin down.
+func (p *Plugin) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// request is a message the fake plugin receives.
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// fake is a plugin answering the requests of the editor.
+type fake struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// receive reads the next message, it may run in a goroutine of its own.
+func (f *fake) receive(t *testing.T) request {
+	length := 0
+	for {
+		line, err := f.r.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return request{}
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(f.r, body); err != nil {
+		t.Error(err)
+		return request{}
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Error(err)
+	}
+	return req
+}
+
+func (f *fake) answer(t *testing.T, req request, result string) {
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+	if _, err := fmt.Fprintf(f.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		t.Error(err)
+	}
+}
+
+// start connects to a fake plugin answering initialize with manifest.
+func start(t *testing.T, manifest string) (*Plugin, *fake) {
+	cr, fw := io.Pipe()
+	fr, cw := io.Pipe()
+	t.Cleanup(func() { cr.Close(); fr.Close() })
+	f := &fake{bufio.NewReader(fr), fw}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := f.receive(t)
+		if req.Method != "initialize" {
+			t.Errorf("got %s, want initialize", req.Method)
+		}
+		f.answer(t, req, manifest)
+	}()
+	p, err := New("fake", cr, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	return p, f
+}
+
+func TestManifest(t *testing.T) {
+	p, _ := start(t, `{"commands":["Hello"],"motions":["next-todo"],
+		"bindings":[{"mode":"normal","keys":"gh","action":"Hello"}],"observe":true}`)
+	m := p.Manifest
+	if len(m.Commands) != 1 || m.Commands[0] != "Hello" || len(m.Motions) != 1 || !m.Observe ||
+		len(m.Bindings) != 1 || m.Bindings[0] != (Binding{"normal", "gh", "Hello"}) {
+		t.Errorf("wrong manifest %+v", m)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	p, f := start(t, `{"commands":["Upper"]}`)
+	go func() {
+		req := f.receive(t)
+		var params struct {
+			Name   string
+			Arg    string
+			Buffer Buffer
+		}
+		json.Unmarshal(req.Params, &params)
+		if req.Method != "command" || params.Name != "Upper" || params.Arg != "x" || params.Buffer.Text != "abc" {
+			t.Errorf("got %s %s", req.Method, req.Params)
+		}
+		f.answer(t, req, `{"edits":[{"off1":0,"off2":3,"text":"ABC"}],"cursor":1,"message":"done"}`)
+	}()
+	r, err := p.Command("Upper", "x", false, Buffer{Name: "a.txt", Text: "abc", Cursor: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Edits) != 1 || r.Edits[0] != (Edit{0, 3, "ABC"}) || r.Cursor == nil || *r.Cursor != 1 || r.Message != "done" {
+		t.Errorf("wrong result %+v", r)
+	}
+}
+
+func TestMotion(t *testing.T) {
+	p, f := start(t, `{"motions":["end"]}`)
+	tests := []struct {
+		answer string
+		off    int
+		ok     bool
+		err    bool
+	}{
+		{`{"offset":3,"ok":true}`, 3, true, false},
+		{`{"offset":0,"ok":false}`, 0, false, false},
+		{`{"offset":9,"ok":true}`, 0, false, true}, // beyond the buffer
+	}
+	for _, test := range tests {
+		go func() { f.answer(t, f.receive(t), test.answer) }()
+		off, ok, err := p.Motion("end", Buffer{Text: "abc"})
+		if off != test.off || ok != test.ok || (err != nil) != test.err {
+			t.Errorf("%s: got %d %v %v", test.answer, off, ok, err)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	p, f := start(t, `{"observe":true}`)
+	go p.Changed(Change{Buffer: "a.txt", Off: 1, Inserted: "x"})
+	req := f.receive(t)
+	if req.Method != "didChange" || req.ID != nil || string(req.Params) != `{"buffer":"a.txt","off":1,"deleted":0,"inserted":"x"}` {
+		t.Errorf("got %s %s %s", req.ID, req.Method, req.Params)
+	}
+}
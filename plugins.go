@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/keymap"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/plugin"
+)
+
+// plugins are the plugins loaded with :plugin.
+type plugins struct {
+	loaded    []*plugin.Plugin
+	commands  map[string]*plugin.Plugin // by name of the command, also an action
+	motions   map[string]*plugin.Plugin // by name of the motion
+	observers map[*buf.Buf]bool         // buffers whose changes are sent on
+}
+
+// :plug[in] {command} starts the plugin command (with arguments) and
+// adds its commands, motions and key bindings.  Without a command it
+// lists the plugins loaded.
+func (ed *editor) cmdPlugin(cmd ex.Command) error {
+	args := splitArgs(cmd.Arg)
+	if len(args) == 0 {
+		var names []string
+		for _, p := range ed.plugins.loaded {
+			names = append(names, p.Name)
+		}
+		if len(names) == 0 {
+			return errors.New("No plugins loaded")
+		}
+		ed.setMessage(strings.Join(names, "\n"))
+		return nil
+	}
+	p, err := plugin.Start(args)
+	if err != nil {
+		return err
+	}
+	if err := ed.addPlugin(p); err != nil {
+		p.Close()
+		return err
+	}
+	return nil
+}
+
+// addPlugin adds what the manifest of p defines.  Like vim's user
+// commands the commands of plugins must start with an upper case
+// letter, so that they don't clash with the commands of the editor.
+func (ed *editor) addPlugin(p *plugin.Plugin) error {
+	pl := &ed.plugins
+	m := p.Manifest
+	for _, name := range m.Commands {
+		if r := []rune(name); len(r) == 0 || !unicode.IsUpper(r[0]) || pl.commands[name] != nil || ed.scripts.commands[name] != nil {
+			return fmt.Errorf("%s: invalid or duplicate command %q", p.Name, name)
+		}
+	}
+	for _, name := range m.Motions {
+		if _, ok := ed.motion(name); ok || pl.motions[name] != nil {
+			return fmt.Errorf("%s: motion %q exists already", p.Name, name)
+		}
+	}
+	// bindings are tried on keymaps of their own first, as nothing is
+	// added unless all of the manifest is
+	tried := keymap.Keymaps{}
+	for _, bd := range m.Bindings {
+		if err := tried.Bind(bd.Mode, bd.Keys, bd.Action); err != nil {
+			return fmt.Errorf("%s: %q: %v", p.Name, bd.Keys, err)
+		}
+	}
+	if pl.commands == nil {
+		pl.commands = make(map[string]*plugin.Plugin)
+		pl.motions = make(map[string]*plugin.Plugin)
+		pl.observers = make(map[*buf.Buf]bool)
+	}
+	for _, name := range m.Commands {
+		pl.commands[name] = p
+		ed.commands.Register(name, func(cmd ex.Command) error {
+			return ed.runPluginCommand(name, cmd)
+		})
+	}
+	for _, name := range m.Motions {
+		pl.motions[name] = p
+	}
+	for _, bd := range m.Bindings {
+		if err := ed.keymaps.Bind(bd.Mode, bd.Keys, bd.Action); err != nil {
+			return fmt.Errorf("%s: %q: %v", p.Name, bd.Keys, err)
+		}
+	}
+	pl.loaded = append(pl.loaded, p)
+	if m.Observe {
+		for _, e := range ed.bufs {
+			ed.observeForPlugins(e.b)
+		}
+	}
+	return nil
+}
+
+// runPluginCommand runs the plugin command name in the focused buffer
+// and makes the changes it answers with.
+func (ed *editor) runPluginCommand(name string, cmd ex.Command) error {
+	p := ed.plugins.commands[name]
+	v := ed.focus
+	b := v.Buffer()
+	r, err := p.Command(name, cmd.Arg, cmd.Bang, pluginBuffer(b, v.Cursor()))
+	if err != nil {
+		return err
+	}
+	if len(r.Edits) > 0 {
+		if err := ed.modifiable(); err != nil {
+			return err
+		}
+		rs := make([]buf.Replacement, len(r.Edits))
+		for i, e := range r.Edits {
+			rs[i] = buf.Replacement{Off1: e.Off1, Off2: e.Off2, Text: []byte(e.Text)}
+		}
+		if err := b.ReplaceAll(rs); err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+	}
+	if r.Cursor != nil {
+		if *r.Cursor < 0 || *r.Cursor > b.Len() {
+			return fmt.Errorf("%s: invalid cursor %d", p.Name, *r.Cursor)
+		}
+		v.SetCursor(*r.Cursor)
+		ed.showCursor()
+	}
+	if r.Message != "" {
+		ed.setMessage(r.Message)
+	}
+	return nil
+}
+
+// pluginMotion returns the motion called name of a plugin, if any.
+func (ed *editor) pluginMotion(name string) (motion.Motion, bool) {
+	p := ed.plugins.motions[name]
+	if p == nil {
+		return nil, false
+	}
+	return motion.New(func(b *buf.Buf, rd *buf.Reader) bool {
+		off, ok, err := p.Motion(name, pluginBuffer(b, rd.Offset()))
+		if err != nil {
+			ed.setError(err)
+			return false
+		}
+		if ok {
+			rd.Seek(int64(off), 0)
+		}
+		return ok
+	}), true
+}
+
+// pluginBuffer returns the state of b sent to plugins.
+func pluginBuffer(b *buf.Buf, cursor int) plugin.Buffer {
+	return plugin.Buffer{Name: b.Name(), Text: b.String(), Cursor: cursor}
+}
+
+// pluginObserver sends the changes of a buffer to the plugins observing
+// them.
+type pluginObserver struct {
+	ed *editor
+	b  *buf.Buf
+}
+
+// OnBufChange implements buf.BufferObserver.
+func (o pluginObserver) OnBufChange(c buf.Change) {
+	for _, p := range o.ed.plugins.loaded {
+		p.Changed(plugin.Change{Buffer: o.b.Name(), Off: c.Off, Deleted: c.Deleted, Inserted: string(c.Inserted)})
+	}
+}
+
+// observeForPlugins sends the changes of b to the plugins observing
+// changes, if any.
+func (ed *editor) observeForPlugins(b *buf.Buf) {
+	pl := &ed.plugins
+	if pl.observers == nil || pl.observers[b] {
+		return
+	}
+	for _, p := range pl.loaded {
+		if p.Manifest.Observe {
+			pl.observers[b] = true
+			b.AddObserver(pluginObserver{ed, b})
+			return
+		}
+	}
+}
+
+// stopPlugins shuts the plugins down.
+func (ed *editor) stopPlugins() {
+	for _, p := range ed.plugins.loaded {
+		p.Close()
+	}
+	ed.plugins.loaded = nil
+}
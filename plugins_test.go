@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bgrundmann/e/plugin"
+)
+
+func TestAddPluginBindings(t *testing.T) {
+	tests := []struct {
+		keys []string // bound to Hello in normal mode
+		ok   bool
+	}{
+		{[]string{"gh"}, true},
+		{[]string{"gh", "<Nope>"}, false},
+		{[]string{"gh", ""}, false},
+	}
+	for _, test := range tests {
+		ed := newTestEditor(t, "")
+		p := &plugin.Plugin{Name: "test", Manifest: plugin.Manifest{Commands: []string{"Hello"}}}
+		for _, keys := range test.keys {
+			p.Manifest.Bindings = append(p.Manifest.Bindings, plugin.Binding{Mode: "normal", Keys: keys, Action: "Hello"})
+		}
+		err := ed.addPlugin(p)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: got error %v", test.keys, err)
+		}
+		// nothing is added unless all is
+		if added := ed.plugins.commands["Hello"] != nil; added != test.ok {
+			t.Errorf("%q: command added %v", test.keys, added)
+		}
+		if action, _ := ed.keymaps.Lookup("normal", []string{"g", "h"}); (action == "Hello") != test.ok {
+			t.Errorf("%q: gh bound to %q", test.keys, action)
+		}
+	}
+}
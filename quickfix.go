@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/view"
+)
+
+// A qfItem is a position in a file the quickfix list refers to, like a
+// match of :grep or an error reported by :make.
+type qfItem struct {
+	file string
+	line int
+	col  int // in runes, starting at 1
+	text string
+}
+
+// quickfix is the list of positions filled by :grep and :make, gone
+// through with
+// :cnext and :cprev.  It is shown in a special buffer, in which
+// <CR> goes to the position of the current line.
+type quickfix struct {
+	items   []qfItem
+	current int      // index of the item last gone to
+	b       *buf.Buf // the buffer listing the items or nil
+}
+
+// quickfixKind is the kind of the buffer listing the quickfix list.
+var quickfixKind = &bufKind{mode: "quickfix"}
+
+func init() {
+	// not in the declaration, as going to an item refers to quickfixKind
+	quickfixKind.actions = map[string]func(ed *editor){
+		"goto-item": func(ed *editor) {
+			line := ed.focus.Buffer().LineOfOffset(ed.focus.Cursor())
+			if err := ed.gotoItem(line - 1); err != nil {
+				ed.setError(err)
+			}
+		},
+	}
+}
+
+// setQuickfix replaces the quickfix list by items, shows it in a window
+// and goes to the first item, if there is one.
+func (ed *editor) setQuickfix(items []qfItem) error {
+	qf := &ed.quickfix
+	qf.items = items
+	qf.current = 0
+	if qf.b == nil {
+		qf.b = new(buf.Buf).Init()
+		qf.b.SetName("[Quickfix List]")
+		ed.addBuffer(qf.b)
+		ed.bufEntry(qf.b).kind = quickfixKind
+	}
+	var list bytes.Buffer
+	for _, it := range items {
+		fmt.Fprintf(&list, "%s:%d:%d: %s\n", it.file, it.line, it.col, it.text)
+	}
+	if _, err := replaceLines(qf.b, list.Bytes()); err != nil {
+		return err
+	}
+	qf.b.MarkSaved()
+	if len(items) == 0 {
+		return nil
+	}
+	if ed.windowShowing(qf.b) == nil {
+		focus := ed.focus
+		ed.split(view.Horizontal)
+		ed.showBuffer(ed.focus, qf.b)
+		ed.setFocus(focus)
+	}
+	return ed.gotoItem(0)
+}
+
+// windowShowing returns the first window of the current tab page
+// showing b or nil.
+func (ed *editor) windowShowing(b *buf.Buf) *view.View {
+	var found *view.View
+	ed.layout.Root().Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+		if v := leaf.View(); found == nil && v.Buffer() == b {
+			found = v
+		}
+	})
+	return found
+}
+
+// gotoItem goes to item i of the quickfix list, in the focused window
+// unless that shows the list itself.
+func (ed *editor) gotoItem(i int) error {
+	qf := &ed.quickfix
+	if i < 0 || i >= len(qf.items) {
+		return errors.New("No more items")
+	}
+	qf.current = i
+	it := qf.items[i]
+	if list := ed.windowShowing(qf.b); list != nil {
+		list.SetCursor(qf.b.Line(i + 1))
+		list.EnsureCursorVisible()
+		if ed.focus == list {
+			ed.layout.Root().Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+				if v := leaf.View(); ed.focus == list && v != list {
+					ed.setFocus(v)
+				}
+			})
+		}
+	}
+	b, err := ed.loadBuffer(it.file)
+	if err != nil {
+		return err
+	}
+	if err := ed.switchBuffer(b, false); err != nil {
+		return err
+	}
+	v := ed.focus
+	off, err := b.PositionToOffset(buf.Position{Line: it.line, Column: it.col})
+	if err != nil {
+		// the file changed since, go to the line at least
+		off = b.Line(it.line)
+	}
+	v.PushJump(v.Cursor())
+	v.SetCursor(off)
+	ed.showCursor()
+	ed.setMessage(fmt.Sprintf("(%d of %d): %s", i+1, len(qf.items), it.text))
+	return nil
+}
+
+// grep returns the matches of re in the lines of the file name, taken
+// from its buffer if it is in the buffer list.
+func (ed *editor) grep(re *regexp.Regexp, name string) ([]qfItem, error) {
+	var text []byte
+	for _, e := range ed.bufs {
+		if e.b.Name() == name && e.kind == nil {
+			text = e.b.Bytes(0, e.b.Len())
+		}
+	}
+	if text == nil {
+		var err error
+		if text, err = os.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	var items []qfItem
+	for i, line := range bytes.Split(text, newline) {
+		if loc := re.FindIndex(line); loc != nil {
+			items = append(items, qfItem{
+				file: name,
+				line: i + 1,
+				col:  utf8.RuneCount(line[:loc[0]]) + 1,
+				text: strings.TrimSpace(string(line)),
+			})
+		}
+	}
+	return items, nil
+}
+
+// :grep pattern [file ...] searches the files (glob patterns) for the
+// regular expression pattern, or the buffers holding files if none are
+// given.  The matches make up the quickfix list.
+func (ed *editor) cmdGrep(cmd ex.Command) error {
+	args := strings.Fields(cmd.Arg)
+	if len(args) == 0 {
+		return errors.New("No pattern")
+	}
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, pattern := range args[1:] {
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if names == nil {
+			return fmt.Errorf("No match for %s", pattern)
+		}
+		for _, name := range names {
+			if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+				files = append(files, name)
+			}
+		}
+	}
+	if len(args) == 1 {
+		for _, e := range ed.bufs {
+			if e.b.Name() != "" && e.kind == nil {
+				files = append(files, e.b.Name())
+			}
+		}
+	}
+	var items []qfItem
+	for _, name := range files {
+		found, err := ed.grep(re, name)
+		if err != nil {
+			return err
+		}
+		items = append(items, found...)
+	}
+	if len(items) == 0 {
+		return errors.New("No matches")
+	}
+	return ed.setQuickfix(items)
+}
+
+// :cn[ext] and :cp[revious] go to the next or previous item of the
+// quickfix list.
+func (ed *editor) cmdQuickfixNext(dir int) func(cmd ex.Command) error {
+	return func(cmd ex.Command) error {
+		n := 1
+		if addr := cmd.Range.Start; cmd.Range.N > 0 && addr.Kind == ex.LineNumber && addr.Line > 0 {
+			// like vim a count given as a line number skips items
+			n = addr.Line
+		}
+		return ed.gotoItem(ed.quickfix.current + dir*n)
+	}
+}
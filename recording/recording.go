@@ -0,0 +1,204 @@
+// Package recording reads and writes recordings of editor sessions, used
+// to replay them (e.g. for profiling or as integration tests).
+//
+// A recording is a stream of JSON objects: a header describing the
+// editor and the files it was started on, the events with the time they
+// occurred relative to the start, and finally a record holding the hashes
+// of the buffers at the end of the session.  Replaying a recording on the
+// same files must reproduce the same buffers.
+package recording
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/nsf/termbox-go"
+)
+
+// Version is the version of the recording format written.
+const Version = 2
+
+// A File identifies the contents of a file or buffer.
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Header is the first record of a recording.
+type Header struct {
+	Version int       `json:"version"`
+	Editor  string    `json:"editor"` // version of the recording editor
+	Start   time.Time `json:"start"`
+	Files   []File    `json:"files"` // initial files, Size -1 if missing
+	// size of the terminal at the start
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// An Event is a key press or other termbox event together with the time
+// it occurred relative to the start of the recording.
+type Event struct {
+	Time  time.Duration
+	Event termbox.Event
+}
+
+// record is the union of all records following the header.
+type record struct {
+	Time  int64          `json:"t"` // milliseconds since the start
+	Event *termbox.Event `json:"ev,omitempty"`
+	End   []File         `json:"end,omitempty"`
+}
+
+// EditorVersion returns the version of the running editor as recorded
+// in headers.
+func EditorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			version += " " + s.Value
+		}
+	}
+	return version
+}
+
+// Hash returns the File describing the contents read from r.
+func Hash(name string, r io.Reader) (File, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Name: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// HashFile returns the File describing the file name.  A missing file
+// is described with Size -1.
+func HashFile(name string) (File, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return File{Name: name, Size: -1}, nil
+	} else if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+	return Hash(name, f)
+}
+
+// HashBuffer returns the File describing the contents of b.
+func HashBuffer(b *buf.Buf) File {
+	sum := b.Hash()
+	return File{Name: b.Name(), Size: int64(b.Len()), SHA256: hex.EncodeToString(sum[:])}
+}
+
+// Verify returns an error describing the first difference between the
+// expected and actual files.
+func Verify(expected, actual []File) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d files got %d", len(expected), len(actual))
+	}
+	for i, e := range expected {
+		if e != actual[i] {
+			return fmt.Errorf("%q differs: expected %d bytes %s got %q %d bytes %s",
+				e.Name, e.Size, e.SHA256, actual[i].Name, actual[i].Size, actual[i].SHA256)
+		}
+	}
+	return nil
+}
+
+// A Writer writes a recording.
+type Writer struct {
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewWriter starts a recording on w of the session described by h.
+// The version, editor and start of h are filled in.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	h.Version = Version
+	h.Editor = EditorVersion()
+	h.Start = time.Now()
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&h); err != nil {
+		return nil, err
+	}
+	return &Writer{enc: enc, start: h.Start}, nil
+}
+
+// Event records ev as happening now.
+func (w *Writer) Event(ev termbox.Event) error {
+	return w.enc.Encode(&record{Time: w.since(), Event: &ev})
+}
+
+// End ends the recording with the buffers the session ended with.
+func (w *Writer) End(buffers []File) error {
+	return w.enc.Encode(&record{Time: w.since(), End: buffers})
+}
+
+func (w *Writer) since() int64 {
+	return time.Since(w.start).Milliseconds()
+}
+
+// A Reader reads a recording.
+type Reader struct {
+	dec    *json.Decoder
+	header Header
+	end    []File
+	ended  bool
+}
+
+// NewReader reads the header of the recording in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{dec: json.NewDecoder(r)}
+	if err := rd.dec.Decode(&rd.header); err != nil {
+		return nil, fmt.Errorf("reading recording header: %v", err)
+	}
+	if rd.header.Version != Version {
+		return nil, fmt.Errorf("unsupported recording version %d (made by editor %q)",
+			rd.header.Version, rd.header.Editor)
+	}
+	return rd, nil
+}
+
+// Header returns the header of the recording.
+func (rd *Reader) Header() Header {
+	return rd.header
+}
+
+// Next returns the next event.  At the end of the recording it returns
+// io.EOF, after which End returns the buffers the session ended with.
+func (rd *Reader) Next() (Event, error) {
+	if rd.ended {
+		return Event{}, io.EOF
+	}
+	var rec record
+	if err := rd.dec.Decode(&rec); err == io.EOF {
+		return Event{}, errors.New("recording ends without end record")
+	} else if err != nil {
+		return Event{}, err
+	}
+	if rec.Event == nil {
+		rd.ended = true
+		rd.end = rec.End
+		return Event{}, io.EOF
+	}
+	return Event{Time: time.Duration(rec.Time) * time.Millisecond, Event: *rec.Event}, nil
+}
+
+// End returns the buffers the recorded session ended with.  Only valid
+// once Next returned io.EOF.
+func (rd *Reader) End() []File {
+	return rd.end
+}
@@ -0,0 +1,76 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/nsf/termbox-go"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.SetName("foo.txt")
+	b.Insert(0, []byte("hello"))
+	start, _ := Hash("foo.txt", strings.NewReader(""))
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Header{Files: []File{start}, Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []termbox.Event{
+		{Type: termbox.EventKey, Ch: 'i'},
+		{Type: termbox.EventKey, Key: termbox.KeyEsc},
+	}
+	for _, ev := range events {
+		if err := w.Event(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	end := HashBuffer(&b)
+	if err := w.End([]File{end}); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(rd.Header().Files, []File{start}); err != nil {
+		t.Error(err)
+	}
+	if h := rd.Header(); h.Width != 80 || h.Height != 24 {
+		t.Errorf("expected size 80x24 got %vx%v", h.Width, h.Height)
+	}
+	for _, exp := range events {
+		ev, err := rd.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Event != exp {
+			t.Errorf("expected %v got %v", exp, ev.Event)
+		}
+	}
+	if _, err := rd.Next(); err != io.EOF {
+		t.Fatalf("expected end of recording got %v", err)
+	}
+	if err := Verify(rd.End(), []File{end}); err != nil {
+		t.Error(err)
+	}
+	if end.Size != 5 {
+		t.Errorf("expected size 5 got %v", end.Size)
+	}
+	if err := Verify(rd.End(), []File{start}); err == nil {
+		t.Error("different buffers verified")
+	}
+}
+
+func TestOldRecording(t *testing.T) {
+	// version 1 recordings were just the events
+	if _, err := NewReader(strings.NewReader(`{"Type":0,"Ch":105}`)); err == nil {
+		t.Error("read recording without header")
+	}
+}
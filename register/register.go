@@ -0,0 +1,82 @@
+// Package register implements vim style registers holding yanked and
+// deleted text.
+package register
+
+import (
+	"fmt"
+)
+
+// Unnamed is the name of the default register.  It is used when no
+// register is given and always holds the most recently yanked or
+// deleted text.
+const Unnamed = '"'
+
+// Registers holds the named registers a-z and the unnamed register.
+// The zero value is ready to use.
+type Registers struct {
+	regs     map[rune][]byte
+	linewise map[rune]bool // registers holding whole lines
+}
+
+// Valid returns whether name denotes a register.  Upper case letters
+// denote the same register as their lower case version but append to it
+// instead of replacing its contents.
+func Valid(name rune) bool {
+	return name == Unnamed || ('a' <= name && name <= 'z') || ('A' <= name && name <= 'Z')
+}
+
+// Get returns the contents of register name.
+func (r *Registers) Get(name rune) ([]byte, error) {
+	if !Valid(name) {
+		return nil, fmt.Errorf("Invalid register name %q", name)
+	}
+	if 'A' <= name && name <= 'Z' {
+		name += 'a' - 'A'
+	}
+	return r.regs[name], nil
+}
+
+// Linewise returns whether register name holds whole lines, which are
+// put on lines of their own.
+func (r *Registers) Linewise(name rune) bool {
+	if 'A' <= name && name <= 'Z' {
+		name += 'a' - 'A'
+	}
+	return r.linewise[name]
+}
+
+// Set stores text in register name (which may be Unnamed) and in the
+// unnamed register.
+func (r *Registers) Set(name rune, text []byte) error {
+	return r.set(name, text, false)
+}
+
+// SetLines is like Set but marks text as consisting of whole lines.
+// Appending lines to a register makes all of it linewise.
+func (r *Registers) SetLines(name rune, text []byte) error {
+	return r.set(name, text, true)
+}
+
+func (r *Registers) set(name rune, text []byte, linewise bool) error {
+	if !Valid(name) {
+		return fmt.Errorf("Invalid register name %q", name)
+	}
+	if r.regs == nil {
+		r.regs = make(map[rune][]byte)
+		r.linewise = make(map[rune]bool)
+	}
+	if 'A' <= name && name <= 'Z' {
+		name += 'a' - 'A'
+		old := r.regs[name]
+		if linewise && !r.linewise[name] && len(old) > 0 && old[len(old)-1] != '\n' {
+			old = append(old, '\n')
+		}
+		text = append(append([]byte(nil), old...), text...)
+		linewise = linewise || r.linewise[name]
+	}
+	r.regs[name] = text
+	r.regs[Unnamed] = text
+	r.linewise[name] = linewise
+	r.linewise[Unnamed] = linewise
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/diff"
+)
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(name string) (fileStamp, bool) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{fi.ModTime(), fi.Size()}, true
+}
+
+// checkInterval is the time between two checks for files changed on
+// disk.
+const checkInterval = time.Second
+
+// fileText identifies the text of a file as read into or written from
+// a buffer by its length and hash.  The hash is computed in the
+// background, and only compared to the file once the file was touched.
+type fileText struct {
+	stamp  fileStamp
+	len    int
+	hash   [sha256.Size]byte
+	hashed chan struct{} // closed once hash is set
+}
+
+// newFileText returns the fileText of s, the text of a file with the
+// version st.  Its hash is computed by a goroutine of its own, which is
+// the last to refer to s.
+func newFileText(st fileStamp, s *buf.Snapshot) *fileText {
+	t := &fileText{stamp: st, len: s.Len(), hashed: make(chan struct{})}
+	go func() {
+		t.hash = s.Hash()
+		close(t.hashed)
+	}()
+	return t
+}
+
+// watch remembers the version of b's file, to notice when it is changed
+// by another program.  It is called when b holds the text of the file,
+// just read or written.
+func (ed *editor) watch(b *buf.Buf) {
+	if b.Name() == "" || ed.kind(b) != nil {
+		return
+	}
+	if st, ok := stampOf(b.Name()); ok {
+		ed.stamps[b] = st
+		ed.texts[b] = newFileText(st, b.Snapshot())
+	} else {
+		delete(ed.stamps, b)
+		delete(ed.texts, b)
+	}
+}
+
+// changedOnDisk returns whether the text of b's file is no longer what
+// was read into or written from b.  The file is only read if it was
+// touched, and a file touched without changing its text doesn't count.
+func (ed *editor) changedOnDisk(b *buf.Buf) bool {
+	old, ok := ed.texts[b]
+	if !ok {
+		return false
+	}
+	if st, ok := stampOf(b.Name()); !ok || st == old.stamp {
+		// a file removed since is simply written again
+		return false
+	}
+	text, _, _, err := readFile(b.Name())
+	if err != nil {
+		// writing it will tell
+		return false
+	}
+	if len(text) != old.len {
+		return true
+	}
+	<-old.hashed
+	return sha256.Sum256(text) != old.hash
+}
+
+// checkFiles tells the user about files shown that were changed on disk
+// since they were loaded or saved.
+func (ed *editor) checkFiles() {
+	for _, b := range ed.buffers() {
+		old, watched := ed.stamps[b]
+		if !watched {
+			continue
+		}
+		if st, ok := stampOf(b.Name()); ok && st != old {
+			// only tell once
+			ed.stamps[b] = st
+			ed.setError(fmt.Errorf("%q changed on disk, use :e! to reload", b.Name()))
+		}
+	}
+}
+
+// readFile returns the text of the file name as loaded into a buffer,
+// with its encoding and line endings.
+func readFile(name string) ([]byte, buf.Encoding, buf.LineEnding, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return decodeText(data)
+}
+
+// decodeText returns data, the contents of a file, decoded as the text
+// of a buffer with its encoding and line endings.
+func decodeText(data []byte) ([]byte, buf.Encoding, buf.LineEnding, error) {
+	text, enc, err := buf.Decode(data)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	text, le := buf.NormalizeLineEndings(text)
+	return text, enc, le, nil
+}
+
+// splitLines splits text into lines, each with its line break.
+func splitLines(text []byte) [][]byte {
+	lines := bytes.SplitAfter(text, newline)
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+var newline = []byte{'\n'}
+
+// reload replaces the contents of b by the contents of its file, or of
+// the listing of its directory.  Only the lines that differ are
+// changed, so markers in the other lines stay where they are.
+func (ed *editor) reload(b *buf.Buf) error {
+	if ed.kind(b) == directoryKind {
+		listing, err := listDirectory(b.Name())
+		if err != nil {
+			return err
+		}
+		if _, err := replaceLines(b, listing); err != nil {
+			return err
+		}
+		b.MarkSaved()
+		return nil
+	}
+	text, enc, le, err := readFile(b.Name())
+	if err != nil {
+		return err
+	}
+	changes, err := replaceLines(b, text)
+	if err != nil {
+		return err
+	}
+	b.SetLineEnding(le)
+	b.SetEncoding(enc)
+	b.MarkSaved()
+	if s := ed.swaps[b]; s != nil {
+		s.Saved()
+	}
+	ed.watch(b)
+	ed.setMessage(fmt.Sprintf("%q reloaded, %d changes", b.Name(), changes))
+	return nil
+}
+
+// replaceLines replaces the contents of b by text, changing only the
+// lines that differ, all with one ReplaceAll.  Returns the number of
+// changed hunks.
+func replaceLines(b *buf.Buf, text []byte) (int, error) {
+	old := splitLines(b.Bytes(0, b.Len()))
+	lines := splitLines(text)
+	hunks := diff.Lines(old, lines)
+	// offsets[i] is the offset of old[i]
+	offsets := make([]int, len(old)+1)
+	for i, l := range old {
+		offsets[i+1] = offsets[i] + len(l)
+	}
+	reps := make([]buf.Replacement, len(hunks))
+	for i, h := range hunks {
+		reps[i] = buf.Replacement{Off1: offsets[h.A1], Off2: offsets[h.A2], Text: bytes.Join(lines[h.B1:h.B2], nil)}
+	}
+	if err := b.ReplaceAll(reps); err != nil {
+		return 0, err
+	}
+	return len(hunks), nil
+}
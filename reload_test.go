@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestReplaceLines(t *testing.T) {
+	tests := []struct {
+		old, text string
+		changes   int
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", 0},
+		{"a\nb\nc\n", "a\nB\nc\n", 1},
+		{"a\nb\nc\n", "x\nb\nc\ny\n", 2},
+		{"a\nb\n", "", 1},
+		{"", "a\n", 1},
+	}
+	for _, test := range tests {
+		var b buf.Buf
+		b.Init()
+		b.Insert(0, []byte(test.old))
+		b.SetValidating(true)
+		changes, err := replaceLines(&b, []byte(test.text))
+		if err != nil || changes != test.changes || b.String() != test.text {
+			t.Errorf("replaceLines(%q, %q) gives %q, %d changes, %v expected %d", test.old, test.text, b.String(), changes, err, test.changes)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package runewidth
+
+import "testing"
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		r   rune
+		exp int
+	}{
+		{'a', 1},
+		{'ä', 1},
+		{'́', 0}, // combining acute accent
+		{'€', 1},
+		{'世', 2},
+		{'ア', 2},
+		{'Ａ', 2}, // fullwidth A
+		{'ｱ', 1}, // halfwidth katakana
+		{'한', 2},
+		{'😀', 2},
+		{'⌚', 2},
+		{'☃', 1},
+		{0x20000, 2},
+	}
+	for _, test := range tests {
+		if got := RuneWidth(test.r); got != test.exp {
+			t.Errorf("%q: expected %v got %v", test.r, test.exp, got)
+		}
+	}
+	if got := StringWidth("a世é"); got != 4 {
+		t.Errorf("StringWidth: expected 4 got %v", got)
+	}
+}
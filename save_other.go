@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// replaceable reports whether the file filename, described by fi, can be
+// replaced by a new file without losing anything but its contents and
+// owner.
+func replaceable(filename string, fi os.FileInfo) bool {
+	return true
+}
+
+// chown gives f the owner of the file described by fi.  Ownership isn't
+// carried over on systems other than unix.
+func chown(f *os.File, fi os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// replaceable reports whether the file filename, described by fi, can be
+// replaced by a new file without losing anything but its contents and
+// owner.  That isn't the case for files with several hard links and files
+// with extended attributes, which include access control lists.
+func replaceable(filename string, fi os.FileInfo) bool {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+		return false
+	}
+	return !hasXattrs(filename)
+}
+
+// chown gives f the owner and group of the file described by fi.
+func chown(f *os.File, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(st.Uid) == os.Getuid() && int(st.Gid) == os.Getgid() {
+		return nil
+	}
+	return f.Chown(int(st.Uid), int(st.Gid))
+}
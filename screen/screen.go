@@ -0,0 +1,163 @@
+// Package screen is what the editor draws on: a grid of cells, each
+// showing a rune in colors and attributes, and a cursor.  Termbox is the
+// terminal, Memory a screen in memory for tests.  Built with the tag
+// tcell, Tcell is the terminal driven by tcell instead of termbox.
+//
+// The colors and attributes are those of termbox (termbox.Attribute),
+// which the themes are written in, and terminals deliver the key strokes
+// as termbox events, which recordings are made of.
+package screen
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// A Screen is a grid of cells.  Drawing outside of it is ignored.
+type Screen interface {
+	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
+	Size() (w, h int)
+	// SetCursor places the cursor, -1, -1 hides it
+	SetCursor(x, y int)
+	// Flush shows what was drawn since the last Flush
+	Flush() error
+}
+
+// A Terminal is a Screen which also delivers the key strokes typed and
+// the changes of its size.
+type Terminal interface {
+	Screen
+	// PollEvent waits for the next event
+	PollEvent() termbox.Event
+	// Close restores the terminal
+	Close()
+}
+
+// Modifiers of key events besides termbox.ModAlt, reported by terminals
+// which know them.  Termbox doesn't, it has control keys for some
+// combinations with control (termbox.KeyCtrlA ...) instead.
+const (
+	ModCtrl termbox.Modifier = 1 << (iota + 2)
+	ModShift
+)
+
+// Termbox is the terminal as set up by termbox.Init.
+type Termbox struct{}
+
+// SetCell implements Screen.
+func (Termbox) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, fg, bg)
+}
+
+// Size implements Screen.
+func (Termbox) Size() (w, h int) {
+	return termbox.Size()
+}
+
+// SetCursor implements Screen.
+func (Termbox) SetCursor(x, y int) {
+	if x < 0 || y < 0 {
+		termbox.HideCursor()
+		return
+	}
+	termbox.SetCursor(x, y)
+}
+
+// Flush implements Screen.
+func (Termbox) Flush() error {
+	return termbox.Flush()
+}
+
+// PollEvent implements Terminal.
+func (Termbox) PollEvent() termbox.Event {
+	return termbox.PollEvent()
+}
+
+// Close implements Terminal.
+func (Termbox) Close() {
+	termbox.Close()
+}
+
+// A Cell is the contents of a cell of a Memory screen.
+type Cell struct {
+	Ch     rune
+	Fg, Bg termbox.Attribute
+}
+
+// Memory is a screen kept in memory.
+type Memory struct {
+	w, h             int
+	cells            []Cell
+	cursorX, cursorY int
+	flushes          int
+}
+
+// NewMemory returns a screen of w x h cells, all blank.
+func NewMemory(w, h int) *Memory {
+	m := &Memory{cursorX: -1, cursorY: -1}
+	m.Resize(w, h)
+	return m
+}
+
+// Resize changes the size of the screen to w x h cells and blanks them.
+func (m *Memory) Resize(w, h int) {
+	m.w, m.h = w, h
+	m.cells = make([]Cell, w*h)
+	for i := range m.cells {
+		m.cells[i].Ch = ' '
+	}
+}
+
+// SetCell implements Screen.
+func (m *Memory) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if 0 <= x && x < m.w && 0 <= y && y < m.h {
+		m.cells[y*m.w+x] = Cell{ch, fg, bg}
+	}
+}
+
+// Size implements Screen.
+func (m *Memory) Size() (w, h int) {
+	return m.w, m.h
+}
+
+// SetCursor implements Screen.
+func (m *Memory) SetCursor(x, y int) {
+	m.cursorX, m.cursorY = x, y
+}
+
+// Flush implements Screen by counting the calls, see Flushes.
+func (m *Memory) Flush() error {
+	m.flushes++
+	return nil
+}
+
+// Cell returns the cell at x, y.
+func (m *Memory) Cell(x, y int) Cell {
+	return m.cells[y*m.w+x]
+}
+
+// Cursor returns the position of the cursor, -1, -1 if it is hidden.
+func (m *Memory) Cursor() (x, y int) {
+	return m.cursorX, m.cursorY
+}
+
+// Flushes returns how often Flush was called.
+func (m *Memory) Flushes() int {
+	return m.flushes
+}
+
+// String returns the runes of the cells, a line per row with the blanks
+// at the end of the rows removed.
+func (m *Memory) String() string {
+	var b strings.Builder
+	for y := 0; y < m.h; y++ {
+		row := make([]rune, m.w)
+		for x := range row {
+			row[x] = m.cells[y*m.w+x].Ch
+		}
+		b.WriteString(strings.TrimRight(string(row), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
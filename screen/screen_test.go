@@ -0,0 +1,34 @@
+package screen
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(4, 2)
+	for i, r := range "hello" {
+		m.SetCell(i, 1, r, termbox.ColorRed, termbox.ColorDefault)
+	}
+	m.SetCell(-1, 0, 'x', 0, 0)
+	m.SetCell(0, 2, 'x', 0, 0)
+	if got, want := m.String(), "\nhell\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if c := m.Cell(1, 1); c != (Cell{'e', termbox.ColorRed, termbox.ColorDefault}) {
+		t.Errorf("got cell %+v", c)
+	}
+	if x, y := m.Cursor(); x != -1 || y != -1 {
+		t.Errorf("cursor of new screen at %d, %d", x, y)
+	}
+	m.SetCursor(2, 1)
+	m.Flush()
+	if x, y := m.Cursor(); x != 2 || y != 1 || m.Flushes() != 1 {
+		t.Errorf("cursor at %d, %d after %d flushes", x, y, m.Flushes())
+	}
+	m.Resize(2, 1)
+	if w, h := m.Size(); w != 2 || h != 1 || m.String() != "\n" {
+		t.Errorf("after Resize got %d x %d %q", w, h, m.String())
+	}
+}
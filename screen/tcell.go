@@ -0,0 +1,166 @@
+//go:build tcell
+
+package screen
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/nsf/termbox-go"
+)
+
+// Tcell is the terminal driven by tcell.  Unlike termbox it reports the
+// modifiers of all keys (as ModCtrl, ModShift and termbox.ModAlt), so
+// that e.g. <C-Up>, <S-Tab> and <M-x> can be bound.
+type Tcell struct {
+	s tcell.Screen
+}
+
+// NewTcell sets the terminal up, Close restores it.
+func NewTcell() (*Tcell, error) {
+	s, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	return &Tcell{s}, nil
+}
+
+// SetCell implements Screen.
+func (t *Tcell) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	style := tcell.StyleDefault.Foreground(tcellColor(fg)).Background(tcellColor(bg)).
+		Bold(fg&termbox.AttrBold != 0).
+		Underline(fg&termbox.AttrUnderline != 0).
+		Reverse(fg&termbox.AttrReverse != 0).
+		Italic(fg&termbox.AttrCursive != 0).
+		Blink(fg&termbox.AttrBlink != 0).
+		Dim(fg&termbox.AttrDim != 0)
+	if fg&termbox.AttrHidden != 0 {
+		ch = ' '
+	}
+	t.s.SetContent(x, y, ch, nil, style)
+}
+
+// colorMask masks the color of a termbox.Attribute, the attributes are
+// the bits above it.
+const colorMask = 1<<9 - 1
+
+// tcellColor returns the color of termbox's color a.  The 16 colors of
+// termbox are the first 16 of the palette.
+func tcellColor(a termbox.Attribute) tcell.Color {
+	c := a & colorMask
+	if c == termbox.ColorDefault {
+		return tcell.ColorDefault
+	}
+	return tcell.PaletteColor(int(c) - 1)
+}
+
+// Size implements Screen.
+func (t *Tcell) Size() (w, h int) {
+	return t.s.Size()
+}
+
+// SetCursor implements Screen.
+func (t *Tcell) SetCursor(x, y int) {
+	if x < 0 || y < 0 {
+		t.s.HideCursor()
+		return
+	}
+	t.s.ShowCursor(x, y)
+}
+
+// Flush implements Screen.
+func (t *Tcell) Flush() error {
+	t.s.Show()
+	return nil
+}
+
+// Close implements Terminal.
+func (t *Tcell) Close() {
+	t.s.Fini()
+}
+
+// PollEvent implements Terminal.  Events termbox doesn't have, like
+// those of the mouse or keys without a termbox.Key, are skipped.
+func (t *Tcell) PollEvent() termbox.Event {
+	for {
+		switch ev := t.s.PollEvent().(type) {
+		case nil:
+			// closed
+			return termbox.Event{Type: termbox.EventInterrupt}
+		case *tcell.EventResize:
+			w, h := ev.Size()
+			return termbox.Event{Type: termbox.EventResize, Width: w, Height: h}
+		case *tcell.EventKey:
+			if e, ok := keyEvent(ev); ok {
+				return e
+			}
+		}
+	}
+}
+
+// tcellKeys maps the keys of tcell to those of termbox, except runes and
+// the control characters, which are the same in both.
+var tcellKeys = map[tcell.Key]termbox.Key{
+	tcell.KeyUp:     termbox.KeyArrowUp,
+	tcell.KeyDown:   termbox.KeyArrowDown,
+	tcell.KeyLeft:   termbox.KeyArrowLeft,
+	tcell.KeyRight:  termbox.KeyArrowRight,
+	tcell.KeyHome:   termbox.KeyHome,
+	tcell.KeyEnd:    termbox.KeyEnd,
+	tcell.KeyPgUp:   termbox.KeyPgup,
+	tcell.KeyPgDn:   termbox.KeyPgdn,
+	tcell.KeyInsert: termbox.KeyInsert,
+	tcell.KeyDelete: termbox.KeyDelete,
+	tcell.KeyF1:     termbox.KeyF1,
+	tcell.KeyF2:     termbox.KeyF2,
+	tcell.KeyF3:     termbox.KeyF3,
+	tcell.KeyF4:     termbox.KeyF4,
+	tcell.KeyF5:     termbox.KeyF5,
+	tcell.KeyF6:     termbox.KeyF6,
+	tcell.KeyF7:     termbox.KeyF7,
+	tcell.KeyF8:     termbox.KeyF8,
+	tcell.KeyF9:     termbox.KeyF9,
+	tcell.KeyF10:    termbox.KeyF10,
+	tcell.KeyF11:    termbox.KeyF11,
+	tcell.KeyF12:    termbox.KeyF12,
+}
+
+// keyEvent returns the termbox event of the key event ev, false if
+// termbox has no such key.
+func keyEvent(ev *tcell.EventKey) (termbox.Event, bool) {
+	e := termbox.Event{Type: termbox.EventKey}
+	mod := ev.Modifiers()
+	if mod&tcell.ModAlt != 0 {
+		e.Mod |= termbox.ModAlt
+	}
+	if mod&tcell.ModCtrl != 0 {
+		e.Mod |= ModCtrl
+	}
+	if mod&tcell.ModShift != 0 {
+		e.Mod |= ModShift
+	}
+	switch k := ev.Key(); {
+	case k == tcell.KeyRune:
+		e.Ch = ev.Rune()
+		// the rune is upper case already, and termbox has a key for space
+		e.Mod &^= ModShift
+		if e.Ch == ' ' {
+			e.Ch, e.Key = 0, termbox.KeySpace
+		}
+	case k == tcell.KeyBacktab:
+		e.Key = termbox.KeyTab
+		e.Mod |= ModShift
+	case k < 0x80:
+		// control keys imply control
+		e.Key = termbox.Key(k)
+		e.Mod &^= ModCtrl
+	default:
+		key, ok := tcellKeys[k]
+		if !ok {
+			return e, false
+		}
+		e.Key = key
+	}
+	return e, true
+}
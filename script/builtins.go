@@ -0,0 +1,107 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var builtins = map[string]Builtin{
+	"len": func(args []Value) (Value, error) {
+		if err := Args("len", args, "any"); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case string:
+			return len(v), nil
+		case []Value:
+			return len(v), nil
+		}
+		return nil, fmt.Errorf("len: invalid argument %s", typeName(args[0]))
+	},
+	"str": func(args []Value) (Value, error) {
+		if err := Args("str", args, "any"); err != nil {
+			return nil, err
+		}
+		return String(args[0]), nil
+	},
+	"int": func(args []Value) (Value, error) {
+		if err := Args("int", args, "any"); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case int:
+			return v, nil
+		case string:
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("int: invalid number %q", v)
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("int: invalid argument %s", typeName(args[0]))
+	},
+	"append": func(args []Value) (Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("append: missing list")
+		}
+		list, ok := args[0].([]Value)
+		if !ok {
+			return nil, fmt.Errorf("append: argument 1 is %s, not list", typeName(args[0]))
+		}
+		return append(append([]Value{}, list...), args[1:]...), nil
+	},
+	"split": func(args []Value) (Value, error) {
+		if err := Args("split", args, "string", "string"); err != nil {
+			return nil, err
+		}
+		var list []Value
+		for _, s := range strings.Split(args[0].(string), args[1].(string)) {
+			list = append(list, s)
+		}
+		return list, nil
+	},
+	"join": func(args []Value) (Value, error) {
+		if err := Args("join", args, "list", "string"); err != nil {
+			return nil, err
+		}
+		var elems []string
+		for _, v := range args[0].([]Value) {
+			elems = append(elems, String(v))
+		}
+		return strings.Join(elems, args[1].(string)), nil
+	},
+	"find": func(args []Value) (Value, error) {
+		if err := Args("find", args, "string", "string"); err != nil {
+			return nil, err
+		}
+		return strings.Index(args[0].(string), args[1].(string)), nil
+	},
+	"upper": stringFunc("upper", strings.ToUpper),
+	"lower": stringFunc("lower", strings.ToLower),
+	"trim":  stringFunc("trim", strings.TrimSpace),
+}
+
+func stringFunc(name string, f func(string) string) Builtin {
+	return func(args []Value) (Value, error) {
+		if err := Args(name, args, "string"); err != nil {
+			return nil, err
+		}
+		return f(args[0].(string)), nil
+	}
+}
+
+// Args checks that the arguments of the builtin function name are of the
+// types given, named like in errors ("int", "string", "list", ...) or
+// "any".
+func Args(name string, args []Value, types ...string) error {
+	if len(args) != len(types) {
+		return fmt.Errorf("%s takes %d arguments, not %d", name, len(types), len(args))
+	}
+	for i, t := range types {
+		if t != "any" && typeName(args[i]) != t {
+			return fmt.Errorf("%s: argument %d is %s, not %s", name, i+1, typeName(args[i]), t)
+		}
+	}
+	return nil
+}
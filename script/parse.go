@@ -0,0 +1,512 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokInt
+	tokString
+	tokPunct
+)
+
+var keywords = map[string]bool{
+	"let": true, "fn": true, "if": true, "else": true, "while": true, "for": true, "in": true,
+	"return": true, "break": true, "continue": true, "true": true, "false": true, "nil": true,
+}
+
+// punctuation lists the operators, longest first.
+var punctuation = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"(", ")", "{", "}", "[", "]", ",", ";", ":", "=", "<", ">", "+", "-", "*", "/", "%", "!",
+}
+
+type token struct {
+	kind tokenKind
+	text string // for strings the value, without quotes and escapes
+	line int
+}
+
+// lex splits src into tokens, ending with tokEOF.
+func lex(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isLetter(c):
+			j := i
+			for j < len(src) && (isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			kind := tokIdent
+			if keywords[src[i:j]] {
+				kind = tokKeyword
+			}
+			toks = append(toks, token{kind, src[i:j], line})
+			i = j
+		case isDigit(c):
+			j := i
+			for j < len(src) && isDigit(src[j]) {
+				j++
+			}
+			toks = append(toks, token{tokInt, src[i:j], line})
+			i = j
+		case c == '"':
+			s, n, err := unquote(src[i:])
+			if err != nil {
+				return nil, &Error{line, err.Error()}
+			}
+			toks = append(toks, token{tokString, s, line})
+			i += n
+		default:
+			found := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(src[i:], p) {
+					toks = append(toks, token{tokPunct, p, line})
+					i += len(p)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, &Error{line, fmt.Sprintf("unexpected character %q", c)}
+			}
+		}
+	}
+	return append(toks, token{tokEOF, "", line}), nil
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// unquote returns the value of the string literal s starts with and its
+// length.  The escapes are \n, \t, \" and \\.
+func unquote(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("newline in string")
+		case '\\':
+			if i++; i == len(s) {
+				break
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				return "", 0, fmt.Errorf("unknown escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses the program src.
+func parse(src string) ([]stmt, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	var stmts []stmt
+	for p.peek().kind != tokEOF {
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether the next token is the operator or keyword s.
+func (p *parser) is(s string) bool {
+	t := p.peek()
+	return (t.kind == tokPunct || t.kind == tokKeyword) && t.text == s
+}
+
+// accept skips the next token if it is the operator or keyword s.
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("expected %s", s)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	t := p.peek()
+	found := strconv.Quote(t.text)
+	if t.kind == tokEOF {
+		found = "end of input"
+	}
+	return &Error{t.line, fmt.Sprintf(format, args...) + ", found " + found}
+}
+
+func (p *parser) ident() (string, error) {
+	if p.peek().kind != tokIdent {
+		return "", p.errorf("expected name")
+	}
+	return p.next().text, nil
+}
+
+func (p *parser) stmt() (stmt, error) {
+	line := p.peek().line
+	var s stmt
+	var err error
+	switch {
+	case p.accept("let"):
+		var name string
+		var e expr
+		if name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("="); err != nil {
+			return nil, err
+		}
+		if e, err = p.expr(); err != nil {
+			return nil, err
+		}
+		s = &letStmt{line, name, e}
+	case p.is("fn") && p.toks[p.pos+1].kind == tokIdent:
+		p.next()
+		name := p.next().text
+		var f *fnExpr
+		if f, err = p.fn(line, name); err != nil {
+			return nil, err
+		}
+		s = &letStmt{line, name, f}
+	case p.accept("if"):
+		if s, err = p.ifStmt(line); err != nil {
+			return nil, err
+		}
+	case p.accept("while"):
+		var cond expr
+		var body []stmt
+		if cond, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if body, err = p.block(); err != nil {
+			return nil, err
+		}
+		s = &whileStmt{line, cond, body}
+	case p.accept("for"):
+		var name string
+		var e expr
+		var body []stmt
+		if name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("in"); err != nil {
+			return nil, err
+		}
+		if e, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if body, err = p.block(); err != nil {
+			return nil, err
+		}
+		s = &forStmt{line, name, e, body}
+	case p.accept("return"):
+		var e expr
+		if !p.is(";") && !p.is("}") && p.peek().kind != tokEOF {
+			if e, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		s = &returnStmt{line, e}
+	case p.accept("break"):
+		s = &branchStmt{line, ctrlBreak}
+	case p.accept("continue"):
+		s = &branchStmt{line, ctrlContinue}
+	default:
+		var e expr
+		if e, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if p.accept("=") {
+			switch e.(type) {
+			case *nameExpr, *indexExpr:
+			default:
+				return nil, &Error{line, "cannot assign to expression"}
+			}
+			var value expr
+			if value, err = p.expr(); err != nil {
+				return nil, err
+			}
+			s = &assignStmt{line, e, value}
+		} else {
+			s = &exprStmt{line, e}
+		}
+	}
+	p.accept(";")
+	return s, nil
+}
+
+func (p *parser) ifStmt(line int) (stmt, error) {
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{line: line, cond: cond}
+	if s.then, err = p.block(); err != nil {
+		return nil, err
+	}
+	if p.accept("else") {
+		if p.is("if") {
+			line := p.next().line
+			elseIf, err := p.ifStmt(line)
+			if err != nil {
+				return nil, err
+			}
+			s.els = []stmt{elseIf}
+		} else if s.els, err = p.block(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (p *parser) block() ([]stmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []stmt
+	for !p.accept("}") {
+		if p.peek().kind == tokEOF {
+			return nil, p.errorf("expected }")
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+// fn parses the parameters and the body of a function.
+func (p *parser) fn(line int, name string) (*fnExpr, error) {
+	f := &fnExpr{line: line, name: name}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.accept(")") {
+		if len(f.params) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		param, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		f.params = append(f.params, param)
+	}
+	var err error
+	if f.body, err = p.block(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// precedence is the precedence of the binary operators, higher binds
+// tighter.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.binary(1)
+}
+
+// binary parses an expression of binary operators of at least
+// precedence prec.
+func (p *parser) binary(prec int) (expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		opPrec := precedence[t.text]
+		if t.kind != tokPunct || opPrec < prec {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binary(opPrec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{t.line, t.text, x, y}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if t := p.peek(); p.is("-") || p.is("!") {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{t.line, t.text, x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		line := p.peek().line
+		switch {
+		case p.accept("("):
+			call := &callExpr{line: line, fn: x}
+			if call.args, err = p.list(")"); err != nil {
+				return nil, err
+			}
+			x = call
+		case p.accept("["):
+			var lo, hi expr
+			if !p.is(":") {
+				if lo, err = p.expr(); err != nil {
+					return nil, err
+				}
+			}
+			if p.accept(":") {
+				if !p.is("]") {
+					if hi, err = p.expr(); err != nil {
+						return nil, err
+					}
+				}
+				x = &sliceExpr{line, x, lo, hi}
+			} else {
+				x = &indexExpr{line, x, lo}
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// list parses a comma separated list of expressions up to end.
+func (p *parser) list(end string) ([]expr, error) {
+	var list []expr
+	for !p.accept(end) {
+		if len(list) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokInt:
+		p.next()
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, &Error{t.line, "invalid number " + t.text}
+		}
+		return &constExpr{n}, nil
+	case t.kind == tokString:
+		p.next()
+		return &constExpr{t.text}, nil
+	case t.kind == tokIdent:
+		p.next()
+		return &nameExpr{t.line, t.text}, nil
+	case p.accept("true"):
+		return &constExpr{true}, nil
+	case p.accept("false"):
+		return &constExpr{false}, nil
+	case p.accept("nil"):
+		return &constExpr{nil}, nil
+	case p.accept("fn"):
+		return p.fn(t.line, "")
+	case p.accept("("):
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case p.accept("["):
+		elems, err := p.list("]")
+		if err != nil {
+			return nil, err
+		}
+		return &listExpr{elems}, nil
+	}
+	return nil, p.errorf("expected expression")
+}
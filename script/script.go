@@ -0,0 +1,732 @@
+// Package script is a small scripting language for automating the
+// editor without recompiling it.  A program is a sequence of statements
+//
+//	let x = 1            # defines x in the current block
+//	x = x + 1            # assigns to a defined variable or list element
+//	fn name(a, b) { ... return a + b }
+//	if x < 2 { ... } else if x == 2 { ... } else { ... }
+//	while x > 0 { ... break ... continue ... }
+//	for elem in list { ... }
+//
+// The values are nil, booleans, integers, strings (of bytes), lists
+// like [1, "a"] and functions, including anonymous ones like
+// fn(x) { return x * 2 }.  Strings and lists can be indexed (s[i]) and
+// sliced (s[i:j]), + concatenates them.  Conditions must be booleans.
+//
+// The builtin functions are len, str, int, append, split, join, find,
+// upper, lower and trim, the host adds its own with Define.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Value is nil, a bool, an int, a string, a []Value, a *Func or a
+// Builtin.
+type Value = any
+
+// A Builtin is a function implemented in Go.
+type Builtin func(args []Value) (Value, error)
+
+// A Func is a function defined by a script.
+type Func struct {
+	name   string
+	params []string
+	body   []stmt
+	env    *env // where it was defined
+}
+
+// An Error is an error in a script.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ErrSteps is the error of a script running longer than allowed, see
+// Interp.MaxSteps.
+var ErrSteps = errors.New("too many steps")
+
+// maxDepth limits how deeply calls of functions defined by scripts
+// nest, so that endless recursion fails before it overflows the stack
+// of the host.
+const maxDepth = 1000
+
+// ErrDepth is the error of calls nested deeper than maxDepth.
+var ErrDepth = errors.New("calls nested too deeply")
+
+// An Interp runs scripts.  The variables defined at the top level of
+// a script stay defined for the scripts run after it.
+type Interp struct {
+	// MaxSteps limits the statements and calls of a single Run or
+	// Call, 0 means no limit.  Runs and Calls nested in another, by a
+	// builtin calling back into the interpreter, share its budget.
+	MaxSteps int
+	globals  *env
+	steps    int
+	depth    int // of the calls of functions defined by scripts
+	nesting  int // of Runs and Calls
+}
+
+// New returns an interpreter with the builtin functions defined.
+func New() *Interp {
+	in := &Interp{}
+	in.globals = &env{in: in, vars: map[string]Value{}}
+	for name, f := range builtins {
+		in.Define(name, f)
+	}
+	return in
+}
+
+// Define defines the global variable name, usually a Builtin.
+func (in *Interp) Define(name string, v Value) {
+	in.globals.vars[name] = v
+}
+
+// Lookup returns the value of the global variable name.
+func (in *Interp) Lookup(name string) (Value, bool) {
+	v, ok := in.globals.vars[name]
+	return v, ok
+}
+
+// Run runs the program src.
+func (in *Interp) Run(src string) error {
+	stmts, err := parse(src)
+	if err != nil {
+		return err
+	}
+	defer in.enter()()
+	c, _, err := in.globals.exec(stmts)
+	if err == nil && c != ctrlNone {
+		err = errors.New("break, continue or return outside of function")
+	}
+	return err
+}
+
+// Call calls the function f, a *Func or a Builtin, with args.
+func (in *Interp) Call(f Value, args ...Value) (Value, error) {
+	defer in.enter()()
+	return in.call(f, args)
+}
+
+// enter starts a Run or Call, resetting the step count unless it is
+// nested in another, and returns the function ending it.
+func (in *Interp) enter() func() {
+	if in.nesting == 0 {
+		in.steps = 0
+	}
+	in.nesting++
+	return func() { in.nesting-- }
+}
+
+func (in *Interp) call(f Value, args []Value) (Value, error) {
+	switch f := f.(type) {
+	case Builtin:
+		return f(args)
+	case *Func:
+		if len(args) != len(f.params) {
+			return nil, fmt.Errorf("%s takes %d arguments, not %d", f, len(f.params), len(args))
+		}
+		if in.depth >= maxDepth {
+			return nil, ErrDepth
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+		e := &env{in: in, vars: map[string]Value{}, parent: f.env}
+		for i, p := range f.params {
+			e.vars[p] = args[i]
+		}
+		c, v, err := e.exec(f.body)
+		if err != nil {
+			return nil, err
+		}
+		if c == ctrlBreak || c == ctrlContinue {
+			return nil, errors.New("break or continue outside of loop")
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot call %s", typeName(f))
+}
+
+// step counts a step, failing if there are too many.
+func (in *Interp) step() error {
+	in.steps++
+	if in.MaxSteps > 0 && in.steps > in.MaxSteps {
+		return ErrSteps
+	}
+	return nil
+}
+
+func (f *Func) String() string {
+	if f.name == "" {
+		return "fn"
+	}
+	return f.name
+}
+
+// String returns v as shown by str: strings as they are, other values
+// as written in a script.
+func String(v Value) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return repr(v)
+}
+
+func repr(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case []Value:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = repr(e)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *Func:
+		return "fn " + v.String()
+	case Builtin:
+		return "builtin"
+	}
+	return fmt.Sprint(v)
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case string:
+		return "string"
+	case []Value:
+		return "list"
+	case *Func, Builtin:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+type ctrl int
+
+const (
+	ctrlNone ctrl = iota
+	ctrlReturn
+	ctrlBreak
+	ctrlContinue
+)
+
+// An env holds the variables of a block.
+type env struct {
+	in     *Interp
+	vars   map[string]Value
+	parent *env
+}
+
+func (e *env) lookup(name string) (*env, bool) {
+	for ; e != nil; e = e.parent {
+		if _, ok := e.vars[name]; ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// exec runs stmts in e.  Returns how they ended and the value returned.
+func (e *env) exec(stmts []stmt) (ctrl, Value, error) {
+	for _, s := range stmts {
+		if err := e.in.step(); err != nil {
+			return ctrlNone, nil, &Error{s.pos(), err.Error()}
+		}
+		c, v, err := s.exec(e)
+		if err != nil || c != ctrlNone {
+			return c, v, err
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+// block runs stmts in a new block in e.
+func (e *env) block(stmts []stmt) (ctrl, Value, error) {
+	return (&env{in: e.in, vars: map[string]Value{}, parent: e}).exec(stmts)
+}
+
+type stmt interface {
+	pos() int
+	exec(e *env) (ctrl, Value, error)
+}
+
+type expr interface {
+	eval(e *env) (Value, error)
+}
+
+type letStmt struct {
+	line  int
+	name  string
+	value expr
+}
+
+type assignStmt struct {
+	line   int
+	target expr // a *nameExpr or an *indexExpr
+	value  expr
+}
+
+type exprStmt struct {
+	line int
+	x    expr
+}
+
+type ifStmt struct {
+	line      int
+	cond      expr
+	then, els []stmt
+}
+
+type whileStmt struct {
+	line int
+	cond expr
+	body []stmt
+}
+
+type forStmt struct {
+	line int
+	name string
+	list expr
+	body []stmt
+}
+
+type returnStmt struct {
+	line  int
+	value expr // nil returns nil
+}
+
+type branchStmt struct {
+	line int
+	ctrl ctrl // ctrlBreak or ctrlContinue
+}
+
+func (s *letStmt) pos() int    { return s.line }
+func (s *assignStmt) pos() int { return s.line }
+func (s *exprStmt) pos() int   { return s.line }
+func (s *ifStmt) pos() int     { return s.line }
+func (s *whileStmt) pos() int  { return s.line }
+func (s *forStmt) pos() int    { return s.line }
+func (s *returnStmt) pos() int { return s.line }
+func (s *branchStmt) pos() int { return s.line }
+
+func (s *letStmt) exec(e *env) (ctrl, Value, error) {
+	v, err := s.value.eval(e)
+	if err != nil {
+		return ctrlNone, nil, err
+	}
+	e.vars[s.name] = v
+	return ctrlNone, nil, nil
+}
+
+func (s *assignStmt) exec(e *env) (ctrl, Value, error) {
+	v, err := s.value.eval(e)
+	if err != nil {
+		return ctrlNone, nil, err
+	}
+	switch t := s.target.(type) {
+	case *nameExpr:
+		scope, ok := e.lookup(t.name)
+		if !ok {
+			return ctrlNone, nil, &Error{s.line, "undefined: " + t.name}
+		}
+		scope.vars[t.name] = v
+	case *indexExpr:
+		x, err := t.x.eval(e)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		list, ok := x.([]Value)
+		if !ok {
+			return ctrlNone, nil, &Error{s.line, "cannot assign to element of " + typeName(x)}
+		}
+		i, err := index(e, t.index, len(list)-1)
+		if err != nil {
+			return ctrlNone, nil, &Error{s.line, err.Error()}
+		}
+		list[i] = v
+	}
+	return ctrlNone, nil, nil
+}
+
+func (s *exprStmt) exec(e *env) (ctrl, Value, error) {
+	_, err := s.x.eval(e)
+	return ctrlNone, nil, err
+}
+
+func (s *ifStmt) exec(e *env) (ctrl, Value, error) {
+	cond, err := condition(e, s.line, s.cond)
+	if err != nil {
+		return ctrlNone, nil, err
+	}
+	if cond {
+		return e.block(s.then)
+	}
+	return e.block(s.els)
+}
+
+func (s *whileStmt) exec(e *env) (ctrl, Value, error) {
+	for {
+		cond, err := condition(e, s.line, s.cond)
+		if err != nil || !cond {
+			return ctrlNone, nil, err
+		}
+		c, v, err := e.block(s.body)
+		if err != nil || c == ctrlReturn {
+			return c, v, err
+		}
+		if c == ctrlBreak {
+			return ctrlNone, nil, nil
+		}
+		if err := e.in.step(); err != nil {
+			return ctrlNone, nil, &Error{s.line, err.Error()}
+		}
+	}
+}
+
+func (s *forStmt) exec(e *env) (ctrl, Value, error) {
+	x, err := s.list.eval(e)
+	if err != nil {
+		return ctrlNone, nil, err
+	}
+	list, ok := x.([]Value)
+	if !ok {
+		return ctrlNone, nil, &Error{s.line, "cannot range over " + typeName(x)}
+	}
+	for _, elem := range list {
+		body := &env{in: e.in, vars: map[string]Value{s.name: elem}, parent: e}
+		c, v, err := body.exec(s.body)
+		if err != nil || c == ctrlReturn {
+			return c, v, err
+		}
+		if c == ctrlBreak {
+			break
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+func (s *returnStmt) exec(e *env) (ctrl, Value, error) {
+	if s.value == nil {
+		return ctrlReturn, nil, nil
+	}
+	v, err := s.value.eval(e)
+	return ctrlReturn, v, err
+}
+
+func (s *branchStmt) exec(e *env) (ctrl, Value, error) {
+	return s.ctrl, nil, nil
+}
+
+// condition evaluates x, which must be a bool.
+func condition(e *env, line int, x expr) (bool, error) {
+	v, err := x.eval(e)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, &Error{line, "condition is " + typeName(v) + ", not bool"}
+	}
+	return b, nil
+}
+
+// index evaluates x, which must be an int from 0 to max.
+func index(e *env, x expr, max int) (int, error) {
+	v, err := x.eval(e)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("index is %s, not int", typeName(v))
+	}
+	if i < 0 || i > max {
+		return 0, fmt.Errorf("index %d out of range", i)
+	}
+	return i, nil
+}
+
+type constExpr struct {
+	value Value
+}
+
+type nameExpr struct {
+	line int
+	name string
+}
+
+type listExpr struct {
+	elems []expr
+}
+
+type fnExpr struct {
+	line   int
+	name   string
+	params []string
+	body   []stmt
+}
+
+type unaryExpr struct {
+	line int
+	op   string
+	x    expr
+}
+
+type binaryExpr struct {
+	line int
+	op   string
+	x, y expr
+}
+
+type callExpr struct {
+	line int
+	fn   expr
+	args []expr
+}
+
+type indexExpr struct {
+	line  int
+	x     expr
+	index expr
+}
+
+type sliceExpr struct {
+	line   int
+	x      expr
+	lo, hi expr // nil if omitted
+}
+
+func (x *constExpr) eval(e *env) (Value, error) {
+	return x.value, nil
+}
+
+func (x *nameExpr) eval(e *env) (Value, error) {
+	scope, ok := e.lookup(x.name)
+	if !ok {
+		return nil, &Error{x.line, "undefined: " + x.name}
+	}
+	return scope.vars[x.name], nil
+}
+
+func (x *listExpr) eval(e *env) (Value, error) {
+	list := make([]Value, len(x.elems))
+	for i, elem := range x.elems {
+		v, err := elem.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+func (x *fnExpr) eval(e *env) (Value, error) {
+	// a named function sees itself as it is defined in e
+	return &Func{name: x.name, params: x.params, body: x.body, env: e}, nil
+}
+
+func (x *unaryExpr) eval(e *env) (Value, error) {
+	v, err := x.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case int:
+		if x.op == "-" {
+			return -v, nil
+		}
+	case bool:
+		if x.op == "!" {
+			return !v, nil
+		}
+	}
+	return nil, &Error{x.line, fmt.Sprintf("invalid operation %s%s", x.op, typeName(v))}
+}
+
+func (x *binaryExpr) eval(e *env) (Value, error) {
+	v, err := x.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if x.op == "&&" || x.op == "||" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, &Error{x.line, fmt.Sprintf("invalid operation %s %s", typeName(v), x.op)}
+		}
+		if b == (x.op == "||") {
+			return b, nil
+		}
+		return condition(e, x.line, x.y)
+	}
+	w, err := x.y.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	r, err := binary(x.op, v, w)
+	if err != nil {
+		return nil, &Error{x.line, err.Error()}
+	}
+	return r, nil
+}
+
+func binary(op string, v, w Value) (Value, error) {
+	switch op {
+	case "==", "!=":
+		if !comparable(v) || !comparable(w) {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeName(v), typeName(w))
+		}
+		return (v == w) == (op == "=="), nil
+	}
+	switch v := v.(type) {
+	case int:
+		if w, ok := w.(int); ok {
+			switch op {
+			case "+":
+				return v + w, nil
+			case "-":
+				return v - w, nil
+			case "*":
+				return v * w, nil
+			case "/", "%":
+				if w == 0 {
+					return nil, errors.New("division by zero")
+				}
+				if op == "/" {
+					return v / w, nil
+				}
+				return v % w, nil
+			case "<":
+				return v < w, nil
+			case "<=":
+				return v <= w, nil
+			case ">":
+				return v > w, nil
+			case ">=":
+				return v >= w, nil
+			}
+		}
+	case string:
+		if w, ok := w.(string); ok {
+			switch op {
+			case "+":
+				return v + w, nil
+			case "<":
+				return v < w, nil
+			case "<=":
+				return v <= w, nil
+			case ">":
+				return v > w, nil
+			case ">=":
+				return v >= w, nil
+			}
+		}
+	case []Value:
+		if w, ok := w.([]Value); ok && op == "+" {
+			return append(append([]Value{}, v...), w...), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operation %s %s %s", typeName(v), op, typeName(w))
+}
+
+func comparable(v Value) bool {
+	switch v.(type) {
+	case nil, bool, int, string:
+		return true
+	}
+	return false
+}
+
+func (x *callExpr) eval(e *env) (Value, error) {
+	f, err := x.fn.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Value, len(x.args))
+	for i, arg := range x.args {
+		if args[i], err = arg.eval(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.in.step(); err != nil {
+		return nil, &Error{x.line, err.Error()}
+	}
+	v, err := e.in.call(f, args)
+	var serr *Error
+	if err != nil && !errors.As(err, &serr) {
+		// the innermost line is the most useful
+		err = &Error{x.line, err.Error()}
+	}
+	return v, err
+}
+
+func (x *indexExpr) eval(e *env) (Value, error) {
+	v, err := x.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case string:
+		i, err := index(e, x.index, len(v)-1)
+		if err != nil {
+			return nil, &Error{x.line, err.Error()}
+		}
+		return v[i : i+1], nil
+	case []Value:
+		i, err := index(e, x.index, len(v)-1)
+		if err != nil {
+			return nil, &Error{x.line, err.Error()}
+		}
+		return v[i], nil
+	}
+	return nil, &Error{x.line, "cannot index " + typeName(v)}
+}
+
+func (x *sliceExpr) eval(e *env) (Value, error) {
+	v, err := x.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	switch v := v.(type) {
+	case string:
+		n = len(v)
+	case []Value:
+		n = len(v)
+	default:
+		return nil, &Error{x.line, "cannot slice " + typeName(v)}
+	}
+	lo, hi := 0, n
+	if x.lo != nil {
+		if lo, err = index(e, x.lo, n); err != nil {
+			return nil, &Error{x.line, err.Error()}
+		}
+	}
+	if x.hi != nil {
+		if hi, err = index(e, x.hi, n); err != nil {
+			return nil, &Error{x.line, err.Error()}
+		}
+	}
+	if lo > hi {
+		return nil, &Error{x.line, fmt.Sprintf("invalid slice [%d:%d]", lo, hi)}
+	}
+	if s, ok := v.(string); ok {
+		return s[lo:hi], nil
+	}
+	return append([]Value{}, v.([]Value)[lo:hi]...), nil
+}
@@ -0,0 +1,136 @@
+package script
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// run runs src with a builtin out, returning what it was called with,
+// one line per call.
+func run(src string) (string, error) {
+	in := New()
+	var out []string
+	in.Define("out", Builtin(func(args []Value) (Value, error) {
+		for _, v := range args {
+			out = append(out, String(v))
+		}
+		return nil, nil
+	}))
+	err := in.Run(src)
+	return strings.Join(out, "\n"), err
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		src, out string
+	}{
+		{`out(1 + 2 * 3, (1 + 2) * 3, 7 / 2, 7 % 2, -3 - -1)`, "7\n9\n3\n1\n-2"},
+		{`out("a" + "b", "ab" < "b", 1 == 1, nil != 0, !true || false && true)`, "ab\ntrue\ntrue\ntrue\nfalse"},
+		{`let x = 1; x = x + 1; out(x)`, "2"},
+		{"let x = 1\nif x > 1 { out(\"big\") } else if x == 1 { out(\"one\") } else { out(\"small\") }", "one"},
+		{`let i = 0; while true { i = i + 1; if i == 2 { continue }; if i > 3 { break }; out(i) }`, "1\n3"},
+		{`for x in [1, "a", [2]] { out(x) }`, "1\na\n[2]"},
+		{`fn fib(n) { if n < 2 { return n } return fib(n-1) + fib(n-2) } out(fib(10))`, "55"},
+		{`fn adder(n) { return fn(x) { return x + n } } let add2 = adder(2); out(add2(3))`, "5"},
+		{`let l = [1, 2, 3]; l[0] = 9; out(l, l[1:], l[:1], "hello"[1:3], "hello"[4], len(l) + len("ab"))`,
+			"[9, 2, 3]\n[2, 3]\n[9]\nel\no\n5"},
+		{`out(str([1, "a", nil]), int(" 42") + 1, append([1], 2, 3), [1] + [2])`, "[1, \"a\", nil]\n43\n[1, 2, 3]\n[1, 2]"},
+		{`out(join(split("a,b,c", ","), "-"), find("abc", "c"), upper("a"), lower("B"), trim(" c "))`, "a-b-c\n2\nA\nb\nc"},
+		{"# a comment\nout(\"a\\tb\\n\\\"\") # another", "a\tb\n\""},
+		{`let x = 1; if true { let x = 2; out(x) } out(x)`, "2\n1"},
+		{`fn f() { return } out(f())`, "nil"},
+	}
+	for _, test := range tests {
+		out, err := run(test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+		} else if out != test.out {
+			t.Errorf("%s: got %q, want %q", test.src, out, test.out)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`out(x)`, "line 1: undefined: x"},
+		{"let x = 1\nx = x + \"a\"", "line 2: invalid operation int + string"},
+		{`if 1 { }`, "line 1: condition is int, not bool"},
+		{`out(1 / 0)`, "line 1: division by zero"},
+		{`[1][1]`, "line 1: index 1 out of range"},
+		{`len(1, 2)`, "line 1: len takes 1 arguments, not 2"},
+		{"fn f(x) {\n  return x.y\n}", `line 2: unexpected character '.'`},
+		{`let = 1`, `line 1: expected name, found "="`},
+		{`1 = 2`, "line 1: cannot assign to expression"},
+		{`out("abc`, "line 1: unterminated string"},
+		{`if true { out(1)`, "line 1: expected }, found end of input"},
+		{`[1] == [1]`, "line 1: cannot compare list and list"},
+		{`return 1`, "break, continue or return outside of function"},
+		{"fn f() { g() }\nfn g() { 1() }\nf()", "line 2: cannot call int"},
+	}
+	for _, test := range tests {
+		_, err := run(test.src)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestSteps(t *testing.T) {
+	in := New()
+	in.MaxSteps = 1000
+	if err := in.Run(`while true { }`); err == nil || !strings.Contains(err.Error(), ErrSteps.Error()) {
+		t.Errorf("got %v, want %v", err, ErrSteps)
+	}
+	if err := in.Run(`let i = 0; while i < 100 { i = i + 1 }`); err != nil {
+		t.Error(err)
+	}
+	// nested runs share the budget of the outermost one
+	in.Define("exec", Builtin(func(args []Value) (Value, error) {
+		return nil, in.Run(String(args[0]))
+	}))
+	if err := in.Run(`while true { exec("1") }`); err == nil || !strings.Contains(err.Error(), ErrSteps.Error()) {
+		t.Errorf("nested: got %v, want %v", err, ErrSteps)
+	}
+	if err := in.Run(`let i = 0; while i < 100 { i = i + 1 }`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	in := New()
+	in.MaxSteps = 10000000
+	if err := in.Run(`fn f(n) { return f(n + 1) }`); err != nil {
+		t.Fatal(err)
+	}
+	err := in.Run(`f(0)`)
+	var serr *Error
+	if !errors.As(err, &serr) || !strings.Contains(err.Error(), ErrDepth.Error()) {
+		t.Errorf("got %v, want %v", err, ErrDepth)
+	}
+	// the depth is back to 0 after the error
+	if err := in.Run(`fn g(n) { if n == 0 { return 0 } return g(n - 1) }
+g(900)`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+	if err := in.Run(`fn double(s) { return s + s }`); err != nil {
+		t.Fatal(err)
+	}
+	f, ok := in.Lookup("double")
+	if !ok {
+		t.Fatal("double not defined")
+	}
+	v, err := in.Call(f, "ab")
+	if err != nil || v != "abab" {
+		t.Errorf("got %v %v, want abab", v, err)
+	}
+	if _, err := in.Call(f); err == nil {
+		t.Error("call with missing argument succeeded")
+	}
+}
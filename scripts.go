@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/script"
+)
+
+// maxScriptSteps stops scripts that would otherwise hang the editor.
+const maxScriptSteps = 10000000
+
+// scripts is the state of the scripts run with :script.
+type scripts struct {
+	interp   *script.Interp          // nil until the first script runs
+	commands map[string]script.Value // defined with command(), also actions
+}
+
+// interp returns the interpreter of the scripts with the functions on
+// the editor defined.  These apply to the focused window as of their
+// call:
+//
+//	text(off1, off2)   the text between the offsets
+//	size()             the length of the buffer
+//	insert(off, text)  inserts text at off
+//	delete(off1, off2) deletes the text between the offsets
+//	cursor()           the offset of the cursor
+//	set_cursor(off)    moves the cursor to off
+//	lines()            the number of lines
+//	line(n)            the text of line n, without the line break
+//	line_start(n)      the offset of line n
+//	line_of(off)       the number of the line of off
+//	filename()         the name of the buffer
+//	exec(cmd)          runs the ex command cmd
+//	message(s)         shows the message s
+//	command(name, fn)  adds the ex command name calling fn(arg)
+func (ed *editor) interp() *script.Interp {
+	if ed.scripts.interp != nil {
+		return ed.scripts.interp
+	}
+	in := script.New()
+	in.MaxSteps = maxScriptSteps
+	ed.scripts.interp = in
+	ed.scripts.commands = make(map[string]script.Value)
+	b := func() *buf.Buf { return ed.focus.Buffer() }
+	// offset checks that the argument i is an offset of the buffer
+	offset := func(args []script.Value, i int) (int, error) {
+		off := args[i].(int)
+		if off < 0 || off > b().Len() {
+			return 0, fmt.Errorf("invalid offset %d", off)
+		}
+		return off, nil
+	}
+	offsets := func(args []script.Value) (int, int, error) {
+		off1, err := offset(args, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		off2, err := offset(args, 1)
+		if err == nil && off1 > off2 {
+			err = fmt.Errorf("invalid offsets %d, %d", off1, off2)
+		}
+		return off1, off2, err
+	}
+	define := func(name string, types []string, f func(args []script.Value) (script.Value, error)) {
+		in.Define(name, script.Builtin(func(args []script.Value) (script.Value, error) {
+			if err := script.Args(name, args, types...); err != nil {
+				return nil, err
+			}
+			return f(args)
+		}))
+	}
+	define("text", []string{"int", "int"}, func(args []script.Value) (script.Value, error) {
+		off1, off2, err := offsets(args)
+		if err != nil {
+			return nil, err
+		}
+		return string(b().Bytes(off1, off2)), nil
+	})
+	define("size", nil, func(args []script.Value) (script.Value, error) {
+		return b().Len(), nil
+	})
+	define("insert", []string{"int", "string"}, func(args []script.Value) (script.Value, error) {
+		if err := ed.modifiable(); err != nil {
+			return nil, err
+		}
+		off, err := offset(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return nil, b().TryInsert(off, []byte(args[1].(string)))
+	})
+	define("delete", []string{"int", "int"}, func(args []script.Value) (script.Value, error) {
+		if err := ed.modifiable(); err != nil {
+			return nil, err
+		}
+		off1, off2, err := offsets(args)
+		if err != nil {
+			return nil, err
+		}
+		return nil, b().TryDelete(off1, off2)
+	})
+	define("cursor", nil, func(args []script.Value) (script.Value, error) {
+		return ed.focus.Cursor(), nil
+	})
+	define("set_cursor", []string{"int"}, func(args []script.Value) (script.Value, error) {
+		off, err := offset(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		ed.focus.SetCursor(off)
+		ed.showCursor()
+		return nil, nil
+	})
+	define("lines", nil, func(args []script.Value) (script.Value, error) {
+		return b().Lines(), nil
+	})
+	// line checks that the argument is a line number
+	line := func(args []script.Value) (int, error) {
+		n := args[0].(int)
+		if n < 1 || n > b().Lines() {
+			return 0, fmt.Errorf("invalid line %d", n)
+		}
+		return n, nil
+	}
+	define("line", []string{"int"}, func(args []script.Value) (script.Value, error) {
+		n, err := line(args)
+		if err != nil {
+			return nil, err
+		}
+		text := ""
+		b().EachLine(n, n, func(_ int, line []byte) bool {
+			text = string(line)
+			return false
+		})
+		return text, nil
+	})
+	define("line_start", []string{"int"}, func(args []script.Value) (script.Value, error) {
+		n, err := line(args)
+		if err != nil {
+			return nil, err
+		}
+		return b().Line(n), nil
+	})
+	define("line_of", []string{"int"}, func(args []script.Value) (script.Value, error) {
+		off, err := offset(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		pos, err := b().PositionFromOffset(off)
+		return pos.Line, err
+	})
+	define("filename", nil, func(args []script.Value) (script.Value, error) {
+		return b().Name(), nil
+	})
+	define("exec", []string{"string"}, func(args []script.Value) (script.Value, error) {
+		return nil, ed.commands.Execute(args[0].(string))
+	})
+	define("message", []string{"any"}, func(args []script.Value) (script.Value, error) {
+		ed.setMessage(script.String(args[0]))
+		return nil, nil
+	})
+	define("command", []string{"string", "function"}, func(args []script.Value) (script.Value, error) {
+		name := args[0].(string)
+		// like the commands of plugins, see addPlugin
+		if r := []rune(name); len(r) == 0 || !unicode.IsUpper(r[0]) || ed.plugins.commands[name] != nil {
+			return nil, fmt.Errorf("invalid command name %q", name)
+		}
+		if _, ok := ed.scripts.commands[name]; !ok {
+			ed.commands.Register(name, func(cmd ex.Command) error {
+				return ed.runScriptCommand(name, cmd.Arg)
+			})
+		}
+		ed.scripts.commands[name] = args[1]
+		return nil, nil
+	})
+	return in
+}
+
+// runScriptCommand calls the function of the command name defined by a
+// script.
+func (ed *editor) runScriptCommand(name, arg string) error {
+	_, err := ed.interp().Call(ed.scripts.commands[name], arg)
+	return err
+}
+
+// :scri[pt] {code} runs code.
+func (ed *editor) cmdScript(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		return errors.New("Usage: script {code}")
+	}
+	return ed.interp().Run(cmd.Arg)
+}
+
+// :scriptf[ile] {file} runs the script in file.
+func (ed *editor) cmdScriptFile(cmd ex.Command) error {
+	if cmd.Arg == "" {
+		return errors.New("Usage: scriptfile {file}")
+	}
+	src, err := os.ReadFile(cmd.Arg)
+	if err != nil {
+		return err
+	}
+	if err := ed.interp().Run(string(src)); err != nil {
+		return fmt.Errorf("%s: %v", cmd.Arg, err)
+	}
+	return nil
+}
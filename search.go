@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/textobject"
+	"github.com/nsf/termbox-go"
+)
+
+// searchState is the state of the incremental search.
+type searchState struct {
+	origCursor    int // cursor and first line when the search started
+	origFirstLine int // restored when the search is cancelled
+	found         bool
+	pattern       string // last accepted pattern
+	backward      bool   // direction of the last search, repeated by n
+}
+
+// searchPrompt returns the prompt of searches backward or forward.
+func searchPrompt(backward bool) string {
+	if backward {
+		return "?"
+	}
+	return "/"
+}
+
+// startSearch enters incremental search mode, searching backward if
+// backward is set.
+func (ed *editor) startSearch(backward bool) {
+	v := ed.focus
+	ed.mode = modeSearch
+	ed.search.backward = backward
+	ed.cmdline.init(searchPrompt(backward))
+	ed.setMessage("")
+	ed.search.origCursor = v.Cursor()
+	ed.search.origFirstLine = v.FirstLine()
+	ed.search.found = false
+}
+
+// cancelSearch leaves search mode restoring the cursor, cancelling
+// the operator the search was for, if any.
+func (ed *editor) cancelSearch() {
+	v := ed.focus
+	v.SetCursor(ed.search.origCursor)
+	v.SetFirstLine(ed.search.origFirstLine)
+	v.SetMatch(0, 0)
+	ed.mode = modeNormal
+	ed.reset()
+}
+
+// handleSearchKey processes a key press while entering a search pattern.
+func (ed *editor) handleSearchKey(ev termbox.Event) {
+	switch ev.Key {
+	case termbox.KeyEsc:
+		ed.cancelSearch()
+	case termbox.KeyEnter:
+		pattern := ed.cmdline.String()
+		if pattern == "" {
+			// like vim an empty pattern repeats the last search
+			pattern = ed.search.pattern
+			ed.updateSearch(pattern)
+		}
+		if !ed.search.found {
+			ed.cancelSearch()
+			ed.setError(fmt.Errorf("Pattern not found: %s", pattern))
+			return
+		}
+		ed.search.pattern = pattern
+		ed.focus.SetMatch(0, 0)
+		ed.mode = modeNormal
+		if ed.pending != nil {
+			ed.searchOperator(pattern)
+			return
+		}
+		ed.focus.PushJump(ed.search.origCursor)
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if ed.cmdline.text.Len() == 0 {
+			ed.cancelSearch()
+			return
+		}
+		ed.cmdline.edit(ev)
+		ed.updateSearch(ed.cmdline.String())
+	default:
+		ed.cmdline.edit(ev)
+		ed.updateSearch(ed.cmdline.String())
+	}
+}
+
+// searchOperator applies the pending operator to the text from where
+// the search started to the match of pattern found (vim's d/pat).
+func (ed *editor) searchOperator(pattern string) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		ed.setError(err)
+		ed.reset()
+		return
+	}
+	ed.focus.SetCursor(ed.search.origCursor)
+	ed.normalMotion("", motion.Search(re, ed.search.backward))
+}
+
+// updateSearch moves the cursor to the first match of pattern after
+// the position the search started at, wrapping around at the end
+// of the buffer, and highlights the match.
+func (ed *editor) updateSearch(pattern string) {
+	v := ed.focus
+	b := v.Buffer()
+	ed.search.found = false
+	v.SetMatch(0, 0)
+	v.SetCursor(ed.search.origCursor)
+	v.SetFirstLine(ed.search.origFirstLine)
+	if pattern == "" {
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		// most likely an incomplete pattern, wait for more
+		return
+	}
+	start, end, ok := motion.Extent(b, ed.search.origCursor, motion.Search(re, ed.search.backward))
+	if !ok {
+		return
+	}
+	ed.search.found = true
+	v.SetCursor(start)
+	v.SetMatch(start, end)
+	v.ShowLine(b.LineOfOffset(start))
+}
+
+// searchMotion returns the motion to the next match of the last search
+// pattern in the direction of the last search, or in the other one if
+// reverse is set.  Moving tells the user about wrapping around the end
+// of the buffer and about not finding the pattern.
+func (ed *editor) searchMotion(reverse bool) (motion.Motion, bool) {
+	pattern := ed.search.pattern
+	if pattern == "" {
+		ed.setError(errors.New("No previous search pattern"))
+		return nil, false
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		ed.setError(err)
+		return nil, false
+	}
+	backward := ed.search.backward != reverse
+	m := motion.Search(re, backward)
+	return motion.New(func(b *buf.Buf, rd *buf.Reader) bool {
+		from := rd.Offset()
+		if !m.Move(b, rd) {
+			ed.setError(fmt.Errorf("Pattern not found: %s", pattern))
+			return false
+		}
+		switch to := rd.Offset(); {
+		case !backward && to <= from:
+			ed.setMessage("search hit BOTTOM, continuing at TOP")
+		case backward && to >= from:
+			ed.setMessage("search hit TOP, continuing at BOTTOM")
+		default:
+			ed.setMessage(searchPrompt(backward) + pattern)
+		}
+		return true
+	}), true
+}
+
+// wordSearchMotion returns the motion to the next whole word occurrence
+// of the word under the cursor (* in vim), or the previous one if
+// backward is set (#).  The word becomes the last search pattern and
+// the direction that of the last search.
+func (ed *editor) wordSearchMotion(backward bool) (motion.Motion, bool) {
+	v := ed.focus
+	r, ok := textobject.InnerWord(v.Buffer(), v.Cursor())
+	word := string(v.Buffer().Bytes(r.Off1, r.Off2))
+	if !ok || strings.TrimSpace(word) == "" {
+		ed.setError(errors.New("No string under cursor"))
+		return nil, false
+	}
+	ed.search.pattern = wordPattern(word)
+	ed.search.backward = backward
+	m, ok := ed.searchMotion(false)
+	if !ok || !backward {
+		return m, ok
+	}
+	// start at the beginning of the word, so that it isn't found itself
+	return motion.New(func(b *buf.Buf, rd *buf.Reader) bool {
+		if off := rd.Offset(); r.Off1 < off && off < r.Off2 {
+			rd.Seek(int64(r.Off1), 0)
+		}
+		return m.Move(b, rd)
+	}), true
+}
+
+// wordPattern returns the pattern matching word only where it isn't part
+// of a longer word.  As \b only knows ASCII, other words may also match
+// within longer ones.
+func wordPattern(word string) string {
+	isASCIIWord := func(r rune) bool {
+		return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	}
+	pattern := regexp.QuoteMeta(word)
+	if first, _ := utf8.DecodeRuneInString(word); isASCIIWord(first) {
+		pattern = `\b` + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(word); isASCIIWord(last) {
+		pattern += `\b`
+	}
+	return pattern
+}
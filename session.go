@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/view"
+)
+
+// defaultSessionFile is where :mksession saves the session unless told
+// otherwise.
+const defaultSessionFile = "Session.json"
+
+// A session is the state of the editor saved by :mksession to be
+// restored with e -S: the working directory, the buffer list, the
+// windows of the tab pages and the settings.  The contents of modified
+// buffers aren't saved.
+type session struct {
+	Dir      string       `json:"dir"`     // the files are relative to it
+	Buffers  []string     `json:"buffers"` // files of the buffer list
+	Tabs     []sessionTab `json:"tabs"`
+	Tab      int          `json:"tab"` // index of the current tab page
+	Makeprg  string       `json:"makeprg"`
+	Gofmtprg string       `json:"gofmtprg"`
+}
+
+type sessionTab struct {
+	Layout sessionLayout `json:"layout"`
+	Focus  int           `json:"focus"` // index of the focused window in display order
+}
+
+// sessionLayout is a node of the window layout (see view.Layout).
+// Windows showing special or unnamed buffers are restored showing an
+// empty buffer.
+type sessionLayout struct {
+	Weight float64 `json:"weight"`
+	// inner nodes
+	Split    string          `json:"split,omitempty"` // "horizontal" or "vertical"
+	Children []sessionLayout `json:"children,omitempty"`
+	// leaves
+	File      string        `json:"file,omitempty"`
+	Cursor    *buf.Position `json:"cursor,omitempty"`
+	FirstLine int           `json:"first_line,omitempty"`
+	Options   *view.Options `json:"options,omitempty"`
+}
+
+var splitNames = map[view.SplitDirection]string{
+	view.Horizontal: "horizontal",
+	view.Vertical:   "vertical",
+}
+
+// sessionFile returns the name b is saved under in a session, "" for
+// buffers not holding a file or a directory.
+func (ed *editor) sessionFile(b *buf.Buf) string {
+	if k := ed.kind(b); k != nil && k != directoryKind {
+		return ""
+	}
+	return b.Name()
+}
+
+// saveSession returns the current state of the editor.
+func (ed *editor) saveSession() *session {
+	s := &session{
+		Tab:      ed.tab,
+		Makeprg:  ed.options.String("makeprg", nil, nil),
+		Gofmtprg: ed.options.String("gofmtprg", nil, nil),
+	}
+	s.Dir, _ = os.Getwd()
+	for _, e := range ed.bufs {
+		if name := ed.sessionFile(e.b); name != "" {
+			s.Buffers = append(s.Buffers, name)
+		}
+	}
+	var saveLayout func(l *view.Layout) sessionLayout
+	saveLayout = func(l *view.Layout) sessionLayout {
+		sl := sessionLayout{Weight: l.Weight()}
+		if v := l.View(); v != nil {
+			b := v.Buffer()
+			sl.File = ed.sessionFile(b)
+			pos, _ := b.PositionFromOffset(v.Cursor())
+			sl.Cursor = &pos
+			sl.FirstLine = v.FirstLine()
+			opts := v.Options
+			sl.Options = &opts
+			return sl
+		}
+		dir, children := l.Children()
+		sl.Split = splitNames[dir]
+		for _, c := range children {
+			sl.Children = append(sl.Children, saveLayout(c))
+		}
+		return sl
+	}
+	for i, t := range ed.tabs {
+		focus := t.focus
+		if i == ed.tab {
+			focus = ed.focus
+		}
+		st := sessionTab{Layout: saveLayout(t.layout)}
+		n := 0
+		t.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+			if leaf.View() == focus {
+				st.Focus = n
+			}
+			n++
+		})
+		s.Tabs = append(s.Tabs, st)
+	}
+	return s
+}
+
+// splitDirection returns the direction of the split called name in
+// sessions.
+func splitDirection(name string) (view.SplitDirection, bool) {
+	for d, n := range splitNames {
+		if n == name {
+			return d, true
+		}
+	}
+	return view.Horizontal, false
+}
+
+// check returns an error if sl isn't a valid layout.
+func (sl sessionLayout) check() error {
+	if sl.Split == "" {
+		return nil
+	}
+	if _, ok := splitDirection(sl.Split); !ok || len(sl.Children) < 2 {
+		return fmt.Errorf("Invalid split in session: %q", sl.Split)
+	}
+	for _, c := range sl.Children {
+		if err := c.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// files calls f with the files of the windows of sl, in display order.
+func (sl sessionLayout) files(f func(name string) error) error {
+	if sl.Split == "" {
+		return f(sl.File)
+	}
+	for _, c := range sl.Children {
+		if err := c.files(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreSession replaces the buffer list and the tab pages of the
+// editor, which has just been started, by those of s.  The working
+// directory is changed to that of the session.  The session is checked
+// and its files are loaded first, so that the editor is left as it was
+// if that fails.
+func (ed *editor) restoreSession(s *session) error {
+	if len(s.Tabs) == 0 {
+		return errors.New("Session without tab pages")
+	}
+	for _, st := range s.Tabs {
+		if err := st.Layout.check(); err != nil {
+			return err
+		}
+	}
+	// the files are named relative to the directory of the session,
+	// which isn't the working directory yet
+	loaded := make(map[string]*buf.Buf)
+	var bufs []*buf.Buf // in the order of the buffer list
+	load := func(name string) error {
+		if name == "" || loaded[filepath.Clean(name)] != nil {
+			return nil
+		}
+		path := name
+		if s.Dir != "" && !filepath.IsAbs(name) {
+			path = filepath.Join(s.Dir, name)
+		}
+		b, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		b.SetName(name)
+		loaded[filepath.Clean(name)] = b
+		bufs = append(bufs, b)
+		return nil
+	}
+	for _, name := range s.Buffers {
+		if err := load(name); err != nil {
+			return err
+		}
+	}
+	for _, st := range s.Tabs {
+		if err := st.Layout.files(load); err != nil {
+			return err
+		}
+	}
+	if s.Dir != "" {
+		if err := os.Chdir(s.Dir); err != nil {
+			return err
+		}
+	}
+
+	started := ed.bufs
+	ed.bufs = nil
+	for _, b := range bufs {
+		ed.addBuffer(b)
+		ed.protect(b)
+		ed.watch(b)
+	}
+	var views []*view.View
+	var restoreLayout func(sl sessionLayout) *view.Layout
+	restoreLayout = func(sl sessionLayout) *view.Layout {
+		if sl.Split == "" {
+			b := loaded[filepath.Clean(sl.File)]
+			if sl.File == "" {
+				// a new empty buffer, which can't fail
+				b, _ = ed.loadBuffer("")
+			}
+			var v view.View
+			v.Init(b)
+			ed.showBuffer(&v, b)
+			if sl.Options != nil {
+				ed.setViewOptions(&v, *sl.Options)
+				ed.applyOptions(&v)
+			}
+			if pos := sl.Cursor; pos != nil {
+				off, err := b.PositionToOffset(*pos)
+				if err != nil {
+					// the file changed since
+					off = b.Line(pos.Line)
+				}
+				v.SetCursor(off)
+			}
+			v.SetFirstLine(sl.FirstLine)
+			views = append(views, &v)
+			return view.NewLayout(&v)
+		}
+		dir, _ := splitDirection(sl.Split)
+		children := make([]*view.Layout, len(sl.Children))
+		weights := make([]float64, len(sl.Children))
+		for i, c := range sl.Children {
+			children[i], weights[i] = restoreLayout(c), c.Weight
+		}
+		return view.NewSplit(dir, children, weights)
+	}
+	var tabs []tabPage
+	for _, st := range s.Tabs {
+		views = nil
+		layout := restoreLayout(st.Layout)
+		focus := views[0]
+		if st.Focus >= 0 && st.Focus < len(views) {
+			focus = views[st.Focus]
+		}
+		tabs = append(tabs, tabPage{layout, focus})
+	}
+	tab := s.Tab
+	if tab < 0 || tab >= len(tabs) {
+		tab = 0
+	}
+	// the windows and buffers the editor was started with are dropped
+	for _, t := range ed.tabs {
+		t.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+			v := leaf.View()
+			v.Close()
+			delete(ed.winOptions, v)
+		})
+	}
+	for _, e := range started {
+		ed.unprotect(e.b)
+		delete(ed.stamps, e.b)
+		delete(ed.texts, e.b)
+	}
+	ed.tabs, ed.tab = tabs, tab
+	ed.layout = tabs[tab].layout
+	ed.setFocus(tabs[tab].focus)
+	if s.Makeprg != "" {
+		ed.setOption("makeprg", s.Makeprg)
+	}
+	if s.Gofmtprg != "" {
+		ed.setOption("gofmtprg", s.Gofmtprg)
+	}
+	ed.relayout()
+	return nil
+}
+
+// loadSession restores the session saved in the file name.
+func (ed *editor) loadSession(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var s session
+	if err := json.NewDecoder(f).Decode(&s); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return ed.restoreSession(&s)
+}
+
+// :mks[ession] [file] saves the session to file, Session.json by
+// default, to be restored with e -S file.  An existing file is only
+// overwritten if ! is given.
+func (ed *editor) cmdMksession(cmd ex.Command) error {
+	name := cmd.Arg
+	if name == "" {
+		name = defaultSessionFile
+	}
+	if _, err := os.Stat(name); err == nil && !cmd.Bang {
+		return fmt.Errorf("File exists: %s (add ! to override)", name)
+	}
+	data, err := json.MarshalIndent(ed.saveSession(), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	ed.setMessage(fmt.Sprintf("Session saved to %q", name))
+	return nil
+}
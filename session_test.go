@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir changes the working directory to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"a.txt": "one\ntwo\nthree\n", "b.txt": "x\ny\n"}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chdir(t, dir)
+	ed := newTestEditor(t, "")
+	steps := []struct{ cmd, keys string }{
+		{"e a.txt", "jjl"},
+		{"vsplit", ""},
+		{"e b.txt", "j"},
+		{"split", ""},
+		{"e a.txt", "j"},
+		{"tabe b.txt", ""},
+		{"set makeprg=make\\ test", ""},
+		{"tabnext", ""},
+	}
+	for _, step := range steps {
+		if err := ed.commands.Execute(step.cmd); err != nil {
+			t.Fatalf("%s: %v", step.cmd, err)
+		}
+		typeKeys(t, ed, step.keys)
+	}
+	saved, err := json.Marshal(ed.saveSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restored in an editor started elsewhere with a file of its own
+	chdir(t, t.TempDir())
+	ed2 := newTestEditor(t, "scratch")
+	started := ed2.focus
+	var s session
+	if err := json.Unmarshal(saved, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := ed2.restoreSession(&s); err != nil {
+		t.Fatal(err)
+	}
+	if wd, _ := os.Getwd(); wd != dir {
+		t.Errorf("working directory %s expected %s", wd, dir)
+	}
+	restored, err := json.Marshal(ed2.saveSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(restored) != string(saved) {
+		t.Errorf("restored session\n%s\nexpected\n%s", restored, saved)
+	}
+	if len(ed2.tabs) != 2 || ed2.tab != 0 {
+		t.Fatalf("%d tab pages, current %d", len(ed2.tabs), ed2.tab)
+	}
+	names := func(ed *editor) []string {
+		var names []string
+		for _, b := range ed.buffers() {
+			names = append(names, b.Name())
+		}
+		return names
+	}
+	if got, want := names(ed2), names(ed); !equalStrings(got, want) {
+		t.Errorf("windows show %q expected %q", got, want)
+	}
+	v, v2 := ed.focus, ed2.focus
+	pos, _ := v.Buffer().PositionFromOffset(v.Cursor())
+	pos2, _ := v2.Buffer().PositionFromOffset(v2.Cursor())
+	if v2.Buffer().Name() != v.Buffer().Name() || pos2 != pos {
+		t.Errorf("focus on %s at %v expected %s at %v", v2.Buffer().Name(), pos2, v.Buffer().Name(), pos)
+	}
+	if got := ed2.options.String("makeprg", nil, nil); got != "make test" {
+		t.Errorf("makeprg %q", got)
+	}
+	// the window and buffer the editor was started with are gone
+	for _, e := range ed2.bufs {
+		if e.b == started.Buffer() {
+			t.Error("started buffer still listed")
+		}
+	}
+	if len(ed2.bufs) != 2 {
+		t.Errorf("%d buffers listed, expected 2", len(ed2.bufs))
+	}
+}
+
+func TestRestoreSessionFails(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// utf-16 with an odd number of bytes can't be loaded
+	if err := os.WriteFile(filepath.Join(dir, "bad.txt"), []byte("\xff\xfeA"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	window := func(file string) sessionLayout { return sessionLayout{Weight: 1, File: file} }
+	sessions := []session{
+		{Dir: dir},
+		{Dir: dir, Buffers: []string{"a.txt", "bad.txt"}, Tabs: []sessionTab{{Layout: window("a.txt")}}},
+		{Dir: dir, Tabs: []sessionTab{{Layout: window("a.txt")}, {Layout: window("bad.txt")}}},
+		{Dir: dir, Tabs: []sessionTab{{Layout: sessionLayout{Split: "diagonal", Children: []sessionLayout{window("a.txt"), window("")}}}}},
+		{Dir: filepath.Join(dir, "missing"), Tabs: []sessionTab{{Layout: window("a.txt")}}},
+	}
+	for i, s := range sessions {
+		// the editor is left as it was
+		wd := t.TempDir()
+		chdir(t, wd)
+		ed := newTestEditor(t, "scratch")
+		started := ed.focus
+		if err := ed.restoreSession(&s); err == nil {
+			t.Errorf("session %d restored", i)
+		}
+		if got, _ := os.Getwd(); got != wd {
+			t.Errorf("session %d: working directory %s expected %s", i, got, wd)
+		}
+		if len(ed.bufs) != 1 || ed.bufs[0].b != started.Buffer() || ed.focus != started {
+			t.Errorf("session %d: buffers or windows changed", i)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
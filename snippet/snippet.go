@@ -0,0 +1,74 @@
+// Package snippet parses the text abbreviations expand to.  Besides
+// plain text a snippet may contain the escapes \n, \t, \$ and \\ and
+// placeholders ${1}, ${2}, ... the cursor visits in order, ending at
+// ${0} or the end of the snippet.
+package snippet
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Snippet is the text to insert and the offsets in it of the
+// placeholders in the order they are visited.
+type Snippet struct {
+	Text  string
+	Stops []int // nil without placeholders
+}
+
+// Parse parses the snippet s.
+func Parse(s string) (Snippet, error) {
+	var text strings.Builder
+	type stop struct{ n, off int }
+	var stops []stop
+	seen := map[int]bool{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				text.WriteByte('\n')
+			case 't':
+				text.WriteByte('\t')
+			default:
+				text.WriteByte(s[i])
+			}
+		case c == '$' && strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return Snippet{}, fmt.Errorf("unterminated placeholder at %q", s[i:])
+			}
+			n, err := strconv.Atoi(s[i+2 : i+end])
+			if err != nil || n < 0 {
+				return Snippet{}, fmt.Errorf("invalid placeholder %q", s[i:i+end+1])
+			}
+			if seen[n] {
+				return Snippet{}, fmt.Errorf("duplicate placeholder %q", s[i:i+end+1])
+			}
+			seen[n] = true
+			stops = append(stops, stop{n, text.Len()})
+			i += end
+		default:
+			text.WriteByte(c)
+		}
+	}
+	sn := Snippet{Text: text.String()}
+	if len(stops) == 0 {
+		return sn, nil
+	}
+	if !seen[0] {
+		stops = append(stops, stop{0, text.Len()})
+	}
+	// 0 goes last
+	sort.Slice(stops, func(i, j int) bool {
+		return stops[i].n != 0 && (stops[j].n == 0 || stops[i].n < stops[j].n)
+	})
+	for _, st := range stops {
+		sn.Stops = append(sn.Stops, st.off)
+	}
+	return sn, nil
+}
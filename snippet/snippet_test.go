@@ -0,0 +1,35 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s     string
+		text  string
+		stops []int
+	}{
+		{"the", "the", nil},
+		{`a\nb\tc\\d\$e$`, "a\nb\tc\\d$e$", nil},
+		{"if ${1} {\\n\\t${2}\\n}", "if  {\n\t\n}", []int{3, 7, 9}},
+		{"f(${2}, ${1})${0};", "f(, );", []int{4, 2, 5}},
+		{"${0}x${1}", "x", []int{1, 0}},
+	}
+	for _, test := range tests {
+		sn, err := Parse(test.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.s, err)
+			continue
+		}
+		if sn.Text != test.text || !reflect.DeepEqual(sn.Stops, test.stops) {
+			t.Errorf("Parse(%q) = %q, %v expected %q, %v", test.s, sn.Text, sn.Stops, test.text, test.stops)
+		}
+	}
+	for _, s := range []string{"${1", "${x}", "${1}${1}", "${-1}"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) succeeded", s)
+		}
+	}
+}
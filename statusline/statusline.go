@@ -0,0 +1,75 @@
+// Package statusline formats the status line shown below each view.
+package statusline
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Status is the information shown in a status line.
+type Status struct {
+	Name     string // buffer name, empty for unnamed buffers
+	Modified bool
+	Line     int // cursor line, starting at 1
+	Column   int // cursor column, starting at 1
+	Lines    int // number of lines in the buffer
+	Mode     string
+	Encoding string // file encoding, only given if not utf-8
+}
+
+// Percent returns how far through the buffer the cursor line is.
+func (s Status) Percent() int {
+	if s.Lines <= 1 {
+		return 100
+	}
+	return (s.Line - 1) * 100 / (s.Lines - 1)
+}
+
+// Format returns the status line for s exactly w runes wide.  The mode
+// and name are left aligned, the position right aligned.  If the line
+// is too narrow the name is cut at its start, as the end of a path is
+// usually the interesting part.
+func Format(s Status, w int) string {
+	name := s.Name
+	if name == "" {
+		name = "[No Name]"
+	}
+	if s.Modified {
+		name += " [+]"
+	}
+	if s.Encoding != "" {
+		name += " [" + s.Encoding + "]"
+	}
+	left := name
+	if s.Mode != "" {
+		left = "-- " + s.Mode + " -- " + name
+	}
+	right := fmt.Sprintf("%d,%d %3d%%", s.Line, s.Column, s.Percent())
+	pad := w - utf8.RuneCountInString(left) - utf8.RuneCountInString(right) - 2
+	for pad < 0 && left != "" {
+		_, n := utf8.DecodeRuneInString(left)
+		left = left[n:]
+		pad++
+	}
+	line := " " + left
+	for i := 0; i < pad; i++ {
+		line += " "
+	}
+	line += " " + right
+	return fit(line, w)
+}
+
+// fit cuts or pads line to exactly w runes.
+func fit(line string, w int) string {
+	n := 0
+	for i := range line {
+		if n == w {
+			return line[:i]
+		}
+		n++
+	}
+	for ; n < w; n++ {
+		line += " "
+	}
+	return line
+}
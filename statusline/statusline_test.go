@@ -0,0 +1,28 @@
+package statusline
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		s    Status
+		w    int
+		want string
+	}{
+		{Status{Name: "e.go", Line: 1, Column: 1, Lines: 10}, 20, " e.go       1,1   0%"},
+		{Status{Name: "e.go", Modified: true, Line: 10, Column: 3, Lines: 10, Mode: "INSERT"}, 36,
+			" -- INSERT -- e.go [+]     10,3 100%"},
+		{Status{Line: 2, Column: 1, Lines: 3}, 24, " [No Name]      2,1  50%"},
+		{Status{Name: "a", Modified: true, Line: 1, Column: 1, Lines: 1, Encoding: "latin1"}, 26,
+			" a [+] [latin1]   1,1 100%"},
+		{Status{Name: "some/long/path.go", Line: 1, Column: 1, Lines: 1}, 20, " ng/path.go 1,1 100%"},
+		{Status{Name: "x", Line: 1, Column: 1, Lines: 1}, 5, "  1,1"},
+	}
+	for _, test := range tests {
+		got := Format(test.s, test.w)
+		if got != test.want {
+			t.Errorf("Format(%+v, %d) = %q, want %q", test.s, test.w, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/textobject"
+	"github.com/nsf/termbox-go"
+)
+
+// The surround commands add, delete and change the delimiters around
+// text like vim-surround: ys{motion}{char} surrounds the text the
+// motion covers (yss the line) with char, ds{char} deletes the
+// delimiters char around the cursor and cs{old}{new} replaces them.
+// Opening brackets add blanks inside the brackets, or delete them.
+
+// surroundAliases are the letters naming brackets.
+var surroundAliases = map[rune]rune{'b': ')', 'B': '}', 'r': ']', 'a': '>'}
+
+// surroundDelimiter returns the delimiter ch names, false if it names
+// none.
+func surroundDelimiter(ch rune) (rune, bool) {
+	if r, ok := surroundAliases[ch]; ok {
+		return r, true
+	}
+	return ch, unicode.IsPunct(ch) || unicode.IsSymbol(ch)
+}
+
+// delimiters returns the texts to put in front of and behind text to
+// surround it with r.
+func delimiters(r rune) (open, close string) {
+	for o, c := range textobject.Brackets {
+		switch r {
+		case o:
+			return string(o) + " ", " " + string(c)
+		case c:
+			return string(o), string(c)
+		}
+	}
+	return string(r), string(r)
+}
+
+// opSurround makes r one of the texts to surround with the delimiter
+// typed next.  Whole lines are surrounded without their indentation
+// and line break.
+func opSurround(ed *editor, r motion.Range) {
+	if r.Linewise {
+		b := ed.focus.Buffer()
+		rd := b.NewReader(r.Off1)
+		motion.FirstNonBlank.Move(b, rd)
+		end := r.Off2
+		if end > r.Off1 && b.Bytes(end-1, end)[0] == '\n' {
+			end--
+		}
+		r = motion.Range{Off1: min(rd.Offset(), end), Off2: end}
+	}
+	ed.surround = "surround"
+	ed.surroundRanges = append(ed.surroundRanges, r)
+}
+
+// startSurround starts the surround command of the pending operator
+// followed by s: ys continues as an operator, ds and cs wait for their
+// delimiters.
+func (ed *editor) startSurround() {
+	switch op := ed.opName; op {
+	case "yank":
+		ed.pending = operators["surround"]
+		ed.opName = "surround"
+		ed.opKey = "s"
+	case "delete", "change":
+		ed.reset()
+		ed.surround = op
+	default:
+		ed.reset()
+	}
+}
+
+// surroundKey handles the keys naming the delimiters of the surround
+// command waiting for them.  Other keys cancel it.
+func (ed *editor) surroundKey(ev termbox.Event) {
+	cmd, old, ranges := ed.surround, ed.surroundOld, ed.surroundRanges
+	ed.surround, ed.surroundOld, ed.surroundRanges = "", 0, nil
+	r, ok := surroundDelimiter(ev.Ch)
+	if !ok {
+		return
+	}
+	switch cmd {
+	case "surround":
+		ed.addSurround(ranges, r)
+	case "delete":
+		ed.eachCursor(func() { ed.replaceSurround(r, "", "") })
+	case "change":
+		if old == 0 {
+			// wait for the new delimiter
+			ed.surround, ed.surroundOld = cmd, r
+			return
+		}
+		open, close := delimiters(r)
+		ed.eachCursor(func() { ed.replaceSurround(old, open, close) })
+	}
+}
+
+// addSurround surrounds the texts rs with r and leaves the cursor on
+// the opening delimiter of the last one, that of the main cursor.
+func (ed *editor) addSurround(rs []motion.Range, r rune) {
+	if len(rs) == 0 {
+		return
+	}
+	v := ed.focus
+	open, close := delimiters(r)
+	var reps []buf.Replacement
+	for _, r := range rs {
+		// the closing delimiter first, so that it ends up behind the
+		// opening one if the text is empty
+		reps = append(reps,
+			buf.Replacement{Off1: r.Off2, Off2: r.Off2, Text: []byte(close)},
+			buf.Replacement{Off1: r.Off1, Off2: r.Off1, Text: []byte(open)})
+	}
+	if err := v.Buffer().ReplaceAll(reps); err != nil {
+		ed.setError(err)
+		return
+	}
+	last := rs[len(rs)-1]
+	off := last.Off1
+	for _, r := range rs[:len(rs)-1] {
+		if r.Off2 <= last.Off1 {
+			off += len(open) + len(close)
+		}
+	}
+	v.SetCursor(off)
+}
+
+// replaceSurround replaces the delimiters old around the cursor by open
+// and close, or deletes them if those are empty, and leaves the cursor
+// at the start of the text.
+func (ed *editor) replaceSurround(old rune, open, close string) {
+	v := ed.focus
+	b := v.Buffer()
+	around, ok1 := textobject.Delimited(old, true)(b, v.Cursor())
+	inner, ok2 := textobject.Delimited(old, false)(b, v.Cursor())
+	if !ok1 || !ok2 {
+		return
+	}
+	if _, ok := textobject.Brackets[old]; ok {
+		text := b.Bytes(inner.Off1, inner.Off2)
+		for len(text) > 0 && (text[0] == ' ' || text[0] == '\t') {
+			text = text[1:]
+			inner.Off1++
+		}
+		for len(text) > 0 && (text[len(text)-1] == ' ' || text[len(text)-1] == '\t') {
+			text = text[:len(text)-1]
+			inner.Off2--
+		}
+	}
+	err := b.ReplaceAll([]buf.Replacement{
+		{Off1: around.Off1, Off2: inner.Off1, Text: []byte(open)},
+		{Off1: inner.Off2, Off2: around.Off2, Text: []byte(close)},
+	})
+	if err != nil {
+		ed.setError(err)
+		return
+	}
+	v.SetCursor(around.Off1)
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestSurround(t *testing.T) {
+	tests := []keysTest{
+		// ys{motion}{char}
+		{"foo bar", "ysiw)", "(foo) bar", 0},
+		{"foo bar", "ysiw(", "( foo ) bar", 0},
+		{"foo bar", "wysiwb", "foo (bar)", 4},
+		{"foo bar", "ysiwB", "{foo} bar", 0},
+		{"foo bar", "ysiw\"", "\"foo\" bar", 0},
+		{"foo bar", "ysiwr", "[foo] bar", 0},
+		{"foo bar", "ysiwa", "<foo> bar", 0},
+		// yss leaves out the indentation and the line break
+		{"\tfoo bar\nx\n", "yss]", "\t[foo bar]\nx\n", 1},
+		// letters that don't name a delimiter cancel
+		{"foo bar", "ysiwx", "foo bar", 0},
+		// ds{char}
+		{"(foo) bar", "lds)", "foo bar", 0},
+		{"x( foo )", "4lds(", "xfoo", 1},
+		{"x( foo )", "4lds)", "x foo ", 1},
+		{"'a' b", "lds'", "a b", 0},
+		{"[a [b] c]", "4lds]", "[a b c]", 3},
+		{"no delimiters", "ds)", "no delimiters", 0},
+		// cs{old}{new}
+		{"(foo)", "lcs)]", "[foo]", 0},
+		{"(foo)", "lcs)[", "[ foo ]", 0},
+		{"( foo )", "3lcs()", "(foo)", 0},
+		{"\"foo\"", "lcs\"'", "'foo'", 0},
+		{"{a}", "lcsB>", "<a>", 0},
+		// each cursor surrounds its own word
+		{"ab x ab x ab", "<C-n><C-n>ysiw)", "(ab) x (ab) x (ab)", 0},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
@@ -0,0 +1,237 @@
+// Package swap protects the changes made to a buffer against crashes.
+//
+// A swap file holds a checksum of the buffer's contents at the time it
+// was last saved (or loaded) followed by a log of all changes made since
+// then.  As the changes only ever get appended, keeping the log up to
+// date is cheap, much like the buffer's own append only store.  After a
+// crash the changes can be replayed onto the file they were made to.
+//
+// The format is a header line "e swap 1 <crc32> <length>\n" followed by
+// records "i <off> <n>\n<n bytes>" for insertions and "d <off> <n>\n"
+// for deletions.
+package swap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+const header = "e swap 1"
+
+// flushEvery is the number of changes after which the log is written
+// to the swap file.  In between it is written by calling Flush, which
+// the editor does regularly.
+const flushEvery = 16
+
+// Path returns the name of the swap file for the file filename.
+func Path(filename string) string {
+	return filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".swp")
+}
+
+// Exists returns whether there is a swap file for filename, left
+// behind by a crashed editor or one still editing the file.
+func Exists(filename string) bool {
+	_, err := os.Stat(Path(filename))
+	return err == nil
+}
+
+// A File logs the changes made to a buffer to its swap file.  The swap
+// file is only created by the first change, so buffers that are just
+// looked at don't leave any files behind.
+type File struct {
+	buf     *buf.Buf
+	id      int // observer id
+	path    string
+	f       *os.File
+	w       *bufio.Writer
+	pending int   // changes not yet flushed
+	err     error // first error writing the swap file
+	// the checksum and length of the contents the log starts from, the
+	// checksum computed in the background (see start)
+	sum chan uint32
+	len int
+}
+
+// New returns a File logging the changes to b.  b must be named.
+func New(b *buf.Buf) *File {
+	s := &File{buf: b, path: Path(b.Name())}
+	s.id = b.AddObserver(s)
+	s.start()
+	return s
+}
+
+// start starts computing the checksum of the current contents of the
+// buffer, which the next log starts from.  It is computed from a
+// snapshot in the background, so that large buffers don't hold up the
+// first change.
+func (s *File) start() {
+	snap := s.buf.Snapshot()
+	sum := make(chan uint32, 1)
+	go func() {
+		h := crc32.NewIEEE()
+		snap.WriteTo(h)
+		sum <- h.Sum32()
+	}()
+	s.sum = sum
+	s.len = snap.Len()
+}
+
+// Recover replays the changes logged in the swap file of b onto b,
+// which must hold the contents the changes were made to.  A record cut
+// short by the crash is dropped.  Returns a File continuing the log.
+func Recover(b *buf.Buf) (*File, error) {
+	path := Path(b.Name())
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := replay(b, bufio.NewReader(f))
+	if err == nil {
+		// drop a trailing incomplete record and continue after the last
+		// complete one
+		err = f.Truncate(valid)
+	}
+	if err == nil {
+		_, err = f.Seek(valid, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &File{buf: b, path: path, f: f, w: bufio.NewWriter(f)}
+	s.id = b.AddObserver(s)
+	return s, nil
+}
+
+// replay applies the changes read from r to b and returns the length of
+// the part of r holding the header and the complete records.
+func replay(b *buf.Buf, r *bufio.Reader) (int64, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, errors.New("Invalid swap file")
+	}
+	valid := int64(len(line))
+	var sum uint32
+	var length int
+	if _, err := fmt.Sscanf(line, header+" %x %d\n", &sum, &length); err != nil {
+		return 0, errors.New("Invalid swap file")
+	}
+	if length != b.Len() || sum != checksum(b) {
+		return 0, errors.New("File changed since the swap file was written")
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			// end of the log, possibly cut short
+			return valid, nil
+		}
+		var op byte
+		var off, n int
+		if _, err := fmt.Sscanf(line, "%c %d %d\n", &op, &off, &n); err != nil {
+			return valid, nil
+		}
+		if off < 0 || n < 0 {
+			return valid, nil
+		}
+		switch op {
+		case 'i':
+			text := make([]byte, n)
+			if _, err := io.ReadFull(r, text); err != nil || b.TryInsert(off, text) != nil {
+				return valid, nil
+			}
+			valid += int64(len(line) + n)
+		case 'd':
+			if b.TryDelete(off, off+n) != nil {
+				return valid, nil
+			}
+			valid += int64(len(line))
+		default:
+			return valid, nil
+		}
+	}
+}
+
+// checksum returns the crc32 of the contents of b.
+func checksum(b *buf.Buf) uint32 {
+	h := crc32.NewIEEE()
+	b.WriteTo(h)
+	return h.Sum32()
+}
+
+// create creates the swap file starting with the contents of the
+// buffer when start was called.
+func (s *File) create() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.w = bufio.NewWriter(f)
+	_, err = fmt.Fprintf(s.w, "%s %08x %d\n", header, <-s.sum, s.len)
+	return err
+}
+
+func (s *File) OnBufChange(c buf.Change) {
+	if s.err != nil {
+		return
+	}
+	if s.f == nil {
+		// the first change since start, so the buffer still holds the
+		// contents the log starts from
+		if s.err = s.create(); s.err != nil {
+			return
+		}
+	}
+	if c.Deleted > 0 {
+		fmt.Fprintf(s.w, "d %d %d\n", c.Off, c.Deleted)
+	}
+	if len(c.Inserted) > 0 {
+		fmt.Fprintf(s.w, "i %d %d\n", c.Off, len(c.Inserted))
+		s.w.Write(c.Inserted)
+	}
+	s.pending++
+	if s.pending >= flushEvery {
+		s.Flush()
+	}
+}
+
+// Flush writes the changes logged so far to the swap file.
+func (s *File) Flush() error {
+	if s.err == nil && s.f != nil {
+		s.err = s.w.Flush()
+		s.pending = 0
+	}
+	return s.err
+}
+
+// Saved removes the swap file after the buffer has been saved.  The
+// next change starts a new one.
+func (s *File) Saved() {
+	s.remove()
+	s.err = nil
+	s.start()
+}
+
+// Close stops logging the changes of the buffer and removes the swap
+// file.
+func (s *File) Close() {
+	s.buf.RemoveObserver(s.id)
+	s.remove()
+}
+
+func (s *File) remove() {
+	if s.f != nil {
+		s.f.Close()
+		os.Remove(s.path)
+		s.f = nil
+		s.w = nil
+		s.pending = 0
+	}
+}
@@ -0,0 +1,82 @@
+package swap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func load(t *testing.T, name string) *buf.Buf {
+	var b buf.Buf
+	if err := b.InitFromFile(name); err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+func TestRecover(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(name, []byte("hello world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := load(t, name)
+	s := New(b)
+	if Exists(name) {
+		t.Fatal("swap file created before the first change")
+	}
+	b.Insert(5, []byte(","))
+	b.Delete(7, 12)
+	b.Insert(7, []byte("there"))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// crash: the swap file stays behind, with a record cut short
+	f, err := os.OpenFile(Path(name), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("i 0 10\nabc")
+	f.Close()
+
+	b2 := load(t, name)
+	s2, err := Recover(b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b2.String(); got != "hello, there\n" {
+		t.Errorf("recovered %q", got)
+	}
+	// the log continues after the last complete record
+	b2.Insert(0, []byte(">"))
+	s2.Flush()
+	b3 := load(t, name)
+	if _, err := Recover(b3); err != nil {
+		t.Fatal(err)
+	}
+	if got := b3.String(); got != ">hello, there\n" {
+		t.Errorf("recovered %q after continuing", got)
+	}
+	s2.Close()
+	if Exists(name) {
+		t.Error("Close didn't remove the swap file")
+	}
+}
+
+func TestRecoverChangedFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(name, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := load(t, name)
+	s := New(b)
+	b.Insert(0, []byte("x"))
+	s.Flush()
+	if err := os.WriteFile(name, []byte("other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Recover(load(t, name)); err == nil {
+		t.Error("recovered onto a different file")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/view"
+	"github.com/nsf/termbox-go"
+)
+
+// A tabPage holds a window layout of its own.  Only the windows of the
+// current tab page are shown.
+type tabPage struct {
+	layout *view.Layout
+	focus  *view.View // as of the last time the tab page was left
+}
+
+// windowArea returns the first row and the number of rows used by the
+// windows on a screen h rows high.  The first row shows the tab pages
+// if there are several, the last one the command line and messages.
+func (ed *editor) windowArea(h int) (y, rows int) {
+	if len(ed.tabs) > 1 {
+		return 1, h - 2
+	}
+	return 0, h - 1
+}
+
+// gotoTab makes tab page n (counting from 0) the current one.
+func (ed *editor) gotoTab(n int) {
+	if n == ed.tab || n < 0 || n >= len(ed.tabs) {
+		return
+	}
+	ed.tabs[ed.tab].focus = ed.focus
+	ed.tab = n
+	ed.layout = ed.tabs[n].layout
+	ed.setFocus(ed.tabs[n].focus)
+	// the windows don't know that the screen shows another tab page
+	ed.invalidateWindows()
+	ed.relayout()
+}
+
+// nextTab goes count tab pages forward, or backward if count is
+// negative, wrapping around at the last (first) one.
+func (ed *editor) nextTab(count int) {
+	n := len(ed.tabs)
+	ed.gotoTab(((ed.tab+count)%n + n) % n)
+}
+
+// newTab adds a tab page after the current one with a single window
+// showing b and makes it the current one.
+func (ed *editor) newTab(b *buf.Buf) {
+	var v view.View
+	v.Init(b)
+	ed.showBuffer(&v, b)
+	tab := tabPage{view.NewLayout(&v), &v}
+	i := ed.tab + 1
+	ed.tabs = append(ed.tabs[:i], append([]tabPage{tab}, ed.tabs[i:]...)...)
+	ed.gotoTab(i)
+}
+
+// closeTab closes the current tab page with all its windows.  The last
+// tab page can't be closed, neither can one holding the last window
+// showing a modified buffer unless force is set.
+func (ed *editor) closeTab(force bool) error {
+	if len(ed.tabs) == 1 {
+		return errors.New("Cannot close last tab page")
+	}
+	var views []*view.View
+	ed.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+		views = append(views, leaf.View())
+	})
+	if !force {
+		for _, v := range views {
+			b := v.Buffer()
+			inTab := 0
+			for _, other := range views {
+				if other.Buffer() == b {
+					inTab++
+				}
+			}
+			if ed.unsaved(b) && ed.windowsShowing(b) == inTab {
+				return errors.New("No write since last change (add ! to override)")
+			}
+		}
+	}
+	closed := ed.tab
+	ed.gotoTab((closed + 1) % len(ed.tabs))
+	ed.tabs = append(ed.tabs[:closed], ed.tabs[closed+1:]...)
+	if ed.tab > closed {
+		ed.tab--
+	}
+	for _, v := range views {
+		ed.hide(v)
+		v.Close()
+		delete(ed.winOptions, v)
+	}
+	// the tab bar may have gone
+	ed.relayout()
+	return nil
+}
+
+// displayTabs draws the tab bar in the first row of a screen w columns
+// wide, labelling each tab page with the buffer of its focused window.
+func (ed *editor) displayTabs(w int) {
+	const coldef = termbox.ColorDefault
+	x := 0
+	put := func(r rune, fg, bg termbox.Attribute) {
+		if x < w {
+			ed.screen.SetCell(x, 0, r, fg, bg)
+		}
+		x++
+	}
+	for i, t := range ed.tabs {
+		focus := t.focus
+		if i == ed.tab {
+			focus = ed.focus
+		}
+		fg := coldef
+		if i == ed.tab {
+			fg |= termbox.AttrReverse
+		}
+		label := "[No Name]"
+		if name := focus.Buffer().Name(); name != "" {
+			label = filepath.Base(name)
+		}
+		if focus.Buffer().Dirty() {
+			label += " +"
+		}
+		for _, r := range fmt.Sprintf(" %d %s ", i+1, label) {
+			put(r, fg, coldef)
+		}
+	}
+	for x < w {
+		put(' ', coldef, coldef)
+	}
+}
+
+// :tabnew [file] and :tabe[dit] [file] edit file (a new buffer if
+// none) in a new tab page.
+func (ed *editor) cmdTabNew(cmd ex.Command) error {
+	b, err := ed.loadBuffer(cmd.Arg)
+	if err != nil {
+		return err
+	}
+	ed.newTab(b)
+	return nil
+}
+
+// :tabc[lose] closes the current tab page.
+func (ed *editor) cmdTabClose(cmd ex.Command) error {
+	return ed.closeTab(cmd.Bang)
+}
+
+// :tabn[ext] and :tabp[revious] go to the next or previous tab page.
+func (ed *editor) cmdTabNext(dir int) func(cmd ex.Command) error {
+	return func(cmd ex.Command) error {
+		ed.nextTab(dir)
+		return nil
+	}
+}
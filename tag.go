@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/tags"
+	"github.com/bgrundmann/e/textobject"
+)
+
+// defaultTags is where the tag files are looked for unless set otherwise
+// with :set tags: names separated by commas, those starting with ./
+// relative to the directory of the current file.
+const defaultTags = "./tags,tags"
+
+// tagState holds the tag files read and the tag last jumped to.
+type tagState struct {
+	files map[string]*tagFile // by name
+	last  string
+}
+
+// A tagFile is a tag file as read.
+type tagFile struct {
+	stamp fileStamp
+	f     *tags.File
+}
+
+// tagFileNames returns the names of the tag files, see defaultTags.
+func (ed *editor) tagFileNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(ed.options.String("tags", nil, nil), ",") {
+		if rest, ok := strings.CutPrefix(name, "./"); ok {
+			name = filepath.Join(filepath.Dir(ed.focus.Buffer().Name()), rest)
+		}
+		if name = filepath.Clean(name); name != "." && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names
+}
+
+// lookupTag returns the tags named name in all the tag files.  The tag
+// files are read again once changed.
+func (ed *editor) lookupTag(name string) ([]tags.Tag, error) {
+	if ed.tags.files == nil {
+		ed.tags.files = make(map[string]*tagFile)
+	}
+	var found []tags.Tag
+	read := false
+	for _, file := range ed.tagFileNames() {
+		stamp, ok := stampOf(file)
+		if !ok {
+			continue
+		}
+		read = true
+		tf := ed.tags.files[file]
+		if tf == nil || tf.stamp != stamp {
+			f, err := tags.Read(file)
+			if err != nil {
+				return nil, err
+			}
+			tf = &tagFile{stamp, f}
+			ed.tags.files[file] = tf
+		}
+		found = append(found, tf.f.Lookup(name)...)
+	}
+	if !read {
+		return nil, errors.New("No tags file")
+	}
+	if found == nil {
+		return nil, fmt.Errorf("Tag not found: %s", name)
+	}
+	return found, nil
+}
+
+// jumpToTag goes to the first definition of name, like <C-]>.  If
+// there are several, the message tells how many.
+func (ed *editor) jumpToTag(name string) error {
+	found, err := ed.lookupTag(name)
+	if err != nil {
+		return err
+	}
+	ed.tags.last = name
+	if err := ed.gotoTag(found[0]); err != nil {
+		return err
+	}
+	if len(found) > 1 {
+		ed.setMessage(fmt.Sprintf("tag 1 of %d, :tselect to choose another", len(found)))
+	}
+	return nil
+}
+
+// gotoTag shows the file of t in the current window with the cursor at
+// its address.  Where the cursor was is pushed to the jump list.
+func (ed *editor) gotoTag(t tags.Tag) error {
+	b, err := ed.loadBuffer(t.File)
+	if err != nil {
+		return err
+	}
+	line, ok := t.Find(b.Bytes(0, b.Len()))
+	if !ok {
+		return fmt.Errorf("Can't find tag pattern: %s", t.Pattern)
+	}
+	if line > b.Lines() {
+		line = b.Lines()
+	}
+	if err := ed.switchBuffer(b, false); err != nil {
+		return err
+	}
+	v := ed.focus
+	v.PushJump(v.Cursor())
+	v.SetCursor(b.Line(line))
+	ed.showCursor()
+	return nil
+}
+
+// gotoTagUnderCursor jumps to the definition of the identifier under
+// the cursor.
+func (ed *editor) gotoTagUnderCursor() error {
+	v := ed.focus
+	r, ok := textobject.InnerWord(v.Buffer(), v.Cursor())
+	word := strings.TrimSpace(string(v.Buffer().Bytes(r.Off1, r.Off2)))
+	if !ok || word == "" {
+		return errors.New("No identifier under cursor")
+	}
+	return ed.jumpToTag(word)
+}
+
+// :ta[g] name jumps to the definition of name, the first one if there
+// are several.  Without a name it jumps to the tag last jumped to again.
+func (ed *editor) cmdTag(cmd ex.Command) error {
+	name := cmd.Arg
+	if name == "" {
+		if name = ed.tags.last; name == "" {
+			return errors.New("No tag name")
+		}
+	}
+	return ed.jumpToTag(name)
+}
+
+// :ts[elect] [name] opens a picker choosing among the definitions of
+// name, by default of the tag last jumped to.
+func (ed *editor) cmdTselect(cmd ex.Command) error {
+	name := cmd.Arg
+	if name == "" {
+		if name = ed.tags.last; name == "" {
+			return errors.New("No tag name")
+		}
+	}
+	found, err := ed.lookupTag(name)
+	if err != nil {
+		return err
+	}
+	ed.tags.last = name
+	items := make([]string, len(found))
+	for i, t := range found {
+		items[i] = fmt.Sprintf("%d %-2s %s %s", i+1, t.Kind, t.File, t.Address())
+	}
+	ed.startPicker("Tag "+name+": ", items, func(i int) {
+		if err := ed.gotoTag(found[i]); err != nil {
+			ed.setError(err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package tags reads the tag files written by ctags, which tell where
+// the identifiers of a program are defined: for each a line
+//
+//	name<Tab>file<Tab>address;"<Tab>kind<Tab>field:value...
+//
+// where the address is a line number or a search pattern like
+// /^func main() {$/ finding the line, and the part from ;" on is
+// optional.
+package tags
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A Tag is where an identifier is defined.
+type Tag struct {
+	Name    string
+	File    string // relative to the directory of the tag file unless absolute
+	Line    int    // if the address is a line number, else 0
+	Pattern string // the search pattern of the address without the delimiters
+	Kind    string // e.g. "f" for a function, "" if not known
+}
+
+// Address returns the address of t as written in the tag file.
+func (t Tag) Address() string {
+	if t.Line > 0 {
+		return strconv.Itoa(t.Line)
+	}
+	return "/" + t.Pattern + "/"
+}
+
+// Find returns the line of text, starting at 1, at the address of t.
+// Patterns are matched literally, only ^ and $ at their ends anchor
+// them and backslash quotes the next character.
+func (t Tag) Find(text []byte) (int, bool) {
+	if t.Line > 0 {
+		return t.Line, true
+	}
+	p := t.Pattern
+	start := strings.HasPrefix(p, "^")
+	p = strings.TrimPrefix(p, "^")
+	end := strings.HasSuffix(p, "$") && !strings.HasSuffix(p, `\$`)
+	if end {
+		p = p[:len(p)-1]
+	}
+	lit := []byte(unquote(p))
+	for i, line := range bytes.Split(text, []byte{'\n'}) {
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		var ok bool
+		switch {
+		case start && end:
+			ok = bytes.Equal(line, lit)
+		case start:
+			ok = bytes.HasPrefix(line, lit)
+		case end:
+			ok = bytes.HasSuffix(line, lit)
+		default:
+			ok = bytes.Contains(line, lit)
+		}
+		if ok {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// unquote removes the backslashes quoting characters in s.
+func unquote(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// Parse returns the tags read from r, the contents of a tag file.  The
+// lines starting with !_TAG_, which tell about the file, are skipped.
+func Parse(r io.Reader) ([]Tag, error) {
+	var tags []Tag
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "!_TAG_") {
+			continue
+		}
+		t, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, sc.Err()
+}
+
+// parseLine parses a line of a tag file.
+func parseLine(line string) (Tag, error) {
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) < 3 || fields[0] == "" {
+		return Tag{}, fmt.Errorf("invalid tag %q", line)
+	}
+	t := Tag{Name: fields[0], File: fields[1]}
+	addr := fields[2]
+	var rest string
+	if addr != "" && (addr[0] == '/' || addr[0] == '?') {
+		// the pattern may contain tabs, it ends at the delimiter
+		i := 1
+		for i < len(addr) && addr[i] != addr[0] {
+			if addr[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(addr) {
+			return Tag{}, fmt.Errorf("unterminated pattern in %q", line)
+		}
+		t.Pattern, rest = addr[1:i], addr[i+1:]
+	} else {
+		i := 0
+		for i < len(addr) && '0' <= addr[i] && addr[i] <= '9' {
+			i++
+		}
+		n, err := strconv.Atoi(addr[:i])
+		if err != nil || n == 0 {
+			return Tag{}, fmt.Errorf("invalid address in %q", line)
+		}
+		t.Line, rest = n, addr[i:]
+	}
+	rest, ok := strings.CutPrefix(rest, `;"`)
+	if !ok {
+		return t, nil
+	}
+	for _, f := range strings.Split(strings.TrimPrefix(rest, "\t"), "\t") {
+		// the kind comes as the only field without a name or as kind:
+		if k, ok := strings.CutPrefix(f, "kind:"); ok {
+			t.Kind = k
+		} else if f != "" && !strings.Contains(f, ":") {
+			t.Kind = f
+		}
+	}
+	return t, nil
+}
+
+// A File is the tags of a tag file by name.
+type File struct {
+	Dir  string // the directory of the tag file
+	tags map[string][]Tag
+}
+
+// Read reads the tag file name.
+func Read(name string) (*File, error) {
+	r, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	tags, err := Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	f := &File{Dir: filepath.Dir(name), tags: make(map[string][]Tag)}
+	for _, t := range tags {
+		f.tags[t.Name] = append(f.tags[t.Name], t)
+	}
+	return f, nil
+}
+
+// Lookup returns the tags named name in the order of the tag file,
+// with the file names made relative to the current directory.
+func (f *File) Lookup(name string) []Tag {
+	var found []Tag
+	for _, t := range f.tags[name] {
+		if !filepath.IsAbs(t.File) {
+			t.File = filepath.Join(f.Dir, filepath.FromSlash(t.File))
+		}
+		found = append(found, t)
+	}
+	return found
+}
@@ -0,0 +1,84 @@
+package tags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tagFile = "!_TAG_FILE_FORMAT\t2\t/extended format/\n" +
+	"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted/\n" +
+	"Buf\tbuf/buf.go\t/^type Buf struct {$/;\"\tt\n" +
+	"Init\tbuf/buf.go\t/^func (b *Buf) Init() *Buf {$/;\"\tkind:f\tclass:Buf\n" +
+	"Init\tview/view.go\t/^func (v *View) Init(b *buf.Buf) {$/;\"\tf\n" +
+	"main\te.go\t42;\"\tf\n" +
+	"path\tx.go\t/^var path = \"a\\/b\"$/\n" +
+	"odd\tx.go\t?\tfoo?\n"
+
+func TestParse(t *testing.T) {
+	tags, err := Parse(strings.NewReader(tagFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tag{
+		{Name: "Buf", File: "buf/buf.go", Pattern: "^type Buf struct {$", Kind: "t"},
+		{Name: "Init", File: "buf/buf.go", Pattern: "^func (b *Buf) Init() *Buf {$", Kind: "f"},
+		{Name: "Init", File: "view/view.go", Pattern: "^func (v *View) Init(b *buf.Buf) {$", Kind: "f"},
+		{Name: "main", File: "e.go", Line: 42, Kind: "f"},
+		{Name: "path", File: "x.go", Pattern: `^var path = "a\/b"$`},
+		{Name: "odd", File: "x.go", Pattern: "\tfoo"},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("got %+v\nwant %+v", tags, want)
+	}
+	for _, bad := range []string{"x\ty.go", "x\ty.go\t/abc", "x\ty.go\tabc"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("no error for %q", bad)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	text := []byte("package x\n\nvar path = \"a/b\"\nfunc f() {\n}\nvar pathological\n")
+	tests := []struct {
+		pattern string
+		line    int
+		ok      bool
+	}{
+		{`^var path = "a\/b"$`, 3, true},
+		{`^func f`, 4, true},
+		{`logical$`, 6, true},
+		{`path`, 3, true},
+		{`^var path$`, 0, false},
+	}
+	for _, test := range tests {
+		line, ok := Tag{Pattern: test.pattern}.Find(text)
+		if line != test.line || ok != test.ok {
+			t.Errorf("Find(%q) = %d, %v expected %d, %v", test.pattern, line, ok, test.line, test.ok)
+		}
+	}
+	if line, ok := (Tag{Line: 7}).Find(text); line != 7 || !ok {
+		t.Errorf("Find of line 7 = %d, %v", line, ok)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "tags")
+	if err := os.WriteFile(name, []byte(tagFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := f.Lookup("Init")
+	if len(found) != 2 || found[0].File != filepath.Join(dir, "buf", "buf.go") || found[1].File != filepath.Join(dir, "view", "view.go") {
+		t.Errorf("Lookup(Init) = %+v", found)
+	}
+	if found := f.Lookup("missing"); found != nil {
+		t.Errorf("Lookup(missing) = %+v", found)
+	}
+}
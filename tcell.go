@@ -0,0 +1,11 @@
+//go:build tcell
+
+package main
+
+import "github.com/bgrundmann/e/screen"
+
+func init() {
+	terminals["tcell"] = func() (screen.Terminal, error) {
+		return screen.NewTcell()
+	}
+}
@@ -0,0 +1,278 @@
+// Package textobject finds the text around an offset that operators
+// like d or c act on in vim's iw, a", i( ... (see :help text-objects).
+// Unlike motions text objects don't move the cursor, they return the
+// range of text they cover.
+package textobject
+
+import (
+	"unicode"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+)
+
+// An Object returns the range of text it covers around off.
+// Returns false if there is no such text (e.g. no quotes around off).
+type Object func(b *buf.Buf, off int) (motion.Range, bool)
+
+// runeAt returns the rune starting at off.
+func runeAt(b *buf.Buf, off int) (rune, bool) {
+	r, _, err := b.NewReader(off).ReadRune()
+	return r, err == nil
+}
+
+// extend returns the largest range around off (which must be the start
+// of a rune) such that all its runes satisfy pred.  The rune at off
+// itself isn't checked.
+func extend(b *buf.Buf, off int, pred func(rune) bool) (start, end int) {
+	start = off
+	rd := b.NewReader(off)
+	rd.Reverse()
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil || !pred(r) {
+			break
+		}
+		start = rd.Offset()
+	}
+	rd = b.NewReader(off)
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil || !pred(r) {
+			break
+		}
+		end = rd.Offset()
+	}
+	return start, end
+}
+
+// Character classes of words.  Unlike for the word motions line breaks
+// end a word object.
+const (
+	classBlank = iota
+	classNewline
+	classPunct
+	classWord
+)
+
+func runeClass(r rune) int {
+	switch {
+	case r == '\n':
+		return classNewline
+	case r == ' ' || r == '\t':
+		return classBlank
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return classWord
+	default:
+		return classPunct
+	}
+}
+
+func isClass(class int) func(rune) bool {
+	return func(r rune) bool {
+		return runeClass(r) == class
+	}
+}
+
+func word(around bool) Object {
+	return func(b *buf.Buf, off int) (motion.Range, bool) {
+		r, ok := runeAt(b, off)
+		if !ok || r == '\n' {
+			return motion.Range{}, false
+		}
+		class := runeClass(r)
+		start, end := extend(b, off, isClass(class))
+		if !around {
+			return motion.Range{Off1: start, Off2: end}, true
+		}
+		if class == classBlank {
+			// the blanks and the following word
+			if r, ok := runeAt(b, end); ok && r != '\n' {
+				_, end = extend(b, end, isClass(runeClass(r)))
+			}
+			return motion.Range{Off1: start, Off2: end}, true
+		}
+		// the word and the following blanks, or the preceding ones
+		// if there are none
+		if r, ok := runeAt(b, end); ok && runeClass(r) == classBlank {
+			_, end = extend(b, end, isClass(classBlank))
+		} else {
+			rd := b.NewReader(start)
+			rd.Reverse()
+			if r, _, err := rd.ReadRune(); err == nil && runeClass(r) == classBlank {
+				start, _ = extend(b, rd.Offset(), isClass(classBlank))
+			}
+		}
+		return motion.Range{Off1: start, Off2: end}, true
+	}
+}
+
+var (
+	// InnerWord is the word (or run of blanks) under the cursor (iw).
+	InnerWord = word(false)
+	// AWord is the word with the blanks following it (aw).
+	AWord = word(true)
+)
+
+// Quote returns the object for text quoted with q in the line of the
+// cursor (i", a").  Quotes preceded by a backslash don't count.
+// The inner object excludes the quotes.
+func Quote(q rune, around bool) Object {
+	return func(b *buf.Buf, off int) (motion.Range, bool) {
+		lineStart, lineEnd := b.LineRange(b.LineOfOffset(off))
+		// offsets of the quotes of the line
+		var quotes []int
+		rd := b.NewReader(lineStart)
+		escaped := false
+		for rd.Offset() < lineEnd {
+			qoff := rd.Offset()
+			r, _, err := rd.ReadRune()
+			if err != nil {
+				break
+			}
+			if r == q && !escaped {
+				quotes = append(quotes, qoff)
+			}
+			escaped = r == '\\' && !escaped
+		}
+		// quotes pair up from the start of the line, take the pair
+		// around the cursor or the first one after it
+		for i := 0; i+1 < len(quotes); i += 2 {
+			start, end := quotes[i], quotes[i+1]
+			if end < off {
+				continue
+			}
+			if around {
+				return motion.Range{Off1: start, Off2: end + len(string(q))}, true
+			}
+			return motion.Range{Off1: start + len(string(q)), Off2: end}, true
+		}
+		return motion.Range{}, false
+	}
+}
+
+// Block returns the object for text enclosed by open and close, e.g.
+// ( and ) (i(, a().  Nested blocks are skipped.
+func Block(open, close rune, around bool) Object {
+	return func(b *buf.Buf, off int) (motion.Range, bool) {
+		start := -1
+		if r, ok := runeAt(b, off); ok && r == open {
+			start = off
+		} else {
+			rd := b.NewReader(off)
+			rd.Reverse()
+			depth := 0
+			for start < 0 {
+				r, _, err := rd.ReadRune()
+				if err != nil {
+					return motion.Range{}, false
+				}
+				switch {
+				case r == close:
+					depth++
+				case r == open && depth == 0:
+					start = rd.Offset()
+				case r == open:
+					depth--
+				}
+			}
+		}
+		rd := b.NewReader(start)
+		rd.ReadRune()
+		depth := 0
+		for {
+			end := rd.Offset()
+			r, _, err := rd.ReadRune()
+			if err != nil {
+				return motion.Range{}, false
+			}
+			switch {
+			case r == open:
+				depth++
+			case r == close && depth == 0:
+				if around {
+					return motion.Range{Off1: start, Off2: rd.Offset()}, true
+				}
+				return motion.Range{Off1: start + len(string(open)), Off2: end}, true
+			case r == close:
+				depth--
+			}
+		}
+	}
+}
+
+// Brackets maps the opening brackets to the closing ones.
+var Brackets = map[rune]rune{'(': ')', '[': ']', '{': '}', '<': '>'}
+
+// Delimited returns the object for text enclosed by the delimiter r:
+// a Block if r is one of the Brackets, opening or closing, a Quote
+// otherwise.
+func Delimited(r rune, around bool) Object {
+	for open, close := range Brackets {
+		if r == open || r == close {
+			return Block(open, close, around)
+		}
+	}
+	return Quote(r, around)
+}
+
+// isBlankLine returns true if line n consists of blanks only.
+func isBlankLine(b *buf.Buf, n int) bool {
+	rd := b.NewReader(b.Line(n))
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil || r == '\n' {
+			return true
+		}
+		if r != ' ' && r != '\t' {
+			return false
+		}
+	}
+}
+
+// lineRange returns the linewise range of lines first to last.
+func lineRange(b *buf.Buf, first, last int) motion.Range {
+	r := motion.Range{Off1: b.Line(first), Off2: b.Len(), Linewise: true}
+	if last < b.Lines() {
+		r.Off2 = b.Line(last + 1)
+	}
+	return r
+}
+
+func paragraph(around bool) Object {
+	return func(b *buf.Buf, off int) (motion.Range, bool) {
+		pos, err := b.PositionFromOffset(off)
+		if err != nil {
+			return motion.Range{}, false
+		}
+		// the run of lines that are blank, or not, like the cursor line
+		run := func(n int, blank bool) (first, last int) {
+			first, last = n, n
+			for first > 1 && isBlankLine(b, first-1) == blank {
+				first--
+			}
+			for last < b.Lines() && isBlankLine(b, last+1) == blank {
+				last++
+			}
+			return first, last
+		}
+		blank := isBlankLine(b, pos.Line)
+		first, last := run(pos.Line, blank)
+		if around {
+			if last < b.Lines() {
+				_, last = run(last+1, !blank)
+			} else if first > 1 {
+				first, _ = run(first-1, !blank)
+			}
+		}
+		return lineRange(b, first, last), true
+	}
+}
+
+var (
+	// InnerParagraph are the lines of the paragraph (ip), or the blank
+	// lines if the cursor is on one.
+	InnerParagraph = paragraph(false)
+	// AParagraph is the paragraph with the blank lines following it (ap).
+	AParagraph = paragraph(true)
+)
@@ -0,0 +1,60 @@
+package textobject
+
+import (
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/motion"
+)
+
+func TestObjects(t *testing.T) {
+	const s = "foo bar_1  baz\nx := f(a, (b), \"q \\\" r\")\n\nl1\nl2\n\n\nl3"
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte(s))
+	tests := []struct {
+		name string
+		o    Object
+		off  int
+		want string
+		ok   bool
+	}{
+		{"iw", InnerWord, 5, "bar_1", true},
+		{"aw", AWord, 5, "bar_1  ", true},
+		{"aw", AWord, 12, "  baz", true},
+		{"iw", InnerWord, 9, "  ", true},
+		{"aw", AWord, 9, "  baz", true},
+		{"iw", InnerWord, 14, "", false},
+		{`i"`, Quote('"', false), 30, `q \" r`, true},
+		{`a"`, Quote('"', true), 30, `"q \" r"`, true},
+		{`i"`, Quote('"', false), 15, `q \" r`, true},
+		{`i"`, Quote('"', false), 0, "", false},
+		{"i(", Block('(', ')', false), 17, "", false},
+		{"i(", Block('(', ')', false), 23, `a, (b), "q \" r"`, true},
+		{"a(", Block('(', ')', true), 22, `(a, (b), "q \" r")`, true},
+		{"i(", Block('(', ')', false), 27, "b", true},
+		{"a(", Block('(', ')', true), 25, "(b)", true},
+		{"a)", Delimited(')', true), 25, "(b)", true},
+		{`i"`, Delimited('"', false), 30, `q \" r`, true},
+		{"ip", InnerParagraph, 43, "l1\nl2\n", true},
+		{"ap", AParagraph, 41, "l1\nl2\n\n\n", true},
+		{"ip", InnerParagraph, 47, "\n\n", true},
+		{"ap", AParagraph, 50, "\n\nl3", true},
+	}
+	for _, test := range tests {
+		r, ok := test.o(&b, test.off)
+		if ok != test.ok {
+			t.Errorf("%s at %d: got ok %v", test.name, test.off, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got := string(b.Bytes(r.Off1, r.Off2)); got != test.want {
+			t.Errorf("%s at %d: got %q want %q", test.name, test.off, got, test.want)
+		}
+	}
+	if r, _ := InnerParagraph(&b, 0); r != (motion.Range{Off1: 0, Off2: 40, Linewise: true}) {
+		t.Errorf("ip at 0: got %v", r)
+	}
+}
@@ -0,0 +1,212 @@
+// Package theme names the elements of the user interface and maps them
+// to the attributes they are drawn with.
+//
+// Styles are written as a foreground, optionally followed by "on" and a
+// background, each a color and attributes separated by blanks, e.g.
+// "yellow bold" or "black on white".
+package theme
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Element is a part of the user interface drawn in its own style.
+type Element int
+
+const (
+	Normal Element = iota
+	// syntax highlighting
+	Keyword
+	Type
+	String
+	Number
+	Comment
+	StatusLine
+	LineNumber
+	Selection
+	SearchMatch
+	Diagnostic        // text a diagnostic is about
+	DiagnosticError   // sign of an error in the gutter
+	DiagnosticWarning // sign of a warning in the gutter
+	DiagnosticInfo    // sign of other diagnostics in the gutter
+	ErrorMessage
+	ExtraCursor // cursors besides the main one
+	Popup       // lists drawn over the windows, like completions
+	Fold        // row shown for a closed fold
+	Whitespace  // tabs and blanks shown with the list chars
+	ColorColumn // column marking where lines get too long
+	DiffAdded   // sign of a line added since the file was saved
+	DiffChanged // sign of a changed line
+	DiffDeleted // sign of where lines were deleted
+)
+
+// names are the names of the elements in config files.
+var names = map[Element]string{
+	Normal:            "normal",
+	Keyword:           "keyword",
+	Type:              "type",
+	String:            "string",
+	Number:            "number",
+	Comment:           "comment",
+	StatusLine:        "status-line",
+	LineNumber:        "line-number",
+	Selection:         "selection",
+	SearchMatch:       "search-match",
+	Diagnostic:        "diagnostic",
+	DiagnosticError:   "diagnostic-error",
+	DiagnosticWarning: "diagnostic-warning",
+	DiagnosticInfo:    "diagnostic-info",
+	ErrorMessage:      "error-message",
+	ExtraCursor:       "extra-cursor",
+	Popup:             "popup",
+	Fold:              "fold",
+	Whitespace:        "whitespace",
+	ColorColumn:       "color-column",
+	DiffAdded:         "diff-added",
+	DiffChanged:       "diff-changed",
+	DiffDeleted:       "diff-deleted",
+}
+
+func (e Element) String() string {
+	return names[e]
+}
+
+// Style is the foreground and background attribute an element is drawn
+// with.
+type Style struct {
+	Fg, Bg termbox.Attribute
+}
+
+// attributes are the bits of an Attribute that aren't the color.
+const attributes = termbox.AttrBold | termbox.AttrUnderline | termbox.AttrReverse | termbox.AttrDim
+
+// Over returns s drawn over base, as a selection is drawn over text: the
+// colors of s replace those of base unless they are the default, the
+// attributes are added to those of base.
+func (s Style) Over(base Style) Style {
+	over := func(a, base termbox.Attribute) termbox.Attribute {
+		if a&^attributes == termbox.ColorDefault {
+			return base | a
+		}
+		return base&attributes | a
+	}
+	return Style{over(s.Fg, base.Fg), over(s.Bg, base.Bg)}
+}
+
+var colors = map[string]termbox.Attribute{
+	"default": termbox.ColorDefault,
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+var attributeNames = map[string]termbox.Attribute{
+	"bold":      termbox.AttrBold,
+	"underline": termbox.AttrUnderline,
+	"reverse":   termbox.AttrReverse,
+	"dim":       termbox.AttrDim,
+}
+
+// ParseStyle parses a style as written in config files.
+func ParseStyle(s string) (Style, error) {
+	var style Style
+	a := &style.Fg
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		if word == "on" && a == &style.Fg {
+			a = &style.Bg
+		} else if c, ok := colors[word]; ok {
+			*a = *a&attributes | c
+		} else if attr, ok := attributeNames[word]; ok {
+			*a |= attr
+		} else {
+			return Style{}, fmt.Errorf("unknown color or attribute %q", word)
+		}
+	}
+	return style, nil
+}
+
+// A Theme maps the elements to their styles.
+type Theme map[Element]Style
+
+// Default returns the theme used unless the user configures another.
+func Default() Theme {
+	const coldef = termbox.ColorDefault
+	return Theme{
+		Normal:            {coldef, coldef},
+		Keyword:           {termbox.ColorYellow, coldef},
+		Type:              {termbox.ColorGreen, coldef},
+		String:            {termbox.ColorRed, coldef},
+		Number:            {termbox.ColorMagenta, coldef},
+		Comment:           {termbox.ColorCyan, coldef},
+		StatusLine:        {coldef | termbox.AttrReverse, coldef},
+		LineNumber:        {termbox.ColorYellow, coldef},
+		Selection:         {coldef | termbox.AttrReverse, coldef},
+		SearchMatch:       {coldef | termbox.AttrReverse, coldef},
+		Diagnostic:        {coldef | termbox.AttrUnderline, coldef},
+		DiagnosticError:   {termbox.ColorRed, coldef},
+		DiagnosticWarning: {termbox.ColorYellow, coldef},
+		DiagnosticInfo:    {termbox.ColorCyan, coldef},
+		ErrorMessage:      {termbox.ColorRed, coldef},
+		ExtraCursor:       {coldef | termbox.AttrReverse, coldef},
+		Popup:             {termbox.ColorBlack, termbox.ColorWhite},
+		Fold:              {termbox.ColorBlue, coldef},
+		Whitespace:        {termbox.ColorBlue, coldef},
+		ColorColumn:       {coldef, termbox.ColorRed},
+		DiffAdded:         {termbox.ColorGreen, coldef},
+		DiffChanged:       {termbox.ColorYellow, coldef},
+		DiffDeleted:       {termbox.ColorRed, coldef},
+	}
+}
+
+// Current is the theme the user interface is drawn with.
+var Current = Default()
+
+// Load sets the styles read from r in t.  r must contain a JSON object
+// mapping names of elements to styles, e.g.
+//
+//	{"keyword": "blue bold", "status-line": "black on white"}
+func (t Theme) Load(r io.Reader) error {
+	var config map[string]string
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return err
+	}
+	for name, s := range config {
+		if err := t.Set(name, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set sets the style of the element called name to s, see ParseStyle.
+func (t Theme) Set(name, s string) error {
+	e, ok := elementNamed(name)
+	if !ok {
+		return fmt.Errorf("unknown element %q", name)
+	}
+	style, err := ParseStyle(s)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	t[e] = style
+	return nil
+}
+
+func elementNamed(name string) (Element, bool) {
+	for e, n := range names {
+		if n == name {
+			return e, true
+		}
+	}
+	return 0, false
+}
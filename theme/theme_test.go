@@ -0,0 +1,69 @@
+package theme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Style
+	}{
+		{"", Style{}},
+		{"yellow", Style{termbox.ColorYellow, termbox.ColorDefault}},
+		{"Red Bold", Style{termbox.ColorRed | termbox.AttrBold, termbox.ColorDefault}},
+		{"bold black on white", Style{termbox.ColorBlack | termbox.AttrBold, termbox.ColorWhite}},
+		{"on blue", Style{termbox.ColorDefault, termbox.ColorBlue}},
+		{"reverse", Style{termbox.ColorDefault | termbox.AttrReverse, termbox.ColorDefault}},
+	}
+	for _, test := range tests {
+		got, err := ParseStyle(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("ParseStyle(%q) = %v, %v want %v", test.s, got, err, test.want)
+		}
+	}
+	for _, s := range []string{"purple", "red on blue on green"} {
+		if _, err := ParseStyle(s); err == nil {
+			t.Errorf("ParseStyle(%q): expected error", s)
+		}
+	}
+}
+
+func TestOver(t *testing.T) {
+	base := Style{termbox.ColorYellow | termbox.AttrBold, termbox.ColorDefault}
+	tests := []struct {
+		s, want Style
+	}{
+		{Style{termbox.AttrReverse, 0}, Style{termbox.ColorYellow | termbox.AttrBold | termbox.AttrReverse, termbox.ColorDefault}},
+		{Style{termbox.ColorBlack, termbox.ColorWhite}, Style{termbox.ColorBlack | termbox.AttrBold, termbox.ColorWhite}},
+	}
+	for _, test := range tests {
+		if got := test.s.Over(base); got != test.want {
+			t.Errorf("%v.Over(%v) = %v want %v", test.s, base, got, test.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	th := Default()
+	if err := th.Load(strings.NewReader(`{"keyword": "blue bold", "status-line": "black on white"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if th[Keyword] != (Style{termbox.ColorBlue | termbox.AttrBold, termbox.ColorDefault}) {
+		t.Errorf("keyword: got %v", th[Keyword])
+	}
+	if th[StatusLine] != (Style{termbox.ColorBlack, termbox.ColorWhite}) {
+		t.Errorf("status-line: got %v", th[StatusLine])
+	}
+	if th[Comment] != Default()[Comment] {
+		t.Errorf("comment changed to %v", th[Comment])
+	}
+	for _, config := range []string{`{"nothing": "red"}`, `{"keyword": "purple"}`, `[`} {
+		if err := Default().Load(strings.NewReader(config)); err == nil {
+			t.Errorf("Load(%s): expected error", config)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package vcs finds out about files from the version control system
+// holding them, git, which it runs as a command: the version of a file
+// staged in the index and who last changed each of its lines.  Which
+// files git ignores it works out from the .gitignore files itself.
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// git runs git with args in dir, with stdin as its input unless nil,
+// and returns its output.  The error tells what git wrote to stderr.
+func git(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git: %s", msg)
+		}
+		return nil, fmt.Errorf("git: %v", err)
+	}
+	return out, nil
+}
+
+// split returns the directory of file, in which git is run, and its
+// name relative to that.
+func split(file string) (dir, name string, err error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Dir(abs), "./" + filepath.Base(abs), nil
+}
+
+// IndexVersion returns the contents of file as staged in the index of
+// its repository.
+func IndexVersion(file string) ([]byte, error) {
+	dir, name, err := split(file)
+	if err != nil {
+		return nil, err
+	}
+	return git(dir, nil, "show", ":"+name)
+}
+
+// A BlameLine tells about the commit that last changed a line.
+type BlameLine struct {
+	Commit  string // hash, all zeros if the line isn't committed yet
+	Author  string
+	Time    time.Time // of authoring
+	Summary string    // first line of the commit message
+}
+
+// Committed returns whether the line has been committed.
+func (l BlameLine) Committed() bool {
+	return strings.Trim(l.Commit, "0") != ""
+}
+
+// Blame returns a BlameLine for each line of contents, the text of
+// file as it is being edited, or of file as saved if contents is nil.
+func Blame(file string, contents []byte) ([]BlameLine, error) {
+	dir, name, err := split(file)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"blame", "--line-porcelain"}
+	if contents != nil {
+		args = append(args, "--contents", "-")
+	}
+	out, err := git(dir, contents, append(args, "--", name)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out)
+}
+
+// parseBlame parses the output of git blame --line-porcelain: for each
+// line a header starting with the hash of the commit, lines with the
+// information about the commit and the line itself after a tab.
+func parseBlame(out []byte) ([]BlameLine, error) {
+	var lines []BlameLine
+	var l BlameLine
+	header := true
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		if header {
+			hash, _, _ := strings.Cut(text, " ")
+			if len(hash) != 40 {
+				return nil, fmt.Errorf("git blame: unexpected %q", text)
+			}
+			l = BlameLine{Commit: hash}
+			header = false
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			lines = append(lines, l)
+			header = true
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		switch key {
+		case "author":
+			l.Author = value
+		case "author-time":
+			secs, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("git blame: invalid time %q", value)
+			}
+			l.Time = time.Unix(secs, 0)
+		case "summary":
+			l.Summary = value
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, errors.New("git blame: output ends within a line")
+	}
+	return lines, nil
+}
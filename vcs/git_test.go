@@ -0,0 +1,104 @@
+package vcs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const porcelain = `0123456789012345678901234567890123456789 1 1 2
+author Ann
+author-mail <ann@example.com>
+author-time 1700000000
+author-tz +0000
+committer Ann
+committer-mail <ann@example.com>
+committer-time 1700000000
+committer-tz +0000
+summary Add the first lines
+boundary
+filename x.txt
+	first
+0123456789012345678901234567890123456789 2 2
+author Ann
+author-mail <ann@example.com>
+author-time 1700000000
+author-tz +0000
+committer Ann
+committer-mail <ann@example.com>
+committer-time 1700000000
+committer-tz +0000
+summary Add the first lines
+boundary
+filename x.txt
+	second
+0000000000000000000000000000000000000000 3 3 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1700000100
+author-tz +0000
+committer Not Committed Yet
+committer-mail <not.committed.yet>
+committer-time 1700000100
+committer-tz +0000
+summary Version of x.txt from x.txt
+previous 0123456789012345678901234567890123456789 x.txt
+filename x.txt
+	third
+`
+
+func TestParseBlame(t *testing.T) {
+	lines, err := parseBlame([]byte(porcelain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	l := lines[1]
+	if l.Commit != "0123456789012345678901234567890123456789" || l.Author != "Ann" ||
+		!l.Time.Equal(time.Unix(1700000000, 0)) || l.Summary != "Add the first lines" || !l.Committed() {
+		t.Errorf("got %+v", l)
+	}
+	if lines[2].Committed() {
+		t.Errorf("line 3 committed: %+v", lines[2])
+	}
+	if _, err := parseBlame([]byte("0123456789012345678901234567890123456789 1 1 1\nauthor Ann\n")); err == nil {
+		t.Error("no error for truncated output")
+	}
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		if _, err := git(dir, nil, append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, "x.txt")
+	run("init", "-q")
+	os.WriteFile(file, []byte("one\ntwo\n"), 0o644)
+	run("add", "x.txt")
+	run("commit", "-q", "-m", "Add x")
+	os.WriteFile(file, []byte("one\n2\n"), 0o644)
+	run("add", "x.txt")
+
+	if text, err := IndexVersion(file); err != nil || string(text) != "one\n2\n" {
+		t.Errorf("IndexVersion: got %q, %v", text, err)
+	}
+	lines, err := Blame(file, []byte("one\n2\nthree\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || !lines[0].Committed() || lines[0].Summary != "Add x" || lines[1].Committed() || lines[2].Committed() {
+		t.Errorf("Blame: got %+v", lines)
+	}
+	if _, err := IndexVersion(filepath.Join(dir, "y.txt")); err == nil {
+		t.Error("IndexVersion of a file not in the index succeeded")
+	}
+}
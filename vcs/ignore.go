@@ -0,0 +1,142 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Root returns the top directory of the git repository holding dir, or
+// "" if there is none.
+func Root(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// An Ignore tells which files git ignores, following the patterns of
+// the .gitignore files added to it.  The names it is asked about are
+// relative to the top directory of the repository and use slashes.
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	base     string   // directory of the .gitignore file, "" for the top one
+	segments []string // of the pattern, split at slashes
+	anchored bool     // matched against the name relative to base rather than its last element
+	dirOnly  bool
+	negate   bool
+}
+
+// Read adds the patterns of the .gitignore file in dir, which is base
+// relative to the top directory.  A missing file adds none.
+func (ig *Ignore) Read(dir, base string) error {
+	text, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ig.Add(base, text)
+	return nil
+}
+
+// Add adds the patterns in text, the contents of the .gitignore file of
+// the directory base.
+func (ig *Ignore) Add(base string, text []byte) {
+	for _, line := range bytes.Split(text, []byte{'\n'}) {
+		s := strings.TrimRight(string(line), " \t\r")
+		if s == "" || s[0] == '#' {
+			continue
+		}
+		p := ignorePattern{base: base}
+		if s[0] == '!' {
+			p.negate = true
+			s = s[1:]
+		} else if s[0] == '\\' {
+			s = s[1:]
+		}
+		if strings.HasSuffix(s, "/") {
+			p.dirOnly = true
+			s = strings.TrimRight(s, "/")
+		}
+		if strings.Contains(s, "/") {
+			p.anchored = true
+			s = strings.TrimLeft(s, "/")
+		}
+		if s == "" {
+			continue
+		}
+		p.segments = strings.Split(s, "/")
+		ig.patterns = append(ig.patterns, p)
+	}
+}
+
+// Ignored returns whether git ignores name, a directory if dir.  As in
+// git the last pattern matching decides.
+func (ig *Ignore) Ignored(name string, dir bool) bool {
+	ignored := false
+	for _, p := range ig.patterns {
+		if p.dirOnly && !dir {
+			continue
+		}
+		rel := name
+		if p.base != "" {
+			if !strings.HasPrefix(name, p.base+"/") {
+				continue
+			}
+			rel = name[len(p.base)+1:]
+		}
+		if p.matches(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// matches returns whether the pattern matches name, relative to its base.
+func (p *ignorePattern) matches(name string) bool {
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], name[strings.LastIndexByte(name, '/')+1:])
+		return ok
+	}
+	return matchSegments(p.segments, strings.Split(name, "/"))
+}
+
+// matchSegments returns whether the elements of a name match those of
+// a pattern, where ** matches any number of elements.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,41 @@
+package vcs
+
+import "testing"
+
+func TestIgnore(t *testing.T) {
+	var ig Ignore
+	ig.Add("", []byte("# comment\n*.o\n/bin\nbuild/\ndoc/*.html\n**/testdata/*.golden\n!keep.o\n\\#hash\n"))
+	ig.Add("sub", []byte("local\n/top.txt\n"))
+	tests := []struct {
+		name    string
+		dir     bool
+		ignored bool
+	}{
+		{"main.o", false, true},
+		{"pkg/x.o", false, true},
+		{"keep.o", false, false},
+		{"pkg/keep.o", false, false},
+		{"bin", true, true},
+		{"pkg/bin", true, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"pkg/build", true, true},
+		{"doc/index.html", false, true},
+		{"doc/api/index.html", false, false},
+		{"a/b/testdata/x.golden", false, true},
+		{"testdata/x.golden", false, true},
+		{"#hash", false, true},
+		{"comment", false, false},
+		{"sub/local", false, true},
+		{"sub/x/local", false, true},
+		{"local", false, false},
+		{"sub/top.txt", false, true},
+		{"sub/x/top.txt", false, false},
+		{"main.go", false, false},
+	}
+	for _, test := range tests {
+		if got := ig.Ignored(test.name, test.dir); got != test.ignored {
+			t.Errorf("Ignored(%q, %v) = %v, expected %v", test.name, test.dir, got, test.ignored)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package view
+
+import (
+	"github.com/bgrundmann/e/motion"
+)
+
+// Block returns the lines and the columns (both inclusive) spanned by a
+// block selection, from the anchor to the cursor.  Returns false if the
+// selection isn't a block.
+func (v *View) Block() (first, last, left, right int, ok bool) {
+	if v.selection != SelectBlock {
+		return 0, 0, 0, 0, false
+	}
+	a, c := v.anchor.Offset(), v.cursor.Offset()
+	first, last = v.buffer.LineOfOffset(a), v.buffer.LineOfOffset(c)
+	if first > last {
+		first, last = last, first
+	}
+	left, right = v.Column(a), v.Column(c)
+	if left > right {
+		left, right = right, left
+	}
+	return first, last, left, right, true
+}
+
+// BlockLine returns the text of line n between the columns left and
+// right (inclusive), a rune partly between them included.  Returns false
+// if the line doesn't reach left, with both offsets at the end of the
+// line.
+func (v *View) BlockLine(n, left, right int) (off1, off2 int, ok bool) {
+	rd := v.buffer.NewReader(v.buffer.Line(n))
+	off1 = -1
+	prev := '\n'
+	for col := 0; ; {
+		off := rd.Offset()
+		r, size, err := rd.ReadRune()
+		if err != nil || r == '\n' {
+			if off1 < 0 {
+				return off, off, col >= left
+			}
+			return off1, off, true
+		}
+		width := v.TabStop - col%v.TabStop
+		if r != '\t' {
+			width = cellWidth(prev, r, size)
+		}
+		// runes taking no columns go with the ones before them
+		if col > right && width > 0 {
+			return off1, off, true
+		}
+		col += width
+		if off1 < 0 && col > left {
+			off1 = off
+		}
+		prev = r
+	}
+}
+
+// BlockRanges returns the text of a block selection, a range in each
+// line of the block reaching its left column.
+func (v *View) BlockRanges() []motion.Range {
+	first, last, left, right, ok := v.Block()
+	if !ok {
+		return nil
+	}
+	var ranges []motion.Range
+	for n := first; n <= last; n++ {
+		if off1, off2, ok := v.BlockLine(n, left, right); ok {
+			ranges = append(ranges, motion.Range{Off1: off1, Off2: off2})
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,57 @@
+package view
+
+import (
+	"github.com/bgrundmann/e/buf"
+)
+
+// AddCursor adds a cursor at off besides the main one.  The additional
+// cursors are markers, so they stay with their text when the buffer
+// changes.  They are moved and edited at by swapping them with the main
+// cursor in turn (see SwapCursor).
+func (v *View) AddCursor(off int) {
+	v.cursors = append(v.cursors, v.buffer.NewMarkerWithGravity(off, buf.GravityRight))
+	v.changed = true
+}
+
+// Cursors returns the offsets of the additional cursors in the order
+// they were added.
+func (v *View) Cursors() []int {
+	offs := make([]int, len(v.cursors))
+	for i, c := range v.cursors {
+		offs[i] = c.Offset()
+	}
+	return offs
+}
+
+// SwapCursor exchanges the main cursor and additional cursor i.
+func (v *View) SwapCursor(i int) {
+	off := v.cursors[i].Offset()
+	v.cursors[i].Move(v.cursor.Offset())
+	v.cursor.Move(off)
+	v.changed = true
+}
+
+// MergeCursors removes the additional cursors that ended up where the
+// main cursor or an earlier one is.
+func (v *View) MergeCursors() {
+	seen := map[int]bool{v.cursor.Offset(): true}
+	kept := v.cursors[:0]
+	for _, c := range v.cursors {
+		if seen[c.Offset()] {
+			c.Close()
+			continue
+		}
+		seen[c.Offset()] = true
+		kept = append(kept, c)
+	}
+	v.cursors = kept
+}
+
+// RemoveCursors removes the additional cursors.
+func (v *View) RemoveCursors() {
+	for _, c := range v.cursors {
+		c.Close()
+	}
+	v.cursors = nil
+	v.changed = true
+}
@@ -0,0 +1,230 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// A fold is a range of lines that can be closed, showing it as a single
+// row.  Its start and end are markers at the start of its first and
+// last line, so the fold follows the changes of the buffer.
+type fold struct {
+	start, end buf.Marker
+	closed     bool
+}
+
+// lines returns the first and the last line of f.
+func (v *View) lines(f *fold) (first, last int) {
+	return v.buffer.LineOfOffset(f.start.Offset()), v.buffer.LineOfOffset(f.end.Offset())
+}
+
+// CreateFold adds a closed fold from line first to line last.
+func (v *View) CreateFold(first, last int) {
+	if first > last {
+		first, last = last, first
+	}
+	v.folds = append(v.folds, &fold{
+		start:  v.buffer.NewMarkerWithGravity(v.buffer.Line(first), buf.GravityLeft),
+		end:    v.buffer.NewMarkerWithGravity(v.buffer.Line(last), buf.GravityLeft),
+		closed: true,
+	})
+	v.changed = true
+}
+
+// DeleteFolds removes all folds.
+func (v *View) DeleteFolds() {
+	for _, f := range v.folds {
+		f.start.Close()
+		f.end.Close()
+	}
+	v.folds = nil
+	v.changed = true
+}
+
+// deleteFoldsIn removes the folds whose lines are all between off1 and
+// off2, which are about to be deleted.
+func (v *View) deleteFoldsIn(off1, off2 int) {
+	kept := v.folds[:0]
+	for _, f := range v.folds {
+		_, last := v.lines(f)
+		end := v.buffer.Len()
+		if last < v.buffer.Lines() {
+			end = v.buffer.Line(last + 1)
+		}
+		if off1 <= f.start.Offset() && end <= off2 {
+			f.start.Close()
+			f.end.Close()
+			continue
+		}
+		kept = append(kept, f)
+	}
+	v.folds = kept
+}
+
+// closedFold returns the lines of the outermost closed fold containing
+// line n, which is what the view shows of it.
+func (v *View) closedFold(n int) (first, last int, ok bool) {
+	for _, f := range v.folds {
+		if !f.closed {
+			continue
+		}
+		if f1, f2 := v.lines(f); f1 <= n && n <= f2 && (!ok || f1 < first || f1 == first && f2 > last) {
+			first, last, ok = f1, f2, true
+		}
+	}
+	return first, last, ok
+}
+
+// visibleLine returns the line shown for line n: the first line of the
+// closed fold containing it, if any, else n.
+func (v *View) visibleLine(n int) int {
+	if first, _, ok := v.closedFold(n); ok {
+		return first
+	}
+	return n
+}
+
+// moveLines returns the line delta lines below line n (above if delta
+// is negative), a closed fold counting as a single line.
+func (v *View) moveLines(n, delta int) int {
+	for ; delta > 0; delta-- {
+		if _, last, ok := v.closedFold(n); ok {
+			n = last
+		}
+		if n >= v.buffer.Lines() {
+			break
+		}
+		n++
+	}
+	for ; delta < 0; delta++ {
+		if first, _, ok := v.closedFold(n); ok {
+			n = first
+		}
+		if n <= 1 {
+			break
+		}
+		n--
+	}
+	return v.visibleLine(n)
+}
+
+// OpenFold opens the closed fold shown at line n.  Returns false if
+// there is none.
+func (v *View) OpenFold(n int) bool {
+	first, last, ok := v.closedFold(n)
+	if !ok {
+		return false
+	}
+	for _, f := range v.folds {
+		if f1, f2 := v.lines(f); f.closed && f1 == first && f2 == last {
+			f.closed = false
+		}
+	}
+	v.changed = true
+	return true
+}
+
+// CloseFold closes the innermost open fold containing line n.  Returns
+// false if there is none.
+func (v *View) CloseFold(n int) bool {
+	var inner *fold
+	var first, last int
+	for _, f := range v.folds {
+		f1, f2 := v.lines(f)
+		if f.closed || n < f1 || n > f2 {
+			continue
+		}
+		if inner == nil || f2-f1 < last-first {
+			inner, first, last = f, f1, f2
+		}
+	}
+	if inner == nil {
+		return false
+	}
+	inner.closed = true
+	v.changed = true
+	return true
+}
+
+// ToggleFold opens the closed fold shown at line n or else closes the
+// innermost fold containing it.  Returns false if there is none.
+func (v *View) ToggleFold(n int) bool {
+	return v.OpenFold(n) || v.CloseFold(n)
+}
+
+// FoldIndent replaces the folds by closed ones following the
+// indentation: each run of lines indented by at least k shift widths is
+// a fold.  Blank lines belong to the less indented of the lines around
+// them.
+func (v *View) FoldIndent() {
+	v.DeleteFolds()
+	n := v.buffer.Lines()
+	levels := make([]int, n+2) // by line, 0 beyond the buffer
+	blank := make([]bool, n+1)
+	v.buffer.EachLine(1, n, func(line int, text []byte) bool {
+		width := 0
+		for _, c := range text {
+			if c == ' ' {
+				width++
+			} else if c == '\t' {
+				width += v.TabStop - width%v.TabStop
+			} else {
+				break
+			}
+		}
+		blank[line] = len(strings.TrimSpace(string(text))) == 0
+		levels[line] = width / v.ShiftWidth
+		return true
+	})
+	// blank lines take the level of the next non blank line, unless the
+	// one before is less indented
+	prev := 0
+	for line := 1; line <= n; line++ {
+		if !blank[line] {
+			prev = levels[line]
+			continue
+		}
+		next := line + 1
+		for next <= n && blank[next] {
+			next++
+		}
+		levels[line] = levels[next]
+		if prev < levels[line] {
+			levels[line] = prev
+		}
+	}
+	for k := 1; ; k++ {
+		found := false
+		for line := 1; line <= n; line++ {
+			if levels[line] < k {
+				continue
+			}
+			first := line
+			for line < n && levels[line+1] >= k {
+				line++
+			}
+			v.CreateFold(first, line)
+			found = true
+		}
+		if !found {
+			break
+		}
+	}
+}
+
+// foldText returns the row shown for the closed fold from line first to
+// line last.
+func (v *View) foldText(first, last int) string {
+	text := ""
+	v.buffer.EachLine(first, first, func(_ int, line []byte) bool {
+		text = strings.TrimSpace(strings.ReplaceAll(string(line), "\t", " "))
+		return false
+	})
+	lines := "lines"
+	if first == last {
+		lines = "line"
+	}
+	return fmt.Sprintf("+--%3d %s: %s", last-first+1, lines, text)
+}
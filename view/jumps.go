@@ -0,0 +1,89 @@
+package view
+
+import (
+	"github.com/bgrundmann/e/buf"
+)
+
+// maxJumps is the number of positions a jump list remembers.
+const maxJumps = 100
+
+// jumpList remembers the cursor positions jumps (searches, going to a
+// line, ...) started at.  The positions are markers so they stay with
+// the text when it is edited.
+type jumpList struct {
+	marks   []buf.Marker
+	current int // index of the position walked to, len(marks) if none
+}
+
+// push records off as the start of a jump.  Positions walked past with
+// back are forgotten.
+func (j *jumpList) push(b *buf.Buf, off int) {
+	for _, m := range j.marks[j.current:] {
+		m.Close()
+	}
+	j.marks = j.marks[:j.current]
+	if len(j.marks) == maxJumps {
+		j.marks[0].Close()
+		j.marks = append(j.marks[:0], j.marks[1:]...)
+	}
+	j.marks = append(j.marks, b.NewMarker(off))
+	j.current = len(j.marks)
+}
+
+// back returns the position before the current one.  When starting
+// to walk back, cursor is recorded so that forward can return to it.
+func (j *jumpList) back(b *buf.Buf, cursor int) (int, bool) {
+	if j.current == 0 {
+		return 0, false
+	}
+	if j.current == len(j.marks) {
+		j.push(b, cursor)
+		j.current--
+	}
+	j.current--
+	return j.marks[j.current].Offset(), true
+}
+
+// forward returns the position after the current one.
+func (j *jumpList) forward() (int, bool) {
+	if j.current+1 >= len(j.marks) {
+		return 0, false
+	}
+	j.current++
+	return j.marks[j.current].Offset(), true
+}
+
+// clear forgets all positions.
+func (j *jumpList) clear() {
+	for _, m := range j.marks {
+		m.Close()
+	}
+	j.marks = nil
+	j.current = 0
+}
+
+// PushJump records off as the position a jump started at.
+func (v *View) PushJump(off int) {
+	v.jumps.push(v.buffer, off)
+}
+
+// JumpBack moves the cursor to the position the last jump started at.
+// Returns false if there is none.
+func (v *View) JumpBack() bool {
+	off, ok := v.jumps.back(v.buffer, v.Cursor())
+	if ok {
+		v.SetCursor(off)
+		v.ShowLine(v.buffer.LineOfOffset(off))
+	}
+	return ok
+}
+
+// JumpForward undoes a JumpBack.  Returns false if there is nothing to undo.
+func (v *View) JumpForward() bool {
+	off, ok := v.jumps.forward()
+	if ok {
+		v.SetCursor(off)
+		v.ShowLine(v.buffer.LineOfOffset(off))
+	}
+	return ok
+}
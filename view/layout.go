@@ -0,0 +1,303 @@
+package view
+
+import (
+	"github.com/bgrundmann/e/screen"
+	"github.com/nsf/termbox-go"
+)
+
+// SplitDirection says how a Layout partitions its rectangle.
+type SplitDirection int
+
+const (
+	// Horizontal stacks children on top of each other.
+	Horizontal SplitDirection = iota
+	// Vertical places children side by side.
+	Vertical
+)
+
+// A Layout is a tree partitioning a rectangle of the screen into Views.
+// Leaves hold exactly one View, inner nodes hold two or more children
+// split in one direction.  Each child gets a share of its parent's space
+// proportional to its weight.
+type Layout struct {
+	view     *View // non nil for leaves
+	dir      SplitDirection
+	children []*Layout
+	parent   *Layout
+	weight   float64
+}
+
+// NewLayout returns a layout consisting of a single leaf showing v.
+func NewLayout(v *View) *Layout {
+	return &Layout{view: v, weight: 1}
+}
+
+// View returns the view of a leaf or nil for inner nodes.
+func (l *Layout) View() *View {
+	return l.view
+}
+
+// NewSplit returns an inner node splitting its space in direction dir
+// between the layouts children, each getting a share proportional to
+// its weight.
+func NewSplit(dir SplitDirection, children []*Layout, weights []float64) *Layout {
+	l := &Layout{dir: dir, children: children, weight: 1}
+	for i, c := range children {
+		c.parent = l
+		c.weight = weights[i]
+	}
+	return l
+}
+
+// Children returns the direction and the children of an inner node, no
+// children for a leaf.
+func (l *Layout) Children() (SplitDirection, []*Layout) {
+	return l.dir, l.children
+}
+
+// Weight returns the weight of l, which determines its share of its
+// parent's space.
+func (l *Layout) Weight() float64 {
+	return l.weight
+}
+
+// Root returns the root of the layout tree l belongs to.
+func (l *Layout) Root() *Layout {
+	for l.parent != nil {
+		l = l.parent
+	}
+	return l
+}
+
+// Split splits the leaf l in direction dir.  The new view v is placed
+// before (above or left of) the existing view, as in vim.  Returns the
+// leaf holding v.
+func (l *Layout) Split(dir SplitDirection, v *View) *Layout {
+	if l.view == nil {
+		panic("Split: not a leaf")
+	}
+	if l.parent != nil && l.parent.dir == dir {
+		// just add a sibling, splitting the weight of l between the two
+		p := l.parent
+		l.weight /= 2
+		n := &Layout{view: v, parent: p, weight: l.weight}
+		i := p.indexOf(l)
+		p.children = append(p.children[:i], append([]*Layout{n}, p.children[i:]...)...)
+		return n
+	}
+	// turn the leaf into an inner node with two children
+	old := &Layout{view: l.view, parent: l, weight: 1}
+	n := &Layout{view: v, parent: l, weight: 1}
+	l.view = nil
+	l.dir = dir
+	l.children = []*Layout{n, old}
+	return n
+}
+
+func (l *Layout) indexOf(child *Layout) int {
+	for i, c := range l.children {
+		if c == child {
+			return i
+		}
+	}
+	panic("indexOf: not a child")
+}
+
+// Each calls f for every leaf of the layout in display order together
+// with the rectangle it occupies when the layout is given x, y, w, h.
+func (l *Layout) Each(x, y, w, h int, f func(leaf *Layout, x, y, w, h int)) {
+	if l.view != nil {
+		f(l, x, y, w, h)
+		return
+	}
+	total := 0.0
+	for _, c := range l.children {
+		total += c.weight
+	}
+	size := h
+	if l.dir == Vertical {
+		// one column between children is used for the separator
+		size = w - (len(l.children) - 1)
+	}
+	pos := 0
+	acc := 0.0
+	for i, c := range l.children {
+		acc += c.weight
+		end := int(float64(size)*acc/total + 0.5)
+		if i == len(l.children)-1 {
+			end = size
+		}
+		n := end - pos
+		if l.dir == Vertical {
+			c.Each(x+pos+i, y, n, h, f)
+		} else {
+			c.Each(x, y+pos, w, n, f)
+		}
+		pos = end
+	}
+}
+
+// Display draws all views of the layout into the given rectangle of scr,
+// separating side by side views by a vertical bar.
+func (l *Layout) Display(scr screen.Screen, x, y, w, h int) {
+	const coldef = termbox.ColorDefault
+	l.Each(x, y, w, h, func(leaf *Layout, lx, ly, lw, lh int) {
+		leaf.view.Display(scr, lx, ly, lw, lh)
+		if lx+lw < x+w {
+			for i := 0; i < lh; i++ {
+				scr.SetCell(lx+lw, ly+i, '│', coldef, coldef)
+			}
+		}
+	})
+}
+
+// Leaf returns the leaf of the layout tree l belongs to that shows v,
+// or nil if v isn't shown.
+func (l *Layout) Leaf(v *View) *Layout {
+	var leaf *Layout
+	l.Root().Each(0, 0, 0, 0, func(c *Layout, x, y, w, h int) {
+		if c.view == v && leaf == nil {
+			leaf = c
+		}
+	})
+	return leaf
+}
+
+// Close removes the leaf l from the layout, its space going to its
+// siblings.  Returns the leaf taking its place, which may be a
+// different node than before, or nil if l is the only leaf.
+func (l *Layout) Close() *Layout {
+	if l.view == nil {
+		panic("Close: not a leaf")
+	}
+	p := l.parent
+	if p == nil {
+		return nil
+	}
+	i := p.indexOf(l)
+	p.children = append(p.children[:i], p.children[i+1:]...)
+	// the following sibling takes the place of l, like in vim
+	next := i
+	if next == len(p.children) {
+		next--
+	}
+	p.children[next].weight += l.weight
+	l.parent = nil
+	if len(p.children) > 1 {
+		return p.children[next].firstLeaf()
+	}
+	// p is left with a single child, which replaces it
+	c := p.children[0]
+	p.view, p.dir, p.children = c.view, c.dir, c.children
+	for _, cc := range p.children {
+		cc.parent = p
+	}
+	if gp := p.parent; gp != nil && p.view == nil && gp.dir == p.dir {
+		// merge with the parent split in the same direction
+		j := gp.indexOf(p)
+		total := 0.0
+		for _, cc := range p.children {
+			total += cc.weight
+		}
+		for _, cc := range p.children {
+			cc.weight *= p.weight / total
+			cc.parent = gp
+		}
+		gp.children = append(gp.children[:j], append(p.children, gp.children[j+1:]...)...)
+		return gp.children[j].firstLeaf()
+	}
+	return p.firstLeaf()
+}
+
+func (l *Layout) firstLeaf() *Layout {
+	for l.view == nil {
+		l = l.children[0]
+	}
+	return l
+}
+
+// Equalize gives all windows of the layout tree l belongs to the same
+// size, as far as the splits allow.
+func (l *Layout) Equalize() {
+	var equalize func(l *Layout) int
+	// equalize returns the number of leaves in the direction of the
+	// parent's split
+	equalize = func(l *Layout) int {
+		if l.view != nil {
+			return 1
+		}
+		n := 0
+		for _, c := range l.children {
+			c.weight = float64(equalize(c))
+			if c.view == nil && c.dir != l.dir {
+				// a split in the other direction takes the space
+				// of one window
+				c.weight = 1
+			}
+			n += int(c.weight)
+		}
+		return n
+	}
+	equalize(l.Root())
+}
+
+// Direction is a direction on the screen.
+type Direction int
+
+const (
+	Left Direction = iota
+	Right
+	Up
+	Down
+)
+
+// Neighbor returns the leaf next to leaf in direction d, when the layout
+// tree is displayed in a w x h rectangle, or nil if there is none.  Of
+// several neighbors the one next to the screen position x, y (usually
+// the cursor) is chosen.
+func (l *Layout) Neighbor(d Direction, w, h, x, y int) *Layout {
+	type rect struct{ x, y, w, h int }
+	rects := map[*Layout]rect{}
+	l.Root().Each(0, 0, w, h, func(c *Layout, cx, cy, cw, ch int) {
+		rects[c] = rect{cx, cy, cw, ch}
+	})
+	r := rects[l]
+	var best *Layout
+	bestDist := 0
+	for c, cr := range rects {
+		var adjacent bool
+		var dist int // distance of x or y from c along the shared edge
+		switch d {
+		case Left, Right:
+			// separated by a column
+			adjacent = (d == Left && cr.x+cr.w+1 == r.x) || (d == Right && r.x+r.w+1 == cr.x)
+			adjacent = adjacent && cr.y < r.y+r.h && r.y < cr.y+cr.h
+			dist = distance(y, cr.y, cr.y+cr.h)
+		case Up, Down:
+			adjacent = (d == Up && cr.y+cr.h == r.y) || (d == Down && r.y+r.h == cr.y)
+			adjacent = adjacent && cr.x < r.x+r.w && r.x < cr.x+cr.w
+			dist = distance(x, cr.x, cr.x+cr.w)
+		}
+		if !adjacent {
+			continue
+		}
+		// on a tie take the top left one, the map order is random
+		if br := rects[best]; best == nil || dist < bestDist ||
+			(dist == bestDist && (cr.y < br.y || (cr.y == br.y && cr.x < br.x))) {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
+
+// distance returns how far p is from the range from start up to
+// (excluding) end.
+func distance(p, start, end int) int {
+	switch {
+	case p < start:
+		return start - p
+	case p >= end:
+		return p - end + 1
+	}
+	return 0
+}
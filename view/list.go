@@ -0,0 +1,56 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ListChars are the runes blanks are shown with if the option List is
+// set, 0 where they are shown as usual.
+type ListChars struct {
+	Tab   [2]rune // the first cell of a tab and the ones filling it
+	Trail rune    // spaces at the end of a line
+	Space rune    // other spaces
+	EOL   rune    // behind the end of a line
+}
+
+// ParseListChars parses list chars written like vim's 'listchars', a
+// comma separated list of tab:xy, trail:c, space:c and eol:c.
+func ParseListChars(s string) (ListChars, error) {
+	var lc ListChars
+	if s == "" {
+		return lc, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(item, ":")
+		n := utf8.RuneCountInString(value)
+		r, _ := utf8.DecodeRuneInString(value)
+		switch {
+		case name == "tab" && n == 2:
+			lc.Tab[0] = r
+			lc.Tab[1], _ = utf8.DecodeRuneInString(value[utf8.RuneLen(r):])
+		case name == "trail" && n == 1:
+			lc.Trail = r
+		case name == "space" && n == 1:
+			lc.Space = r
+		case name == "eol" && n == 1:
+			lc.EOL = r
+		default:
+			return ListChars{}, fmt.Errorf("invalid list chars %q", item)
+		}
+	}
+	return lc, nil
+}
+
+// trailStart returns the offset the blanks at the end of line n start
+// at, the end of the line if there are none.
+func (v *View) trailStart(n int) int {
+	start, end := v.buffer.LineRange(n)
+	text := v.buffer.Bytes(start, end)
+	i := len(text)
+	for i > 0 && (text[i-1] == ' ' || text[i-1] == '\t') {
+		i--
+	}
+	return start + i
+}
@@ -0,0 +1,75 @@
+package view
+
+import (
+	"github.com/bgrundmann/e/runewidth"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/theme"
+)
+
+// maxPopupRows is the number of items a popup shows at most.
+const maxPopupRows = 10
+
+// A Popup is a list of items drawn over the windows next to a position
+// on the screen, e.g. the candidates of a completion next to the
+// cursor.
+type Popup struct {
+	Items    []string
+	Selected int // index of the highlighted item or -1
+}
+
+// Display draws the popup below the position x, y of scr, or above it
+// if there isn't enough room below, in the top left w x h cells of scr.
+// Windows drawn over have to be redrawn once the popup goes away.
+func (p *Popup) Display(scr screen.Screen, x, y, w, h int) {
+	rows := len(p.Items)
+	if rows > maxPopupRows {
+		rows = maxPopupRows
+	}
+	top := y + 1
+	if top+rows > h && y > h-y-1 {
+		// more room above
+		if rows > y {
+			rows = y
+		}
+		top = y - rows
+	} else if top+rows > h {
+		rows = h - top
+	}
+	width := 0
+	for _, item := range p.Items {
+		if n := runewidth.StringWidth(item) + 2; n > width {
+			width = n
+		}
+	}
+	if width > w {
+		width = w
+	}
+	if x+width > w {
+		x = w - width
+	}
+	// scroll so that the selected item is visible
+	first := 0
+	if p.Selected >= rows {
+		first = p.Selected - rows + 1
+	}
+	for i := 0; i < rows; i++ {
+		style := theme.Current[theme.Popup]
+		if first+i == p.Selected {
+			style = theme.Current[theme.Selection].Over(style)
+		}
+		col := x
+		scr.SetCell(col, top+i, ' ', style.Fg, style.Bg)
+		col++
+		for _, r := range p.Items[first+i] {
+			rw := runewidth.RuneWidth(r)
+			if col+rw > x+width-1 {
+				break
+			}
+			scr.SetCell(col, top+i, r, style.Fg, style.Bg)
+			col += rw
+		}
+		for ; col < x+width; col++ {
+			scr.SetCell(col, top+i, ' ', style.Fg, style.Bg)
+		}
+	}
+}
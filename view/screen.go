@@ -0,0 +1,356 @@
+package view
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/grapheme"
+	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/runewidth"
+)
+
+// This file maps buffer offsets to screen rows when long lines wrap.
+// It has to agree with the way Display lays out the text.
+
+// escape returns the text shown for the rune r read as size bytes if
+// it can't be shown as itself: ^X for control characters and <xx> for
+// other non printable ones and bytes that aren't valid utf-8 (raw is
+// the first byte of r).  Returns "" for runes shown as themselves.
+func escape(r rune, size int, raw byte) string {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return fmt.Sprintf("<%02x>", raw)
+	case r < 0x20 || r == 0x7f:
+		return "^" + string(r^0x40)
+	case 0x80 <= r && r < 0xa0:
+		return fmt.Sprintf("<%02x>", r)
+	}
+	return ""
+}
+
+// cellWidth returns the number of columns the rune r read as size bytes
+// takes following the rune prev, except for tabs whose width depends on
+// the column.  It agrees with escape.  Runes merged into the glyph of
+// the grapheme cluster prev belongs to take none, e.g. the rest of an
+// emoji sequence.
+func cellWidth(prev, r rune, size int) int {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return 4
+	case r < 0x20 || r == 0x7f:
+		return 2
+	case 0x80 <= r && r < 0xa0:
+		return 4
+	case grapheme.Merges(prev, r):
+		return 0
+	}
+	return runewidth.RuneWidth(r)
+}
+
+// runeWidth returns the number of columns r read as size bytes takes
+// following prev at column col of a screen row of width w.
+func (v *View) runeWidth(prev, r rune, size, col, w int) int {
+	if r == '\t' {
+		n := v.TabStop - col%v.TabStop
+		if v.Wrap && col+n > w {
+			n = w - col
+		}
+		return n
+	}
+	return cellWidth(prev, r, size)
+}
+
+// wraps returns whether r read as size bytes following prev starts a
+// new screen row if it would be shown at column col.  Runes wider than
+// one column aren't split across rows, tabs are cut at the end of the row
+// instead.  Runes taking no columns stay with the ones they are drawn
+// with.
+func (v *View) wraps(prev, r rune, size, col int) bool {
+	if r == '\t' {
+		return col >= v.width
+	}
+	width := cellWidth(prev, r, size)
+	return width > 0 && (col >= v.width || (col > 0 && col+width > v.width))
+}
+
+// rowStarts returns the offsets at which the screen rows showing line n
+// start.  Just one if not wrapping.
+func (v *View) rowStarts(n int) []int {
+	off := v.buffer.Line(n)
+	starts := []int{off}
+	if !v.Wrap {
+		return starts
+	}
+	rd := v.buffer.NewReader(off)
+	col := 0
+	prev := '\n'
+	for {
+		r, size, err := rd.ReadRune()
+		if v.wraps(prev, r, size, col) {
+			starts = append(starts, off)
+			col = 0
+		}
+		if err != nil || r == '\n' {
+			return starts
+		}
+		if r == '\r' {
+			// part of a \r\n line break?
+			next, _, _ := rd.ReadRune()
+			rd.UnreadRune()
+			if next == '\n' {
+				off += size
+				continue
+			}
+		}
+		col += v.runeWidth(prev, r, size, col, v.width)
+		off += size
+		prev = r
+	}
+}
+
+// rows returns the number of screen rows line n takes: none if hidden
+// in a closed fold, one for the first line of a closed fold.
+func (v *View) rows(n int) int {
+	if first, _, ok := v.closedFold(n); ok {
+		if n == first {
+			return 1
+		}
+		return 0
+	}
+	return len(v.rowStarts(n))
+}
+
+// rowColumn returns the column of off in the screen row starting at start.
+func (v *View) rowColumn(start, off int) int {
+	rd := v.buffer.NewReader(start)
+	col := 0
+	prev := '\n'
+	for rd.Offset() < off {
+		r, size, err := rd.ReadRune()
+		if err != nil {
+			break
+		}
+		col += v.runeWidth(prev, r, size, col, v.width)
+		prev = r
+	}
+	return col
+}
+
+// rowOffset returns the offset of the rune at column col of the screen
+// row from start to end, or of its last rune if the row is shorter.
+func (v *View) rowOffset(start, end, col int) int {
+	rd := v.buffer.NewReader(start)
+	c := 0
+	off := start
+	prev := '\n'
+	for rd.Offset() < end {
+		r, size, err := rd.ReadRune()
+		if err != nil || r == '\n' {
+			break
+		}
+		c += v.runeWidth(prev, r, size, c, v.width)
+		if c > col {
+			break
+		}
+		off = rd.Offset()
+		prev = r
+	}
+	return off
+}
+
+// RowMotion returns a motion to the same column in the next screen row,
+// or the previous one if up is set (vim's gj and gk).  Unlike j and k
+// it moves within lines that wrap.
+func (v *View) RowMotion(up bool) motion.Motion {
+	return motion.New(func(b *buf.Buf, rd *buf.Reader) bool {
+		off := rd.Offset()
+		line := v.buffer.LineOfOffset(off)
+		starts := v.rowStarts(line)
+		i := len(starts) - 1
+		for i > 0 && starts[i] > off {
+			i--
+		}
+		col := v.rowColumn(starts[i], off)
+		if up {
+			i--
+			if i < 0 {
+				if line == 1 {
+					return false
+				}
+				line--
+				starts = v.rowStarts(line)
+				i = len(starts) - 1
+			}
+		} else {
+			i++
+			if i == len(starts) {
+				if line == b.Lines() {
+					return false
+				}
+				line++
+				starts = v.rowStarts(line)
+				i = 0
+			}
+		}
+		_, end := b.LineRange(line)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		_, err := rd.Seek(int64(v.rowOffset(starts[i], end, col)), 0)
+		return err == nil
+	})
+}
+
+// lastLine returns the last line that fits completely into the view.
+func (v *View) lastLine() int {
+	rows := 0
+	n := v.firstLine
+	for ; n < v.buffer.Lines(); n++ {
+		rows += v.rows(n)
+		if rows >= v.height {
+			break
+		}
+	}
+	if rows > v.height && n > v.firstLine {
+		n--
+	}
+	return n
+}
+
+// PageDown scrolls forward by a screen, keeping two rows of overlap.
+// The cursor moves along if it would leave the view.
+func (v *View) PageDown() {
+	rows := 0
+	for v.firstLine < v.buffer.Lines() {
+		rows += v.rows(v.firstLine)
+		if rows > v.height-2 && rows > v.rows(v.firstLine) {
+			break
+		}
+		v.firstLine++
+	}
+	if v.buffer.LineOfOffset(v.cursor.Offset()) < v.firstLine {
+		v.cursor.Move(v.buffer.Line(v.firstLine))
+	}
+}
+
+// PageUp scrolls backward by a screen, keeping two rows of overlap.
+// The cursor moves along if it would leave the view.
+func (v *View) PageUp() {
+	rows := 0
+	for v.firstLine > 1 {
+		rows += v.rows(v.firstLine - 1)
+		if rows > v.height-2 && rows > v.rows(v.firstLine-1) {
+			break
+		}
+		v.firstLine--
+	}
+	if last := v.lastLine(); v.buffer.LineOfOffset(v.cursor.Offset()) > last {
+		v.cursor.Move(v.buffer.Line(last))
+	}
+}
+
+// ScrollDown scrolls forward by n lines, up to the last line of the
+// buffer at the top (vim's CTRL-E).  The cursor moves along if it would
+// leave the view.
+func (v *View) ScrollDown(n int) {
+	v.firstLine = v.moveLines(v.visibleLine(v.firstLine), n)
+	v.keepCursorInView()
+}
+
+// ScrollUp scrolls backward by n lines (vim's CTRL-Y).  The cursor
+// moves along if it would leave the view.
+func (v *View) ScrollUp(n int) {
+	v.firstLine = v.moveLines(v.visibleLine(v.firstLine), -n)
+	v.keepCursorInView()
+}
+
+// HalfPageDown moves the cursor forward by n lines, half a screen if n
+// is 0, and scrolls as many unless the end of the buffer is shown
+// already (vim's CTRL-D).
+func (v *View) HalfPageDown(n int) {
+	if n <= 0 {
+		n = max(v.height/2, 1)
+	}
+	v.cursorToLine(v.moveLines(v.buffer.LineOfOffset(v.cursor.Offset()), n))
+	for i := 0; i < n && v.lastLine() < v.buffer.Lines(); i++ {
+		v.firstLine = v.moveLines(v.visibleLine(v.firstLine), 1)
+	}
+	v.keepCursorInView()
+}
+
+// HalfPageUp moves the cursor backward by n lines, half a screen if n
+// is 0, and scrolls as many (vim's CTRL-U).
+func (v *View) HalfPageUp(n int) {
+	if n <= 0 {
+		n = max(v.height/2, 1)
+	}
+	v.cursorToLine(v.moveLines(v.buffer.LineOfOffset(v.cursor.Offset()), -n))
+	v.firstLine = v.moveLines(v.visibleLine(v.firstLine), -n)
+	v.keepCursorInView()
+}
+
+// CursorLineToTop scrolls the cursor line to the top of the view (vim's
+// zt), CursorLineToCenter to its center (zz) and CursorLineToBottom to
+// its bottom (zb), as far as the buffer and ScrollOff allow.
+func (v *View) CursorLineToTop() {
+	v.scrollAbove(0)
+}
+
+func (v *View) CursorLineToCenter() {
+	n := v.visibleLine(v.buffer.LineOfOffset(v.cursor.Offset()))
+	v.scrollAbove((v.height - v.rows(n)) / 2)
+}
+
+func (v *View) CursorLineToBottom() {
+	n := v.visibleLine(v.buffer.LineOfOffset(v.cursor.Offset()))
+	v.scrollAbove(v.height - v.rows(n))
+}
+
+// scrollAbove scrolls so that rows screen rows are shown above the
+// cursor line, or as few as there are.
+func (v *View) scrollAbove(rows int) {
+	n := v.visibleLine(v.buffer.LineOfOffset(v.cursor.Offset()))
+	for n > 1 {
+		prev := v.moveLines(n, -1)
+		if v.rows(prev) > rows {
+			break
+		}
+		rows -= v.rows(prev)
+		n = prev
+	}
+	v.firstLine = n
+}
+
+// keepCursorInView moves the cursor, keeping its goal column, to the
+// nearest line shown after scrolling, ScrollOff lines away from the top
+// and bottom of the view unless the buffer starts or ends there.
+func (v *View) keepCursorInView() {
+	so := v.scrollOff()
+	top, bottom := v.firstLine, v.lastLine()
+	if top > 1 {
+		top = v.moveLines(top, so)
+	}
+	if bottom < v.buffer.Lines() {
+		bottom = v.moveLines(bottom, -so)
+	}
+	switch line := v.buffer.LineOfOffset(v.cursor.Offset()); {
+	case line < top:
+		v.cursorToLine(top)
+	case line > bottom && bottom >= top:
+		v.cursorToLine(bottom)
+	}
+}
+
+// cursorToLine moves the cursor to line n keeping the goal column, like
+// moving up and down does (see MoveCursor).
+func (v *View) cursorToLine(n int) {
+	cursor := v.cursor.Offset()
+	goal := v.goal
+	if v.goalOff != cursor {
+		goal = v.Column(cursor)
+	}
+	off := v.columnOffset(v.buffer.Line(n), goal)
+	v.cursor.Move(off)
+	v.goal, v.goalOff = goal, off
+}
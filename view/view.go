@@ -2,95 +2,864 @@ package view
 
 import (
 	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
 
-	"github.com/nsf/termbox-go"
 	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/highlight"
 	"github.com/bgrundmann/e/motion"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/statusline"
+	"github.com/bgrundmann/e/theme"
+	"github.com/nsf/termbox-go"
 )
 
+// Options are the settings of a view.
+type Options struct {
+	TabStop    int  // number of columns between tab stops
+	ShiftWidth int  // number of columns an indentation level is wide
+	ExpandTab  bool // insert spaces instead of tabs
+	Wrap       bool // wrap long lines instead of scrolling horizontally
+	ScrollOff  int  // number of lines kept visible above and below the cursor
+	Number     bool // show line numbers
+	// show line numbers relative to the cursor line, combined with
+	// Number the cursor line shows its absolute number
+	RelativeNumber bool
+	AutoIndent     bool // start a new line with the indentation of the previous one
+	List           bool // show tabs and blanks with ListChars
+	// the runes of List, as parsed by ParseListChars
+	ListChars   string
+	ColorColumn int // column highlighted to show where lines get too long, 0 for none
+}
+
+// DefaultOptions are the options new views start with.
+var DefaultOptions = Options{
+	TabStop:    4,
+	ShiftWidth: 4,
+	ExpandTab:  false,
+	Wrap:       true,
+	ScrollOff:  0,
+	AutoIndent: true,
+	ListChars:  "tab:> ,trail:-",
+}
+
+// gutterWidth returns the width of the column of the signs of the
+// marks and lines and of the line number column including the space
+// separating them from the text, 0 if there are neither.
+func (v *View) gutterWidth() int {
+	g := 0
+	if len(v.marks) > 0 || len(v.lineSigns) > 0 {
+		g = 2
+	}
+	if !v.Number && !v.RelativeNumber {
+		return g
+	}
+	digits := len(strconv.Itoa(v.buffer.Lines()))
+	if digits < 3 {
+		digits = 3
+	}
+	return g + digits + 1
+}
+
+// A Mark points out the text between Off1 and Off2, e.g. a problem
+// found in it: the text is drawn as theme.Diagnostic and Sign is shown
+// as Element in the gutter of the line Off1 is in.
+type Mark struct {
+	Off1, Off2 int
+	Sign       rune
+	Element    theme.Element
+}
+
+// SetMarks replaces the marks shown by marks.
+func (v *View) SetMarks(marks []Mark) {
+	v.marks = marks
+	v.changed = true
+}
+
+// A LineSign is shown as Element in the gutter of line Line, e.g. to
+// point out a line changed since the file was saved.
+type LineSign struct {
+	Line    int
+	Sign    rune
+	Element theme.Element
+}
+
+// SetLineSigns replaces the signs of lines shown by signs.
+func (v *View) SetLineSigns(signs []LineSign) {
+	v.lineSigns = signs
+	v.changed = true
+}
+
+// signs returns the marks by the line their sign is shown in, with
+// the line signs as marks without text.
+func (v *View) signs() map[int]Mark {
+	signs := make(map[int]Mark)
+	for _, m := range v.marks {
+		line := v.buffer.LineOfOffset(m.Off1)
+		if _, ok := signs[line]; !ok {
+			signs[line] = m
+		}
+	}
+	for _, s := range v.lineSigns {
+		if _, ok := signs[s.Line]; !ok {
+			signs[s.Line] = Mark{Off1: -1, Off2: -1, Sign: s.Sign, Element: s.Element}
+		}
+	}
+	return signs
+}
+
+// displayGutter draws the sign and the number of line n into the gutter
+// of row y.
+func (v *View) displayGutter(scr screen.Screen, x0, y0, n, cursorLine int, signs map[int]Mark) {
+	if m, ok := signs[n]; ok {
+		style := theme.Current[m.Element]
+		scr.SetCell(x0, y0, m.Sign, style.Fg, style.Bg)
+	}
+	if v.Number || v.RelativeNumber {
+		v.displayNumber(scr, x0, y0, n, cursorLine)
+	}
+}
+
+// displayNumber draws the number of line n into the gutter of row y.
+func (v *View) displayNumber(scr screen.Screen, x0, y0, n, cursorLine int) {
+	num := n
+	if v.RelativeNumber && (n != cursorLine || !v.Number) {
+		num = n - cursorLine
+		if num < 0 {
+			num = -num
+		}
+	}
+	s := strconv.Itoa(num)
+	g := v.gutterWidth()
+	style := theme.Current[theme.LineNumber]
+	for i, r := range s {
+		scr.SetCell(x0+g-1-len(s)+i, y0, r, style.Fg, style.Bg)
+	}
+}
+
 type View struct {
+	Options
 	buffer        *buf.Buf // views may share same buffer
 	firstLine     int      // first visible line on screen
+	leftCol       int      // first visible column if not wrapping
 	width, height int      // size last time it was displayed
 	cursor        buf.Marker
+	goal          int                   // column kept moving up and down (see motion.Goal)
+	goalOff       int                   // while the cursor stays at goalOff, or -1
+	cursors       []buf.Marker          // additional cursors, see AddCursor
+	folds         []*fold               // see CreateFold
+	cursorX       int                   // screen position of the cursor last time it was displayed
+	cursorY       int                   // or -1 if the cursor was not visible
+	highlighter   highlight.Highlighter // may be nil
+	marks         []Mark                // sorted by Off1
+	lineSigns     []LineSign            // shown unless a mark has a sign in the line
+	match1        int                   // text between match1 and match2 is shown as
+	match2        int                   // a search match
+	selection     Selection             // kind of the current selection
+	anchor        buf.Marker            // the selection is between anchor and cursor
+	mode          string                // editing mode shown in the status line
+	focused       bool                  // the hardware cursor is in the view
+	jumps         jumpList              // positions jumps started at
+	observerID    int                   // as observer of buffer
+	changed       bool                  // buffer changed since last Display
+	drawn         drawState             // what was drawn by the last Display
+}
+
+// drawState is everything that determines what Display draws apart
+// from the contents of the buffer.  Display skips redrawing if it is
+// the same as last time and the buffer did not change.
+type drawState struct {
+	screen         screen.Screen
+	x0, y0, w, h   int
+	buffer         *buf.Buf
+	name           string
+	modified       bool
+	encoding       buf.Encoding
+	options        Options
+	firstLine      int
+	cursor, anchor int
+	match1, match2 int
+	selection      Selection
+	mode           string
+	focused        bool
 }
 
+func (v *View) drawState(scr screen.Screen, x0, y0, w, h int) drawState {
+	return drawState{
+		screen: scr,
+		x0:     x0, y0: y0, w: w, h: h,
+		buffer:    v.buffer,
+		name:      v.buffer.Name(),
+		modified:  v.buffer.Dirty(),
+		encoding:  v.buffer.Encoding(),
+		options:   v.Options,
+		firstLine: v.firstLine,
+		cursor:    v.cursor.Offset(),
+		anchor:    v.anchor.Offset(),
+		match1:    v.match1,
+		match2:    v.match2,
+		selection: v.selection,
+		mode:      v.mode,
+		focused:   v.focused,
+	}
+}
+
+// OnBufChange implements buf.BufferObserver.
+func (v *View) OnBufChange(c buf.Change) {
+	v.changed = true
+	if c.Deleted > 0 && len(v.folds) > 0 {
+		v.deleteFoldsIn(c.Off, c.Off+c.Deleted)
+	}
+}
+
+// Invalidate forces the next Display to redraw the view.
+func (v *View) Invalidate() {
+	v.changed = true
+}
+
+// Selection is the kind of selection of a view.
+type Selection int
+
+const (
+	SelectNone Selection = iota
+	SelectChars
+	SelectLines
+	SelectBlock // a rectangle, see Block
+)
+
 func (v *View) Init(b *buf.Buf) {
+	v.Options = DefaultOptions
 	v.buffer = b
 	v.firstLine = 1
 	// We initialize width and height with something
 	// sensible here.  Will be updated on first display
 	v.width = 80
 	v.height = 25
-	v.cursor = v.buffer.NewMarker(0)
+	v.newMarkers()
+	v.observerID = v.buffer.AddObserver(v)
+	v.cursorX, v.cursorY = -1, -1
+	v.goalOff = -1
+	v.changed = true
+}
+
+// newMarkers creates the cursor and the selection anchor.  Text typed at
+// the cursor ends up in front of it.  The anchor stays in front of text
+// inserted at its offset, so that a selection growing from a line start
+// includes text inserted at that start by someone else.
+func (v *View) newMarkers() {
+	v.cursor = v.buffer.NewMarkerWithGravity(0, buf.GravityRight)
+	v.anchor = v.buffer.NewMarkerWithGravity(0, buf.GravityLeft)
+}
+
+// SetBuffer makes the view display b, starting at its first line.
+func (v *View) SetBuffer(b *buf.Buf) {
+	v.Close()
+	v.buffer = b
+	v.observerID = v.buffer.AddObserver(v)
+	v.firstLine = 1
+	v.newMarkers()
+	v.selection = SelectNone
+	v.marks = nil
+	v.goalOff = -1
+}
+
+// Close detaches the view from its buffer, e.g. when its window is
+// closed.  Only SetBuffer may be called afterwards.
+func (v *View) Close() {
+	if closer, ok := v.highlighter.(interface{ Close() }); ok {
+		closer.Close()
+	}
+	v.highlighter = nil
+	v.cursor.Close()
+	v.anchor.Close()
+	v.RemoveCursors()
+	v.DeleteFolds()
+	v.buffer.RemoveObserver(v.observerID)
+	v.jumps.clear()
+}
+
+// SetMode sets the name of the editing mode shown in the status line.
+// Empty for none.
+func (v *View) SetMode(mode string) {
+	v.mode = mode
+}
+
+// SetFocused sets whether the view has the focus.  The editor places
+// the hardware cursor in the focused view, the others draw their
+// cursor themselves, dimmed.
+func (v *View) SetFocused(focused bool) {
+	v.focused = focused
+}
+
+// Buffer returns the buffer displayed by the view.
+func (v *View) Buffer() *buf.Buf {
+	return v.buffer
+}
+
+// SetHighlighter sets the highlighter used to display the buffer.
+// nil disables highlighting.
+func (v *View) SetHighlighter(h highlight.Highlighter) {
+	v.highlighter = h
+	v.changed = true
+}
+
+// spans returns the highlighted spans of line n.
+func (v *View) spans(n int) []highlight.Span {
+	if v.highlighter == nil {
+		return nil
+	}
+	return v.highlighter.Line(n)
+}
+
+// SetMatch marks the text between off1 and off2 as a search match.
+// Pass off1 == off2 to remove the mark.
+func (v *View) SetMatch(off1, off2 int) {
+	v.match1, v.match2 = off1, off2
+}
+
+// Select starts a selection of kind sel anchored at the cursor,
+// or changes the kind of the current one.  SelectNone ends the selection.
+func (v *View) Select(sel Selection) {
+	if v.selection == SelectNone {
+		v.anchor.Move(v.cursor.Offset())
+	}
+	v.selection = sel
+}
+
+// SelectionKind returns the kind of the current selection.
+func (v *View) SelectionKind() Selection {
+	return v.selection
+}
+
+// SwapAnchor exchanges the cursor and the anchor of the selection.
+func (v *View) SwapAnchor() {
+	a := v.anchor.Offset()
+	v.anchor.Move(v.cursor.Offset())
+	v.cursor.Move(a)
+}
+
+// SelectRange selects the text in r, line wise if r is.  The cursor
+// ends up on the last rune of r.
+func (v *View) SelectRange(r motion.Range) {
+	v.selection = SelectChars
+	if r.Linewise {
+		v.selection = SelectLines
+	}
+	v.anchor.Move(r.Off1)
+	last := r.Off2
+	if r.Off2 > r.Off1 {
+		rd := v.buffer.NewReader(r.Off2)
+		rd.Reverse()
+		rd.ReadRune()
+		last = rd.Offset()
+	}
+	v.cursor.Move(last)
+}
+
+// Selection returns the selected text.  Character wise selections
+// include the rune under the cursor (or anchor), line wise selections
+// all lines touched, as do block selections (see BlockRanges for the
+// text in the block).  Returns false if there is no selection.
+func (v *View) Selection() (motion.Range, bool) {
+	if v.selection == SelectNone {
+		return motion.Range{}, false
+	}
+	r := motion.Range{Off1: v.anchor.Offset(), Off2: v.cursor.Offset()}
+	if r.Off2 < r.Off1 {
+		r.Off1, r.Off2 = r.Off2, r.Off1
+	}
+	if v.selection == SelectLines || v.selection == SelectBlock {
+		r.Linewise = true
+		r.Off1 = v.buffer.Line(v.buffer.LineOfOffset(r.Off1))
+		if last := v.buffer.LineOfOffset(r.Off2); last < v.buffer.Lines() {
+			r.Off2 = v.buffer.Line(last + 1)
+		} else {
+			r.Off2 = v.buffer.Len()
+		}
+	} else if r.Off2 < v.buffer.Len() {
+		rd := v.buffer.NewReader(r.Off2)
+		rd.ReadRune()
+		r.Off2 = rd.Offset()
+	}
+	return r, true
+}
+
+// Column returns the display column (starting at 0) of off in its line,
+// taking tab stops into account.
+func (v *View) Column(off int) int {
+	col := 0
+	rd := v.buffer.NewReader(v.buffer.Line(v.buffer.LineOfOffset(off)))
+	prev := '\n'
+	for rd.Offset() < off {
+		r, size, err := rd.ReadRune()
+		if err != nil {
+			break
+		}
+		if r == '\t' {
+			col += v.TabStop - col%v.TabStop
+		} else {
+			col += cellWidth(prev, r, size)
+		}
+		prev = r
+	}
+	return col
+}
+
+// FirstLine returns the first line shown in the view.
+func (v *View) FirstLine() int {
+	return v.firstLine
+}
+
+// SetFirstLine scrolls the view so that it starts with line n.
+func (v *View) SetFirstLine(n int) {
+	if n < 1 {
+		n = 1
+	}
+	v.firstLine = n
+}
+
+// ShowLine scrolls the view so that line n is visible, centering
+// it if it wasn't.
+func (v *View) ShowLine(n int) {
+	if n < v.firstLine || n >= v.firstLine+v.height {
+		v.SetFirstLine(n - v.height/2)
+	}
 }
 
-func (v *View) PageDown() {
-	lines := v.buffer.Lines()
-	v.firstLine += v.height - 2 // like a little overlap
-	if v.firstLine > lines-v.height+1 {
-		v.firstLine = lines - v.height + 1
+// scrollOff returns the number of lines kept visible above and below
+// the cursor, ScrollOff as far as the view is high enough.
+func (v *View) scrollOff() int {
+	if 2*v.ScrollOff >= v.height {
+		return (v.height - 1) / 2
 	}
+	return v.ScrollOff
 }
 
-func (v *View) PageUp() {
-	v.firstLine -= v.height - 2 // like a little overlap
-	if v.firstLine < 0 {
-		v.firstLine = 0
+// EnsureCursorVisible scrolls the view so that the cursor and ScrollOff
+// lines above and below it are visible, as far as the view is high enough.
+func (v *View) EnsureCursorVisible() {
+	line := v.visibleLine(v.buffer.LineOfOffset(v.cursor.Offset()))
+	so := v.scrollOff()
+	if line-so < v.firstLine {
+		v.SetFirstLine(line - so)
+	} else {
+		last := line + so
+		if last > v.buffer.Lines() {
+			last = v.buffer.Lines()
+		}
+		// every line takes at least one row
+		if v.firstLine < last-v.height+1 {
+			v.firstLine = last - v.height + 1
+		}
+		rows := 0
+		for n := v.firstLine; n <= last; n++ {
+			rows += v.rows(n)
+		}
+		for rows > v.height && v.firstLine < line {
+			rows -= v.rows(v.firstLine)
+			v.firstLine++
+		}
 	}
+	v.firstLine = v.visibleLine(v.firstLine)
+	v.scrollToCursor()
+}
+
+// CursorPosition returns the screen position of the cursor as of the
+// last call to Display.  ok is false if the cursor wasn't visible.
+func (v *View) CursorPosition() (x, y int, ok bool) {
+	return v.cursorX, v.cursorY, v.cursorY >= 0
+}
+
+// Cursor returns the offset of the cursor.
+func (v *View) Cursor() int {
+	return v.cursor.Offset()
+}
+
+// SetCursor moves the cursor to off.
+func (v *View) SetCursor(off int) {
+	v.cursor.Move(off)
 }
 
-// MoveCursor moves the cursor by motion
+// endOfLine is the goal column after $.
+const endOfLine = math.MaxInt
+
+// MoveCursor moves the cursor by motion.  Moving up and down keeps the
+// goal column, the column the cursor was in before the first of those
+// moves (see motion.Goal).
 func (v *View) MoveCursor(m motion.Motion) {
-	rd := v.buffer.NewReader(v.cursor.Offset())
-	if m.Move(v.buffer, rd) {
-		pos, _ := rd.Seek(0, 1)
-		v.cursor.Move(int(pos))
+	cursor := v.cursor.Offset()
+	goal := v.goal
+	if v.goalOff != cursor {
+		// moved or changed since the goal was set
+		goal = v.Column(cursor)
+	}
+	rd := v.buffer.NewReader(cursor)
+	if !m.Move(v.buffer, rd) {
+		return
+	}
+	off := rd.Offset()
+	switch motion.GoalOf(m) {
+	case motion.SetGoal:
+		v.cursor.Move(off)
+		v.goalOff = -1
+		return
+	case motion.KeepGoal:
+		if from, to := v.buffer.LineOfOffset(cursor), v.buffer.LineOfOffset(off); len(v.folds) > 0 && from != to {
+			// a closed fold counts as a single line
+			off = v.buffer.Line(v.moveLines(from, to-from))
+		}
+		off = v.columnOffset(off, goal)
+	case motion.EndGoal:
+		goal = endOfLine
+	}
+	v.cursor.Move(off)
+	v.goal, v.goalOff = goal, off
+}
+
+// columnOffset returns the offset of the rune at column col of the line
+// containing off, or of its last rune if the line is shorter.
+func (v *View) columnOffset(off, col int) int {
+	off = v.buffer.Line(v.buffer.LineOfOffset(off))
+	rd := v.buffer.NewReader(off)
+	prev := '\n'
+	for c := 0; c <= col; {
+		start := rd.Offset()
+		r, size, err := rd.ReadRune()
+		if err != nil || r == '\n' {
+			break
+		}
+		if r == '\t' {
+			off = start
+			c += v.TabStop - c%v.TabStop
+		} else if width := cellWidth(prev, r, size); width > 0 {
+			// not within a grapheme cluster
+			off = start
+			c += width
+		}
+		prev = r
+	}
+	return off
+}
+
+// Resize sets the size of the window showing the view like Display
+// does.  Views that aren't displayed (e.g. when running without a
+// terminal) need it for the motions depending on the screen like gj.
+func (v *View) Resize(w, h int) {
+	if h > 1 {
+		// status line
+		h--
+	}
+	if g := v.gutterWidth(); g > 0 && g < w {
+		w -= g
 	}
+	v.width = w
+	v.height = h
 }
 
-func (v *View) Display() {
-	// This implements simple wrapping
+// Display draws the view into the rectangle of scr of size w x h at x0, y0.
+// The last row is used for the status line.
+// It neither clears nor flushes the whole screen, that is the job of the
+// caller (see Layout).  If neither the buffer nor anything else
+// affecting the view changed since the last call, nothing is drawn,
+// as the screen still shows the view.
+func (v *View) Display(scr screen.Screen, x0, y0, w, h int) {
+	state := v.drawState(scr, x0, y0, w, h)
+	if !v.changed && state == v.drawn {
+		return
+	}
+	v.changed = false
+	v.drawn = state
+	if h > 1 {
+		h--
+		v.displayStatus(scr, x0, y0+h, w)
+	}
+	// This implements simple wrapping, or horizontal scrolling
+	// if Wrap is off
 	const coldef = termbox.ColorDefault
-	termbox.Clear(coldef, coldef)
-	w, h := termbox.Size()
+	v.cursorX, v.cursorY = -1, -1
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			scr.SetCell(x0+x, y0+y, ' ', coldef, coldef)
+		}
+	}
+	// the gutter with the line numbers is left of the text
+	gx := x0
+	cursorLine := v.buffer.LineOfOffset(v.cursor.Offset())
+	if g := v.gutterWidth(); g > 0 && g < w {
+		x0 += g
+		w -= g
+	}
 	v.width = w
 	v.height = h
+	v.scrollToCursor()
+	v.firstLine = v.visibleLine(v.firstLine)
 	off := v.buffer.Line(v.firstLine)
 	r := v.buffer.NewReader(off)
-	x := 0
+	line := v.firstLine
+	signs := v.signs()
+	if x0 > gx {
+		v.displayGutter(scr, gx, y0, line, cursorLine, signs)
+	}
+	spans := v.spans(line)
+	sel, _ := v.Selection()
+	var blocks []motion.Range
+	if v.selection == SelectBlock {
+		blocks = v.BlockRanges()
+		sel = motion.Range{}
+	}
+	cursors := make(map[int]bool, len(v.cursors))
+	for _, c := range v.cursors {
+		cursors[c.Offset()] = true
+	}
+	// the screen column of the color column, -1 if there is none
+	ccx := v.ColorColumn - 1 - v.leftCol
+	var lc ListChars
+	if v.List {
+		lc, _ = ParseListChars(v.ListChars)
+	}
+	wsStyle := theme.Current[theme.Whitespace]
+	// where the blanks ending the line start
+	trail := v.trailStart(line)
+	col := 0 // column in the line, or in the screen row when wrapping
 	y := 0
-	termbox.HideCursor()
+	// parts of the line left or right of the view if not wrapping
+	cutLeft, cutRight := false, false
+	// put draws r taking width columns, a wide rune covers the cell
+	// following it too
+	put := func(r rune, width int, style theme.Style) {
+		if x := col - v.leftCol; x <= ccx && ccx < x+width {
+			style = style.Over(theme.Current[theme.ColorColumn])
+		}
+		switch x := col - v.leftCol; {
+		case x < 0:
+			cutLeft = true
+		case x+width > w:
+			cutRight = true
+		case width > 0:
+			scr.SetCell(x0+x, y0+y, r, style.Fg, style.Bg)
+		}
+		col += width
+	}
+	// startRow draws the color column of the row of text starting
+	// at y
+	startRow := func() {
+		if 0 <= ccx && ccx < w && y < h {
+			style := theme.Current[theme.ColorColumn]
+			scr.SetCell(x0+ccx, y0+y, ' ', style.Fg, style.Bg)
+		}
+	}
+	// endLine marks the parts of the line not shown
+	endLine := func() {
+		if cutLeft {
+			scr.SetCell(x0, y0+y, '<', coldef, coldef)
+		}
+		if cutRight {
+			scr.SetCell(x0+w-1, y0+y, '>', coldef, coldef)
+		}
+		cutLeft, cutRight = false, false
+	}
+	// skipFolds draws the closed folds starting at the current line as a
+	// row each and continues behind them.  Returns false if the buffer
+	// ends with a fold.
+	skipFolds := func() bool {
+		for y < h {
+			first, last, ok := v.closedFold(line)
+			if !ok {
+				startRow()
+				return true
+			}
+			style := theme.Current[theme.Fold]
+			x := 0
+			for _, c := range v.foldText(first, last) {
+				if x >= w {
+					break
+				}
+				scr.SetCell(x0+x, y0+y, c, style.Fg, style.Bg)
+				x++
+			}
+			for ; x < w; x++ {
+				scr.SetCell(x0+x, y0+y, ' ', style.Fg, style.Bg)
+			}
+			if c := v.buffer.LineOfOffset(v.cursor.Offset()); first <= c && c <= last {
+				v.cursorX, v.cursorY = x0, y0+y
+			}
+			if last >= v.buffer.Lines() {
+				return false
+			}
+			y++
+			line = last + 1
+			off = v.buffer.Line(line)
+			r.Seek(int64(off), 0)
+			spans = v.spans(line)
+			trail = v.trailStart(line)
+			if x0 > gx && y < h {
+				v.displayGutter(scr, gx, y0+y, line, cursorLine, signs)
+			}
+		}
+		return true
+	}
+	if !skipFolds() {
+		return
+	}
+	prev := '\n' // the rune before, see cellWidth
 	for {
 		rune, n, err := r.ReadRune()
-		if v.cursor.Offset() == off {
-			termbox.SetCursor(x, y)
+		for len(spans) > 0 && spans[0].Off2 <= off {
+			spans = spans[1:]
 		}
-		off += n
-		if x >= w {
-			x = 0
+		for len(blocks) > 0 && blocks[0].Off2 <= off {
+			blocks = blocks[1:]
+		}
+		if len(blocks) > 0 && blocks[0].Off1 <= off {
+			sel = blocks[0]
+		}
+		style := highlight.StyleOf(highlight.Normal)
+		if len(spans) > 0 && spans[0].Off1 <= off {
+			style = highlight.StyleOf(spans[0].Kind)
+		}
+		for _, m := range v.marks {
+			if m.Off1 > off {
+				break
+			}
+			if off < m.Off2 || off == m.Off1 {
+				style = theme.Current[theme.Diagnostic].Over(style)
+				break
+			}
+		}
+		if v.match1 <= off && off < v.match2 {
+			style = theme.Current[theme.SearchMatch].Over(style)
+		} else if sel.Off1 <= off && off < sel.Off2 {
+			style = theme.Current[theme.Selection].Over(style)
+		}
+		if v.Wrap && v.wraps(prev, rune, n, col) {
+			col = 0
 			y++
+			startRow()
 		}
+		if x := col - v.leftCol; cursors[off] && y < h && 0 <= x && x < w {
+			style = theme.Current[theme.ExtraCursor].Over(style)
+			if rune == '\n' || err == io.EOF {
+				scr.SetCell(x0+x, y0+y, ' ', style.Fg, style.Bg)
+			}
+		}
+		if x := col - v.leftCol; v.cursor.Offset() == off && y < h && 0 <= x && x < w {
+			v.cursorX, v.cursorY = x0+x, y0+y
+			if !v.focused {
+				style.Fg ^= termbox.AttrReverse
+				style.Fg |= termbox.AttrDim
+				if rune == '\n' || err == io.EOF {
+					// nothing drawn for the rune
+					scr.SetCell(v.cursorX, v.cursorY, ' ', style.Fg, style.Bg)
+				}
+			}
+		}
+		off += n
 		if y >= h || err == io.EOF {
+			if y < h {
+				endLine()
+			}
 			break
 		}
 		switch rune {
 		case '\n':
+			if lc.EOL != 0 {
+				put(lc.EOL, 1, wsStyle.Over(style))
+			}
+			endLine()
 			y++
-			x = 0
+			col = 0
+			line++
+			spans = v.spans(line)
+			trail = v.trailStart(line)
+			if x0 > gx && y < h {
+				v.displayGutter(scr, gx, y0+y, line, cursorLine, signs)
+			}
+			if !skipFolds() {
+				return
+			}
+		case '\r':
+			// part of a \r\n line break
+			if next, _, _ := r.ReadRune(); next == '\n' {
+				r.UnreadRune()
+				break
+			}
+			r.UnreadRune()
+			for _, c := range escape(rune, n, 0) {
+				put(c, 1, style)
+			}
 		case '\t':
+			c := ' '
+			if lc.Tab[0] != 0 {
+				c, style = lc.Tab[0], wsStyle.Over(style)
+			}
 			for {
-				termbox.SetCell(x, y, ' ', coldef, coldef)
-				x++
-				if x%4 == 0 || x >= w {
+				put(c, 1, style)
+				if col%v.TabStop == 0 || (v.Wrap && col >= w) {
 					break
 				}
+				if lc.Tab[0] != 0 {
+					c = lc.Tab[1]
+				}
+			}
+		case ' ':
+			switch {
+			case lc.Trail != 0 && off-n >= trail:
+				put(lc.Trail, 1, wsStyle.Over(style))
+			case lc.Space != 0:
+				put(lc.Space, 1, wsStyle.Over(style))
+			default:
+				put(' ', 1, style)
 			}
 		default:
-			termbox.SetCell(x, y, rune, coldef, coldef)
-			x++
+			if rune < utf8.RuneSelf && rune >= ' ' && rune != 0x7f {
+				put(rune, 1, style)
+			} else if e := escape(rune, n, v.buffer.Bytes(off-n, off)[0]); e != "" {
+				for _, c := range e {
+					put(c, 1, style)
+				}
+			} else {
+				put(rune, cellWidth(prev, rune, n), style)
+			}
 		}
+		prev = rune
 	}
-	termbox.Flush()
 }
 
+// scrollToCursor scrolls horizontally so that the cursor is visible,
+// if not wrapping.
+func (v *View) scrollToCursor() {
+	if v.Wrap {
+		v.leftCol = 0
+		return
+	}
+	col := v.Column(v.cursor.Offset())
+	if col < v.leftCol {
+		v.leftCol = col
+	} else if col >= v.leftCol+v.width {
+		v.leftCol = col - v.width + 1
+	}
+}
+
+// displayStatus draws the status line of the view at x0, y0.
+func (v *View) displayStatus(scr screen.Screen, x0, y0, w int) {
+	cursor := v.cursor.Offset()
+	line := v.buffer.LineOfOffset(cursor)
+	status := statusline.Status{
+		Name:     v.buffer.Name(),
+		Modified: v.buffer.Dirty(),
+		Line:     line,
+		Column:   v.Column(cursor) + 1,
+		Lines:    v.buffer.Lines(),
+		Mode:     v.mode,
+	}
+	if enc := v.buffer.Encoding(); enc != buf.UTF8 {
+		status.Encoding = enc.String()
+	}
+	x := x0
+	style := theme.Current[theme.StatusLine]
+	for _, r := range statusline.Format(status, w) {
+		scr.SetCell(x, y0, r, style.Fg, style.Bg)
+		x++
+	}
+}
@@ -0,0 +1,137 @@
+package view
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/screen"
+	"github.com/bgrundmann/e/theme"
+)
+
+func TestDisplay(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte("hello\nworld\n"))
+	var v View
+	v.Init(&b)
+	v.SetCursor(7)
+	s := screen.NewMemory(20, 4)
+	v.Display(s, 0, 0, 20, 4)
+	rows := strings.Split(s.String(), "\n")
+	if rows[0] != "hello" || rows[1] != "world" || rows[2] != "" {
+		t.Errorf("got text %q", rows[:3])
+	}
+	if !strings.Contains(rows[3], "2,2") {
+		t.Errorf("status line %q doesn't show the cursor position 2,2", rows[3])
+	}
+	if x, y, ok := v.CursorPosition(); !ok || x != 1 || y != 1 {
+		t.Errorf("cursor at %d, %d, %v, want 1, 1", x, y, ok)
+	}
+
+	// drawn into a rectangle of the screen
+	s = screen.NewMemory(20, 6)
+	v.Display(s, 3, 1, 10, 4)
+	rows = strings.Split(s.String(), "\n")
+	if rows[0] != "" || rows[1] != "   hello" || rows[2] != "   world" || rows[5] != "" {
+		t.Errorf("got %q", rows)
+	}
+	if x, y, ok := v.CursorPosition(); !ok || x != 4 || y != 2 {
+		t.Errorf("cursor at %d, %d, %v, want 4, 2", x, y, ok)
+	}
+}
+
+func TestDisplayGraphemes(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	// an emoji sequence joined by zero width joiners and e with a
+	// combining accent
+	b.Insert(0, []byte("\U0001f468\u200d\U0001f469\u200d\U0001f467e\u0301x\n"))
+	var v View
+	v.Init(&b)
+	s := screen.NewMemory(20, 3)
+	v.Display(s, 0, 0, 20, 3)
+	if row := strings.Split(s.String(), "\n")[0]; row != "\U0001f468 ex" {
+		t.Errorf("got %q", row)
+	}
+	x := strings.Index(b.String(), "x")
+	if col := v.Column(x); col != 3 {
+		t.Errorf("x in column %d, want 3", col)
+	}
+}
+
+func TestDisplayList(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte("\ta b  \nxy\n"))
+	var v View
+	v.Init(&b)
+	v.List = true
+	v.ListChars = "tab:>-,trail:~,eol:$"
+	v.ColorColumn = 3
+	s := screen.NewMemory(20, 4)
+	v.Display(s, 0, 0, 20, 4)
+	rows := strings.Split(s.String(), "\n")
+	if rows[0] != ">---a b~~$" || rows[1] != "xy$" {
+		t.Errorf("got %q", rows[:2])
+	}
+	for y := 0; y < 3; y++ {
+		if c := s.Cell(2, y); c.Bg != theme.Current[theme.ColorColumn].Bg {
+			t.Errorf("column 3 of row %d has background %v", y, c.Bg)
+		}
+	}
+}
+
+func TestParseListChars(t *testing.T) {
+	lc, err := ParseListChars("tab:» ,trail:·,eol:$")
+	if want := (ListChars{Tab: [2]rune{'»', ' '}, Trail: '·', EOL: '$'}); err != nil || lc != want {
+		t.Errorf("got %v, %v", lc, err)
+	}
+	if _, err := ParseListChars("tab:>"); err == nil {
+		t.Error("tab:> parsed")
+	}
+}
+
+func TestScroll(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	for i := 0; i < 100; i++ {
+		b.Insert(b.Len(), []byte("line\n"))
+	}
+	var v View
+	v.Init(&b)
+	v.Resize(20, 11) // 10 rows of text
+	v.ScrollOff = 2
+	line := func() int { return b.LineOfOffset(v.Cursor()) }
+
+	v.ScrollDown(5)
+	if v.FirstLine() != 6 || line() != 8 {
+		t.Errorf("CTRL-E: first line %d, cursor in line %d, want 6, 8", v.FirstLine(), line())
+	}
+	v.ScrollUp(3)
+	if v.FirstLine() != 3 || line() != 8 {
+		t.Errorf("CTRL-Y: first line %d, cursor in line %d, want 3, 8", v.FirstLine(), line())
+	}
+	v.HalfPageDown(0)
+	if v.FirstLine() != 8 || line() != 13 {
+		t.Errorf("CTRL-D: first line %d, cursor in line %d, want 8, 13", v.FirstLine(), line())
+	}
+	v.HalfPageUp(2)
+	if v.FirstLine() != 6 || line() != 11 {
+		t.Errorf("2 CTRL-U: first line %d, cursor in line %d, want 6, 11", v.FirstLine(), line())
+	}
+	for _, test := range []struct {
+		name  string
+		f     func()
+		first int
+	}{
+		{"zt", v.CursorLineToTop, 11},
+		{"zz", v.CursorLineToCenter, 7},
+		{"zb", v.CursorLineToBottom, 2},
+	} {
+		test.f()
+		if v.FirstLine() != test.first || line() != 11 {
+			t.Errorf("%s: first line %d, cursor in line %d, want %d, 11", test.name, v.FirstLine(), line(), test.first)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/bgrundmann/e/view"
+	"github.com/nsf/termbox-go"
+)
+
+// startVisual enters visual mode selecting characters or lines.
+func (ed *editor) startVisual(sel view.Selection) {
+	ed.mode = modeVisual
+	ed.focus.Select(sel)
+}
+
+// endVisual leaves visual mode.
+func (ed *editor) endVisual() {
+	ed.setVisualMarks()
+	ed.focus.Select(view.SelectNone)
+	ed.mode = modeNormal
+	ed.reset()
+}
+
+// visualCmdline leaves visual mode and enters command line mode with
+// the range of the selected lines.
+func (ed *editor) visualCmdline() {
+	ed.endVisual()
+	ed.startCmdline()
+	for _, r := range "'<,'>" {
+		ed.cmdline.insert(r)
+	}
+}
+
+// toggleVisual changes the kind of the selection to sel or ends
+// visual mode if it already is of that kind.
+func (ed *editor) toggleVisual(sel view.Selection) {
+	if ed.focus.SelectionKind() == sel {
+		ed.endVisual()
+	} else {
+		ed.focus.Select(sel)
+	}
+}
+
+// handleVisualKey processes a key press in visual mode.  Motions extend
+// the selection, operators apply to it.
+func (ed *editor) handleVisualKey(ev termbox.Event) {
+	v := ed.focus
+	if ed.findAction != "" {
+		if m, ok := ed.findKey(ev); ok {
+			v.MoveCursor(m)
+		}
+		ed.reset()
+		return
+	}
+	if ed.markAction != "" {
+		if m, ok := ed.markKey(ev); ok {
+			v.MoveCursor(m)
+		}
+		ed.reset()
+		return
+	}
+	if len(ed.keys) == 0 && ed.prefixKey(ev) {
+		return
+	}
+	action, ok := ed.lookupKey("visual", ev)
+	if !ok {
+		return
+	}
+	if ed.startFind(action) || ed.startMark(action) {
+		return
+	}
+	if m, ok := ed.motion(action); ok {
+		v.MoveCursor(m)
+		return
+	}
+	if obj, ok := textObjects[action]; ok {
+		if r, ok := obj(v.Buffer(), v.Cursor()); ok {
+			v.SelectRange(r)
+		}
+		ed.reset()
+		return
+	}
+	if op, ok := operators[action]; ok {
+		if err := ed.modifiable(); modifyingActions[action] && err != nil {
+			ed.setError(err)
+			ed.endVisual()
+			return
+		}
+		if f, ok := blockOperators[action]; ok && v.SelectionKind() == view.SelectBlock {
+			f(ed)
+			ed.reset()
+			return
+		}
+		r, _ := v.Selection()
+		ed.setVisualMarks()
+		// the operator may switch to insert mode, so leave visual mode first
+		v.Select(view.SelectNone)
+		ed.mode = modeNormal
+		op.Apply(ed, r)
+		ed.reset()
+		return
+	}
+	if f, ok := visualActions[action]; ok {
+		f(ed)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/ex"
+	"github.com/bgrundmann/e/view"
+)
+
+// setFocus makes v the view receiving the key strokes.
+func (ed *editor) setFocus(v *view.View) {
+	ed.focus = v
+}
+
+// focusNeighbor moves the focus to the window next to the focused one
+// in direction d.
+func (ed *editor) focusNeighbor(d view.Direction) {
+	w, h := ed.width, ed.height
+	if w == 0 {
+		w, h = ed.screen.Size()
+	}
+	top, rows := ed.windowArea(h)
+	x, y, _ := ed.focus.CursorPosition()
+	if n := ed.layout.Leaf(ed.focus).Neighbor(d, w, rows, x, y-top); n != nil {
+		ed.setFocus(n.View())
+	}
+}
+
+// split splits the focused window in direction dir.  The new window
+// shows the same buffer at the same position and gets the focus.
+func (ed *editor) split(dir view.SplitDirection) {
+	old := ed.focus
+	var v view.View
+	v.Init(old.Buffer())
+	// like vim the new window starts with the options of the old one
+	ed.winOptions[&v] = ed.windowOptions(old).Copy()
+	ed.showBuffer(&v, old.Buffer())
+	v.SetCursor(old.Cursor())
+	v.SetFirstLine(old.FirstLine())
+	ed.layout.Leaf(old).Split(dir, &v)
+	ed.setFocus(&v)
+	ed.relayout()
+}
+
+// closeWindow closes the focused window, closing the tab page with its
+// last window.  The last window can't be closed, neither can the last
+// window showing a modified buffer unless force is set.
+func (ed *editor) closeWindow(force bool) error {
+	leaf := ed.layout.Leaf(ed.focus)
+	if leaf.Root() == leaf {
+		if len(ed.tabs) > 1 {
+			return ed.closeTab(force)
+		}
+		return errors.New("Cannot close last window")
+	}
+	b := ed.focus.Buffer()
+	if ed.unsaved(b) && !force && ed.windowsShowing(b) == 1 {
+		return errors.New("No write since last change (add ! to override)")
+	}
+	closed := ed.focus
+	ed.setFocus(leaf.Close().View())
+	ed.hide(closed)
+	closed.Close()
+	delete(ed.winOptions, closed)
+	ed.relayout()
+	return nil
+}
+
+// equalize gives all windows the same size.
+func (ed *editor) equalize() {
+	ed.layout.Equalize()
+	ed.relayout()
+}
+
+// relayout tells the windows their new size after the layout changed.
+func (ed *editor) relayout() {
+	if ed.width > 0 {
+		ed.resize(ed.width, ed.height)
+	}
+}
+
+// windowsShowing returns the number of windows showing b.
+func (ed *editor) windowsShowing(b *buf.Buf) int {
+	n := 0
+	for _, shown := range ed.buffers() {
+		if shown == b {
+			n++
+		}
+	}
+	return n
+}
+
+// invalidateWindows makes the windows of the current tab page redraw
+// themselves, after something else was drawn over them.
+func (ed *editor) invalidateWindows() {
+	ed.layout.Each(0, 0, 0, 0, func(leaf *view.Layout, x, y, w, h int) {
+		leaf.View().Invalidate()
+	})
+}
+
+// :sp[lit] and :vs[plit] split the current window.
+func (ed *editor) cmdSplit(dir view.SplitDirection) func(cmd ex.Command) error {
+	return func(cmd ex.Command) error {
+		ed.split(dir)
+		return nil
+	}
+}
+
+// :clo[se] closes the current window.
+func (ed *editor) cmdClose(cmd ex.Command) error {
+	return ed.closeWindow(cmd.Bang)
+}
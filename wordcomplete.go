@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+	"github.com/bgrundmann/e/runewidth"
+	"github.com/bgrundmann/e/view"
+	"github.com/bgrundmann/e/wordindex"
+)
+
+// wordCompletion is the state of completing the word before the cursor
+// in insert mode with <C-n> and <C-p>.
+type wordCompletion struct {
+	start int    // offset of the completed word
+	typed string // the part of the word typed before completing
+	popup view.Popup
+}
+
+// completionActions are the insert mode actions that complete words.
+// Any other key accepts the completion inserted.
+var completionActions = map[string]func(ed *editor){
+	"complete-next":     func(ed *editor) { ed.completeWord(1) },
+	"complete-previous": func(ed *editor) { ed.completeWord(-1) },
+	"complete-cancel":   (*editor).cancelCompletion,
+}
+
+// completeWord replaces the word before the cursor by the next (dir 1)
+// or previous (dir -1) of the words in the buffers starting with it,
+// cycling through the candidates and the word as typed.
+func (ed *editor) completeWord(dir int) {
+	c := ed.completion
+	if c == nil {
+		v := ed.focus
+		b := v.Buffer()
+		start := wordStart(b, v.Cursor())
+		typed := string(b.Bytes(start, v.Cursor()))
+		words := ed.completeWords(typed)
+		if len(words) == 0 {
+			ed.setError(errors.New("Pattern not found"))
+			return
+		}
+		c = &wordCompletion{start: start, typed: typed, popup: view.Popup{Items: words, Selected: -1}}
+		ed.completion = c
+	}
+	// -1 selects the word as typed
+	n := len(c.popup.Items) + 1
+	c.popup.Selected = (c.popup.Selected+1+dir+n)%n - 1
+	ed.replaceCompleted(c.current())
+}
+
+// wordStart returns the offset of the word ending at off, off if there
+// is none.
+func wordStart(b *buf.Buf, off int) int {
+	for off > 0 {
+		from := off - utf8.UTFMax
+		if from < 0 {
+			from = 0
+		}
+		r, size := utf8.DecodeLastRune(b.Bytes(from, off))
+		if !wordindex.IsWordRune(r) {
+			break
+		}
+		off -= size
+	}
+	return off
+}
+
+// current returns the text the completion puts in place of the word.
+func (c *wordCompletion) current() string {
+	if c.popup.Selected < 0 {
+		return c.typed
+	}
+	return c.popup.Items[c.popup.Selected]
+}
+
+// cancelCompletion goes back to the word as typed.
+func (ed *editor) cancelCompletion() {
+	if c := ed.completion; c != nil {
+		ed.replaceCompleted(c.typed)
+		ed.completion = nil
+	}
+}
+
+// replaceCompleted replaces the text between the start of the completed
+// word and the cursor by s.
+func (ed *editor) replaceCompleted(s string) {
+	v := ed.focus
+	start := ed.completion.start
+	if err := v.Buffer().TryDelete(start, v.Cursor()); err != nil {
+		ed.setError(err)
+		return
+	}
+	v.SetCursor(start)
+	ed.insert(s)
+}
+
+// completeWords returns the words starting with prefix of the focused
+// buffer followed by those only found in the other buffers.
+func (ed *editor) completeWords(prefix string) []string {
+	bufs := []*buf.Buf{ed.focus.Buffer()}
+	for _, e := range ed.bufs {
+		if e.b != ed.focus.Buffer() {
+			bufs = append(bufs, e.b)
+		}
+	}
+	var words []string
+	seen := map[string]bool{}
+	for _, b := range bufs {
+		x := ed.wordIndex[b]
+		if x == nil {
+			x = wordindex.New(b)
+			ed.wordIndex[b] = x
+		}
+		for _, w := range x.Complete(prefix) {
+			if !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+// displayCompletion draws the candidates of the completion below (or
+// above) the completed word, on a screen w x h cells big.
+func (ed *editor) displayCompletion(w, h int) bool {
+	c := ed.completion
+	if c == nil || ed.mode != modeInsert {
+		return false
+	}
+	x, y, ok := ed.focus.CursorPosition()
+	if !ok {
+		return false
+	}
+	x -= runewidth.StringWidth(c.current())
+	if x < 0 {
+		x = 0
+	}
+	c.popup.Display(ed.screen, x, y, w, h-1)
+	return true
+}
@@ -0,0 +1,141 @@
+// Package wordindex keeps track of the words in a buffer for completing
+// them.  The index follows the changes of the buffer, only the lines
+// changed are indexed again, and only when the words are asked for.
+package wordindex
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+// An Index holds the words of a buffer.
+type Index struct {
+	b  *buf.Buf
+	id int // as observer of b
+	// lines[i] are the words of line i+1, nil if it has to be indexed
+	// (again)
+	lines  []*[]string
+	stale  int            // number of lines to be indexed
+	counts map[string]int // number of occurrences of the words indexed
+}
+
+// New returns the index of b, which follows the changes of b until
+// closed.
+func New(b *buf.Buf) *Index {
+	x := &Index{b: b, counts: make(map[string]int)}
+	x.lines = make([]*[]string, b.Lines())
+	x.stale = len(x.lines)
+	x.id = b.AddObserver(x)
+	return x
+}
+
+// Close stops following the changes of the buffer.
+func (x *Index) Close() {
+	x.b.RemoveObserver(x.id)
+}
+
+// OnBufChange implements buf.BufferObserver.  The lines touched by the
+// change are forgotten, to be indexed again when needed.
+func (x *Index) OnBufChange(c buf.Change) {
+	pos, err := x.b.PositionFromOffset(c.Off)
+	if err != nil {
+		return
+	}
+	first := pos.Line - 1
+	deleted := strings.Count(string(x.b.Bytes(c.Off, c.Off+c.Deleted)), "\n")
+	last := first + deleted // inclusive
+	for i := first; i <= last && i < len(x.lines); i++ {
+		x.forget(i)
+	}
+	// the changed lines become those of the new text
+	n := 1 + strings.Count(string(c.Inserted), "\n")
+	fresh := make([]*[]string, n)
+	x.stale += n
+	x.lines = append(x.lines[:first], append(fresh, x.lines[last+1:]...)...)
+}
+
+// forget removes the words of line i from the counts and marks the line
+// to be indexed again.
+func (x *Index) forget(i int) {
+	words := x.lines[i]
+	if words == nil {
+		x.stale--
+		return
+	}
+	for _, w := range *words {
+		if x.counts[w]--; x.counts[w] == 0 {
+			delete(x.counts, w)
+		}
+	}
+	x.lines[i] = nil
+}
+
+// update indexes the lines that changed.
+func (x *Index) update() {
+	if x.stale == 0 {
+		return
+	}
+	for i, words := range x.lines {
+		if words != nil {
+			continue
+		}
+		ws := Split(x.b.Bytes(x.b.Line(i+1), x.lineEnd(i+1)))
+		for _, w := range ws {
+			x.counts[w]++
+		}
+		x.lines[i] = &ws
+	}
+	x.stale = 0
+}
+
+// lineEnd returns the offset of the end of line n, excluding its
+// newline.
+func (x *Index) lineEnd(n int) int {
+	_, end := x.b.LineRange(n)
+	return end
+}
+
+// Complete returns the words starting with prefix other than prefix
+// itself, sorted.
+func (x *Index) Complete(prefix string) []string {
+	x.update()
+	var words []string
+	for w := range x.counts {
+		if len(w) > len(prefix) && strings.HasPrefix(w, prefix) {
+			words = append(words, w)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// IsWordRune reports whether r is part of words.
+func IsWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Split returns the words of text.
+func Split(text []byte) []string {
+	var words []string
+	start := -1
+	for i := 0; i <= len(text); {
+		r, size := utf8.RuneError, 1
+		if i < len(text) {
+			r, size = utf8.DecodeRune(text[i:])
+		}
+		if i < len(text) && IsWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			words = append(words, string(text[start:i]))
+			start = -1
+		}
+		i += size
+	}
+	return words
+}
@@ -0,0 +1,45 @@
+package wordindex
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bgrundmann/e/buf"
+)
+
+func TestSplit(t *testing.T) {
+	got := Split([]byte("foo(bar_1, \"Äpfel\") + x"))
+	want := []string{"foo", "bar_1", "Äpfel", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split = %q expected %q", got, want)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var b buf.Buf
+	b.Init()
+	b.Insert(0, []byte("foo foobar\nfork\n"))
+	x := New(&b)
+	defer x.Close()
+	check := func(prefix string, want ...string) {
+		t.Helper()
+		if got := x.Complete(prefix); !reflect.DeepEqual(got, want) {
+			t.Errorf("Complete(%q) = %q expected %q", prefix, got, want)
+		}
+	}
+	check("fo", "foo", "foobar", "fork")
+	check("foo", "foobar")
+	// replacing a line
+	b.Delete(11, 15)
+	b.Insert(11, []byte("format\nfox"))
+	check("fo", "foo", "foobar", "format", "fox")
+	// joining lines
+	b.Delete(3, 4)
+	b.Delete(9, 10)
+	check("fo", "foofoobarformat", "fox")
+	// the last occurrence of a word going away
+	b.Delete(0, b.Len())
+	check("f")
+	b.Insert(0, []byte("fine\nfun\n"))
+	check("f", "fine", "fun")
+}
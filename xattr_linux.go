@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// hasXattrs reports whether the file filename has extended attributes.
+func hasXattrs(filename string) bool {
+	n, err := syscall.Listxattr(filename, nil)
+	return err == nil && n > 0
+}
@@ -0,0 +1,9 @@
+//go:build unix && !linux
+
+package main
+
+// hasXattrs reports whether the file filename has extended attributes.
+// They aren't looked for on systems other than linux.
+func hasXattrs(filename string) bool {
+	return false
+}